@@ -31,6 +31,30 @@ apiClient.interceptors.request.use(
   },
 )
 
+// Refresh tokens are single-use: the backend rotates them on every refresh and
+// treats a replayed token as theft. Concurrent 401s must share one refresh call.
+let refreshPromise: Promise<string> | null = null
+
+function refreshAccessToken(refreshToken: string): Promise<string> {
+  if (!refreshPromise) {
+    refreshPromise = axios
+      .post(`${API_BASE_URL}/auth/refresh`, {
+        // Backend expects snake_case: refresh_token
+        refresh_token: refreshToken,
+      })
+      .then((response) => {
+        const { access_token, refresh_token } = response.data.data
+        setToken(access_token)
+        setRefreshToken(refresh_token)
+        return access_token as string
+      })
+      .finally(() => {
+        refreshPromise = null
+      })
+  }
+  return refreshPromise
+}
+
 // Response interceptor - Handle errors and token refresh
 apiClient.interceptors.response.use(
   (response) => {
@@ -51,13 +75,7 @@ apiClient.interceptors.response.use(
         // Try to refresh token
         const refreshToken = getRefreshToken()
         if (refreshToken) {
-          const response = await axios.post(`${API_BASE_URL}/auth/refresh`, {
-            // Backend expects snake_case: refresh_token
-            refresh_token: refreshToken,
-          })
-
-          const { token } = response.data
-          setToken(token)
+          const token = await refreshAccessToken(refreshToken)
 
           // Retry original request with new token
           if (originalRequest.headers) {
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
+	"github.com/bisosad1501/DATN/shared/pkg/metrics"
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/bff"
 	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
 	"github.com/bisosad1501/ielts-platform/api-gateway/internal/middleware"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/proxy"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/ratelimit"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/revocation"
 	"github.com/bisosad1501/ielts-platform/api-gateway/internal/routes"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 func main() {
@@ -21,33 +34,79 @@ func main() {
 
 	log.Println("🚀 Starting API Gateway...")
 	log.Printf("📝 Gateway Port: %s", cfg.ServerPort)
-	log.Printf("📝 Auth Service: %s", cfg.Services.AuthService)
-	log.Printf("📝 User Service: %s", cfg.Services.UserService)
-	log.Printf("📝 Course Service: %s", cfg.Services.CourseService)
-	log.Printf("📝 Exercise Service: %s", cfg.Services.ExerciseService)
-	log.Printf("📝 Notification Service: %s", cfg.Services.NotificationService)
+	log.Printf("📝 Auth Service: %s", strings.Join(cfg.Services.AuthService.URLs, ", "))
+	log.Printf("📝 User Service: %s", strings.Join(cfg.Services.UserService.URLs, ", "))
+	log.Printf("📝 Course Service: %s", strings.Join(cfg.Services.CourseService.URLs, ", "))
+	log.Printf("📝 Exercise Service: %s", strings.Join(cfg.Services.ExerciseService.URLs, ", "))
+	log.Printf("📝 Notification Service: %s", strings.Join(cfg.Services.NotificationService.URLs, ", "))
+
+	// Initialize tracing (exporter selected with OTEL_TRACES_EXPORTER)
+	shutdownTracing, err := tracing.Init("api-gateway")
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Setup Gin
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.New()
 
-	// Global middleware
-	r.Use(gin.Recovery()) // Panic recovery
-	r.Use(middleware.CORS())
-	r.Use(middleware.RequestLogger())
+	// Initialize auth middleware (keys are fetched from auth-service on first use)
+	authMiddleware := middleware.NewAuthMiddleware(jwks.NewCache(cfg.JWKSURL), newRevocationChecker(cfg.Revocation))
 
-	// Initialize auth middleware
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWTSecret)
+	if len(cfg.InternalAPIKeys) == 0 {
+		log.Println("⚠️  No INTERNAL_API_KEY configured, internal routes will be refused")
+	}
+
+	// Initialize rate limiter
+	rateLimiter := middleware.NewRateLimiter(newLimiterBackend(cfg.RateLimit), cfg.RateLimit)
+	log.Printf("📝 Rate Limit: enabled=%v backend=%s default=%d rpm strict=%d rpm",
+		cfg.RateLimit.Enabled, cfg.RateLimit.Backend,
+		cfg.RateLimit.Rule(config.RateLimitClassDefault).RequestsPerMinute,
+		cfg.RateLimit.Rule(config.RateLimitClassStrict).RequestsPerMinute)
+
+	// Initialize backend proxies (load balancing, timeouts, retries, circuit breakers)
+	backends := proxy.NewRegistry(cfg.Services)
+	healthCtx, stopHealthChecks := context.WithCancel(context.Background())
+	defer stopHealthChecks()
+	backends.StartHealthChecks(healthCtx)
 
-	// Setup all routes
-	routes.SetupRoutes(r, cfg, authMiddleware)
+	// Build routes from the route table; the engine is rebuilt on every reload
+	var ready atomic.Bool
+	deps := routes.Dependencies{
+		Backends:       backends,
+		AuthMiddleware: authMiddleware,
+		ServiceAuth:    middleware.NewServiceAuth(cfg.InternalAPIKeys),
+		RateLimiter:    rateLimiter,
+		BFF:            bff.NewHandler(backends, cfg.BFF),
+		Ready:          &ready,
+	}
+	router, err := routes.NewRouter(cfg.RoutesFile, deps, func() *gin.Engine { return newEngine(cfg) }, cfg.RateLimit.ClassNames())
+	if err != nil {
+		log.Fatalf("❌ Failed to load routes: %v", err)
+	}
 
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	// Reload the route table on SIGHUP
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 	go func() {
-		if err := r.Run(":" + cfg.ServerPort); err != nil {
+		for range reload {
+			log.Println("🔄 Reloading routes...")
+			if err := router.Reload(); err != nil {
+				log.Printf("⚠️  Route reload failed, keeping current routes: %v", err)
+			}
+		}
+	}()
+
+	srv := &http.Server{Addr: ":" + cfg.ServerPort, Handler: router}
+	srv.RegisterOnShutdown(backends.CloseStreams)
+
+	ready.Store(true)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("❌ Failed to start gateway: %v", err)
 		}
 	}()
@@ -57,4 +116,91 @@ func main() {
 
 	<-quit
 	log.Println("🛑 Shutting down API Gateway...")
+	shutdown(srv, &ready, cfg.Shutdown)
+}
+
+// shutdown flips readiness, waits for load balancers to notice, then drains in-flight requests
+func shutdown(srv *http.Server, ready *atomic.Bool, cfg config.ShutdownConfig) {
+	ready.Store(false)
+	if cfg.ReadinessDelay > 0 {
+		log.Printf("Not ready, waiting %s for load balancers to notice", cfg.ReadinessDelay)
+		time.Sleep(cfg.ReadinessDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	log.Printf("Draining in-flight requests (up to %s)", cfg.Timeout)
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("⚠️  Drain window expired, closing remaining connections: %v", err)
+		srv.Close()
+	}
+	log.Println("✅ API Gateway stopped")
+}
+
+// newEngine creates a gin engine with the global middleware installed
+func newEngine(cfg *config.Config) *gin.Engine {
+	r := gin.New()
+	// Validated by LoadConfig; nil trusts no proxy
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("❌ Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.Use(tracing.Middleware("api-gateway")) // Trace context and request ID, first so every log line has them
+	r.Use(metrics.Middleware("api-gateway"))
+	r.Use(gin.Recovery()) // Panic recovery
+	r.Use(middleware.CORS(cfg.CORS))
+	r.Use(middleware.RequestLogger())
+	return r
+}
+
+// newRevocationChecker connects to the auth Redis that holds revoked tokens
+// and sessions. Returns nil when the check is disabled or misconfigured.
+func newRevocationChecker(cfg config.RevocationConfig) *revocation.Checker {
+	if !cfg.Enabled {
+		log.Println("⚠️  Token revocation check disabled, revoked tokens stay valid until they expire")
+		return nil
+	}
+
+	opt, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		log.Printf("⚠️  Invalid TOKEN_REVOCATION_REDIS_URL (%v), token revocation check disabled", err)
+		return nil
+	}
+
+	// Unlike the rate limiter there is no local fallback: keep the client so
+	// checks resume when Redis comes back (they fail open meanwhile)
+	client := redis.NewClient(opt)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Printf("⚠️  Redis unreachable for token revocation (%v), checks fail open until it is", err)
+	}
+
+	log.Printf("📝 Token revocation: cache=%s", cfg.CacheTTL)
+	return revocation.NewChecker(client, cfg.CacheTTL)
+}
+
+// newLimiterBackend builds the configured rate limit backend.
+// Falls back to in-memory buckets if Redis is unreachable at startup.
+func newLimiterBackend(cfg config.RateLimitConfig) ratelimit.Limiter {
+	if cfg.Backend != "redis" {
+		return ratelimit.NewMemoryLimiter(10 * time.Minute)
+	}
+
+	opt, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		log.Printf("⚠️  Invalid RATE_LIMIT_REDIS_URL (%v), using in-memory rate limiter", err)
+		return ratelimit.NewMemoryLimiter(10 * time.Minute)
+	}
+
+	client := redis.NewClient(opt)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Printf("⚠️  Redis unreachable (%v), using in-memory rate limiter", err)
+		client.Close()
+		return ratelimit.NewMemoryLimiter(10 * time.Minute)
+	}
+
+	return ratelimit.NewRedisLimiter(client, "gateway:ratelimit:")
 }
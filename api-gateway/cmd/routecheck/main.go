@@ -0,0 +1,90 @@
+// Command routecheck validates the gateway route table and compares it with the
+// gin routes registered by each service.
+//
+//	go run ./cmd/routecheck -routes routes.yaml -services ../services
+//
+// Exits non-zero when the table is invalid or exposes a route no service handles.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/routetable"
+)
+
+func main() {
+	routesFile := flag.String("routes", "routes.yaml", "route table to check")
+	servicesDir := flag.String("services", "../services", "directory containing the <name>/internal/routes packages")
+	strict := flag.Bool("strict", false, "also fail when a service route is not exposed by the gateway")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fail("failed to load config: %v", err)
+	}
+
+	table, err := routetable.Load(*routesFile)
+	if err != nil {
+		fail("%v", err)
+	}
+
+	var services []string
+	for _, svc := range cfg.Services.All() {
+		services = append(services, svc.Name)
+	}
+	if err := table.Validate(services, cfg.RateLimit.ClassNames()); err != nil {
+		fail("%v", err)
+	}
+
+	registered := make(map[string][]routetable.ServiceRoute)
+	for _, name := range services {
+		routes, err := routetable.ExtractServiceRoutes(filepath.Join(*servicesDir, name, "internal", "routes"))
+		if err != nil {
+			fail("%s: %v", name, err)
+		}
+		registered[name] = routes
+	}
+
+	endpoints := table.Endpoints()
+	report := routetable.Compare(endpoints, registered)
+
+	fmt.Printf("Checked %d gateway endpoints against %d services\n", len(endpoints), len(services))
+
+	if len(report.Missing) > 0 {
+		fmt.Printf("\n❌ Exposed by the gateway but not registered by the service (%d):\n", len(report.Missing))
+		for _, ep := range report.Missing {
+			fmt.Printf("  %-7s %-50s → %s\n", ep.Method, ep.Path, ep.Service)
+		}
+	}
+
+	unexposed := 0
+	names := make([]string, 0, len(report.Unexposed))
+	for name, routes := range report.Unexposed {
+		names = append(names, name)
+		unexposed += len(routes)
+	}
+	sort.Strings(names)
+	if unexposed > 0 {
+		fmt.Printf("\n⚠️  Registered by the service but not exposed by the gateway (%d):\n", unexposed)
+		for _, name := range names {
+			for _, r := range report.Unexposed[name] {
+				fmt.Printf("  %-7s %-50s (%s)\n", r.Method, r.Path, name)
+			}
+		}
+	}
+
+	if len(report.Missing) > 0 || (*strict && unexposed > 0) {
+		os.Exit(1)
+	}
+	fmt.Println("\n✅ Route table is consistent with the services")
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "❌ "+format+"\n", args...)
+	os.Exit(1)
+}
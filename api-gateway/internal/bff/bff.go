@@ -0,0 +1,228 @@
+package bff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/middleware"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/proxy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Section status markers in aggregated responses
+const (
+	SectionOK    = "ok"
+	SectionError = "error"
+)
+
+// forwardedHeaders are copied from the client request to every upstream call
+var forwardedHeaders = []string{"Authorization", "X-User-ID", "X-User-Email", "X-User-Role", "X-User-Permissions", "Accept-Language"}
+
+// Handler serves backend-for-frontend endpoints that compose one screen
+// from several services, fetched concurrently through the proxy backends.
+type Handler struct {
+	backends *proxy.Registry
+	cfg      config.BFFConfig
+	cache    *cache
+}
+
+func NewHandler(backends *proxy.Registry, cfg config.BFFConfig) *Handler {
+	return &Handler{
+		backends: backends,
+		cfg:      cfg,
+		cache:    newCache(cfg.CacheTTL),
+	}
+}
+
+// section is one upstream call of an aggregated response
+type section struct {
+	name    string
+	service string
+	path    string
+}
+
+// Section is the outcome of one upstream call. A failed section does not fail
+// the whole response, the client renders what is available.
+type Section struct {
+	Status     string          `json:"status"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	HTTPStatus int             `json:"http_status,omitempty"`
+}
+
+// Response is the body of every BFF endpoint
+type Response struct {
+	Sections    map[string]Section `json:"sections"`
+	Partial     bool               `json:"partial"` // at least one section failed
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// Dashboard aggregates the home screen of the authenticated user
+func (h *Handler) Dashboard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.serve(c, []section{
+			{name: "progress", service: "user-service", path: "/api/v1/user/progress"},
+			{name: "statistics", service: "user-service", path: "/api/v1/user/statistics"},
+			{name: "goals", service: "user-service", path: "/api/v1/user/goals"},
+			{name: "enrollments", service: "course-service", path: "/api/v1/enrollments/my"},
+			{name: "submissions", service: "exercise-service", path: "/api/v1/submissions/my?limit=5"},
+			{name: "unread_notifications", service: "notification-service", path: "/api/v1/notifications/unread-count"},
+		})
+	}
+}
+
+// CourseFull aggregates a course page; the progress section is only fetched for signed-in users
+func (h *Handler) CourseFull() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The id is spliced into upstream paths, so only a UUID gets through,
+		// in its canonical form
+		courseID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid_course_id",
+				"message": "Course id must be a UUID",
+			})
+			return
+		}
+		id := courseID.String()
+		sections := []section{
+			{name: "course", service: "course-service", path: "/api/v1/courses/" + id},
+			{name: "categories", service: "course-service", path: "/api/v1/courses/" + id + "/categories"},
+			{name: "reviews", service: "course-service", path: "/api/v1/courses/" + id + "/reviews"},
+		}
+		if c.GetString(middleware.ContextUserID) != "" {
+			sections = append(sections, section{name: "progress", service: "course-service", path: "/api/v1/courses/" + id + "/progress"})
+		}
+		h.serve(c, sections)
+	}
+}
+
+func (h *Handler) serve(c *gin.Context, sections []section) {
+	// Anonymous responses are shared, everything else is cached per user
+	key := c.GetString(middleware.ContextUserID) + " " + c.Request.URL.RequestURI()
+	cacheControl := "private, max-age=" + strconv.Itoa(int(h.cfg.CacheTTL.Seconds()))
+
+	if body, ok := h.cache.get(key); ok {
+		c.Header("Cache-Control", cacheControl)
+		c.Header("X-Cache", "HIT")
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+		return
+	}
+
+	header := make(http.Header)
+	for _, name := range forwardedHeaders {
+		if value := c.Request.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+
+	resp := Response{Sections: make(map[string]Section, len(sections))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, s := range sections {
+		wg.Add(1)
+		go func(s section) {
+			defer wg.Done()
+			result := h.fetch(c.Request.Context(), s, header)
+			mu.Lock()
+			resp.Sections[s.name] = result
+			mu.Unlock()
+		}(s)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, s := range resp.Sections {
+		if s.Status != SectionOK {
+			failed++
+		}
+	}
+	resp.Partial = failed > 0
+	resp.GeneratedAt = time.Now().UTC()
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "aggregation_failed",
+			"message": "Failed to build response",
+		})
+		return
+	}
+
+	// Degraded responses are not cached so the next request retries the failed sections
+	if resp.Partial {
+		c.Header("Cache-Control", "no-store")
+	} else {
+		c.Header("Cache-Control", cacheControl)
+		h.cache.set(key, body)
+	}
+
+	// Nothing to show at all: let the client fall back to its error screen
+	if failed == len(sections) {
+		c.Data(http.StatusBadGateway, "application/json; charset=utf-8", body)
+		return
+	}
+
+	c.Header("X-Cache", "MISS")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+func (h *Handler) fetch(ctx context.Context, s section, header http.Header) Section {
+	if h.cfg.SectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.cfg.SectionTimeout)
+		defer cancel()
+	}
+
+	status, body, err := h.backends.Get(s.service).Fetch(ctx, http.MethodGet, s.path, header)
+	if err != nil {
+		var unavailable *proxy.UnavailableError
+		switch {
+		case errors.As(err, &unavailable):
+			return Section{Status: SectionError, Error: unavailable.Reason}
+		case errors.Is(err, context.DeadlineExceeded):
+			return Section{Status: SectionError, Error: "timeout"}
+		default:
+			log.Printf("[BFF] %s (%s %s) failed: %v trace_id=%s", s.name, s.service, s.path, err, tracing.TraceID(ctx))
+			return Section{Status: SectionError, Error: "service_unavailable"}
+		}
+	}
+
+	if status >= http.StatusBadRequest {
+		return Section{Status: SectionError, Error: "upstream_error", HTTPStatus: status, Data: errorBody(body)}
+	}
+	return Section{Status: SectionOK, Data: unwrap(body)}
+}
+
+// unwrap returns the "data" field of the services' {"success":..,"data":..} envelope,
+// or the body itself when it has none
+func unwrap(body []byte) json.RawMessage {
+	if !json.Valid(body) {
+		return nil
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil && len(envelope.Data) > 0 {
+		return envelope.Data
+	}
+	return bytes.TrimSpace(body)
+}
+
+// errorBody keeps the upstream error payload when it is JSON
+func errorBody(body []byte) json.RawMessage {
+	if len(body) == 0 || !json.Valid(body) {
+		return nil
+	}
+	return bytes.TrimSpace(body)
+}
@@ -2,53 +2,285 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	ServerPort string
-	JWTSecret  string
+	JWKSURL    string // auth-service key set that verifies access tokens
+	RoutesFile string // declarative route table (YAML or JSON)
 	Services   ServiceURLs
 	RateLimit  RateLimitConfig
+	Revocation RevocationConfig
+	CORS       CORSConfig
+	BFF        BFFConfig
+	Shutdown   ShutdownConfig
+
+	// Service credentials accepted on auth: internal routes
+	InternalAPIKeys []string
+
+	// Load balancers whose X-Forwarded-For is believed (IPs or CIDRs).
+	// Empty means the client IP is the TCP peer, so clients cannot pick
+	// their own rate limit bucket with a forged header.
+	TrustedProxies []string
 }
 
 type ServiceURLs struct {
-	AuthService         string
-	UserService         string
-	CourseService       string
-	ExerciseService     string
-	NotificationService string
+	AuthService         BackendConfig
+	UserService         BackendConfig
+	CourseService       BackendConfig
+	ExerciseService     BackendConfig
+	NotificationService BackendConfig
+}
+
+// All returns every backend in a stable order
+func (s ServiceURLs) All() []BackendConfig {
+	return []BackendConfig{s.AuthService, s.UserService, s.CourseService, s.ExerciseService, s.NotificationService}
+}
+
+// CORSConfig is the cross-origin policy applied to every response.
+// Origins may be exact ("https://app.example.com"), wildcard subdomains
+// ("https://*.example.com") or "*" (not allowed together with credentials).
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // how long browsers may cache a preflight response
+}
+
+// RevocationConfig controls the check of access tokens revoked by auth-service
+type RevocationConfig struct {
+	Enabled  bool
+	RedisURL string        // the Redis auth-service writes revocations to
+	CacheTTL time.Duration // local cache; a revocation applies within this delay
+}
+
+// BFFConfig controls the aggregation endpoints under /api/v1/bff
+type BFFConfig struct {
+	CacheTTL       time.Duration // per-user response cache, 0 disables it
+	SectionTimeout time.Duration // deadline of each upstream call
+}
+
+// ShutdownConfig controls connection draining on SIGTERM
+type ShutdownConfig struct {
+	ReadinessDelay time.Duration // /ready reports 503 this long before the listener closes
+	Timeout        time.Duration // drain window for in-flight requests
+}
+
+// Load balancing strategies for services with several upstream instances
+const (
+	LoadBalanceRoundRobin = "round_robin"
+	LoadBalanceLeastConn  = "least_conn"
+)
+
+// BackendConfig holds the upstream instances and resilience settings of one downstream service
+type BackendConfig struct {
+	Name          string
+	URLs          []string // one entry per instance
+	LoadBalancing string   // round_robin or least_conn
+	HealthCheck   HealthCheckConfig
+
+	DialTimeout   time.Duration // TCP connect timeout
+	HeaderTimeout time.Duration // time to wait for response headers
+	Timeout       time.Duration // overall request deadline (not applied to SSE streams)
+	MaxRetries    int           // retries for GET/HEAD on connection errors
+
+	BreakerFailureThreshold int           // consecutive failures before the circuit opens
+	BreakerOpenTimeout      time.Duration // how long the circuit stays open before probing
 }
 
+// HealthCheckConfig controls the active probing of upstream instances
+type HealthCheckConfig struct {
+	Path               string
+	Interval           time.Duration // 0 disables probing
+	Timeout            time.Duration
+	UnhealthyThreshold int // consecutive failed probes before an instance leaves rotation
+	HealthyThreshold   int // consecutive successful probes before it comes back
+}
+
+// Rate limit classes that routes can opt into
+const (
+	RateLimitClassDefault = "default"
+	RateLimitClassStrict  = "strict" // login, password reset and 6-digit code endpoints
+)
+
 type RateLimitConfig struct {
 	RequestsPerMinute int
+	Burst             int
 	Enabled           bool
+	Backend           string // memory or redis
+	RedisURL          string
+	Classes           map[string]RateLimitRule
+}
+
+type RateLimitRule struct {
+	RequestsPerMinute int
+	Burst             int
+}
+
+// Rule returns the limits for a class, falling back to the global default
+func (c RateLimitConfig) Rule(class string) RateLimitRule {
+	if rule, ok := c.Classes[class]; ok {
+		return rule
+	}
+	return RateLimitRule{RequestsPerMinute: c.RequestsPerMinute, Burst: c.Burst}
+}
+
+// ClassNames lists the configured rate limit classes
+func (c RateLimitConfig) ClassNames() []string {
+	names := make([]string, 0, len(c.Classes))
+	for name := range c.Classes {
+		names = append(names, name)
+	}
+	return names
 }
 
 func LoadConfig() (*Config, error) {
 	config := &Config{
 		ServerPort: getEnv("SERVER_PORT", "8080"),
-		JWTSecret:  getEnv("JWT_SECRET", "your-secret-key"),
+		JWKSURL:    getEnv("JWKS_URL", "http://auth-service:8081/.well-known/jwks.json"),
+		RoutesFile: getEnv("ROUTES_FILE", "routes.yaml"),
 		Services: ServiceURLs{
-			AuthService:         getEnv("AUTH_SERVICE_URL", "http://auth-service:8081"),
-			UserService:         getEnv("USER_SERVICE_URL", "http://user-service:8082"),
-			CourseService:       getEnv("COURSE_SERVICE_URL", "http://course-service:8083"),
-			ExerciseService:     getEnv("EXERCISE_SERVICE_URL", "http://exercise-service:8084"),
-			NotificationService: getEnv("NOTIFICATION_SERVICE_URL", "http://notification-service:8085"),
+			AuthService:         loadBackend("auth-service", "AUTH_SERVICE", "http://auth-service:8081"),
+			UserService:         loadBackend("user-service", "USER_SERVICE", "http://user-service:8082"),
+			CourseService:       loadBackend("course-service", "COURSE_SERVICE", "http://course-service:8083"),
+			ExerciseService:     loadBackend("exercise-service", "EXERCISE_SERVICE", "http://exercise-service:8084"),
+			NotificationService: loadBackend("notification-service", "NOTIFICATION_SERVICE", "http://notification-service:8085"),
 		},
 		RateLimit: RateLimitConfig{
 			RequestsPerMinute: getEnvAsInt("RATE_LIMIT_RPM", 100),
+			Burst:             getEnvAsInt("RATE_LIMIT_BURST", 0),
 			Enabled:           getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			Backend:           getEnv("RATE_LIMIT_BACKEND", "memory"),
+			RedisURL:          getEnv("RATE_LIMIT_REDIS_URL", "redis://:ielts_redis_password@redis:6379/1"),
+		},
+		Revocation: RevocationConfig{
+			Enabled:  getEnvAsBool("TOKEN_REVOCATION_ENABLED", true),
+			RedisURL: getEnv("TOKEN_REVOCATION_REDIS_URL", "redis://:ielts_redis_password@redis:6379/0"),
+			CacheTTL: getEnvAsDuration("TOKEN_REVOCATION_CACHE_TTL", 5*time.Second),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   splitList(getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:3000")),
+			AllowedMethods:   splitList(getEnv("CORS_ALLOWED_METHODS", "GET, POST, PUT, PATCH, DELETE, OPTIONS")),
+			AllowedHeaders:   splitList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Accept, Origin, Cache-Control, X-Requested-With, X-Request-ID, traceparent, tracestate")),
+			ExposedHeaders:   splitList(getEnv("CORS_EXPOSED_HEADERS", "X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After")),
+			AllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", true),
+			MaxAge:           getEnvAsDuration("CORS_MAX_AGE", 24*time.Hour),
+		},
+		BFF: BFFConfig{
+			CacheTTL:       getEnvAsDuration("BFF_CACHE_TTL", 10*time.Second),
+			SectionTimeout: getEnvAsDuration("BFF_SECTION_TIMEOUT", 3*time.Second),
+		},
+		Shutdown: ShutdownConfig{
+			ReadinessDelay: getEnvAsDuration("SHUTDOWN_READINESS_DELAY", 2*time.Second),
+			Timeout:        getEnvAsDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
 		},
+		// INTERNAL_API_KEYS (comma-separated) allows key rotation, INTERNAL_API_KEY matches the services
+		InternalAPIKeys: splitList(getEnv("INTERNAL_API_KEYS", os.Getenv("INTERNAL_API_KEY"))),
+		TrustedProxies:  splitList(getEnv("TRUSTED_PROXIES", "")),
 	}
 
-	if config.JWTSecret == "" {
-		return nil, fmt.Errorf("JWT_SECRET is required")
+	config.RateLimit.Classes = map[string]RateLimitRule{
+		RateLimitClassDefault: {
+			RequestsPerMinute: config.RateLimit.RequestsPerMinute,
+			Burst:             config.RateLimit.Burst,
+		},
+		RateLimitClassStrict: {
+			RequestsPerMinute: getEnvAsInt("RATE_LIMIT_STRICT_RPM", 5),
+			Burst:             getEnvAsInt("RATE_LIMIT_STRICT_BURST", 5),
+		},
+	}
+
+	if config.JWKSURL == "" {
+		return nil, fmt.Errorf("JWKS_URL is required")
+	}
+
+	if config.RateLimit.Backend != "memory" && config.RateLimit.Backend != "redis" {
+		return nil, fmt.Errorf("RATE_LIMIT_BACKEND must be 'memory' or 'redis', got %q", config.RateLimit.Backend)
+	}
+
+	if err := config.CORS.validate(); err != nil {
+		return nil, err
+	}
+
+	for _, proxy := range config.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return nil, fmt.Errorf("TRUSTED_PROXIES: %q is not an IP address or CIDR", proxy)
+			}
+		}
+	}
+
+	for _, svc := range config.Services.All() {
+		if len(svc.URLs) == 0 {
+			return nil, fmt.Errorf("%s: at least one upstream URL is required", svc.Name)
+		}
+		if svc.LoadBalancing != LoadBalanceRoundRobin && svc.LoadBalancing != LoadBalanceLeastConn {
+			return nil, fmt.Errorf("%s: load balancing must be '%s' or '%s', got %q",
+				svc.Name, LoadBalanceRoundRobin, LoadBalanceLeastConn, svc.LoadBalancing)
+		}
 	}
 
 	return config, nil
 }
 
+func (c CORSConfig) validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS=* cannot be combined with CORS_ALLOW_CREDENTIALS=true")
+			}
+			continue
+		}
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("CORS origin %q must start with http:// or https://", origin)
+		}
+		if strings.Contains(origin, "*") && !strings.Contains(origin, "://*.") {
+			return fmt.Errorf("CORS origin %q: wildcards are only supported as a subdomain prefix (https://*.example.com)", origin)
+		}
+	}
+	return nil
+}
+
+// loadBackend reads <PREFIX>_URL (comma-separated for several instances) plus optional
+// per-service overrides (<PREFIX>_DIAL_TIMEOUT, <PREFIX>_HEADER_TIMEOUT, <PREFIX>_TIMEOUT,
+// <PREFIX>_MAX_RETRIES, <PREFIX>_LB_STRATEGY), falling back to the global
+// PROXY_* / BREAKER_* / HEALTH_CHECK_* defaults.
+func loadBackend(name, prefix, defaultURL string) BackendConfig {
+	dialTimeout := getEnvAsDuration("PROXY_DIAL_TIMEOUT", 5*time.Second)
+	headerTimeout := getEnvAsDuration("PROXY_HEADER_TIMEOUT", 30*time.Second)
+	timeout := getEnvAsDuration("PROXY_TIMEOUT", 60*time.Second)
+	maxRetries := getEnvAsInt("PROXY_MAX_RETRIES", 2)
+
+	lbStrategy := getEnv("PROXY_LB_STRATEGY", LoadBalanceRoundRobin)
+
+	return BackendConfig{
+		Name:          name,
+		URLs:          splitList(getEnv(prefix+"_URL", defaultURL)),
+		LoadBalancing: getEnv(prefix+"_LB_STRATEGY", lbStrategy),
+		HealthCheck: HealthCheckConfig{
+			Path:               getEnv("HEALTH_CHECK_PATH", "/ready"),
+			Interval:           getEnvAsDuration("HEALTH_CHECK_INTERVAL", 10*time.Second),
+			Timeout:            getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			UnhealthyThreshold: getEnvAsInt("HEALTH_CHECK_UNHEALTHY_THRESHOLD", 2),
+			HealthyThreshold:   getEnvAsInt("HEALTH_CHECK_HEALTHY_THRESHOLD", 1),
+		},
+
+		DialTimeout:   getEnvAsDuration(prefix+"_DIAL_TIMEOUT", dialTimeout),
+		HeaderTimeout: getEnvAsDuration(prefix+"_HEADER_TIMEOUT", headerTimeout),
+		Timeout:       getEnvAsDuration(prefix+"_TIMEOUT", timeout),
+		MaxRetries:    getEnvAsInt(prefix+"_MAX_RETRIES", maxRetries),
+
+		BreakerFailureThreshold: getEnvAsInt("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerOpenTimeout:      getEnvAsDuration("BREAKER_OPEN_TIMEOUT", 30*time.Second),
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -73,3 +305,26 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return valueStr == "true" || valueStr == "1"
 }
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,22 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Request count and latency come from shared/pkg/metrics like every other
+// service; only the per-attempt backend latency is specific to the gateway
+var upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "gateway_upstream_request_duration_seconds",
+	Help:    "Time until response headers from a backend instance, per attempt.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"backend", "upstream", "status"})
+
+// ObserveUpstream records one attempt against a backend instance.
+// status is the HTTP status code, or "error" when no response was received.
+func ObserveUpstream(backend, upstream, status string, duration time.Duration) {
+	upstreamDuration.WithLabelValues(backend, upstream, status).Observe(duration.Seconds())
+}
@@ -1,27 +1,46 @@
 package middleware
 
 import (
-	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/revocation"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+// ContextUserID is the gin context key holding the authenticated user ID.
+// Unlike the X-User-ID header it cannot be supplied by the client.
+const ContextUserID = "gateway_user_id"
+
+// ContextPermissions holds the permissions of the validated token
+const ContextPermissions = "gateway_permissions"
+
+// HeaderUserPermissions forwards the token's permissions, comma-separated
+const HeaderUserPermissions = "X-User-Permissions"
+
 type AuthMiddleware struct {
-    jwtSecret string
+	keys       *jwks.Cache
+	revocation *revocation.Checker // nil disables the denylist check
 }
 
-func NewAuthMiddleware(jwtSecret string) *AuthMiddleware {
-	return &AuthMiddleware{jwtSecret: jwtSecret}
+// NewAuthMiddleware verifies tokens against auth-service's published keys
+// and rejects the ones revoked before their expiry
+func NewAuthMiddleware(keys *jwks.Cache, revoked *revocation.Checker) *AuthMiddleware {
+	return &AuthMiddleware{keys: keys, revocation: revoked}
 }
 
 type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
 	Role   string    `json:"role"`
+	// SessionID is the login session, revoked when the user signs it out
+	SessionID string `json:"sid,omitempty"`
+	// Permissions ("resource:action") granted through the user's roles
+	Permissions []string `json:"permissions"`
 	jwt.RegisteredClaims
 }
 
@@ -50,12 +69,7 @@ func (m *AuthMiddleware) ValidateToken() gin.HandlerFunc {
 
 		tokenString := parts[1]
 
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		token, err := m.parse(tokenString)
 
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -76,10 +90,22 @@ func (m *AuthMiddleware) ValidateToken() gin.HandlerFunc {
 			return
 		}
 
+		if m.isRevoked(c, claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "token_revoked",
+				"message": "Token has been revoked, please sign in again",
+			})
+			c.Abort()
+			return
+		}
+
 		// Add claims to request headers for downstream services
 		c.Request.Header.Set("X-User-ID", claims.UserID.String())
 		c.Request.Header.Set("X-User-Email", claims.Email)
 		c.Request.Header.Set("X-User-Role", claims.Role)
+		c.Request.Header.Set(HeaderUserPermissions, strings.Join(claims.Permissions, ","))
+		c.Set(ContextUserID, claims.UserID.String())
+		c.Set(ContextPermissions, claims.Permissions)
 
 		// Keep original Authorization header for services that need it
 		c.Next()
@@ -89,6 +115,9 @@ func (m *AuthMiddleware) ValidateToken() gin.HandlerFunc {
 // OptionalAuth validates token if present, but allows requests without token
 func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Permissions are only ever forwarded from a verified token
+		c.Request.Header.Del(HeaderUserPermissions)
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.Next()
@@ -102,18 +131,22 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		token, err := m.parse(tokenString)
 
 		if err == nil && token.Valid {
-			if claims, ok := token.Claims.(*Claims); ok {
+			claims, ok := token.Claims.(*Claims)
+			if ok && m.isRevoked(c, claims) {
+				// Continue anonymously; services must not see the revoked token either
+				c.Request.Header.Del("Authorization")
+				ok = false
+			}
+			if ok {
 				c.Request.Header.Set("X-User-ID", claims.UserID.String())
 				c.Request.Header.Set("X-User-Email", claims.Email)
 				c.Request.Header.Set("X-User-Role", claims.Role)
+				c.Request.Header.Set(HeaderUserPermissions, strings.Join(claims.Permissions, ","))
+				c.Set(ContextUserID, claims.UserID.String())
+				c.Set(ContextPermissions, claims.Permissions)
 			}
 		}
 
@@ -132,12 +165,7 @@ func (m *AuthMiddleware) RequireRole(allowedRoles ...string) gin.HandlerFunc {
             parts := strings.Split(authHeader, " ")
             if len(parts) == 2 && parts[0] == "Bearer" {
                 tokenString := parts[1]
-                if token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-                    if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-                        return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-                    }
-                    return []byte(m.jwtSecret), nil
-                }); err == nil && token.Valid {
+                if token, err := m.parse(tokenString); err == nil && token.Valid {
                     if claims, ok := token.Claims.(*Claims); ok {
                         role = claims.Role
                     }
@@ -160,3 +188,56 @@ func (m *AuthMiddleware) RequireRole(allowedRoles ...string) gin.HandlerFunc {
         c.Abort()
     }
 }
+
+// parse verifies the signature against the key named by the token's kid
+func (m *AuthMiddleware) parse(tokenString string) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return m.keys.KeyFromHeader(token.Header)
+	}, jwt.WithValidMethods(jwks.Algorithms))
+}
+
+// isRevoked checks the revocation denylist. Like the rate limiter it fails
+// open when Redis is unavailable; the token is still verified and expires.
+func (m *AuthMiddleware) isRevoked(c *gin.Context, claims *Claims) bool {
+	if m.revocation == nil {
+		return false
+	}
+
+	token := revocation.Token{
+		ID:        claims.ID,
+		SessionID: claims.SessionID,
+		UserID:    claims.UserID.String(),
+	}
+	if claims.IssuedAt != nil {
+		token.IssuedAt = claims.IssuedAt.Time
+	}
+
+	revoked, err := m.revocation.IsRevoked(c.Request.Context(), token)
+	if err != nil {
+		log.Printf("[Auth] revocation check failed: %v", err)
+		return false
+	}
+	return revoked
+}
+
+// RequirePermission ensures the validated token grants at least one of the
+// permissions. It must run after ValidateToken.
+func (m *AuthMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := c.GetStringSlice(ContextPermissions)
+		for _, required := range permissions {
+			for _, p := range granted {
+				if p == required {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "forbidden",
+			"message": "You do not have permission to access this resource",
+		})
+		c.Abort()
+	}
+}
@@ -1,29 +1,13 @@
 package middleware
 
 import (
+	"strconv"
 	"time"
 
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
 	"github.com/gin-gonic/gin"
 )
 
-// CORS middleware for handling cross-origin requests
-func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
-		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	}
-}
-
 // RequestLogger logs incoming requests with timing
 func RequestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -38,13 +22,15 @@ func RequestLogger() gin.HandlerFunc {
 		// Get status code
 		statusCode := c.Writer.Status()
 
-		// Log format: [Gateway] Method Path Status Latency
+		// Log format: [Gateway] Method Path Status Latency TraceID RequestID
 		gin.DefaultWriter.Write([]byte(
 			"[Gateway] " +
 				c.Request.Method + " " +
 				c.Request.URL.Path + " " +
-				"Status:" + string(rune(statusCode)) + " " +
-				"Latency:" + latency.String() + "\n",
+				"Status:" + strconv.Itoa(statusCode) + " " +
+				"Latency:" + latency.String() + " " +
+				"trace_id=" + tracing.TraceID(c.Request.Context()) + " " +
+				"request_id=" + tracing.RequestID(c.Request.Context()) + "\n",
 		))
 	}
 }
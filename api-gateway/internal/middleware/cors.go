@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS answers cross-origin requests from the configured origins.
+// The request origin is echoed back instead of "*" so credentials (cookies,
+// Authorization) are accepted by browsers; other origins get no CORS headers.
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	matcher := newOriginMatcher(cfg.AllowedOrigins)
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		// The response depends on the origin, shared caches must key on it
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !matcher.allowed(origin) {
+			if preflight {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error":   "origin_not_allowed",
+					"message": "Origin is not allowed by the CORS policy",
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if matcher.any && !cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", exposeHeaders)
+		}
+
+		// A plain OPTIONS request is not a preflight, the upstream answers it
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+			// Browsers cache the preflight per origin and URL for this long
+			header.Set("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// originMatcher matches exact origins and wildcard subdomain patterns
+// such as https://*.example.com (any depth, same scheme and port)
+type originMatcher struct {
+	any      bool
+	exact    map[string]bool
+	patterns []originPattern
+}
+
+type originPattern struct {
+	scheme string
+	suffix string // ".example.com"
+	port   string
+}
+
+func newOriginMatcher(origins []string) *originMatcher {
+	m := &originMatcher{exact: make(map[string]bool)}
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			m.any = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://*.")
+			host, port, _ := strings.Cut(host, ":")
+			m.patterns = append(m.patterns, originPattern{scheme: scheme, suffix: "." + host, port: port})
+		default:
+			m.exact[origin] = true
+		}
+	}
+	return m
+}
+
+func (m *originMatcher) allowed(origin string) bool {
+	if m.any {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if m.exact[origin] {
+		return true
+	}
+	if len(m.patterns) == 0 {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, p := range m.patterns {
+		if u.Scheme == p.scheme && u.Port() == p.port && strings.HasSuffix(u.Hostname(), p.suffix) {
+			return true
+		}
+	}
+	return false
+}
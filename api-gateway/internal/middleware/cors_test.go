@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	r := gin.New()
+	r.Use(CORS(config.CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization"},
+		MaxAge:         time.Hour,
+	}))
+	r.OPTIONS("/api/v1/courses", func(c *gin.Context) {
+		c.String(http.StatusOK, "upstream")
+	})
+
+	tests := []struct {
+		name          string
+		origin        string
+		requestMethod string
+		wantStatus    int
+		wantUpstream  bool
+	}{
+		{name: "preflight", origin: "https://app.example.com", requestMethod: "POST", wantStatus: http.StatusNoContent},
+		{name: "plain OPTIONS", origin: "https://app.example.com", wantStatus: http.StatusOK, wantUpstream: true},
+		{name: "preflight from another origin", origin: "https://evil.example.com", requestMethod: "POST", wantStatus: http.StatusForbidden},
+		{name: "plain OPTIONS from another origin", origin: "https://evil.example.com", wantStatus: http.StatusOK, wantUpstream: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/api/v1/courses", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if upstream := w.Body.String() == "upstream"; upstream != tt.wantUpstream {
+				t.Errorf("reached upstream = %v, want %v", upstream, tt.wantUpstream)
+			}
+			if preflight := w.Header().Get("Access-Control-Allow-Methods") != ""; preflight != (tt.wantStatus == http.StatusNoContent) {
+				t.Errorf("Access-Control-Allow-Methods = %q", w.Header().Get("Access-Control-Allow-Methods"))
+			}
+		})
+	}
+}
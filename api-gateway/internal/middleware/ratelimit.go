@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+type RateLimiter struct {
+	limiter ratelimit.Limiter
+	cfg     config.RateLimitConfig
+}
+
+func NewRateLimiter(limiter ratelimit.Limiter, cfg config.RateLimitConfig) *RateLimiter {
+	return &RateLimiter{limiter: limiter, cfg: cfg}
+}
+
+// Limit enforces the token bucket of the given class.
+// Requests are keyed by the user ID established by ValidateToken/OptionalAuth,
+// or by client IP for anonymous requests. Must run after the auth middleware.
+func (rl *RateLimiter) Limit(class string) gin.HandlerFunc {
+	rule := rl.cfg.Rule(class)
+	bucketRule := ratelimit.PerMinute(rule.RequestsPerMinute, rule.Burst)
+
+	return func(c *gin.Context) {
+		if !rl.cfg.Enabled || bucketRule.Rate <= 0 {
+			c.Next()
+			return
+		}
+
+		key := class + ":ip:" + c.ClientIP()
+		if userID := c.GetString(ContextUserID); userID != "" {
+			key = class + ":user:" + userID
+		}
+
+		res, err := rl.limiter.Allow(c.Request.Context(), key, bucketRule)
+		if err != nil {
+			// Fail open: a broken limiter backend must not take the gateway down
+			log.Printf("[RateLimit] backend error for %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter.Seconds())))
+
+		if !res.Allowed {
+			retryAfter := ceilSeconds(res.RetryAfter.Seconds())
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "rate_limit_exceeded",
+				"message":     "Too many requests. Please try again later.",
+				"retry_after": retryAfter,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(s float64) int {
+	n := int(math.Ceil(s))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
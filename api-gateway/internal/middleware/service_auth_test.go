@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// serveInternal sends one request through RequireServiceCredential to a
+// handler that answers 200
+func serveInternal(t *testing.T, keys []string, providedKey string) (int, string) {
+	t.Helper()
+
+	r := gin.New()
+	r.GET("/api/v1/user/internal/profile", NewServiceAuth(keys).RequireServiceCredential(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/user/internal/profile", nil)
+	if providedKey != "" {
+		req.Header.Set(HeaderInternalAPIKey, providedKey)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if w.Code != http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("response is not JSON: %v", err)
+		}
+	}
+	return w.Code, body.Error
+}
+
+func TestRequireServiceCredential(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		provided   string
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name:       "missing key",
+			keys:       []string{"secret"},
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "service_credential_required",
+		},
+		{
+			name:       "wrong key",
+			keys:       []string{"secret"},
+			provided:   "guess",
+			wantStatus: http.StatusForbidden,
+			wantError:  "invalid_service_credential",
+		},
+		{
+			name:       "prefix of the key",
+			keys:       []string{"secret"},
+			provided:   "secre",
+			wantStatus: http.StatusForbidden,
+			wantError:  "invalid_service_credential",
+		},
+		{
+			name:       "no key configured",
+			keys:       nil,
+			provided:   "secret",
+			wantStatus: http.StatusForbidden,
+			wantError:  "internal_routes_disabled",
+		},
+		{
+			name:       "only empty keys configured",
+			keys:       []string{""},
+			provided:   "anything",
+			wantStatus: http.StatusForbidden,
+			wantError:  "internal_routes_disabled",
+		},
+		{
+			name:       "valid key",
+			keys:       []string{"secret"},
+			provided:   "secret",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "rotated key",
+			keys:       []string{"old", "new"},
+			provided:   "old",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, errCode := serveInternal(t, tt.keys, tt.provided)
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if errCode != tt.wantError {
+				t.Errorf("error = %q, want %q", errCode, tt.wantError)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState string
+
+const (
+	StateClosed   BreakerState = "closed"
+	StateOpen     BreakerState = "open"
+	StateHalfOpen BreakerState = "half_open"
+)
+
+// CircuitBreaker fails fast once a backend has produced too many consecutive failures.
+// After openTimeout it lets a single probe request through (half-open);
+// success closes the circuit, failure opens it again.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	state            BreakerState
+	failures         int
+	failureThreshold int
+	openTimeout      time.Duration
+	openedAt         time.Time
+	probeInFlight    bool
+}
+
+// BreakerSnapshot is a point-in-time view of a breaker, used by /health
+type BreakerSnapshot struct {
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	RetryAfterSeconds   int          `json:"retry_after_seconds,omitempty"`
+}
+
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = 5
+	}
+	if openTimeout <= 0 {
+		openTimeout = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		state:            StateClosed,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+	}
+}
+
+// Allow reports whether a request may proceed. When it may not, it also returns
+// how long the caller should wait before trying again.
+func (b *CircuitBreaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		elapsed := time.Since(b.openedAt)
+		if elapsed < b.openTimeout {
+			return false, b.openTimeout - elapsed
+		}
+		// Cooldown over: let one probe through
+		b.state = StateHalfOpen
+		b.probeInFlight = true
+		return true, 0
+	case StateHalfOpen:
+		if b.probeInFlight {
+			return false, time.Second
+		}
+		b.probeInFlight = true
+		return true, 0
+	default:
+		return true, 0
+	}
+}
+
+// RecordSuccess closes the circuit and resets the failure count
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.state = StateClosed
+	b.probeInFlight = false
+}
+
+// RecordFailure counts a failure and opens the circuit when the threshold is reached
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probeInFlight = false
+	if b.state == StateHalfOpen || b.failures >= b.failureThreshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// ReleaseProbe frees the half-open probe slot without judging the backend,
+// e.g. when the client cancelled the request
+func (b *CircuitBreaker) ReleaseProbe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probeInFlight = false
+}
+
+func (b *CircuitBreaker) Snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snap := BreakerSnapshot{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state == StateOpen {
+		if remaining := b.openTimeout - time.Since(b.openedAt); remaining > 0 {
+			snap.RetryAfterSeconds = int(remaining.Seconds()) + 1
+		}
+	}
+	return snap
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxFetchBody caps the response body read by Fetch
+const maxFetchBody = 5 << 20
+
+// UnavailableError is returned by Fetch when no instance can take the request
+type UnavailableError struct {
+	Backend string
+	Reason  string // no_healthy_upstream or circuit_open
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("backend %s unavailable: %s", e.Backend, e.Reason)
+}
+
+// Fetch issues a request on behalf of the gateway itself (e.g. response aggregation).
+// It goes through the same instance selection, circuit breakers, retries, tracing and
+// metrics as proxied requests. path may contain a query string.
+func (b *Backend) Fetch(ctx context.Context, method, path string, header http.Header) (int, []byte, error) {
+	upstream, reason, _ := b.pick()
+	if upstream == nil {
+		return 0, nil, &UnavailableError{Backend: b.cfg.Name, Reason: reason}
+	}
+
+	upstream.active.Add(1)
+	defer upstream.active.Add(-1)
+
+	if b.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.Timeout)
+		defer cancel()
+	}
+
+	target := strings.TrimSuffix(upstream.target.String(), "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		upstream.breaker.ReleaseProbe()
+		return 0, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			upstream.breaker.ReleaseProbe()
+		} else {
+			upstream.breaker.RecordFailure()
+		}
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		upstream.breaker.RecordFailure()
+	default:
+		upstream.breaker.RecordSuccess()
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBody))
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
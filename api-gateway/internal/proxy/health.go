@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Aggregated health states reported by /health
+const (
+	HealthHealthy   = "healthy"
+	HealthDegraded  = "degraded"  // some instances are out of rotation
+	HealthUnhealthy = "unhealthy" // no instance is available
+)
+
+// BackendHealth is the aggregated state of a service and its instances
+type BackendHealth struct {
+	Status        string             `json:"status"`
+	LoadBalancing string             `json:"load_balancing"`
+	Upstreams     []UpstreamSnapshot `json:"upstreams"`
+}
+
+// Health aggregates the state of every upstream of the backend
+func (b *Backend) Health() BackendHealth {
+	health := BackendHealth{LoadBalancing: b.cfg.LoadBalancing}
+
+	available := 0
+	for _, u := range b.upstreams {
+		snap := u.Snapshot()
+		if snap.Healthy && snap.Breaker.State != StateOpen {
+			available++
+		}
+		health.Upstreams = append(health.Upstreams, snap)
+	}
+
+	switch {
+	case available == len(b.upstreams):
+		health.Status = HealthHealthy
+	case available > 0:
+		health.Status = HealthDegraded
+	default:
+		health.Status = HealthUnhealthy
+	}
+	return health
+}
+
+// StartHealthChecks probes every upstream's health endpoint until ctx is cancelled.
+// Does nothing when the probe interval is not configured.
+func (b *Backend) StartHealthChecks(ctx context.Context) {
+	hc := b.cfg.HealthCheck
+	if hc.Interval <= 0 {
+		return
+	}
+
+	client := &http.Client{Timeout: hc.Timeout}
+	for _, u := range b.upstreams {
+		go b.probeLoop(ctx, client, u)
+	}
+}
+
+func (b *Backend) probeLoop(ctx context.Context, client *http.Client, u *Upstream) {
+	ticker := time.NewTicker(b.cfg.HealthCheck.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := probe(ctx, client, u.target.String()+b.cfg.HealthCheck.Path)
+		if ctx.Err() != nil {
+			return
+		}
+		if changed := u.recordProbe(err, b.cfg.HealthCheck); changed {
+			if err != nil {
+				log.Printf("[Health] %s instance %s is DOWN: %v", b.cfg.Name, u.target, err)
+			} else {
+				log.Printf("[Health] %s instance %s is UP", b.cfg.Name, u.target)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe treats any 2xx answer as healthy
+func probe(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -1,49 +1,239 @@
 package proxy
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
 	"github.com/gin-gonic/gin"
 )
 
-// ReverseProxy creates a reverse proxy handler for a target service
-func ReverseProxy(targetURL string) gin.HandlerFunc {
-	target, err := url.Parse(targetURL)
-	if err != nil {
-		log.Fatalf("Failed to parse target URL %s: %v", targetURL, err)
+// Backend is a downstream service with one or more upstream instances, a load
+// balancer, its own transport and retry policy, and a circuit breaker per instance.
+// Create one per service and share its Handler across all routes targeting it.
+type Backend struct {
+	cfg       config.BackendConfig
+	upstreams []*Upstream
+	balancer  Balancer
+	proxy     *httputil.ReverseProxy
+	client    *http.Client // requests issued by the gateway itself, see Fetch
+
+	streams      context.Context // cancelled by CloseStreams to end SSE streams on shutdown
+	closeStreams context.CancelFunc
+}
+
+type upstreamContextKey struct{}
+
+// NewBackend builds the reverse proxy for a backend
+func NewBackend(cfg config.BackendConfig) *Backend {
+	b := &Backend{
+		cfg:      cfg,
+		balancer: newBalancer(cfg.LoadBalancing),
+	}
+	b.streams, b.closeStreams = context.WithCancel(context.Background())
+	for _, rawURL := range cfg.URLs {
+		target, err := url.Parse(rawURL)
+		if err != nil {
+			log.Fatalf("Failed to parse target URL %s: %v", rawURL, err)
+		}
+		b.upstreams = append(b.upstreams, newUpstream(target, cfg))
 	}
 
-	proxy := httputil.NewSingleHostReverseProxy(target)
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ResponseHeaderTimeout: cfg.HeaderTimeout,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       90 * time.Second,
+	}
 
-	// Customize the Director to preserve the path and query
-	originalDirector := proxy.Director
+	roundTripper := &retryTransport{
+		base:       tracing.Transport(&instrumentedTransport{backend: cfg.Name, base: transport}),
+		maxRetries: cfg.MaxRetries,
+	}
+	proxy := &httputil.ReverseProxy{Transport: roundTripper}
+
+	// Route to the instance chosen in Handler, preserving the path and query
 	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
+		target := upstreamFromContext(req.Context()).target
 		req.Host = target.Host
 		req.URL.Scheme = target.Scheme
 		req.URL.Host = target.Host
+		if target.Path != "" && target.Path != "/" {
+			req.URL.Path = strings.TrimSuffix(target.Path, "/") + req.URL.Path
+		}
+		if _, ok := req.Header["User-Agent"]; !ok {
+			// explicitly disable User-Agent so it's not set to default value
+			req.Header.Set("User-Agent", "")
+		}
 
 		// Log the proxied request
-		log.Printf("[Proxy] %s %s → %s%s", req.Method, req.URL.Path, target.String(), req.URL.Path)
+		log.Printf("[Proxy] %s %s → %s%s trace_id=%s", req.Method, req.URL.Path, target.String(), req.URL.Path,
+			tracing.TraceID(req.Context()))
+	}
+
+	// 5xx gateway-class responses count against the instance's breaker, everything else is a success
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		breaker := upstreamFromContext(resp.Request.Context()).breaker
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			breaker.RecordFailure()
+		default:
+			breaker.RecordSuccess()
+		}
+		return nil
 	}
 
-	// Custom error handler
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		log.Printf("[Proxy Error] Failed to proxy request to %s: %v", targetURL, err)
+		upstream := upstreamFromContext(r.Context())
+
+		// Client went away: not the backend's fault
+		if r.Context().Err() == context.Canceled {
+			upstream.breaker.ReleaseProbe()
+			return
+		}
+
+		upstream.breaker.RecordFailure()
+		log.Printf("[Proxy Error] Failed to proxy request to %s: %v trace_id=%s", upstream.target, err, tracing.TraceID(r.Context()))
+
 		w.Header().Set("Content-Type", "application/json")
+		if isTimeoutError(err) {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			fmt.Fprintf(w, `{"error":"gateway_timeout","message":"Backend service %s did not respond in time"}`, cfg.Name)
+			return
+		}
 		w.WriteHeader(http.StatusBadGateway)
-		fmt.Fprintf(w, `{"error":"service_unavailable","message":"Failed to connect to backend service","details":"%v"}`, err.Error())
+		fmt.Fprintf(w, `{"error":"service_unavailable","message":"Failed to connect to backend service %s"}`, cfg.Name)
 	}
 
+	b.proxy = proxy
+	b.client = &http.Client{
+		Transport: roundTripper,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return b
+}
+
+func upstreamFromContext(ctx context.Context) *Upstream {
+	return ctx.Value(upstreamContextKey{}).(*Upstream)
+}
+
+// Name returns the configured service name
+func (b *Backend) Name() string {
+	return b.cfg.Name
+}
+
+// pick chooses a healthy instance whose circuit allows the request.
+// When none is available it returns the reason and how long the caller should wait.
+func (b *Backend) pick() (*Upstream, string, time.Duration) {
+	candidates := make([]*Upstream, 0, len(b.upstreams))
+	for _, u := range b.upstreams {
+		if u.Healthy() {
+			candidates = append(candidates, u)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, "no_healthy_upstream", b.cfg.HealthCheck.Interval
+	}
+
+	var retryAfter time.Duration
+	for len(candidates) > 0 {
+		u := b.balancer.Next(candidates)
+		allowed, wait := u.breaker.Allow()
+		if allowed {
+			return u, "", 0
+		}
+		if retryAfter == 0 || wait < retryAfter {
+			retryAfter = wait
+		}
+
+		// Circuit open on this instance, try the others
+		for i, c := range candidates {
+			if c == u {
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil, "circuit_open", retryAfter
+}
+
+// Handler returns the gin handler that proxies to this backend
+func (b *Backend) Handler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		proxy.ServeHTTP(c.Writer, c.Request)
+		upstream, reason, retryAfter := b.pick()
+		if upstream == nil {
+			seconds := int(retryAfter.Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":       reason,
+				"message":     fmt.Sprintf("Backend service %s is temporarily unavailable", b.cfg.Name),
+				"retry_after": seconds,
+			})
+			c.Abort()
+			return
+		}
+
+		upstream.active.Add(1)
+		defer upstream.active.Add(-1)
+
+		ctx := context.WithValue(c.Request.Context(), upstreamContextKey{}, upstream)
+		// SSE streams are long-lived by design, only apply the overall deadline to regular requests.
+		// Streams are ended by CloseStreams instead so they do not hold a shutdown drain open.
+		var cancel context.CancelFunc
+		if strings.Contains(c.Request.Header.Get("Accept"), "text/event-stream") {
+			ctx, cancel = context.WithCancel(ctx)
+			stop := context.AfterFunc(b.streams, cancel)
+			defer stop()
+		} else if b.cfg.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, b.cfg.Timeout)
+		}
+		if cancel != nil {
+			defer cancel()
+		}
+
+		// Replace whatever the client sent with the address resolved from the
+		// trusted proxies, so services rate limit and audit the same IP
+		clientIP := c.ClientIP()
+		c.Request.Header.Set("X-Forwarded-For", clientIP)
+		c.Request.Header.Set("X-Real-IP", clientIP)
+
+		b.proxy.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
 	}
 }
 
+// CloseStreams ends every open SSE stream; clients reconnect to another gateway instance
+func (b *Backend) CloseStreams() {
+	b.closeStreams()
+}
+
+// ReverseProxy creates a reverse proxy handler for a target service using default settings.
+// Prefer a shared Backend from the Registry so breaker state is tracked per service.
+func ReverseProxy(targetURL string) gin.HandlerFunc {
+	return NewBackend(config.BackendConfig{
+		Name:          targetURL,
+		URLs:          []string{targetURL},
+		LoadBalancing: config.LoadBalanceRoundRobin,
+		DialTimeout:   5 * time.Second,
+		HeaderTimeout: 30 * time.Second,
+	}).Handler()
+}
+
 // ProxyWithPathRewrite creates a reverse proxy that rewrites the path
 func ProxyWithPathRewrite(targetURL, stripPrefix string) gin.HandlerFunc {
 	target, err := url.Parse(targetURL)
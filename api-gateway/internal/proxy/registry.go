@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"context"
+
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
+)
+
+// Registry holds one Backend per downstream service, keyed by service name
+type Registry struct {
+	backends map[string]*Backend
+	order    []string
+}
+
+func NewRegistry(services config.ServiceURLs) *Registry {
+	reg := &Registry{backends: make(map[string]*Backend)}
+	for _, svc := range services.All() {
+		reg.backends[svc.Name] = NewBackend(svc)
+		reg.order = append(reg.order, svc.Name)
+	}
+	return reg
+}
+
+// Get returns the backend for a service name, or nil if unknown
+func (r *Registry) Get(name string) *Backend {
+	return r.backends[name]
+}
+
+// All returns every backend in configuration order
+func (r *Registry) All() []*Backend {
+	all := make([]*Backend, 0, len(r.order))
+	for _, name := range r.order {
+		all = append(all, r.backends[name])
+	}
+	return all
+}
+
+// CloseStreams ends the open SSE streams of every backend
+func (r *Registry) CloseStreams() {
+	for _, backend := range r.All() {
+		backend.CloseStreams()
+	}
+}
+
+// StartHealthChecks starts active probing of every backend's instances
+func (r *Registry) StartHealthChecks(ctx context.Context) {
+	for _, backend := range r.All() {
+		backend.StartHealthChecks(ctx)
+	}
+}
+
+// Health returns the gateway-wide status and the per-service details.
+// The gateway is unhealthy only when no service has an available instance.
+func (r *Registry) Health() (string, map[string]BackendHealth) {
+	services := make(map[string]BackendHealth, len(r.order))
+	healthy, unhealthy := 0, 0
+	for _, backend := range r.All() {
+		health := backend.Health()
+		services[backend.Name()] = health
+		switch health.Status {
+		case HealthHealthy:
+			healthy++
+		case HealthUnhealthy:
+			unhealthy++
+		}
+	}
+
+	switch {
+	case healthy == len(r.order):
+		return HealthHealthy, services
+	case unhealthy == len(r.order):
+		return HealthUnhealthy, services
+	default:
+		return HealthDegraded, services
+	}
+}
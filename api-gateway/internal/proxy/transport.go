@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/metrics"
+)
+
+// retryTransport retries idempotent requests (GET/HEAD) that failed before
+// any response was received, e.g. connection refused or reset.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= t.maxRetries; attempt++ {
+		if attempt > 0 {
+			// Linear backoff, aborted if the client goes away
+			select {
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			log.Printf("[Proxy] Retry %d/%d %s %s: %v", attempt, t.maxRetries, req.Method, req.URL.String(), lastErr)
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		if !isConnectionError(err) || req.Context().Err() != nil {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// isConnectionError reports whether err happened while establishing or reusing a
+// connection, i.e. the backend cannot have processed the request.
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF)
+}
+
+func isTimeoutError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// instrumentedTransport records the latency of every attempt per backend instance
+type instrumentedTransport struct {
+	backend string
+	base    http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.ObserveUpstream(t.backend, req.URL.Host, status, time.Since(start))
+
+	return resp, err
+}
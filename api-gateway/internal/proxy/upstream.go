@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
+)
+
+// Upstream is one instance of a backend service. Each instance has its own
+// circuit breaker and health state so a single bad replica does not take the
+// whole service out of rotation.
+type Upstream struct {
+	target  *url.URL
+	breaker *CircuitBreaker
+	active  atomic.Int64 // in-flight requests, used by least_conn
+
+	mu                   sync.Mutex
+	healthy              bool
+	consecutiveFailures  int
+	consecutiveSuccesses int
+	lastCheck            time.Time
+	lastError            string
+}
+
+// UpstreamSnapshot is a point-in-time view of an instance, used by /health
+type UpstreamSnapshot struct {
+	URL               string          `json:"url"`
+	Healthy           bool            `json:"healthy"`
+	ActiveConnections int64           `json:"active_connections"`
+	LastCheck         *time.Time      `json:"last_check,omitempty"`
+	LastError         string          `json:"last_error,omitempty"`
+	Breaker           BreakerSnapshot `json:"breaker"`
+}
+
+func newUpstream(target *url.URL, cfg config.BackendConfig) *Upstream {
+	return &Upstream{
+		target:  target,
+		breaker: NewCircuitBreaker(cfg.BreakerFailureThreshold, cfg.BreakerOpenTimeout),
+		healthy: true, // assume healthy until the first probe says otherwise
+	}
+}
+
+// Healthy reports whether the instance is currently in rotation
+func (u *Upstream) Healthy() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.healthy
+}
+
+// recordProbe updates the health state after an active check and reports
+// whether the instance changed state
+func (u *Upstream) recordProbe(err error, hc config.HealthCheckConfig) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.lastCheck = time.Now()
+	wasHealthy := u.healthy
+
+	if err != nil {
+		u.lastError = err.Error()
+		u.consecutiveSuccesses = 0
+		u.consecutiveFailures++
+		if u.consecutiveFailures >= hc.UnhealthyThreshold {
+			u.healthy = false
+		}
+	} else {
+		u.lastError = ""
+		u.consecutiveFailures = 0
+		u.consecutiveSuccesses++
+		if u.consecutiveSuccesses >= hc.HealthyThreshold {
+			u.healthy = true
+		}
+	}
+
+	return wasHealthy != u.healthy
+}
+
+func (u *Upstream) Snapshot() UpstreamSnapshot {
+	u.mu.Lock()
+	snap := UpstreamSnapshot{
+		URL:       u.target.String(),
+		Healthy:   u.healthy,
+		LastError: u.lastError,
+	}
+	if !u.lastCheck.IsZero() {
+		lastCheck := u.lastCheck
+		snap.LastCheck = &lastCheck
+	}
+	u.mu.Unlock()
+
+	snap.ActiveConnections = u.active.Load()
+	snap.Breaker = u.breaker.Snapshot()
+	return snap
+}
+
+// Balancer picks the next upstream from the instances currently in rotation
+type Balancer interface {
+	Next(candidates []*Upstream) *Upstream
+}
+
+func newBalancer(strategy string) Balancer {
+	if strategy == config.LoadBalanceLeastConn {
+		return &leastConnBalancer{}
+	}
+	return &roundRobinBalancer{}
+}
+
+type roundRobinBalancer struct {
+	counter atomic.Uint64
+}
+
+func (b *roundRobinBalancer) Next(candidates []*Upstream) *Upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+	n := b.counter.Add(1) - 1
+	return candidates[n%uint64(len(candidates))]
+}
+
+// leastConnBalancer picks the instance with the fewest in-flight requests,
+// rotating between ties so idle instances share the load
+type leastConnBalancer struct {
+	counter atomic.Uint64
+}
+
+func (b *leastConnBalancer) Next(candidates []*Upstream) *Upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+	offset := int(b.counter.Add(1) % uint64(len(candidates)))
+
+	var best *Upstream
+	for i := range candidates {
+		u := candidates[(offset+i)%len(candidates)]
+		if best == nil || u.active.Load() < best.active.Load() {
+			best = u
+		}
+	}
+	return best
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Rule describes a token bucket: Burst tokens at most, refilled at Rate tokens per second
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute builds a rule that allows requestsPerMinute on average with the given burst
+func PerMinute(requestsPerMinute, burst int) Rule {
+	if burst <= 0 {
+		burst = requestsPerMinute
+	}
+	return Rule{
+		Rate:  float64(requestsPerMinute) / 60.0,
+		Burst: burst,
+	}
+}
+
+// Result is the outcome of a single Allow call
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // time until one token is available (only meaningful when !Allowed)
+	ResetAfter time.Duration // time until the bucket is full again
+}
+
+// Limiter is implemented by every rate limit backend
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// newResult derives the response fields from the bucket level left after a request
+func newResult(allowed bool, tokens float64, rule Rule) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     rule.Burst,
+		Remaining: int(math.Floor(tokens)),
+	}
+	if rule.Rate > 0 {
+		res.ResetAfter = secondsToDuration((float64(rule.Burst) - tokens) / rule.Rate)
+		if !allowed {
+			res.RetryAfter = secondsToDuration((1 - tokens) / rule.Rate)
+		}
+	}
+	if res.Remaining < 0 {
+		res.Remaining = 0
+	}
+	return res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
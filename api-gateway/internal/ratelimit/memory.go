@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// MemoryLimiter keeps token buckets in process memory.
+// Suitable for a single gateway instance; use RedisLimiter when running several.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	idleTTL time.Duration
+}
+
+// NewMemoryLimiter creates an in-memory limiter and starts a janitor that drops idle buckets
+func NewMemoryLimiter(idleTTL time.Duration) *MemoryLimiter {
+	if idleTTL <= 0 {
+		idleTTL = 10 * time.Minute
+	}
+	l := &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		idleTTL: idleTTL,
+	}
+	go l.janitor()
+	return l
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	// Refill since last request
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.Rate)
+	b.lastSeen = now
+
+	allowed := false
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	}
+
+	return newResult(allowed, b.tokens, rule), nil
+}
+
+func (l *MemoryLimiter) janitor() {
+	ticker := time.NewTicker(l.idleTTL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-l.idleTTL)
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if b.lastSeen.Before(cutoff) {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript refills and takes one token atomically.
+// ARGV: rate (tokens per ms), burst, now (ms). Returns {allowed, tokens_left}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate) + 1000)
+
+return {allowed, tostring(tokens)}
+`)
+
+// RedisLimiter shares token buckets between gateway instances through Redis
+type RedisLimiter struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisLimiter creates a limiter backed by the given Redis client
+func NewRedisLimiter(client *redis.Client, prefix string) *RedisLimiter {
+	if prefix == "" {
+		prefix = "gateway:ratelimit:"
+	}
+	return &RedisLimiter{client: client, prefix: prefix}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	ratePerMs := rule.Rate / 1000.0
+	if ratePerMs <= 0 {
+		return Result{}, fmt.Errorf("invalid rate limit rule: rate must be positive")
+	}
+
+	now := time.Now().UnixMilli()
+	raw, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key},
+		strconv.FormatFloat(ratePerMs, 'f', -1, 64), rule.Burst, now).Result()
+	if err != nil {
+		return Result{}, fmt.Errorf("run token bucket script: %w", err)
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected token bucket reply: %v", raw)
+	}
+
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("parse token count: %w", err)
+	}
+
+	return newResult(allowed == 1, tokens, rule), nil
+}
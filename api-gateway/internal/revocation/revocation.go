@@ -0,0 +1,142 @@
+// Package revocation rejects access tokens that auth-service revoked before
+// their expiry (logout, password change, role change, deactivation).
+//
+// auth-service writes the entries to Redis; the gateway reads them with a
+// short local cache so most requests do not reach Redis. A revocation
+// therefore takes effect at the gateway within the cache TTL.
+package revocation
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Key prefixes written by auth-service (internal/service/token_revocation.go)
+const (
+	revokedTokenKeyPrefix     = "auth:revoked:jti:"
+	revokedSessionKeyPrefix   = "auth:revoked:sid:"
+	tokensValidAfterKeyPrefix = "auth:tokens_valid_after:"
+)
+
+// Token identifies the access token being checked
+type Token struct {
+	ID        string // jti
+	SessionID string // sid
+	UserID    string
+	IssuedAt  time.Time
+}
+
+type cacheEntry struct {
+	value   string // "" when the key does not exist
+	expires time.Time
+}
+
+// Checker looks up revocation entries in Redis
+type Checker struct {
+	client   *redis.Client
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewChecker creates a checker and starts a janitor that drops expired cache entries
+func NewChecker(client *redis.Client, cacheTTL time.Duration) *Checker {
+	c := &Checker{
+		client:   client,
+		cacheTTL: cacheTTL,
+		cache:    make(map[string]cacheEntry),
+	}
+	if cacheTTL > 0 {
+		go c.janitor()
+	}
+	return c
+}
+
+// IsRevoked reports whether the token was revoked by jti, session or user
+func (c *Checker) IsRevoked(ctx context.Context, token Token) (bool, error) {
+	keys := []string{tokensValidAfterKeyPrefix + token.UserID}
+	if token.ID != "" {
+		keys = append(keys, revokedTokenKeyPrefix+token.ID)
+	}
+	if token.SessionID != "" {
+		keys = append(keys, revokedSessionKeyPrefix+token.SessionID)
+	}
+
+	values, err := c.lookup(ctx, keys)
+	if err != nil {
+		return false, err
+	}
+
+	for _, key := range keys[1:] {
+		if values[key] != "" {
+			return true, nil
+		}
+	}
+	if validAfter := values[keys[0]]; validAfter != "" {
+		cutoff, err := strconv.ParseInt(validAfter, 10, 64)
+		if err == nil && token.IssuedAt.Unix() < cutoff {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// lookup returns the value of each key, reading Redis only for keys not cached
+func (c *Checker) lookup(ctx context.Context, keys []string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	var missing []string
+
+	now := time.Now()
+	c.mu.Lock()
+	for _, key := range keys {
+		if entry, ok := c.cache[key]; ok && now.Before(entry.expires) {
+			values[key] = entry.value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return values, nil
+	}
+
+	results, err := c.client.MGet(ctx, missing...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	expires := time.Now().Add(c.cacheTTL)
+	c.mu.Lock()
+	for i, key := range missing {
+		value, _ := results[i].(string)
+		values[key] = value
+		if c.cacheTTL > 0 {
+			c.cache[key] = cacheEntry{value: value, expires: expires}
+		}
+	}
+	c.mu.Unlock()
+
+	return values, nil
+}
+
+func (c *Checker) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+		c.mu.Lock()
+		for key, entry := range c.cache {
+			if !now.Before(entry.expires) {
+				delete(c.cache, key)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
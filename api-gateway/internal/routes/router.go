@@ -0,0 +1,86 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/routetable"
+	"github.com/gin-gonic/gin"
+)
+
+// Router serves requests with the gin engine built from the current route table.
+// Reload builds a new engine from the file and swaps it in atomically, so
+// in-flight requests finish on the engine they started on.
+type Router struct {
+	path             string
+	deps             Dependencies
+	newEngine        func() *gin.Engine
+	services         []string
+	rateLimitClasses []string
+
+	mu     sync.Mutex // serializes reloads
+	engine atomic.Pointer[gin.Engine]
+}
+
+// NewRouter loads the route table at path and builds the first engine.
+// newEngine must return an engine with the global middleware already installed.
+func NewRouter(path string, deps Dependencies, newEngine func() *gin.Engine, rateLimitClasses []string) (*Router, error) {
+	rt := &Router{
+		path:             path,
+		deps:             deps,
+		newEngine:        newEngine,
+		rateLimitClasses: rateLimitClasses,
+	}
+	for _, backend := range deps.Backends.All() {
+		rt.services = append(rt.services, backend.Name())
+	}
+
+	if err := rt.Reload(); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Reload re-reads the route table. On any error the current routes stay active.
+func (rt *Router) Reload() error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	table, err := routetable.Load(rt.path)
+	if err != nil {
+		return err
+	}
+	if err := table.Validate(rt.services, rt.rateLimitClasses); err != nil {
+		return err
+	}
+
+	engine, err := rt.build(table)
+	if err != nil {
+		return err
+	}
+
+	rt.engine.Store(engine)
+	log.Printf("[Routes] Loaded %d endpoints from %s", len(table.Endpoints()), rt.path)
+	return nil
+}
+
+// build registers the table on a fresh engine. gin panics on conflicting
+// paths, which is turned into an error so a bad reload cannot crash the gateway.
+func (rt *Router) build(table *routetable.Table) (engine *gin.Engine, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid route table: %v", r)
+		}
+	}()
+
+	engine = rt.newEngine()
+	SetupRoutes(engine, rt.deps, table)
+	return engine, nil
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rt.engine.Load().ServeHTTP(w, req)
+}
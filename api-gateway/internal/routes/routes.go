@@ -2,23 +2,93 @@ package routes
 
 import (
 	"net/http"
+	"sync/atomic"
 
+	"github.com/bisosad1501/DATN/shared/pkg/metrics"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/bff"
 	"github.com/bisosad1501/ielts-platform/api-gateway/internal/config"
 	"github.com/bisosad1501/ielts-platform/api-gateway/internal/middleware"
 	"github.com/bisosad1501/ielts-platform/api-gateway/internal/proxy"
+	"github.com/bisosad1501/ielts-platform/api-gateway/internal/routetable"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(r *gin.Engine, cfg *config.Config, authMiddleware *middleware.AuthMiddleware) {
-	// Health check for gateway itself
+// Dependencies are the long-lived components shared by every route table generation
+type Dependencies struct {
+	Backends       *proxy.Registry
+	AuthMiddleware *middleware.AuthMiddleware
+	ServiceAuth    *middleware.ServiceAuth
+	RateLimiter    *middleware.RateLimiter
+	BFF            *bff.Handler
+	Ready          *atomic.Bool // false once shutdown began
+}
+
+// chain builds the handler chain of one endpoint: auth, roles and permissions, rate limits, proxy.
+// Rate limits come after auth so requests are keyed per user.
+func (d Dependencies) chain(ep routetable.Endpoint) []gin.HandlerFunc {
+	var handlers []gin.HandlerFunc
+
+	switch ep.Auth {
+	case routetable.AuthRequired:
+		handlers = append(handlers, d.AuthMiddleware.ValidateToken())
+		if len(ep.Roles) > 0 {
+			handlers = append(handlers, d.AuthMiddleware.RequireRole(ep.Roles...))
+		}
+		if len(ep.Permissions) > 0 {
+			handlers = append(handlers, d.AuthMiddleware.RequirePermission(ep.Permissions...))
+		}
+	case routetable.AuthOptional:
+		handlers = append(handlers, d.AuthMiddleware.OptionalAuth())
+	case routetable.AuthInternal:
+		handlers = append(handlers, d.ServiceAuth.RequireServiceCredential())
+	}
+
+	// The default class always applies, stricter classes are layered on top
+	if ep.RateLimit != routetable.RateLimitNone {
+		handlers = append(handlers, d.RateLimiter.Limit(config.RateLimitClassDefault))
+		if ep.RateLimit != "" && ep.RateLimit != config.RateLimitClassDefault {
+			handlers = append(handlers, d.RateLimiter.Limit(ep.RateLimit))
+		}
+	}
+
+	// One shared proxy per backend so retries and circuit breaker state are tracked per service
+	return append(handlers, d.Backends.Get(ep.Service).Handler())
+}
+
+// SetupRoutes registers the gateway's own endpoints and every route of the table.
+// The table must have been validated against the registry's services.
+func SetupRoutes(r *gin.Engine, deps Dependencies, table *routetable.Table) {
+	backends := deps.Backends
+
+	// Health check for gateway itself, aggregated over every downstream instance
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "healthy",
-			"service": "api-gateway",
-			"version": "1.0.0",
+		status, services := backends.Health()
+
+		code := http.StatusOK
+		if status == proxy.HealthUnhealthy {
+			code = http.StatusServiceUnavailable
+		}
+
+		c.JSON(code, gin.H{
+			"status":   status,
+			"service":  "api-gateway",
+			"version":  "1.0.0",
+			"backends": services,
 		})
 	})
 
+	// Readiness: flips to 503 when the gateway starts draining
+	r.GET("/ready", func(c *gin.Context) {
+		if !deps.Ready.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down", "service": "api-gateway"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "service": "api-gateway"})
+	})
+
+	// Prometheus metrics
+	r.GET("/metrics", metrics.Handler())
+
 	// Gateway info endpoint
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -27,6 +97,7 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authMiddleware *middleware.A
 			"status":  "running",
 			"endpoints": gin.H{
 				"health":        "/health",
+				"ready":         "/ready",
 				"auth":          "/api/v1/auth/* (login, register, OAuth, password reset)",
 				"user":          "/api/v1/user/* (profile, progress, goals, reminders, leaderboard)",
 				"courses":       "/api/v1/courses/* (browse, enroll, reviews, videos, materials)",
@@ -41,300 +112,23 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config, authMiddleware *middleware.A
 				"tags":          "/api/v1/tags (exercise tags)",
 				"notifications": "/api/v1/notifications/* (notifications, preferences, timezone, scheduled)",
 				"admin":         "/api/v1/admin/* (course/exercise/notification management)",
+				"bff":           "/api/v1/bff/* (aggregated dashboard and course screens)",
 			},
 			"documentation": "See README.md for detailed API documentation",
 		})
 	})
 
-	// API v1 routes
-	v1 := r.Group("/api/v1")
-
-	// ============================================
-	// AUTH SERVICE - No auth required (public routes)
-	// ============================================
-	authGroup := v1.Group("/auth")
+	// Aggregation endpoints served by the gateway itself
+	limit := deps.RateLimiter.Limit(config.RateLimitClassDefault)
+	bffGroup := r.Group("/api/v1/bff")
 	{
-		// Public auth endpoints (no token required)
-		authGroup.POST("/register", proxy.ReverseProxy(cfg.Services.AuthService))
-		authGroup.POST("/login", proxy.ReverseProxy(cfg.Services.AuthService))
-		authGroup.POST("/refresh", proxy.ReverseProxy(cfg.Services.AuthService))
-		authGroup.POST("/logout", proxy.ReverseProxy(cfg.Services.AuthService))
-
-		// Email verification
-		authGroup.GET("/verify-email", proxy.ReverseProxy(cfg.Services.AuthService))          // Legacy token-based verification
-		authGroup.POST("/verify-email-by-code", proxy.ReverseProxy(cfg.Services.AuthService)) // New 6-digit code verification
-		authGroup.POST("/resend-verification", proxy.ReverseProxy(cfg.Services.AuthService))
-
-		// Password reset
-		authGroup.POST("/forgot-password", proxy.ReverseProxy(cfg.Services.AuthService))        // Request reset (sends 6-digit code)
-		authGroup.POST("/reset-password", proxy.ReverseProxy(cfg.Services.AuthService))         // Legacy token-based reset
-		authGroup.POST("/reset-password-by-code", proxy.ReverseProxy(cfg.Services.AuthService)) // New 6-digit code reset
-
-		// Google OAuth
-		authGroup.GET("/google/url", proxy.ReverseProxy(cfg.Services.AuthService))      // Get OAuth URL (Mobile/Web)
-		authGroup.GET("/google", proxy.ReverseProxy(cfg.Services.AuthService))          // Web flow: Redirect to Google
-		authGroup.GET("/google/callback", proxy.ReverseProxy(cfg.Services.AuthService)) // Web flow: Handle callback
-		authGroup.POST("/google/token", proxy.ReverseProxy(cfg.Services.AuthService))   // Mobile flow: Exchange code
-
-		// Protected auth endpoints (require token)
-		authProtected := authGroup.Group("")
-		authProtected.Use(authMiddleware.ValidateToken())
-		{
-			authProtected.GET("/validate", proxy.ReverseProxy(cfg.Services.AuthService))
-			authProtected.POST("/change-password", proxy.ReverseProxy(cfg.Services.AuthService))
-			authProtected.GET("/me", proxy.ReverseProxy(cfg.Services.AuthService))
-		}
+		bffGroup.GET("/dashboard", deps.AuthMiddleware.ValidateToken(), limit, deps.BFF.Dashboard())
+		bffGroup.GET("/course/:id/full", deps.AuthMiddleware.OptionalAuth(), limit, deps.BFF.CourseFull())
 	}
 
-	// ============================================
-	// USER SERVICE - Most require auth
-	// ============================================
-	// Public user profile route (optional auth)
-	usersGroup := v1.Group("/users")
-	usersGroup.Use(authMiddleware.OptionalAuth()) // Optional auth for visibility check
-	{
-		usersGroup.GET("/:id/profile", proxy.ReverseProxy(cfg.Services.UserService))
-		usersGroup.GET("/:id/achievements", proxy.ReverseProxy(cfg.Services.UserService))
-		usersGroup.GET("/:id/followers", proxy.ReverseProxy(cfg.Services.UserService))
-		usersGroup.GET("/:id/following", proxy.ReverseProxy(cfg.Services.UserService))
-	}
-
-	// Protected social routes (auth required)
-	usersProtected := v1.Group("/users")
-	usersProtected.Use(authMiddleware.ValidateToken())
-	{
-		usersProtected.POST("/:id/follow", proxy.ReverseProxy(cfg.Services.UserService))
-		usersProtected.DELETE("/:id/follow", proxy.ReverseProxy(cfg.Services.UserService))
-	}
-
-	userGroup := v1.Group("/user")
-	userGroup.Use(authMiddleware.ValidateToken())
-	{
-		userGroup.GET("/profile", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.PUT("/profile", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.POST("/profile/avatar", proxy.ReverseProxy(cfg.Services.UserService))
-		// Remove follower (user removes someone from their followers list)
-		userGroup.DELETE("/followers/:id", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/progress", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/progress/history", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/statistics", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/statistics/:skill", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/achievements", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/achievements/earned", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/preferences", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.PUT("/preferences", proxy.ReverseProxy(cfg.Services.UserService))
-
-		// Study sessions
-		userGroup.POST("/sessions", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.POST("/sessions/:id/end", proxy.ReverseProxy(cfg.Services.UserService))
-
-		// Study goals
-		userGroup.POST("/goals", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/goals", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/goals/:id", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.PUT("/goals/:id", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.POST("/goals/:id/complete", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.DELETE("/goals/:id", proxy.ReverseProxy(cfg.Services.UserService))
-
-		// Study reminders
-		userGroup.POST("/reminders", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/reminders", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.PUT("/reminders/:id", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.DELETE("/reminders/:id", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.PUT("/reminders/:id/toggle", proxy.ReverseProxy(cfg.Services.UserService))
-
-		// Leaderboard
-		userGroup.GET("/leaderboard", proxy.ReverseProxy(cfg.Services.UserService))
-		userGroup.GET("/leaderboard/rank", proxy.ReverseProxy(cfg.Services.UserService))
-	}
-
-	// ============================================
-	// COURSE SERVICE - Mixed auth (some public, some protected)
-	// ============================================
-	courseGroup := v1.Group("/courses")
-	{
-		// Public endpoints (browsing courses)
-		courseGroup.GET("", authMiddleware.OptionalAuth(), proxy.ReverseProxy(cfg.Services.CourseService))
-		courseGroup.GET("/:id", authMiddleware.OptionalAuth(), proxy.ReverseProxy(cfg.Services.CourseService))
-		courseGroup.GET("/:id/reviews", authMiddleware.OptionalAuth(), proxy.ReverseProxy(cfg.Services.CourseService))    // Get course reviews
-		courseGroup.GET("/:id/categories", authMiddleware.OptionalAuth(), proxy.ReverseProxy(cfg.Services.CourseService)) // Get course categories
-
-		// Protected review endpoints
-		courseProtected := courseGroup.Group("")
-		courseProtected.Use(authMiddleware.ValidateToken())
-		{
-			courseProtected.POST("/:id/enroll", proxy.ReverseProxy(cfg.Services.CourseService))
-			courseProtected.GET("/my-courses", proxy.ReverseProxy(cfg.Services.CourseService))
-			courseProtected.GET("/:id/progress", proxy.ReverseProxy(cfg.Services.CourseService))
-			courseProtected.POST("/:id/reviews", proxy.ReverseProxy(cfg.Services.CourseService)) // Create course review
-			courseProtected.PUT("/:id/reviews", proxy.ReverseProxy(cfg.Services.CourseService))  // Update course review
-		}
-	}
-
-	// Categories (public)
-	v1.GET("/categories", proxy.ReverseProxy(cfg.Services.CourseService))
-
-	// Lessons endpoints (from Course Service)
-	lessonGroup := v1.Group("/lessons")
-	{
-		lessonGroup.GET("/:id", authMiddleware.OptionalAuth(), proxy.ReverseProxy(cfg.Services.CourseService))
-	}
-
-	// Video endpoints (protected)
-	videoGroup := v1.Group("/videos")
-	videoGroup.Use(authMiddleware.ValidateToken())
-	{
-		videoGroup.POST("/track", proxy.ReverseProxy(cfg.Services.CourseService))        // Track video watch progress
-		videoGroup.GET("/history", proxy.ReverseProxy(cfg.Services.CourseService))       // Get watch history
-		videoGroup.GET("/:id/subtitles", proxy.ReverseProxy(cfg.Services.CourseService)) // Get video subtitles
-	}
-
-	// Materials endpoints (protected)
-	materialGroup := v1.Group("/materials")
-	materialGroup.Use(authMiddleware.ValidateToken())
-	{
-		materialGroup.POST("/:id/download", proxy.ReverseProxy(cfg.Services.CourseService)) // Record material download
-	}
-
-	// Enrollments endpoints (from Course Service)
-	enrollmentGroup := v1.Group("/enrollments")
-	enrollmentGroup.Use(authMiddleware.ValidateToken())
-	{
-		enrollmentGroup.POST("", proxy.ReverseProxy(cfg.Services.CourseService))
-		enrollmentGroup.GET("/my", proxy.ReverseProxy(cfg.Services.CourseService))
-		enrollmentGroup.GET("/:id/progress", proxy.ReverseProxy(cfg.Services.CourseService))
-	}
-
-	// Progress endpoints (from Course Service)
-	progressGroup := v1.Group("/progress")
-	progressGroup.Use(authMiddleware.ValidateToken())
-	{
-		progressGroup.GET("/lessons/:id", proxy.ReverseProxy(cfg.Services.CourseService)) // Get lesson progress (for resume watching)
-		progressGroup.PUT("/lessons/:id", proxy.ReverseProxy(cfg.Services.CourseService)) // Update lesson progress
-	}
-
-	// ============================================
-	// EXERCISE SERVICE - Mixed auth
-	// ============================================
-	exerciseGroup := v1.Group("/exercises")
-	{
-		// Public browsing
-		exerciseGroup.GET("", authMiddleware.OptionalAuth(), proxy.ReverseProxy(cfg.Services.ExerciseService))
-		exerciseGroup.GET("/:id", authMiddleware.OptionalAuth(), proxy.ReverseProxy(cfg.Services.ExerciseService))
-		exerciseGroup.GET("/:id/tags", proxy.ReverseProxy(cfg.Services.ExerciseService)) // Get exercise tags
-
-		// Protected (requires login)
-		exerciseProtected := exerciseGroup.Group("")
-		exerciseProtected.Use(authMiddleware.ValidateToken())
-		{
-			exerciseProtected.POST("/:id/start", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		}
-	}
-
-	// Tags (public)
-	tagsGroup := v1.Group("/tags")
-	{
-		tagsGroup.GET("", proxy.ReverseProxy(cfg.Services.ExerciseService)) // Get all tags
-	}
-
-	// Submissions (all protected)
-	submissionGroup := v1.Group("/submissions")
-	submissionGroup.Use(authMiddleware.ValidateToken())
-	{
-		submissionGroup.POST("", proxy.ReverseProxy(cfg.Services.ExerciseService)) // Start new submission
-		submissionGroup.PUT("/:id/answers", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		submissionGroup.GET("/:id/result", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		submissionGroup.GET("/my", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		submissionGroup.GET("", proxy.ReverseProxy(cfg.Services.ExerciseService)) // List my submissions (duplicate of /my)
-	}
-
-	// ============================================
-	// NOTIFICATION SERVICE - All protected
-	// ============================================
-	notificationGroup := v1.Group("/notifications")
-	notificationGroup.Use(authMiddleware.ValidateToken())
-	{
-		// SSE stream (must be before /:id to avoid route conflict)
-		notificationGroup.GET("/stream", proxy.ReverseProxy(cfg.Services.NotificationService))
-		
-		notificationGroup.GET("", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.GET("/unread-count", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.GET("/:id", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.PUT("/:id/read", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.PUT("/mark-all-read", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.DELETE("/:id", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.POST("/devices", proxy.ReverseProxy(cfg.Services.NotificationService))
-
-		// Preferences (including timezone)
-		notificationGroup.GET("/preferences", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.PUT("/preferences", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.GET("/preferences/timezone", proxy.ReverseProxy(cfg.Services.NotificationService)) // Get timezone
-		notificationGroup.PUT("/preferences/timezone", proxy.ReverseProxy(cfg.Services.NotificationService)) // Update timezone
-
-		// Scheduled notifications
-		notificationGroup.POST("/scheduled", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.GET("/scheduled", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.GET("/scheduled/:id", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.PUT("/scheduled/:id", proxy.ReverseProxy(cfg.Services.NotificationService))
-		notificationGroup.DELETE("/scheduled/:id", proxy.ReverseProxy(cfg.Services.NotificationService))
-	}
-
-	// Internal notification routes (service-to-service communication)
-	notificationInternal := v1.Group("/notifications/internal")
-	// TODO: Add internal auth middleware for service-to-service calls
-	{
-		notificationInternal.POST("/send", proxy.ReverseProxy(cfg.Services.NotificationService)) // Send notification from another service
-		notificationInternal.POST("/bulk", proxy.ReverseProxy(cfg.Services.NotificationService)) // Send bulk notifications
-	}
-
-	// ============================================
-	// ADMIN ROUTES - Require admin/instructor role
-	// ============================================
-    adminGroup := v1.Group("/admin")
-    adminGroup.Use(authMiddleware.ValidateToken())
-    adminGroup.Use(authMiddleware.RequireRole("instructor", "admin"))
-	{
-		// Course management
-		adminGroup.POST("/courses", proxy.ReverseProxy(cfg.Services.CourseService))
-		adminGroup.PUT("/courses/:id", proxy.ReverseProxy(cfg.Services.CourseService))
-		adminGroup.DELETE("/courses/:id", proxy.ReverseProxy(cfg.Services.CourseService))
-		adminGroup.POST("/courses/:id/publish", proxy.ReverseProxy(cfg.Services.CourseService))
-
-		// Module and lesson management
-		adminGroup.POST("/modules", proxy.ReverseProxy(cfg.Services.CourseService))
-		adminGroup.POST("/lessons", proxy.ReverseProxy(cfg.Services.CourseService))
-
-		// Video management
-		adminGroup.POST("/lessons/:lesson_id/videos", proxy.ReverseProxy(cfg.Services.CourseService))
-
-		// Exercise management
-		adminGroup.POST("/exercises", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.PUT("/exercises/:id", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.DELETE("/exercises/:id", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.POST("/exercises/:id/publish", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.POST("/exercises/:id/unpublish", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.POST("/exercises/:id/sections", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.GET("/exercises/:id/analytics", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.POST("/exercises/:id/tags", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.DELETE("/exercises/:id/tags/:tag_id", proxy.ReverseProxy(cfg.Services.ExerciseService))
-
-		// Question management
-		adminGroup.POST("/questions", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.POST("/questions/:id/options", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.POST("/questions/:id/answer", proxy.ReverseProxy(cfg.Services.ExerciseService))
-
-		// Question Bank management
-		adminGroup.GET("/question-bank", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.POST("/question-bank", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.PUT("/question-bank/:id", proxy.ReverseProxy(cfg.Services.ExerciseService))
-		adminGroup.DELETE("/question-bank/:id", proxy.ReverseProxy(cfg.Services.ExerciseService))
-
-		// Tag management
-		adminGroup.POST("/tags", proxy.ReverseProxy(cfg.Services.ExerciseService))
-
-		// Notification management
-		adminGroup.POST("/notifications", proxy.ReverseProxy(cfg.Services.NotificationService))
-		adminGroup.POST("/notifications/bulk", proxy.ReverseProxy(cfg.Services.NotificationService))
+	// Proxied API routes, declared in the route table
+	for _, ep := range table.Endpoints() {
+		r.Handle(ep.Method, ep.Path, deps.chain(ep)...)
 	}
 
 	// ============================================
@@ -0,0 +1,172 @@
+package routetable
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ServiceRoute is a route registered with gin inside a service
+type ServiceRoute struct {
+	Method string
+	Path   string
+}
+
+var ginMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+// ExtractServiceRoutes statically reads the gin routes registered in the Go files of dir
+// (typically <service>/internal/routes). It follows `x := y.Group("/prefix")` chains and
+// collects `x.GET("/path", ...)` style registrations; non-literal paths are skipped.
+func ExtractServiceRoutes(dir string) ([]ServiceRoute, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	var routes []ServiceRoute
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, file, src, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Variable name -> accumulated group prefix; unknown receivers are the engine itself
+		prefixes := make(map[string]string)
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch node := n.(type) {
+			case *ast.AssignStmt:
+				if len(node.Lhs) != 1 || len(node.Rhs) != 1 {
+					return true
+				}
+				lhs, ok := node.Lhs[0].(*ast.Ident)
+				if !ok {
+					return true
+				}
+				if recv, method, path, ok := routerCall(node.Rhs[0]); ok && method == "Group" {
+					prefixes[lhs.Name] = prefixes[recv] + path
+				}
+			case *ast.ExprStmt:
+				if recv, method, path, ok := routerCall(node.X); ok && (ginMethods[method] || method == "Any") {
+					fullPath := prefixes[recv] + path
+					if method == "Any" {
+						for m := range ginMethods {
+							routes = append(routes, ServiceRoute{Method: m, Path: fullPath})
+						}
+					} else {
+						routes = append(routes, ServiceRoute{Method: method, Path: fullPath})
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	return routes, nil
+}
+
+// routerCall matches `recv.Method("literal", ...)`
+func routerCall(expr ast.Expr) (recv, method, path string, ok bool) {
+	call, isCall := expr.(*ast.CallExpr)
+	if !isCall || len(call.Args) == 0 {
+		return "", "", "", false
+	}
+	sel, isSel := call.Fun.(*ast.SelectorExpr)
+	if !isSel {
+		return "", "", "", false
+	}
+	ident, isIdent := sel.X.(*ast.Ident)
+	if !isIdent {
+		return "", "", "", false
+	}
+	lit, isLit := call.Args[0].(*ast.BasicLit)
+	if !isLit || lit.Kind != token.STRING {
+		return "", "", "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", "", "", false
+	}
+	return ident.Name, sel.Sel.Name, value, true
+}
+
+// Report is the result of comparing the route table with the services
+type Report struct {
+	// Gateway routes that no service registers: requests would always 404 downstream
+	Missing []Endpoint
+	// Service routes the gateway does not expose (health and /internal/ routes excluded)
+	Unexposed map[string][]ServiceRoute
+}
+
+// Compare matches every table endpoint against the routes of its target service.
+// Path parameters are compared by position only, so /:id and /:course_id are equal.
+func Compare(endpoints []Endpoint, services map[string][]ServiceRoute) Report {
+	report := Report{Unexposed: make(map[string][]ServiceRoute)}
+
+	exposed := make(map[string]bool)
+	for _, ep := range endpoints {
+		key := ep.Service + " " + ep.Method + " " + normalizePath(ep.Path)
+		exposed[key] = true
+	}
+
+	registered := make(map[string]bool)
+	for service, routes := range services {
+		for _, r := range routes {
+			key := service + " " + r.Method + " " + normalizePath(r.Path)
+			registered[key] = true
+			if !exposed[key] && r.Path != "/health" && !strings.Contains(r.Path, "/internal") {
+				report.Unexposed[service] = append(report.Unexposed[service], r)
+			}
+		}
+	}
+
+	for _, ep := range endpoints {
+		if _, checked := services[ep.Service]; !checked {
+			continue
+		}
+		if !registered[ep.Service+" "+ep.Method+" "+normalizePath(ep.Path)] {
+			report.Missing = append(report.Missing, ep)
+		}
+	}
+
+	for service := range report.Unexposed {
+		routes := report.Unexposed[service]
+		sort.Slice(routes, func(i, j int) bool {
+			if routes[i].Path != routes[j].Path {
+				return routes[i].Path < routes[j].Path
+			}
+			return routes[i].Method < routes[j].Method
+		})
+	}
+	return report
+}
+
+func normalizePath(path string) string {
+	segments := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = ":"
+		} else if strings.HasPrefix(s, "*") {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,212 @@
+package routetable
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Auth modes a route can require
+const (
+	AuthNone     = "none"
+	AuthOptional = "optional" // claims are forwarded when a valid token is present
+	AuthRequired = "required"
+	AuthInternal = "internal" // service credential (X-Internal-API-Key) verified by the gateway
+)
+
+// RateLimitNone disables rate limiting for a route
+const RateLimitNone = "none"
+
+// Table is the declarative list of routes exposed by the gateway.
+// Routes are organised in groups sharing a path prefix and defaults;
+// every field set on a route overrides the group value.
+type Table struct {
+	Groups []Group `yaml:"groups" json:"groups"`
+}
+
+type Group struct {
+	Prefix      string   `yaml:"prefix" json:"prefix"`
+	Service     string   `yaml:"service" json:"service"`
+	Auth        string   `yaml:"auth" json:"auth"`
+	Roles       []string `yaml:"roles" json:"roles"`
+	Permissions []string `yaml:"permissions" json:"permissions"`
+	RateLimit   string   `yaml:"rate_limit" json:"rate_limit"`
+	Routes      []Route  `yaml:"routes" json:"routes"`
+}
+
+type Route struct {
+	Path        string   `yaml:"path" json:"path"`
+	Methods     []string `yaml:"methods" json:"methods"`
+	Service     string   `yaml:"service" json:"service"`
+	Auth        string   `yaml:"auth" json:"auth"`
+	Roles       []string `yaml:"roles" json:"roles"`
+	Permissions []string `yaml:"permissions" json:"permissions"`
+	RateLimit   string   `yaml:"rate_limit" json:"rate_limit"`
+}
+
+// Endpoint is a fully resolved route: one method on one path
+type Endpoint struct {
+	Method      string
+	Path        string
+	Service     string
+	Auth        string
+	Roles       []string
+	Permissions []string // any one of them grants access
+	RateLimit   string
+}
+
+// Load reads a route table from a .yaml/.yml or .json file
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read route table: %w", err)
+	}
+
+	var table Table
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &table)
+	default:
+		err = yaml.Unmarshal(data, &table)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse route table %s: %w", path, err)
+	}
+
+	return &table, nil
+}
+
+// Endpoints flattens the groups, applying group defaults
+func (t *Table) Endpoints() []Endpoint {
+	var endpoints []Endpoint
+	for _, g := range t.Groups {
+		for _, r := range g.Routes {
+			ep := Endpoint{
+				Path:        joinPath(g.Prefix, r.Path),
+				Service:     firstNonEmpty(r.Service, g.Service),
+				Auth:        firstNonEmpty(r.Auth, g.Auth, AuthNone),
+				RateLimit:   firstNonEmpty(r.RateLimit, g.RateLimit),
+				Roles:       g.Roles,
+				Permissions: g.Permissions,
+			}
+			if r.Roles != nil {
+				ep.Roles = r.Roles
+			}
+			if r.Permissions != nil {
+				ep.Permissions = r.Permissions
+			}
+			for _, method := range r.Methods {
+				ep.Method = strings.ToUpper(method)
+				endpoints = append(endpoints, ep)
+			}
+		}
+	}
+	return endpoints
+}
+
+// permissionPattern matches "<resource>:<action>" names such as course:publish
+var permissionPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*:[a-z][a-z0-9_]*$`)
+
+var validMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Validate checks every endpoint against the known services and rate limit classes.
+// All problems are reported at once.
+func (t *Table) Validate(services []string, rateLimitClasses []string) error {
+	knownServices := toSet(services)
+	knownClasses := toSet(rateLimitClasses)
+	knownClasses[RateLimitNone] = true
+
+	var problems []string
+	seen := make(map[string]bool)
+	for _, ep := range t.Endpoints() {
+		where := ep.Method + " " + ep.Path
+
+		if !strings.HasPrefix(ep.Path, "/") {
+			problems = append(problems, fmt.Sprintf("%s: path must start with /", where))
+		}
+		if !validMethods[ep.Method] {
+			problems = append(problems, fmt.Sprintf("%s: unsupported method", where))
+		}
+		if !knownServices[ep.Service] {
+			problems = append(problems, fmt.Sprintf("%s: unknown service %q", where, ep.Service))
+		}
+		switch ep.Auth {
+		case AuthNone, AuthOptional, AuthRequired, AuthInternal:
+		default:
+			problems = append(problems, fmt.Sprintf("%s: auth must be none, optional, required or internal, got %q", where, ep.Auth))
+		}
+		if IsInternalPath(ep.Path) && ep.Auth != AuthInternal {
+			problems = append(problems, fmt.Sprintf("%s: /internal/ routes must use auth: internal", where))
+		}
+		if len(ep.Roles) > 0 && ep.Auth != AuthRequired {
+			problems = append(problems, fmt.Sprintf("%s: roles require auth: required", where))
+		}
+		if len(ep.Permissions) > 0 && ep.Auth != AuthRequired {
+			problems = append(problems, fmt.Sprintf("%s: permissions require auth: required", where))
+		}
+		for _, permission := range ep.Permissions {
+			if !permissionPattern.MatchString(permission) {
+				problems = append(problems, fmt.Sprintf("%s: permission %q must look like resource:action", where, permission))
+			}
+		}
+		if ep.RateLimit != "" && !knownClasses[ep.RateLimit] {
+			problems = append(problems, fmt.Sprintf("%s: unknown rate limit class %q", where, ep.RateLimit))
+		}
+		if seen[where] {
+			problems = append(problems, fmt.Sprintf("%s: declared more than once", where))
+		}
+		seen[where] = true
+	}
+
+	if len(seen) == 0 {
+		problems = append(problems, "route table declares no routes")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid route table:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// IsInternalPath reports whether path belongs to a service's internal group,
+// i.e. has an "internal" segment such as /api/v1/user/internal/...
+func IsInternalPath(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + path
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,48 @@
+package routetable
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateInternalRoutes(t *testing.T) {
+	table := func(auth string) *Table {
+		return &Table{Groups: []Group{{
+			Prefix:  "/api/v1/user",
+			Service: "user",
+			Routes: []Route{
+				{Path: "/internal/profile/:id", Methods: []string{"GET"}, Auth: auth},
+			},
+		}}}
+	}
+
+	for _, auth := range []string{"", AuthNone, AuthOptional, AuthRequired} {
+		err := table(auth).Validate([]string{"user"}, nil)
+		if err == nil {
+			t.Errorf("auth %q: internal route accepted", auth)
+			continue
+		}
+		if !strings.Contains(err.Error(), "/internal/ routes must use auth: internal") {
+			t.Errorf("auth %q: unexpected error: %v", auth, err)
+		}
+	}
+
+	if err := table(AuthInternal).Validate([]string{"user"}, nil); err != nil {
+		t.Errorf("auth internal: %v", err)
+	}
+}
+
+func TestIsInternalPath(t *testing.T) {
+	tests := map[string]bool{
+		"/api/v1/user/internal/profile": true,
+		"/internal/health":              true,
+		"/api/v1/user/internals":        false,
+		"/api/v1/courses/international": false,
+		"/api/v1/user/profile":          false,
+	}
+	for path, want := range tests {
+		if got := IsInternalPath(path); got != want {
+			t.Errorf("IsInternalPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+-- ============================================
+-- Migration 018: Add refresh token families
+-- ============================================
+-- Purpose: Rotate refresh tokens on every use and detect reuse of rotated tokens
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+-- Every token issued from one login shares a family_id; replaced_by links
+-- a rotated token to its successor.
+ALTER TABLE refresh_tokens
+ADD COLUMN IF NOT EXISTS family_id UUID;
+
+ALTER TABLE refresh_tokens
+ADD COLUMN IF NOT EXISTS replaced_by UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL;
+
+-- Existing tokens each start their own family
+UPDATE refresh_tokens SET family_id = id WHERE family_id IS NULL;
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+
+COMMENT ON COLUMN refresh_tokens.family_id IS 'Shared by every token rotated from the same login';
+COMMENT ON COLUMN refresh_tokens.replaced_by IS 'Token issued when this one was rotated';
@@ -0,0 +1,46 @@
+-- ============================================
+-- Migration 019: Add two-factor authentication
+-- ============================================
+-- Purpose: TOTP enrollment, one-time recovery codes and per-role 2FA policy
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+-- ============================================
+-- USER_MFA TABLE
+-- ============================================
+-- One TOTP authenticator per user; enabled_at stays NULL until the first
+-- code is verified
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret_encrypted TEXT NOT NULL,
+    enabled_at TIMESTAMP,
+    last_used_step BIGINT, -- last accepted TOTP time step, blocks code replay
+    
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ============================================
+-- MFA_RECOVERY_CODES TABLE
+-- ============================================
+-- Hashed single-use codes for when the authenticator is unavailable
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id) WHERE used_at IS NULL;
+
+-- ============================================
+-- ROLE POLICY
+-- ============================================
+ALTER TABLE roles
+ADD COLUMN IF NOT EXISTS require_mfa BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN roles.require_mfa IS 'Users with this role must complete 2FA to sign in';
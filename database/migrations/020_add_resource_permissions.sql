@@ -0,0 +1,47 @@
+-- ============================================
+-- Migration 020: Resource permissions
+-- ============================================
+-- Purpose: Replace the unused seed permissions with resource:action names
+--          that services enforce, and grant them to the default roles
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+-- The original seed permissions were never checked anywhere
+DELETE FROM permissions WHERE name IN (
+    'view_courses', 'enroll_course', 'submit_exercise', 'view_own_progress',
+    'manage_courses', 'manage_exercises', 'view_student_progress',
+    'manage_users', 'manage_system', 'view_analytics'
+);
+
+-- name is always "<resource>:<action>"
+INSERT INTO permissions (name, resource, action, description) VALUES
+('course:create', 'course', 'create', 'Tạo khóa học'),
+('course:update', 'course', 'update', 'Cập nhật khóa học'),
+('course:publish', 'course', 'publish', 'Xuất bản khóa học'),
+('course:delete', 'course', 'delete', 'Xóa khóa học'),
+('course:manage_content', 'course', 'manage_content', 'Quản lý chương, bài học và video'),
+('video:sync', 'video', 'sync', 'Đồng bộ thời lượng video'),
+('exercise:manage', 'exercise', 'manage', 'Quản lý bài tập và ngân hàng câu hỏi'),
+('notification:send', 'notification', 'send', 'Gửi thông báo cho người dùng'),
+('user:manage', 'user', 'manage', 'Quản lý người dùng'),
+('role:manage', 'role', 'manage', 'Quản lý vai trò và phân quyền')
+ON CONFLICT (name) DO NOTHING;
+
+-- Instructors manage their own content
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'instructor'
+  AND p.name IN ('course:create', 'course:update', 'course:publish', 'course:manage_content',
+                 'exercise:manage', 'notification:send')
+ON CONFLICT (role_id, permission_id) DO NOTHING;
+
+-- Admins get every permission
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
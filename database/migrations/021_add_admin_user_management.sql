@@ -0,0 +1,12 @@
+-- ============================================
+-- Migration 021: Admin user management
+-- ============================================
+-- Purpose: Let admins force a password reset; the flag blocks password
+--          login until the user sets a new password
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_reset_required BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,51 @@
+-- ============================================
+-- Migration 022: Audit log queries and retention
+-- ============================================
+-- Purpose: Index audit_logs for the admin audit API and the user's security
+--          activity view, add the archive table that the retention job
+--          moves old rows into, and add the audit:read permission
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+-- Per-user history, newest first (security activity, admin filter by user)
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_created ON audit_logs(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_ip_address ON audit_logs(ip_address);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_event_status ON audit_logs(event_status);
+
+-- Rows older than AUDIT_LOG_RETENTION are moved here. No foreign key on
+-- user_id so the history outlives the account.
+CREATE TABLE IF NOT EXISTS audit_logs_archive (
+    id BIGINT PRIMARY KEY,
+    user_id UUID,
+    
+    event_type VARCHAR(50) NOT NULL,
+    event_status VARCHAR(20) NOT NULL,
+    
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    device_info JSONB,
+    
+    metadata JSONB,
+    error_message TEXT,
+    
+    created_at TIMESTAMP,
+    archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_archive_user_created ON audit_logs_archive(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_archive_created_at ON audit_logs_archive(created_at);
+
+COMMENT ON TABLE audit_logs_archive IS 'Audit log đã quá thời hạn lưu trữ, chuyển từ audit_logs';
+
+INSERT INTO permissions (name, resource, action, description) VALUES
+('audit:read', 'audit', 'read', 'Xem và xuất nhật ký audit')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'audit:read'
+ON CONFLICT (role_id, permission_id) DO NOTHING;
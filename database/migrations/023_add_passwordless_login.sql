@@ -0,0 +1,49 @@
+-- ============================================
+-- Migration 023: Passwordless login
+-- ============================================
+-- Purpose: One-time email codes and magic links that sign a user in
+--          without a password (also for OAuth-only accounts)
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+-- One pending code per user; requesting a new one replaces it
+CREATE TABLE IF NOT EXISTS login_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(255) NOT NULL,  -- SHA-256 of the 6-digit code
+    token_hash VARCHAR(255) NOT NULL, -- SHA-256 of the magic link token
+    attempts INT NOT NULL DEFAULT 0,  -- wrong codes entered
+    
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_codes_user_id ON login_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_login_codes_token_hash ON login_codes(token_hash);
+
+COMMENT ON TABLE login_codes IS 'Mã đăng nhập một lần và magic link gửi qua email';
+
+CREATE OR REPLACE FUNCTION cleanup_expired_tokens()
+RETURNS void AS $$
+BEGIN
+    -- Delete expired refresh tokens
+    DELETE FROM refresh_tokens WHERE expires_at < CURRENT_TIMESTAMP;
+    
+    -- Delete used/expired password reset tokens
+    DELETE FROM password_reset_tokens 
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete verified/expired email verification tokens
+    DELETE FROM email_verification_tokens 
+    WHERE expires_at < CURRENT_TIMESTAMP OR verified_at IS NOT NULL;
+    
+    -- Delete used/expired passwordless login codes
+    DELETE FROM login_codes
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+END;
+$$ LANGUAGE plpgsql;
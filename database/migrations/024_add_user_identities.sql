@@ -0,0 +1,47 @@
+-- ============================================
+-- Migration 024: Linked identities
+-- ============================================
+-- Purpose: Replace users.google_id with a table of external identities so
+--          users can sign in with any configured OAuth/OIDC provider and
+--          link several providers to one account
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,  -- google, microsoft, ...
+    subject VARCHAR(255) NOT NULL,  -- the provider's stable user ID ("sub")
+    email VARCHAR(255),             -- email reported by the provider when linked
+    
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    
+    UNIQUE(provider, subject),
+    UNIQUE(user_id, provider)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+COMMENT ON TABLE user_identities IS 'Tài khoản bên ngoài (Google, Microsoft, OIDC) liên kết với user';
+
+-- Google's "sub" is the same ID the userinfo API returned
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'users' AND column_name = 'google_id'
+    ) THEN
+        INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+        SELECT id, 'google', google_id, email, updated_at
+        FROM users
+        WHERE google_id IS NOT NULL AND deleted_at IS NULL
+        ON CONFLICT DO NOTHING;
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS idx_users_google_id_unique;
+ALTER TABLE users DROP COLUMN IF EXISTS google_id;
@@ -0,0 +1,75 @@
+-- ============================================
+-- Migration 025: Account deletion and data export
+-- ============================================
+-- Purpose: Self-service account deletion with a grace period, run as a
+--          saga across the user, course, exercise and notification
+--          services, and personal-data export jobs
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+-- One request per deletion; no foreign key on user_id so the record of
+-- the erasure outlives the account
+CREATE TABLE IF NOT EXISTS account_deletion_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled', -- scheduled, cancelled, in_progress, completed, failed
+
+    scheduled_for TIMESTAMP NOT NULL, -- end of the grace period
+    locked_until TIMESTAMP,           -- claimed by a worker until then
+
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    cancelled_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one open request per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletion_requests_open
+    ON account_deletion_requests(user_id) WHERE status IN ('scheduled', 'in_progress');
+CREATE INDEX IF NOT EXISTS idx_account_deletion_requests_due
+    ON account_deletion_requests(scheduled_for) WHERE status IN ('scheduled', 'in_progress');
+
+-- One step per service; run in order, retried until max attempts
+CREATE TABLE IF NOT EXISTS account_deletion_steps (
+    request_id UUID NOT NULL REFERENCES account_deletion_requests(id) ON DELETE CASCADE,
+    service VARCHAR(50) NOT NULL, -- notification, exercise, course, user, auth
+    step_order INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, completed, failed
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    result JSONB, -- rows the service deleted/anonymized
+
+    completed_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (request_id, service)
+);
+
+CREATE TABLE IF NOT EXISTS data_export_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, processing, completed, failed, expired
+
+    file_path TEXT, -- ZIP in DATA_EXPORT_DIR
+    file_size BIGINT,
+    error_message TEXT,
+    locked_until TIMESTAMP,
+
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP -- download available until then
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_export_jobs_user_requested ON data_export_jobs(user_id, requested_at DESC);
+
+-- At most one unfinished export per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_export_jobs_open
+    ON data_export_jobs(user_id) WHERE status IN ('pending', 'processing');
+
+COMMENT ON TABLE account_deletion_requests IS 'Yêu cầu xóa tài khoản, thực hiện sau thời gian chờ';
+COMMENT ON TABLE account_deletion_steps IS 'Trạng thái xóa dữ liệu ở từng service của một yêu cầu xóa tài khoản';
+COMMENT ON TABLE data_export_jobs IS 'Yêu cầu xuất dữ liệu cá nhân (file ZIP JSON)';
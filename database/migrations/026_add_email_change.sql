@@ -0,0 +1,68 @@
+-- ============================================
+-- Migration 026: Verified email change
+-- ============================================
+-- Purpose: Pending email changes confirmed with a code sent to the new
+--          address, and a copy of the email on the user-service profile
+--          that auth-service keeps in sync
+-- Date: 2025-01-XX
+-- Affects: auth_db, user_db
+-- ============================================
+
+\c auth_db;
+
+-- One pending change per user; requesting a new one replaces it
+CREATE TABLE IF NOT EXISTS email_change_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    code_hash VARCHAR(255) NOT NULL, -- SHA-256 of the 6-digit code
+    attempts INT NOT NULL DEFAULT 0, -- wrong codes entered
+    
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_change_requests_user_id ON email_change_requests(user_id);
+
+COMMENT ON TABLE email_change_requests IS 'Yêu cầu đổi email, xác nhận bằng mã gửi tới địa chỉ mới';
+
+CREATE OR REPLACE FUNCTION cleanup_expired_tokens()
+RETURNS void AS $$
+BEGIN
+    -- Delete expired refresh tokens
+    DELETE FROM refresh_tokens WHERE expires_at < CURRENT_TIMESTAMP;
+    
+    -- Delete used/expired password reset tokens
+    DELETE FROM password_reset_tokens 
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete verified/expired email verification tokens
+    DELETE FROM email_verification_tokens 
+    WHERE expires_at < CURRENT_TIMESTAMP OR verified_at IS NOT NULL;
+    
+    -- Delete used/expired passwordless login codes
+    DELETE FROM login_codes
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete used/expired email change codes
+    DELETE FROM email_change_requests
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+\c user_db;
+
+-- Copy of users.email; set when the profile is created and updated by
+-- auth-service after a confirmed email change
+ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS email VARCHAR(255);
+
+-- Backfill existing profiles from auth_db (dblink, see migration 012)
+UPDATE user_profiles up
+SET email = au.email
+FROM dblink(
+    'dbname=auth_db user=ielts_admin password=ielts_password_2025',
+    'SELECT id, email FROM users WHERE deleted_at IS NULL'
+) AS au(id uuid, email text)
+WHERE up.user_id = au.id AND up.email IS NULL;
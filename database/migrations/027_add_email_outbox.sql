@@ -0,0 +1,63 @@
+-- ============================================
+-- Migration 027: Email outbox
+-- ============================================
+-- Purpose: Queue outgoing emails so requests no longer wait on SMTP; a
+--          background sender retries failures with backoff and parks
+--          messages that keep failing as dead
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_email VARCHAR(255) NOT NULL,
+    template VARCHAR(50) NOT NULL, -- password_reset, email_verification, login_code, ...
+    locale VARCHAR(5), -- resolved from the user's preferences on the first attempt
+    data JSONB, -- template data; cleared once sent since it holds codes
+
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, sent, dead
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP,
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_due
+    ON email_outbox(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_email_outbox_status_created ON email_outbox(status, created_at DESC);
+
+COMMENT ON TABLE email_outbox IS 'Hàng đợi email gửi đi, gửi lại khi lỗi và đánh dấu dead sau nhiều lần thất bại';
+
+CREATE OR REPLACE FUNCTION cleanup_expired_tokens()
+RETURNS void AS $$
+BEGIN
+    -- Delete expired refresh tokens
+    DELETE FROM refresh_tokens WHERE expires_at < CURRENT_TIMESTAMP;
+    
+    -- Delete used/expired password reset tokens
+    DELETE FROM password_reset_tokens 
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete verified/expired email verification tokens
+    DELETE FROM email_verification_tokens 
+    WHERE expires_at < CURRENT_TIMESTAMP OR verified_at IS NOT NULL;
+    
+    -- Delete used/expired passwordless login codes
+    DELETE FROM login_codes
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete used/expired email change codes
+    DELETE FROM email_change_requests
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete sent emails after a week; dead ones stay for admins to retry
+    DELETE FROM email_outbox
+    WHERE status = 'sent' AND sent_at < CURRENT_TIMESTAMP - INTERVAL '7 days';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,28 @@
+-- ============================================
+-- Migration 028: Password history
+-- ============================================
+-- Purpose: Keep the hashes of each user's recent passwords so the password
+--          policy can refuse reusing them
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL, -- bcrypt, as in users.password_hash
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_history_user_created ON password_history(user_id, created_at DESC);
+
+-- Start every history with the current password
+INSERT INTO password_history (user_id, password_hash, created_at)
+SELECT u.id, u.password_hash, COALESCE(u.updated_at, u.created_at, CURRENT_TIMESTAMP)
+FROM users u
+WHERE u.password_hash IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM password_history h WHERE h.user_id = u.id);
+
+COMMENT ON TABLE password_history IS 'Hash các mật khẩu gần đây của người dùng, chặn dùng lại mật khẩu cũ';
@@ -0,0 +1,59 @@
+-- ============================================
+-- Migration 029: Login history
+-- ============================================
+-- Purpose: Remember the device, network and location of each successful
+--          login so new devices, new countries and impossible travel can be
+--          detected and the user alerted
+-- Date: 2025-01-XX
+-- Affects: auth_db
+-- ============================================
+
+\c auth_db;
+
+CREATE TABLE IF NOT EXISTS login_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_fingerprint VARCHAR(64) NOT NULL, -- SHA-256 of the client device ID or browser and OS
+    ip_address VARCHAR(45),
+    ip_network VARCHAR(50), -- /24 (IPv4) or /48 (IPv6)
+    country_code CHAR(2), -- from the GeoIP database, NULL when unknown
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_history_user_created ON login_history(user_id, created_at DESC);
+
+CREATE OR REPLACE FUNCTION cleanup_expired_tokens()
+RETURNS void AS $$
+BEGIN
+    -- Delete expired refresh tokens
+    DELETE FROM refresh_tokens WHERE expires_at < CURRENT_TIMESTAMP;
+    
+    -- Delete used/expired password reset tokens
+    DELETE FROM password_reset_tokens 
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete verified/expired email verification tokens
+    DELETE FROM email_verification_tokens 
+    WHERE expires_at < CURRENT_TIMESTAMP OR verified_at IS NOT NULL;
+    
+    -- Delete used/expired passwordless login codes
+    DELETE FROM login_codes
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete used/expired email change codes
+    DELETE FROM email_change_requests
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete sent emails after a week; dead ones stay for admins to retry
+    DELETE FROM email_outbox
+    WHERE status = 'sent' AND sent_at < CURRENT_TIMESTAMP - INTERVAL '7 days';
+    
+    -- Login risk checks look back at most LOGIN_HISTORY_WINDOW (up to 180 days)
+    DELETE FROM login_history
+    WHERE created_at < CURRENT_TIMESTAMP - INTERVAL '180 days';
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON TABLE login_history IS 'Lịch sử đăng nhập thành công (thiết bị, mạng, quốc gia) dùng để phát hiện đăng nhập bất thường';
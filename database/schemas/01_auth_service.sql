@@ -20,8 +20,8 @@ CREATE TABLE users (
     password_hash VARCHAR(255),
     phone VARCHAR(20),
     
-    -- OAuth fields
-    google_id VARCHAR(255),
+    -- Provider the account was created with (NULL for email sign-up);
+    -- linked identities are in user_identities
     oauth_provider VARCHAR(50),
     
     -- Account status
@@ -34,6 +34,7 @@ CREATE TABLE users (
     locked_until TIMESTAMP,
     last_login_at TIMESTAMP,
     last_login_ip VARCHAR(45),
+    password_reset_required BOOLEAN NOT NULL DEFAULT false, -- set by admins, blocks password login
     
     -- Timestamps
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -44,11 +45,31 @@ CREATE TABLE users (
 -- Indexes for users table
 CREATE UNIQUE INDEX idx_users_email_unique ON users(email) WHERE deleted_at IS NULL;
 CREATE UNIQUE INDEX idx_users_phone_unique ON users(phone) WHERE phone IS NOT NULL AND deleted_at IS NULL;
-CREATE UNIQUE INDEX idx_users_google_id_unique ON users(google_id) WHERE google_id IS NOT NULL AND deleted_at IS NULL;
 CREATE INDEX idx_users_active ON users(is_active) WHERE deleted_at IS NULL;
 CREATE INDEX idx_users_created_at ON users(created_at) WHERE deleted_at IS NULL;
 CREATE INDEX idx_users_oauth_provider ON users(oauth_provider) WHERE deleted_at IS NULL;
 
+-- ============================================
+-- USER_IDENTITIES TABLE
+-- ============================================
+-- External OAuth/OIDC identities linked to a user (one per provider)
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,  -- google, microsoft, ...
+    subject VARCHAR(255) NOT NULL,  -- the provider's stable user ID ("sub")
+    email VARCHAR(255),             -- email reported by the provider when linked
+    
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    
+    UNIQUE(provider, subject),
+    UNIQUE(user_id, provider)
+);
+
+-- Indexes
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
 -- ============================================
 -- ROLES TABLE
 -- ============================================
@@ -58,6 +79,7 @@ CREATE TABLE roles (
     name VARCHAR(50) UNIQUE NOT NULL, -- student, instructor, admin
     display_name VARCHAR(100) NOT NULL,
     description TEXT,
+    require_mfa BOOLEAN NOT NULL DEFAULT false, -- users with this role must complete 2FA
     
     -- Timestamps
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -77,30 +99,26 @@ INSERT INTO roles (name, display_name, description) VALUES
 CREATE TABLE permissions (
     id SERIAL PRIMARY KEY,
     name VARCHAR(100) UNIQUE NOT NULL,
-    resource VARCHAR(50) NOT NULL, -- courses, exercises, users, etc.
+    resource VARCHAR(50) NOT NULL, -- course, exercise, user, etc.
     action VARCHAR(50) NOT NULL, -- create, read, update, delete
     description TEXT,
     
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
--- Insert default permissions
+-- Insert default permissions (name is always "<resource>:<action>")
 INSERT INTO permissions (name, resource, action, description) VALUES
--- Student permissions
-('view_courses', 'courses', 'read', 'Xem các khóa học'),
-('enroll_course', 'courses', 'create', 'Đăng ký khóa học'),
-('submit_exercise', 'exercises', 'create', 'Nộp bài tập'),
-('view_own_progress', 'progress', 'read', 'Xem tiến trình của chính mình'),
-
--- Instructor permissions
-('manage_courses', 'courses', 'manage', 'Quản lý khóa học'),
-('manage_exercises', 'exercises', 'manage', 'Quản lý bài tập'),
-('view_student_progress', 'progress', 'read', 'Xem tiến trình học viên'),
-
--- Admin permissions
-('manage_users', 'users', 'manage', 'Quản lý người dùng'),
-('manage_system', 'system', 'manage', 'Quản lý hệ thống'),
-('view_analytics', 'analytics', 'read', 'Xem thống kê toàn hệ thống');
+('course:create', 'course', 'create', 'Tạo khóa học'),
+('course:update', 'course', 'update', 'Cập nhật khóa học'),
+('course:publish', 'course', 'publish', 'Xuất bản khóa học'),
+('course:delete', 'course', 'delete', 'Xóa khóa học'),
+('course:manage_content', 'course', 'manage_content', 'Quản lý chương, bài học và video'),
+('video:sync', 'video', 'sync', 'Đồng bộ thời lượng video'),
+('exercise:manage', 'exercise', 'manage', 'Quản lý bài tập và ngân hàng câu hỏi'),
+('notification:send', 'notification', 'send', 'Gửi thông báo cho người dùng'),
+('user:manage', 'user', 'manage', 'Quản lý người dùng'),
+('role:manage', 'role', 'manage', 'Quản lý vai trò và phân quyền'),
+('audit:read', 'audit', 'read', 'Xem và xuất nhật ký audit');
 
 -- ============================================
 -- USER_ROLES TABLE
@@ -138,12 +156,10 @@ CREATE TABLE role_permissions (
 
 -- Assign permissions to roles
 INSERT INTO role_permissions (role_id, permission_id) VALUES
--- Student role
-(1, 1), (1, 2), (1, 3), (1, 4),
--- Instructor role
-(2, 1), (2, 2), (2, 3), (2, 4), (2, 5), (2, 6), (2, 7),
+-- Instructor role (own content)
+(2, 1), (2, 2), (2, 3), (2, 5), (2, 7), (2, 8),
 -- Admin role (all permissions)
-(3, 1), (3, 2), (3, 3), (3, 4), (3, 5), (3, 6), (3, 7), (3, 8), (3, 9), (3, 10);
+(3, 1), (3, 2), (3, 3), (3, 4), (3, 5), (3, 6), (3, 7), (3, 8), (3, 9), (3, 10), (3, 11);
 
 -- ============================================
 -- REFRESH_TOKENS TABLE
@@ -161,6 +177,10 @@ CREATE TABLE refresh_tokens (
     user_agent TEXT,
     ip_address VARCHAR(45),
     
+    -- Rotation: tokens issued from one login share a family
+    family_id UUID NOT NULL,
+    replaced_by UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL,
+    
     -- Token lifecycle
     expires_at TIMESTAMP NOT NULL,
     revoked_at TIMESTAMP,
@@ -176,6 +196,7 @@ CREATE TABLE refresh_tokens (
 CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
 CREATE INDEX idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
 CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
 
 -- ============================================
 -- PASSWORD_RESET_TOKENS TABLE
@@ -219,6 +240,111 @@ CREATE INDEX idx_email_verification_user_id ON email_verification_tokens(user_id
 CREATE INDEX idx_email_verification_token_hash ON email_verification_tokens(token_hash);
 CREATE INDEX idx_email_verification_code ON email_verification_tokens(code) WHERE verified_at IS NULL;
 
+-- ============================================
+-- LOGIN_CODES TABLE
+-- ============================================
+-- One-time passwordless login codes and magic links (one pending per user)
+CREATE TABLE login_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(255) NOT NULL,  -- SHA-256 of the 6-digit code
+    token_hash VARCHAR(255) NOT NULL, -- SHA-256 of the magic link token
+    attempts INT NOT NULL DEFAULT 0,  -- wrong codes entered
+    
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX idx_login_codes_user_id ON login_codes(user_id);
+CREATE INDEX idx_login_codes_token_hash ON login_codes(token_hash);
+
+-- ============================================
+-- EMAIL_CHANGE_REQUESTS TABLE
+-- ============================================
+-- Pending email changes, confirmed with a code sent to the new address
+-- (one pending per user)
+CREATE TABLE email_change_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    new_email VARCHAR(255) NOT NULL,
+    code_hash VARCHAR(255) NOT NULL, -- SHA-256 of the 6-digit code
+    attempts INT NOT NULL DEFAULT 0, -- wrong codes entered
+    
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX idx_email_change_requests_user_id ON email_change_requests(user_id);
+
+-- ============================================
+-- PASSWORD_HISTORY TABLE
+-- ============================================
+-- Recent password hashes; the password policy refuses reusing them
+CREATE TABLE password_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash VARCHAR(255) NOT NULL, -- bcrypt, as in users.password_hash
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX idx_password_history_user_created ON password_history(user_id, created_at DESC);
+
+-- ============================================
+-- LOGIN_HISTORY TABLE
+-- ============================================
+-- Device, network and location of successful logins, compared against by
+-- the login risk checks
+CREATE TABLE login_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    device_fingerprint VARCHAR(64) NOT NULL, -- SHA-256 of the client device ID or browser and OS
+    ip_address VARCHAR(45),
+    ip_network VARCHAR(50), -- /24 (IPv4) or /48 (IPv6)
+    country_code CHAR(2), -- from the GeoIP database, NULL when unknown
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX idx_login_history_user_created ON login_history(user_id, created_at DESC);
+
+-- ============================================
+-- USER_MFA TABLE
+-- ============================================
+-- One TOTP authenticator per user; enabled_at stays NULL until the first
+-- code is verified
+CREATE TABLE user_mfa (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret_encrypted TEXT NOT NULL,
+    enabled_at TIMESTAMP,
+    last_used_step BIGINT, -- last accepted TOTP time step, blocks code replay
+    
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ============================================
+-- MFA_RECOVERY_CODES TABLE
+-- ============================================
+-- Hashed single-use codes for when the authenticator is unavailable
+CREATE TABLE mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id) WHERE used_at IS NULL;
+
 -- ============================================
 -- AUDIT_LOGS TABLE
 -- ============================================
@@ -244,6 +370,132 @@ CREATE TABLE audit_logs (
 CREATE INDEX idx_audit_logs_user_id ON audit_logs(user_id);
 CREATE INDEX idx_audit_logs_event_type ON audit_logs(event_type);
 CREATE INDEX idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX idx_audit_logs_user_created ON audit_logs(user_id, created_at DESC);
+CREATE INDEX idx_audit_logs_ip_address ON audit_logs(ip_address);
+CREATE INDEX idx_audit_logs_event_status ON audit_logs(event_status);
+
+-- ============================================
+-- AUDIT_LOGS_ARCHIVE TABLE
+-- ============================================
+-- Audit logs older than the retention period (moved by auth-service).
+-- No foreign key on user_id so the history outlives the account.
+CREATE TABLE audit_logs_archive (
+    id BIGINT PRIMARY KEY,
+    user_id UUID,
+    
+    event_type VARCHAR(50) NOT NULL,
+    event_status VARCHAR(20) NOT NULL,
+    
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    device_info JSONB,
+    
+    metadata JSONB,
+    error_message TEXT,
+    
+    created_at TIMESTAMP,
+    archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexes
+CREATE INDEX idx_audit_logs_archive_user_created ON audit_logs_archive(user_id, created_at DESC);
+CREATE INDEX idx_audit_logs_archive_created_at ON audit_logs_archive(created_at);
+
+-- ============================================
+-- ACCOUNT_DELETION_REQUESTS TABLE
+-- ============================================
+-- Self-service account deletions, run after the grace period.
+-- No foreign key on user_id so the record of the erasure outlives the account.
+CREATE TABLE account_deletion_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled', -- scheduled, cancelled, in_progress, completed, failed
+
+    scheduled_for TIMESTAMP NOT NULL, -- end of the grace period
+    locked_until TIMESTAMP,           -- claimed by a worker until then
+
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    cancelled_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one open request per user
+CREATE UNIQUE INDEX idx_account_deletion_requests_open
+    ON account_deletion_requests(user_id) WHERE status IN ('scheduled', 'in_progress');
+CREATE INDEX idx_account_deletion_requests_due
+    ON account_deletion_requests(scheduled_for) WHERE status IN ('scheduled', 'in_progress');
+
+-- ============================================
+-- ACCOUNT_DELETION_STEPS TABLE
+-- ============================================
+-- Per-service progress of a deletion (saga steps, run in step_order)
+CREATE TABLE account_deletion_steps (
+    request_id UUID NOT NULL REFERENCES account_deletion_requests(id) ON DELETE CASCADE,
+    service VARCHAR(50) NOT NULL, -- notification, exercise, course, user, auth
+    step_order INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, completed, failed
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    result JSONB, -- rows the service deleted/anonymized
+
+    completed_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (request_id, service)
+);
+
+-- ============================================
+-- DATA_EXPORT_JOBS TABLE
+-- ============================================
+-- Personal-data exports (ZIP of JSON from every service)
+CREATE TABLE data_export_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, processing, completed, failed, expired
+
+    file_path TEXT, -- ZIP in DATA_EXPORT_DIR
+    file_size BIGINT,
+    error_message TEXT,
+    locked_until TIMESTAMP,
+
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP -- download available until then
+);
+
+CREATE INDEX idx_data_export_jobs_user_requested ON data_export_jobs(user_id, requested_at DESC);
+
+-- At most one unfinished export per user
+CREATE UNIQUE INDEX idx_data_export_jobs_open
+    ON data_export_jobs(user_id) WHERE status IN ('pending', 'processing');
+
+-- ============================================
+-- EMAIL_OUTBOX TABLE
+-- ============================================
+-- Outgoing emails, sent in the background with retries; messages that keep
+-- failing are parked as dead until an admin retries them
+CREATE TABLE email_outbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_email VARCHAR(255) NOT NULL,
+    template VARCHAR(50) NOT NULL, -- password_reset, email_verification, login_code, ...
+    locale VARCHAR(5), -- resolved from the user's preferences on the first attempt
+    data JSONB, -- template data; cleared once sent since it holds codes
+
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, sent, dead
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_until TIMESTAMP,
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX idx_email_outbox_due ON email_outbox(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_email_outbox_status_created ON email_outbox(status, created_at DESC);
 
 -- ============================================
 -- FUNCTIONS & TRIGGERS
@@ -284,6 +536,22 @@ BEGIN
     -- Delete verified/expired email verification tokens
     DELETE FROM email_verification_tokens 
     WHERE expires_at < CURRENT_TIMESTAMP OR verified_at IS NOT NULL;
+    
+    -- Delete used/expired passwordless login codes
+    DELETE FROM login_codes
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete used/expired email change codes
+    DELETE FROM email_change_requests
+    WHERE expires_at < CURRENT_TIMESTAMP OR used_at IS NOT NULL;
+    
+    -- Delete sent emails after a week; dead ones stay for admins to retry
+    DELETE FROM email_outbox
+    WHERE status = 'sent' AND sent_at < CURRENT_TIMESTAMP - INTERVAL '7 days';
+    
+    -- Login risk checks look back at most LOGIN_HISTORY_WINDOW (up to 180 days)
+    DELETE FROM login_history
+    WHERE created_at < CURRENT_TIMESTAMP - INTERVAL '180 days';
 END;
 $$ LANGUAGE plpgsql;
 
@@ -296,3 +564,13 @@ COMMENT ON TABLE permissions IS 'Bảng phân quyền chi tiết';
 COMMENT ON TABLE user_roles IS 'Bảng quan hệ nhiều-nhiều giữa user và role';
 COMMENT ON TABLE refresh_tokens IS 'Bảng lưu refresh token cho JWT authentication';
 COMMENT ON TABLE audit_logs IS 'Bảng log các sự kiện authentication để audit';
+COMMENT ON TABLE user_identities IS 'Tài khoản bên ngoài (Google, Microsoft, OIDC) liên kết với user';
+COMMENT ON TABLE login_codes IS 'Mã đăng nhập một lần và magic link gửi qua email';
+COMMENT ON TABLE email_change_requests IS 'Yêu cầu đổi email, xác nhận bằng mã gửi tới địa chỉ mới';
+COMMENT ON TABLE password_history IS 'Hash các mật khẩu gần đây của người dùng, chặn dùng lại mật khẩu cũ';
+COMMENT ON TABLE login_history IS 'Lịch sử đăng nhập thành công (thiết bị, mạng, quốc gia) dùng để phát hiện đăng nhập bất thường';
+COMMENT ON TABLE audit_logs_archive IS 'Audit log đã quá thời hạn lưu trữ, chuyển từ audit_logs';
+COMMENT ON TABLE account_deletion_requests IS 'Yêu cầu xóa tài khoản, thực hiện sau thời gian chờ';
+COMMENT ON TABLE account_deletion_steps IS 'Trạng thái xóa dữ liệu ở từng service của một yêu cầu xóa tài khoản';
+COMMENT ON TABLE data_export_jobs IS 'Yêu cầu xuất dữ liệu cá nhân (file ZIP JSON)';
+COMMENT ON TABLE email_outbox IS 'Hàng đợi email gửi đi, gửi lại khi lỗi và đánh dấu dead sau nhiều lần thất bại';
@@ -24,6 +24,7 @@ CREATE TABLE user_profiles (
     gender VARCHAR(20), -- male, female, other
     
     -- Contact information
+    email VARCHAR(255), -- copy of users.email in auth_db, kept in sync by auth-service
     phone VARCHAR(20),
     address TEXT,
     city VARCHAR(100),
@@ -0,0 +1,47 @@
+#!/bin/bash
+
+# ============================================
+# Generate a JWT signing key for auth-service
+# ============================================
+# Usage: scripts/generate-jwt-key.sh [kid] [ed25519|rsa]
+# Writes keys/jwt/<kid>.pem (PKCS#8). See docs/ROLES_AND_PERMISSIONS.md
+# for the rotation procedure.
+# ============================================
+
+set -e
+
+SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
+PROJECT_ROOT="$(dirname "$SCRIPT_DIR")"
+KEYS_DIR="${JWT_KEYS_HOST_DIR:-$PROJECT_ROOT/keys/jwt}"
+
+KID="${1:-$(date +%Y%m%d)}"
+ALGORITHM="${2:-ed25519}"
+KEY_FILE="$KEYS_DIR/$KID.pem"
+
+if [ -e "$KEY_FILE" ]; then
+    echo "❌ $KEY_FILE already exists"
+    exit 1
+fi
+
+mkdir -p "$KEYS_DIR"
+
+case "$ALGORITHM" in
+    ed25519)
+        openssl genpkey -algorithm ed25519 -out "$KEY_FILE"
+        ;;
+    rsa)
+        openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:3072 -out "$KEY_FILE"
+        ;;
+    *)
+        echo "❌ Unknown algorithm '$ALGORITHM' (use ed25519 or rsa)"
+        exit 1
+        ;;
+esac
+chmod 600 "$KEY_FILE"
+
+echo "✅ Created $KEY_FILE"
+echo ""
+echo "It is published in the JWKS as soon as auth-service restarts."
+echo "To sign with it, set JWT_ACTIVE_KEY_ID=$KID (and JWT_KEYS_DIR=/keys/jwt in Docker)."
+echo "To retire an old key, replace its private key with the public key until tokens it signed expire:"
+echo "  openssl pkey -in $KEYS_DIR/<old-kid>.pem -pubout -out $KEYS_DIR/<old-kid>.pem.pub && mv $KEYS_DIR/<old-kid>.pem.pub $KEYS_DIR/<old-kid>.pem"
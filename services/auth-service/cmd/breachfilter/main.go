@@ -0,0 +1,114 @@
+// Command breachfilter builds the bloom filter file used to reject breached
+// passwords (PASSWORD_BREACHED_FILTER).
+//
+//	go run ./cmd/breachfilter -in common_passwords.txt -out breached.bloom
+//	go run ./cmd/breachfilter -format sha1 -in pwned-passwords-sha1.txt -out breached.bloom
+//
+// The input has one password per line, or with -format sha1 one hex SHA-1
+// hash per line as in the Have I Been Pwned download ("HASH:COUNT").
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/breached"
+)
+
+func main() {
+	in := flag.String("in", "", "input file, one password or SHA-1 hash per line")
+	out := flag.String("out", "breached.bloom", "filter file to write")
+	format := flag.String("format", "plain", "input format: plain or sha1")
+	fp := flag.Float64("fp", 0.0001, "false-positive rate")
+	flag.Parse()
+
+	if *in == "" {
+		fail("-in is required")
+	}
+	if *format != "plain" && *format != "sha1" {
+		fail("-format must be plain or sha1")
+	}
+
+	// Count first so the filter is sized for the input
+	var count uint64
+	if err := eachHash(*in, *format, func([sha1.Size]byte) { count++ }); err != nil {
+		fail("%v", err)
+	}
+
+	filter := breached.New(count, *fp)
+	if err := eachHash(*in, *format, filter.Add); err != nil {
+		fail("%v", err)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		fail("%v", err)
+	}
+	size, err := filter.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		fail("failed to write %s: %v", *out, err)
+	}
+
+	fmt.Printf("Wrote %d hashes to %s (%d bytes)\n", count, *out, size)
+}
+
+func eachHash(path, format string, add func([sha1.Size]byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		text, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		text = strings.TrimRight(text, "\r\n")
+
+		if text != "" && !strings.HasPrefix(text, "#") {
+			if format == "plain" {
+				add(sha1.Sum([]byte(text)))
+			} else {
+				hash, herr := parseSHA1(text)
+				if herr != nil {
+					return fmt.Errorf("%s:%d: %v", path, line, herr)
+				}
+				add(hash)
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// parseSHA1 reads "HASH" or "HASH:COUNT"
+func parseSHA1(text string) ([sha1.Size]byte, error) {
+	var hash [sha1.Size]byte
+	if i := strings.IndexByte(text, ':'); i >= 0 {
+		text = text[:i]
+	}
+	b, err := hex.DecodeString(strings.TrimSpace(text))
+	if err != nil || len(b) != sha1.Size {
+		return hash, fmt.Errorf("invalid SHA-1 hash %q", text)
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "breachfilter: "+format+"\n", args...)
+	os.Exit(1)
+}
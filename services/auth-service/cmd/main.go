@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -11,6 +12,9 @@ import (
 	"github.com/bisosad1501/DATN/services/auth-service/internal/routes"
 	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
 	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
+	"github.com/bisosad1501/DATN/shared/pkg/metrics"
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
@@ -24,12 +28,20 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Initialize tracing (exporter selected with OTEL_TRACES_EXPORTER)
+	shutdownTracing, err := tracing.Init("auth-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize database connection
 	db, err := database.NewPostgresConnection(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
+	metrics.RegisterDBStats(cfg.DBName, db.DB)
 
 	// Initialize Redis connection
 	redisClient, err := database.NewRedisClient(cfg)
@@ -45,35 +57,75 @@ func main() {
 	auditRepo := repository.NewAuditLogRepository(db)
 	passwordResetRepo := repository.NewPasswordResetRepository(db)
 	emailVerificationRepo := repository.NewEmailVerificationRepository(db)
-
-	// Initialize email service
-	emailService := service.NewEmailService(
-		cfg.SMTPHost,
-		cfg.SMTPPort,
-		cfg.SMTPUsername,
-		cfg.SMTPPassword,
-		cfg.SMTPFromEmail,
-		cfg.SMTPFromName,
-	)
+	mfaRepo := repository.NewMFARepository(db)
+	loginCodeRepo := repository.NewLoginCodeRepository(db)
+	emailChangeRepo := repository.NewEmailChangeRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	deletionRepo := repository.NewAccountDeletionRepository(db)
+	exportRepo := repository.NewDataExportRepository(db)
+	emailOutboxRepo := repository.NewEmailOutboxRepository(db)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(db)
+	loginHistoryRepo := repository.NewLoginHistoryRepository(db)
 
 	// Initialize service clients
 	userServiceClient := client.NewUserServiceClient(cfg.UserServiceURL, cfg.InternalAPIKey)
 
+	// Initialize email service: emails are queued in the outbox and sent in the background
+	emailSender, err := service.NewEmailSender(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize email delivery: %v", err)
+	}
+	emailService, err := service.NewEmailService(emailOutboxRepo, emailSender, userServiceClient, cfg.EmailMaxAttempts)
+	if err != nil {
+		log.Fatalf("Failed to load email templates: %v", err)
+	}
+	if cfg.EmailDelivery == "sink" {
+		log.Printf("Email sink enabled: emails are written to %s instead of being sent", cfg.EmailSinkDir)
+	}
+
+	// Load the access token signing keys
+	signingKeys, err := service.LoadSigningKeys(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
+
 	// Initialize services
-	authService := service.NewAuthService(userRepo, roleRepo, tokenRepo, auditRepo, passwordResetRepo, emailVerificationRepo, emailService, redisClient, cfg)
-	googleOAuthService := service.NewGoogleOAuthService(cfg, userRepo, roleRepo, tokenRepo, auditRepo, authService, userServiceClient)
+	authService := service.NewAuthService(userRepo, roleRepo, tokenRepo, auditRepo, passwordResetRepo, emailVerificationRepo, mfaRepo, loginCodeRepo, emailChangeRepo, deletionRepo, exportRepo, emailOutboxRepo, passwordHistoryRepo, loginHistoryRepo, emailService, redisClient, signingKeys, cfg)
+
+	// Sign-in providers: Google and any OIDC_PROVIDERS
+	var oauthProviders []service.OAuthProvider
+	for _, providerCfg := range cfg.OAuthProviders {
+		oauthProviders = append(oauthProviders, service.NewOIDCProvider(providerCfg))
+		log.Printf("OAuth provider enabled: %s (%s)", providerCfg.Name, providerCfg.Issuer)
+	}
+	oauthService := service.NewOAuthService(cfg, oauthProviders, userRepo, roleRepo, auditRepo, identityRepo, authService, redisClient, userServiceClient)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService, googleOAuthService)
+	authHandler := handlers.NewAuthHandler(authService, oauthService)
 
 	// Setup Gin router
 	if cfg.AppEnv == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	router := gin.Default()
+	router := gin.New()
+	router.Use(tracing.Middleware("auth-service"), metrics.Middleware("auth-service"), tracing.Logger(), gin.Recovery())
+	router.GET("/metrics", metrics.Handler())
+
+	// Graceful shutdown: drains in-flight requests and stops background jobs
+	lc := lifecycle.New("auth-service")
+	router.GET("/ready", lc.ReadyHandler())
+
+	// Move expired audit logs to the archive table
+	service.NewAuditRetentionService(auditRepo, cfg.AuditLogRetention, lc).StartPeriodicArchive()
+
+	// Run account deletions after their grace period and build data exports
+	service.NewAccountJobsService(authService, lc).Start()
+
+	// Send queued emails, retrying failures with backoff
+	service.NewEmailOutboxService(emailService, lc).Start()
 
 	// Setup routes
-	routes.SetupRoutes(router, authHandler, authService)
+	routes.SetupRoutes(router, authHandler, authService, cfg.InternalAPIKey)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -82,7 +134,7 @@ func main() {
 	}
 
 	log.Printf("Auth Service starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
+	if err := lc.Run(":"+port, router); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
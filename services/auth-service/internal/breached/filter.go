@@ -0,0 +1,138 @@
+// Package breached checks passwords against an offline bloom filter of
+// breached passwords.
+//
+// The filter stores SHA-1 hashes, the format Have I Been Pwned publishes, so
+// a filter built from its full download works the same as the small bundled
+// one. Membership can be a false positive at the rate the filter was built
+// for, never a false negative.
+package breached
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+//go:generate go run ../../cmd/breachfilter -in common_passwords.txt -out common_passwords.bloom
+
+// Bundled filter of common passwords, built from common_passwords.txt
+//
+//go:embed common_passwords.bloom
+var bundled []byte
+
+// fileMagic starts every filter file; the version changes with the layout
+var fileMagic = [8]byte{'B', 'P', 'W', 'B', 'L', 'M', '0', '1'}
+
+// Filter is a bloom filter of SHA-1 password hashes. File layout: magic,
+// hash count k (uint32), bit count m (uint64), then the m bits; big endian.
+type Filter struct {
+	k    uint32
+	m    uint64
+	bits []byte
+}
+
+// New sizes a filter for n hashes at false-positive rate fp
+func New(n uint64, fp float64) *Filter {
+	if n == 0 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &Filter{k: k, m: m, bits: make([]byte, (m+7)/8)}
+}
+
+// Bundled returns the filter of common passwords shipped with the service
+func Bundled() (*Filter, error) {
+	return Read(bytes.NewReader(bundled))
+}
+
+// Load reads a filter file
+func Load(path string) (*Filter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password filter: %w", err)
+	}
+	defer f.Close()
+	return Read(bufio.NewReader(f))
+}
+
+// Read decodes a filter written by WriteTo
+func Read(r io.Reader) (*Filter, error) {
+	var header struct {
+		Magic [8]byte
+		K     uint32
+		M     uint64
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read breached password filter: %w", err)
+	}
+	if header.Magic != fileMagic {
+		return nil, errors.New("not a breached password filter")
+	}
+	if header.K == 0 || header.M == 0 {
+		return nil, errors.New("breached password filter is empty")
+	}
+
+	bits := make([]byte, (header.M+7)/8)
+	if _, err := io.ReadFull(r, bits); err != nil {
+		return nil, fmt.Errorf("breached password filter is truncated: %w", err)
+	}
+	return &Filter{k: header.K, m: header.M, bits: bits}, nil
+}
+
+// WriteTo writes the filter in the format Read expects
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	header := struct {
+		Magic [8]byte
+		K     uint32
+		M     uint64
+	}{fileMagic, f.k, f.m}
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return 0, err
+	}
+	n, err := w.Write(f.bits)
+	return int64(binary.Size(header) + n), err
+}
+
+// Add records a SHA-1 password hash
+func (f *Filter) Add(hash [sha1.Size]byte) {
+	f.each(hash, func(bit uint64) bool {
+		f.bits[bit/8] |= 1 << (bit % 8)
+		return true
+	})
+}
+
+// ContainsHash reports whether the SHA-1 hash is (probably) in the filter
+func (f *Filter) ContainsHash(hash [sha1.Size]byte) bool {
+	return f.each(hash, func(bit uint64) bool {
+		return f.bits[bit/8]&(1<<(bit%8)) != 0
+	})
+}
+
+// Contains reports whether the password is (probably) breached
+func (f *Filter) Contains(password string) bool {
+	return f.ContainsHash(sha1.Sum([]byte(password)))
+}
+
+// each visits the k bits of hash by double hashing; SHA-1 output is already
+// uniform, so its first 16 bytes serve as the two base hashes
+func (f *Filter) each(hash [sha1.Size]byte, visit func(bit uint64) bool) bool {
+	h1 := binary.BigEndian.Uint64(hash[0:8])
+	h2 := binary.BigEndian.Uint64(hash[8:16]) | 1
+	for i := uint64(0); i < uint64(f.k); i++ {
+		if !visit((h1 + i*h2) % f.m) {
+			return false
+		}
+	}
+	return true
+}
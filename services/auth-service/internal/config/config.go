@@ -2,9 +2,16 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// DefaultMFAEncryptionKey lets development run without configuring a key;
+// the service refuses to start with it in any other environment
+const DefaultMFAEncryptionKey = "mfa_encryption_key_change_in_production"
+
 type Config struct {
 	// Application
 	AppEnv string
@@ -21,20 +28,60 @@ type Config struct {
 	RedisURL string
 
 	// JWT
-	JWTSecret          string
+	JWTKeysDir         string // <kid>.pem signing keys, published at /.well-known/jwks.json
+	JWTActiveKeyID     string // kid of the key that signs new tokens
 	JWTExpiry          string
 	RefreshTokenExpiry string
 	BcryptRounds       int
 
+	// A rotated refresh token presented again by the same client within this
+	// window is a concurrent refresh (two tabs), not theft; 0 disables it
+	RefreshTokenReuseGrace time.Duration
+
 	// Security
 	MaxLoginAttempts    int
 	AccountLockDuration int // minutes
 
+	// Password policy, applied on registration, change and reset
+	PasswordMinLength      int
+	PasswordMinCharClasses int    // of lowercase, uppercase, digits and symbols
+	PasswordHistorySize    int    // recent passwords that cannot be reused; 0 disables
+	PasswordBreachedCheck  bool   // reject passwords found in the breached-password filter
+	PasswordBreachedFilter string // bloom filter file; empty uses the bundled common passwords
+
+	// Login risk checks: new devices, networks and countries, impossible
+	// travel and failure bursts from one IP
+	GeoIPDatabase              string        // DB-IP Lite CSV (country or city, may be .gz); empty skips country and travel checks
+	LoginHistoryWindow         time.Duration // how far back a device or location counts as known; at most 180 days are kept
+	LoginFailureBurstThreshold int           // distinct accounts failing from one IP that mark it as attacking
+	LoginFailureBurstWindow    time.Duration
+	LoginStepUpEnabled         bool // high-risk logins without 2FA must enter a code emailed to the account
+
+	// Two-factor authentication
+	MFAIssuer          string // shown in authenticator apps
+	MFAEncryptionKey   string // encrypts TOTP secrets at rest
+	MFAChallengeExpiry string
+
+	// Passwordless login
+	LoginCodeExpiry string
+	FrontendURL     string // magic links point to <FrontendURL>/auth/magic-link
+
+	// Audit logs older than this are moved to audit_logs_archive; 0 keeps them
+	AuditLogRetention time.Duration
+
+	// Account deletion and data export
+	AccountDeletionGracePeriod time.Duration // time to cancel before the data is erased
+	DataExportDir              string        // where export ZIPs are written; shared by all instances
+	DataExportExpiry           time.Duration // how long a finished export can be downloaded
+
 	// Google OAuth
 	GoogleClientID     string
 	GoogleClientSecret string
 	GoogleRedirectURL  string
 
+	// OAuth/OIDC sign-in providers: Google when configured, then OIDC_PROVIDERS
+	OAuthProviders []OAuthProviderConfig
+
 	// SMTP Email
 	SMTPHost      string
 	SMTPPort      string
@@ -43,16 +90,87 @@ type Config struct {
 	SMTPFromEmail string
 	SMTPFromName  string
 
+	// Outbound email queue
+	EmailDelivery    string // smtp, or sink to write messages to EmailSinkDir instead of sending
+	EmailSinkDir     string
+	EmailMaxAttempts int // failed sends are retried with backoff, then dead-lettered
+
 	// Service URLs
 	UserServiceURL         string
+	CourseServiceURL       string
+	ExerciseServiceURL     string
 	NotificationServiceURL string
 	InternalAPIKey         string
 }
 
+// OAuthProviderConfig configures an OpenID Connect provider. Endpoints and
+// signing keys are discovered from <Issuer>/.well-known/openid-configuration.
+type OAuthProviderConfig struct {
+	Name         string // URL segment and identity provider name, e.g. "google"
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
 func Load() *Config {
 	bcryptRounds, _ := strconv.Atoi(getEnv("BCRYPT_ROUNDS", "12"))
 	maxLoginAttempts, _ := strconv.Atoi(getEnv("MAX_LOGIN_ATTEMPTS", "5"))
 	lockDuration, _ := strconv.Atoi(getEnv("ACCOUNT_LOCK_DURATION", "30"))
+	passwordMinLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil || passwordMinLength < 1 {
+		passwordMinLength = 8
+	}
+	passwordMinCharClasses, err := strconv.Atoi(getEnv("PASSWORD_MIN_CHAR_CLASSES", "2"))
+	if err != nil || passwordMinCharClasses < 0 || passwordMinCharClasses > 4 {
+		passwordMinCharClasses = 2
+	}
+	passwordHistorySize, err := strconv.Atoi(getEnv("PASSWORD_HISTORY_SIZE", "5"))
+	if err != nil || passwordHistorySize < 0 {
+		passwordHistorySize = 5
+	}
+	passwordBreachedCheck, err := strconv.ParseBool(getEnv("PASSWORD_BREACHED_CHECK", "true"))
+	if err != nil {
+		passwordBreachedCheck = true
+	}
+	refreshTokenReuseGrace, err := time.ParseDuration(getEnv("REFRESH_TOKEN_REUSE_GRACE", "5s"))
+	if err != nil || refreshTokenReuseGrace < 0 {
+		refreshTokenReuseGrace = 5 * time.Second
+	}
+	loginHistoryWindow, err := time.ParseDuration(getEnv("LOGIN_HISTORY_WINDOW", "2160h"))
+	if err != nil || loginHistoryWindow <= 0 {
+		loginHistoryWindow = 90 * 24 * time.Hour
+	}
+	loginFailureBurstThreshold, err := strconv.Atoi(getEnv("LOGIN_FAILURE_BURST_THRESHOLD", "10"))
+	if err != nil || loginFailureBurstThreshold < 2 {
+		loginFailureBurstThreshold = 10
+	}
+	loginFailureBurstWindow, err := time.ParseDuration(getEnv("LOGIN_FAILURE_BURST_WINDOW", "15m"))
+	if err != nil || loginFailureBurstWindow <= 0 {
+		loginFailureBurstWindow = 15 * time.Minute
+	}
+	loginStepUpEnabled, _ := strconv.ParseBool(getEnv("LOGIN_STEP_UP", "false"))
+	emailMaxAttempts, err := strconv.Atoi(getEnv("EMAIL_MAX_ATTEMPTS", "8"))
+	if err != nil || emailMaxAttempts < 1 {
+		emailMaxAttempts = 8
+	}
+	googleClientID := getEnv("GOOGLE_CLIENT_ID", "")
+	googleClientSecret := getEnv("GOOGLE_CLIENT_SECRET", "")
+	googleRedirectURL := getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/api/v1/auth/google/callback")
+	auditLogRetention, err := time.ParseDuration(getEnv("AUDIT_LOG_RETENTION", "2160h"))
+	if err != nil {
+		auditLogRetention = 90 * 24 * time.Hour
+	}
+	deletionGracePeriod, err := time.ParseDuration(getEnv("ACCOUNT_DELETION_GRACE_PERIOD", "168h"))
+	if err != nil || deletionGracePeriod < 0 {
+		deletionGracePeriod = 7 * 24 * time.Hour
+	}
+	dataExportExpiry, err := time.ParseDuration(getEnv("DATA_EXPORT_EXPIRY", "168h"))
+	if err != nil || dataExportExpiry <= 0 {
+		dataExportExpiry = 7 * 24 * time.Hour
+	}
 
 	return &Config{
 		AppEnv: getEnv("APP_ENV", "development"),
@@ -66,17 +184,47 @@ func Load() *Config {
 
 		RedisURL: getEnv("REDIS_URL", "redis://:ielts_redis_password@localhost:6379"),
 
-		JWTSecret:          getEnv("JWT_SECRET", "your_jwt_secret_key_change_in_production"),
+		JWTKeysDir:         getEnv("JWT_KEYS_DIR", ""),
+		JWTActiveKeyID:     getEnv("JWT_ACTIVE_KEY_ID", ""),
 		JWTExpiry:          getEnv("JWT_EXPIRY", "24h"),
 		RefreshTokenExpiry: getEnv("REFRESH_TOKEN_EXPIRY", "168h"),
 		BcryptRounds:       bcryptRounds,
 
+		RefreshTokenReuseGrace: refreshTokenReuseGrace,
+
 		MaxLoginAttempts:    maxLoginAttempts,
 		AccountLockDuration: lockDuration,
 
-		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
-		GoogleRedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/api/v1/auth/google/callback"),
+		PasswordMinLength:      passwordMinLength,
+		PasswordMinCharClasses: passwordMinCharClasses,
+		PasswordHistorySize:    passwordHistorySize,
+		PasswordBreachedCheck:  passwordBreachedCheck,
+		PasswordBreachedFilter: getEnv("PASSWORD_BREACHED_FILTER", ""),
+
+		GeoIPDatabase:              getEnv("GEOIP_DATABASE", ""),
+		LoginHistoryWindow:         loginHistoryWindow,
+		LoginFailureBurstThreshold: loginFailureBurstThreshold,
+		LoginFailureBurstWindow:    loginFailureBurstWindow,
+		LoginStepUpEnabled:         loginStepUpEnabled,
+
+		MFAIssuer:          getEnv("MFA_ISSUER", "IELTS Platform"),
+		MFAEncryptionKey:   getEnv("MFA_ENCRYPTION_KEY", DefaultMFAEncryptionKey),
+		MFAChallengeExpiry: getEnv("MFA_CHALLENGE_EXPIRY", "5m"),
+
+		LoginCodeExpiry: getEnv("LOGIN_CODE_EXPIRY", "10m"),
+		FrontendURL:     getEnv("FRONTEND_URL", "http://localhost:3000"),
+
+		AuditLogRetention: auditLogRetention,
+
+		AccountDeletionGracePeriod: deletionGracePeriod,
+		DataExportDir:              getEnv("DATA_EXPORT_DIR", filepath.Join(os.TempDir(), "ielts-data-exports")),
+		DataExportExpiry:           dataExportExpiry,
+
+		GoogleClientID:     googleClientID,
+		GoogleClientSecret: googleClientSecret,
+		GoogleRedirectURL:  googleRedirectURL,
+
+		OAuthProviders: loadOAuthProviders(googleClientID, googleClientSecret, googleRedirectURL),
 
 		SMTPHost:      getEnv("SMTP_HOST", "smtp.gmail.com"),
 		SMTPPort:      getEnv("SMTP_PORT", "587"),
@@ -85,12 +233,62 @@ func Load() *Config {
 		SMTPFromEmail: getEnv("SMTP_FROM_EMAIL", "noreply@ieltsplatform.com"),
 		SMTPFromName:  getEnv("SMTP_FROM_NAME", "IELTS Learning Platform"),
 
+		EmailDelivery:    strings.ToLower(getEnv("EMAIL_DELIVERY", "smtp")),
+		EmailSinkDir:     getEnv("EMAIL_SINK_DIR", filepath.Join(os.TempDir(), "ielts-email-sink")),
+		EmailMaxAttempts: emailMaxAttempts,
+
 		UserServiceURL:         getEnv("USER_SERVICE_URL", "http://user-service:8082"),
+		CourseServiceURL:       getEnv("COURSE_SERVICE_URL", "http://course-service:8083"),
+		ExerciseServiceURL:     getEnv("EXERCISE_SERVICE_URL", "http://exercise-service:8084"),
 		NotificationServiceURL: getEnv("NOTIFICATION_SERVICE_URL", "http://notification-service:8085"),
 		InternalAPIKey:         getEnv("INTERNAL_API_KEY", "internal_secret_key_ielts_2025_change_in_production"),
 	}
 }
 
+// loadOAuthProviders reads OIDC_PROVIDERS=name1,name2 and, per provider,
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and optionally _SCOPES,
+// _DISPLAY_NAME and _REDIRECT_URL (default
+// <OAUTH_REDIRECT_BASE_URL>/<name>/callback). Providers without an issuer or
+// client ID are skipped.
+func loadOAuthProviders(googleClientID, googleClientSecret, googleRedirectURL string) []OAuthProviderConfig {
+	var providers []OAuthProviderConfig
+	if googleClientID != "" {
+		providers = append(providers, OAuthProviderConfig{
+			Name:         "google",
+			DisplayName:  "Google",
+			Issuer:       "https://accounts.google.com",
+			ClientID:     googleClientID,
+			ClientSecret: googleClientSecret,
+			RedirectURL:  googleRedirectURL,
+			Scopes:       []string{"openid", "email", "profile"},
+		})
+	}
+
+	redirectBaseURL := strings.TrimSuffix(getEnv("OAUTH_REDIRECT_BASE_URL", "http://localhost:8080/api/v1/auth/oauth"), "/")
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "google" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		provider := OAuthProviderConfig{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", redirectBaseURL+"/"+name+"/callback"),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
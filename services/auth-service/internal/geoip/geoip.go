@@ -0,0 +1,209 @@
+// Package geoip resolves IP addresses to a country and coordinates from an
+// offline database, so login checks never send addresses to a third party.
+//
+// Open reads the free DB-IP Lite CSV downloads (https://db-ip.com/db/lite.php):
+// "IP to Country" gives countries only, "IP to City" also gives coordinates.
+// Other databases can be plugged in by implementing Locator.
+package geoip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Location is where an IP address is registered
+type Location struct {
+	Country        string // ISO 3166-1 alpha-2
+	Latitude       float64
+	Longitude      float64
+	HasCoordinates bool // only city-level databases have them
+}
+
+// Locator looks up IP addresses; implementations must be safe for concurrent use
+type Locator interface {
+	Lookup(ip net.IP) (Location, bool)
+}
+
+// None is used when no database is configured; every lookup misses
+var None Locator = none{}
+
+type none struct{}
+
+func (none) Lookup(net.IP) (Location, bool) { return Location{}, false }
+
+// location is Location packed for tables with millions of ranges
+type location struct {
+	country   [2]byte
+	lat, lon  float32
+	hasCoords bool
+}
+
+type v4Range struct {
+	start, end uint32
+	loc        location
+}
+
+type v6Range struct {
+	start, end [16]byte
+	loc        location
+}
+
+// table is a DB-IP range database held in memory, sorted by range start
+type table struct {
+	v4 []v4Range
+	v6 []v6Range
+}
+
+// Open loads a DB-IP Lite CSV file, gzipped when the name ends in .gz
+func Open(path string) (Locator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	t, err := ReadCSV(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// ReadCSV parses DB-IP Lite CSV rows: "start,end,country" or
+// "start,end,continent,country,region,city,latitude,longitude"
+func ReadCSV(r io.Reader) (Locator, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	t := &table{}
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, end, loc, ok, err := parseRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !ok {
+			continue
+		}
+
+		if start.Is4() && end.Is4() {
+			t.v4 = append(t.v4, v4Range{start: v4Key(start), end: v4Key(end), loc: loc})
+		} else {
+			t.v6 = append(t.v6, v6Range{start: start.As16(), end: end.As16(), loc: loc})
+		}
+	}
+	if len(t.v4)+len(t.v6) == 0 {
+		return nil, errors.New("GeoIP database has no ranges")
+	}
+
+	sort.Slice(t.v4, func(i, j int) bool { return t.v4[i].start < t.v4[j].start })
+	sort.Slice(t.v6, func(i, j int) bool { return compare16(t.v6[i].start, t.v6[j].start) < 0 })
+	return t, nil
+}
+
+// parseRecord returns ok false for ranges without a country
+func parseRecord(record []string) (start, end netip.Addr, loc location, ok bool, err error) {
+	var country string
+	switch {
+	case len(record) == 3:
+		country = record[2]
+	case len(record) >= 8:
+		country = record[3]
+	default:
+		return start, end, loc, false, fmt.Errorf("expected 3 or 8 columns, got %d", len(record))
+	}
+
+	if start, err = netip.ParseAddr(strings.TrimSpace(record[0])); err != nil {
+		return start, end, loc, false, err
+	}
+	if end, err = netip.ParseAddr(strings.TrimSpace(record[1])); err != nil {
+		return start, end, loc, false, err
+	}
+	start, end = start.Unmap(), end.Unmap()
+
+	// ZZ marks reserved and unassigned ranges
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if len(country) != 2 || country == "ZZ" {
+		return start, end, loc, false, nil
+	}
+	copy(loc.country[:], country)
+
+	if len(record) >= 8 {
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(record[6]), 32)
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(record[7]), 32)
+		if latErr == nil && lonErr == nil {
+			loc.lat, loc.lon, loc.hasCoords = float32(lat), float32(lon), true
+		}
+	}
+	return start, end, loc, true, nil
+}
+
+func (t *table) Lookup(ip net.IP) (Location, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return Location{}, false
+	}
+	addr = addr.Unmap()
+
+	var loc location
+	if addr.Is4() {
+		key := v4Key(addr)
+		i := sort.Search(len(t.v4), func(i int) bool { return t.v4[i].start > key }) - 1
+		if i < 0 || key > t.v4[i].end {
+			return Location{}, false
+		}
+		loc = t.v4[i].loc
+	} else {
+		key := addr.As16()
+		i := sort.Search(len(t.v6), func(i int) bool { return compare16(t.v6[i].start, key) > 0 }) - 1
+		if i < 0 || compare16(key, t.v6[i].end) > 0 {
+			return Location{}, false
+		}
+		loc = t.v6[i].loc
+	}
+
+	return Location{
+		Country:        string(loc.country[:]),
+		Latitude:       float64(loc.lat),
+		Longitude:      float64(loc.lon),
+		HasCoordinates: loc.hasCoords,
+	}, true
+}
+
+func v4Key(addr netip.Addr) uint32 {
+	b := addr.As4()
+	return binary.BigEndian.Uint32(b[:])
+}
+
+func compare16(a, b [16]byte) int {
+	return bytes.Compare(a[:], b[:])
+}
@@ -0,0 +1,139 @@
+package geoip
+
+import (
+	"compress/gzip"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cityCSV mixes IPv4 and IPv6 ranges, out of order, with a reserved range
+// and a gap between 1.0.1.0 and 1.0.3.255
+const cityCSV = `1.0.4.0,1.0.7.255,OC,AU,Victoria,Melbourne,-37.814,144.9633
+1.0.0.0,1.0.0.255,AS,VN,Hanoi,Hanoi,21.0245,105.841
+10.0.0.0,10.255.255.255,ZZ,ZZ,,,,
+2001:ee0::,2001:ee0:ffff:ffff:ffff:ffff:ffff:ffff,AS,VN,Ho Chi Minh,Ho Chi Minh City,10.8231,106.6297
+2a01:cb00::,2a01:cb00:ffff:ffff:ffff:ffff:ffff:ffff,EU,FR,Ile-de-France,Paris,48.8566,2.3522
+`
+
+const countryCSV = `1.0.0.0,1.0.0.255,vn
+2a01:cb00::,2a01:cb00:ffff:ffff:ffff:ffff:ffff:ffff,FR
+`
+
+func mustReadCSV(t *testing.T, data string) Locator {
+	t.Helper()
+	locator, err := ReadCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	return locator
+}
+
+func TestLookupCity(t *testing.T) {
+	locator := mustReadCSV(t, cityCSV)
+
+	tests := []struct {
+		ip      string
+		country string
+		lat     float64
+		found   bool
+	}{
+		{ip: "1.0.0.0", country: "VN", lat: 21.0245, found: true},
+		{ip: "1.0.0.255", country: "VN", lat: 21.0245, found: true},
+		{ip: "1.0.5.1", country: "AU", lat: -37.814, found: true},
+		{ip: "::ffff:1.0.0.7", country: "VN", lat: 21.0245, found: true},
+		{ip: "2001:ee0:1::1", country: "VN", lat: 10.8231, found: true},
+		{ip: "2a01:cb00::42", country: "FR", lat: 48.8566, found: true},
+		{ip: "1.0.2.1"},       // gap between ranges
+		{ip: "0.255.255.255"}, // before the first range
+		{ip: "10.1.2.3"},      // reserved
+		{ip: "2001:db8::1"},   // no IPv6 range
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			loc, found := locator.Lookup(net.ParseIP(tt.ip))
+			if found != tt.found {
+				t.Fatalf("found = %v, want %v", found, tt.found)
+			}
+			if !found {
+				return
+			}
+			if loc.Country != tt.country || !loc.HasCoordinates {
+				t.Errorf("got %+v, want %s with coordinates", loc, tt.country)
+			}
+			// Coordinates are stored as float32
+			if diff := loc.Latitude - tt.lat; diff > 1e-4 || diff < -1e-4 {
+				t.Errorf("latitude = %f, want %f", loc.Latitude, tt.lat)
+			}
+		})
+	}
+}
+
+func TestLookupCountry(t *testing.T) {
+	locator := mustReadCSV(t, countryCSV)
+
+	loc, found := locator.Lookup(net.ParseIP("1.0.0.9"))
+	if !found || loc.Country != "VN" || loc.HasCoordinates {
+		t.Errorf("got %+v %v, want VN without coordinates", loc, found)
+	}
+	if loc, found := locator.Lookup(net.ParseIP("2a01:cb00::1")); !found || loc.Country != "FR" {
+		t.Errorf("got %+v %v, want FR", loc, found)
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "empty", data: ""},
+		{name: "only reserved ranges", data: "10.0.0.0,10.255.255.255,ZZ\n"},
+		{name: "wrong column count", data: "1.0.0.0,1.0.0.255,AS,VN\n"},
+		{name: "bad address", data: "1.0.0,1.0.0.255,VN\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadCSV(strings.NewReader(tt.data)); err == nil {
+				t.Error("ReadCSV succeeded")
+			}
+		})
+	}
+}
+
+func TestOpenGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dbip-city-lite.csv.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(cityCSV)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	locator, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if loc, found := locator.Lookup(net.ParseIP("1.0.6.6")); !found || loc.Country != "AU" {
+		t.Errorf("got %+v %v, want AU", loc, found)
+	}
+
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("Open of a missing file succeeded")
+	}
+}
+
+func TestNone(t *testing.T) {
+	if _, found := None.Lookup(net.ParseIP("1.0.0.1")); found {
+		t.Error("None found a location")
+	}
+}
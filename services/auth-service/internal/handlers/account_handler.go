@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestAccountDeletion godoc
+// @Summary Delete account
+// @Description Schedule the account for deletion after the grace period (7 days by default).
+// @Description The account keeps working until then and the request can be cancelled.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.DeleteAccountRequest false "Current password (required when the account has one)"
+// @Success 202 {object} models.SuccessResponse{data=models.AccountDeletionResponse}
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/account/deletion [post]
+func (h *AuthHandler) RequestAccountDeletion(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// The body is optional for accounts without a password
+	var req models.DeleteAccountRequest
+	if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+		return
+	}
+
+	deletion, err := h.authService.RequestAccountDeletion(userID, &req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondAccountError(c, err, "Failed to request account deletion")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Success: true,
+		Data:    deletion,
+		Message: "Account scheduled for deletion",
+	})
+}
+
+// GetAccountDeletion godoc
+// @Summary Get account deletion status
+// @Description The latest deletion request and the progress of each service
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.AccountDeletionResponse}
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/account/deletion [get]
+func (h *AuthHandler) GetAccountDeletion(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	deletion, err := h.authService.GetAccountDeletion(userID)
+	if err != nil {
+		respondAccountError(c, err, "Failed to get account deletion")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    deletion,
+	})
+}
+
+// CancelAccountDeletion godoc
+// @Summary Cancel account deletion
+// @Description Keep the account; only possible during the grace period
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/account/deletion [delete]
+func (h *AuthHandler) CancelAccountDeletion(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.CancelAccountDeletion(userID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondAccountError(c, err, "Failed to cancel account deletion")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Account deletion cancelled",
+	})
+}
+
+// RequestDataExport godoc
+// @Summary Export personal data
+// @Description Queue a ZIP of everything the platform stores about the user, one JSON file per service
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} models.SuccessResponse{data=models.DataExportResponse}
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/account/export [post]
+func (h *AuthHandler) RequestDataExport(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.authService.RequestDataExport(userID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondAccountError(c, err, "Failed to request data export")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.SuccessResponse{
+		Success: true,
+		Data:    job,
+		Message: "Data export requested",
+	})
+}
+
+// GetDataExport godoc
+// @Summary Get data export status
+// @Description The latest export; download it once its status is completed
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.DataExportResponse}
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/account/export [get]
+func (h *AuthHandler) GetDataExport(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.authService.GetDataExport(userID)
+	if err != nil {
+		respondAccountError(c, err, "Failed to get data export")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// DownloadDataExport godoc
+// @Summary Download data export
+// @Tags auth
+// @Produce application/zip
+// @Security BearerAuth
+// @Param id path string true "Export ID"
+// @Success 200 {file} file
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 410 {object} models.ErrorResponse
+// @Router /auth/account/export/{id}/download [get]
+func (h *AuthHandler) DownloadDataExport(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid export ID",
+			},
+		})
+		return
+	}
+
+	path, err := h.authService.DataExportFile(userID, jobID)
+	if err != nil {
+		respondAccountError(c, err, "Failed to download data export")
+		return
+	}
+
+	c.FileAttachment(path, "ielts-data-export-"+jobID.String()+".zip")
+}
+
+// ListAccountDeletions godoc
+// @Summary List account deletions
+// @Description Deletion requests with their per-service steps, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "scheduled, cancelled, in_progress, completed or failed"
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} models.SuccessResponse{data=models.AccountDeletionListResponse}
+// @Router /auth/admin/account-deletions [get]
+func (h *AuthHandler) ListAccountDeletions(c *gin.Context) {
+	var query models.AdminAccountDeletionQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	deletions, err := h.authService.ListAccountDeletions(&query)
+	if err != nil {
+		respondAccountError(c, err, "Failed to list account deletions")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    deletions,
+	})
+}
+
+// RetryAccountDeletion godoc
+// @Summary Retry a failed account deletion
+// @Description Resume the saga from the step that failed
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Deletion request ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/admin/account-deletions/{id}/retry [post]
+func (h *AuthHandler) RetryAccountDeletion(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid deletion request ID",
+			},
+		})
+		return
+	}
+
+	if err := h.authService.RetryAccountDeletion(adminID, requestID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondAccountError(c, err, "Failed to retry account deletion")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Account deletion will be retried",
+	})
+}
+
+// respondAccountError maps account deletion and data export errors to status codes
+func respondAccountError(c *gin.Context, err error, fallback string) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, service.ErrInvalidPassword):
+		status, code = http.StatusBadRequest, "INVALID_PASSWORD"
+	case errors.Is(err, service.ErrUserNotFound):
+		status, code = http.StatusNotFound, "USER_NOT_FOUND"
+	case errors.Is(err, service.ErrAccountDeletionNotFound):
+		status, code = http.StatusNotFound, "ACCOUNT_DELETION_NOT_FOUND"
+	case errors.Is(err, service.ErrAccountDeletionExists):
+		status, code = http.StatusConflict, "ACCOUNT_DELETION_EXISTS"
+	case errors.Is(err, service.ErrAccountDeletionStarted):
+		status, code = http.StatusConflict, "ACCOUNT_DELETION_STARTED"
+	case errors.Is(err, service.ErrAccountDeletionNotFailed):
+		status, code = http.StatusConflict, "ACCOUNT_DELETION_NOT_FAILED"
+	case errors.Is(err, service.ErrDataExportNotFound):
+		status, code = http.StatusNotFound, "DATA_EXPORT_NOT_FOUND"
+	case errors.Is(err, service.ErrDataExportExists):
+		status, code = http.StatusConflict, "DATA_EXPORT_EXISTS"
+	case errors.Is(err, service.ErrDataExportNotReady):
+		status, code = http.StatusConflict, "DATA_EXPORT_NOT_READY"
+	case errors.Is(err, service.ErrDataExportExpired):
+		status, code = http.StatusGone, "DATA_EXPORT_EXPIRED"
+	}
+
+	message := fallback
+	if status != http.StatusInternalServerError {
+		message = err.Error()
+	}
+
+	c.JSON(status, models.ErrorResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListUsers godoc
+// @Summary Search users
+// @Description Paginated search by email, role, status and creation date
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param email query string false "Email contains"
+// @Param role query string false "Role name"
+// @Param status query string false "active, inactive, locked, unverified or password_reset_required"
+// @Param created_from query string false "Created on or after (YYYY-MM-DD)"
+// @Param created_before query string false "Created on or before (YYYY-MM-DD)"
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} models.SuccessResponse{data=models.AdminUserListResponse}
+// @Router /auth/admin/users [get]
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	var query models.AdminUserListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "VALIDATION_ERROR",
+				Message: err.Error(),
+			},
+		})
+		return
+	}
+
+	users, err := h.authService.ListUsers(&query)
+	if err != nil {
+		respondAdminUserError(c, err, "Failed to list users")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    users,
+	})
+}
+
+// GetUser godoc
+// @Summary Get a user
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse{data=models.AdminUserResponse}
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/admin/users/{id} [get]
+func (h *AuthHandler) GetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.GetUserForAdmin(userID)
+	if err != nil {
+		respondAdminUserError(c, err, "Failed to get user")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    user,
+	})
+}
+
+// ActivateUser godoc
+// @Summary Activate a user
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Router /auth/admin/users/{id}/activate [post]
+func (h *AuthHandler) ActivateUser(c *gin.Context) {
+	h.setUserActive(c, true, "User activated")
+}
+
+// DeactivateUser godoc
+// @Summary Deactivate a user
+// @Description Blocks sign-in and revokes every session
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Router /auth/admin/users/{id}/deactivate [post]
+func (h *AuthHandler) DeactivateUser(c *gin.Context) {
+	h.setUserActive(c, false, "User deactivated")
+}
+
+func (h *AuthHandler) setUserActive(c *gin.Context, active bool, message string) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.SetUserActive(adminID, userID, active, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondAdminUserError(c, err, "Failed to update user status")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user
+// @Description Clear the lock set after too many failed logins
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Router /auth/admin/users/{id}/unlock [post]
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.UnlockUser(adminID, userID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondAdminUserError(c, err, "Failed to unlock user")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "User unlocked",
+	})
+}
+
+// ForcePasswordReset godoc
+// @Summary Force a password reset
+// @Description Revoke every session, block password login and email a reset code
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Router /auth/admin/users/{id}/force-password-reset [post]
+func (h *AuthHandler) ForcePasswordReset(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.ForcePasswordReset(adminID, userID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondAdminUserError(c, err, "Failed to force password reset")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Password reset required; a reset code was emailed to the user",
+	})
+}
+
+// AssignUserRole godoc
+// @Summary Assign a role to a user
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body models.AssignRoleRequest true "Role"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/admin/users/{id}/roles [post]
+func (h *AuthHandler) AssignUserRole(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.AssignUserRole(adminID, userID, req.Role, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondAdminUserError(c, err, "Failed to assign role")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Role assigned",
+	})
+}
+
+// RemoveUserRole godoc
+// @Summary Remove a role from a user
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param role path string true "Role name"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/admin/users/{id}/roles/{role} [delete]
+func (h *AuthHandler) RemoveUserRole(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.RemoveUserRole(adminID, userID, c.Param("role"), c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondAdminUserError(c, err, "Failed to remove role")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Role removed",
+	})
+}
+
+// RevokeUserSessions godoc
+// @Summary Sign a user out everywhere
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse
+// @Router /auth/admin/users/{id}/sessions [delete]
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.authService.RevokeUserSessions(adminID, userID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondAdminUserError(c, err, "Failed to revoke sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    map[string]interface{}{"revoked": revoked},
+		Message: "All sessions revoked",
+	})
+}
+
+// userIDParam parses the :id path parameter, writing a 400 when it is not a UUID
+func userIDParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid user ID",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// respondAdminUserError maps admin user management errors to status codes
+func respondAdminUserError(c *gin.Context, err error, fallback string) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		status, code = http.StatusNotFound, "USER_NOT_FOUND"
+	case errors.Is(err, service.ErrRoleNotFound):
+		status, code = http.StatusNotFound, "ROLE_NOT_FOUND"
+	case errors.Is(err, service.ErrRoleNotAssigned):
+		status, code = http.StatusNotFound, "ROLE_NOT_ASSIGNED"
+	case errors.Is(err, service.ErrLastRole):
+		status, code = http.StatusConflict, "LAST_ROLE"
+	case errors.Is(err, service.ErrSelfAction):
+		status, code = http.StatusConflict, "SELF_ACTION"
+	}
+
+	message := fallback
+	if status != http.StatusInternalServerError {
+		message = err.Error()
+	}
+
+	c.JSON(status, models.ErrorResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// ListAuditLogs godoc
+// @Summary Search audit logs
+// @Description Paginated audit log search, newest first
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query string false "User ID"
+// @Param event_type query string false "Event type, e.g. login"
+// @Param status query string false "success or failed"
+// @Param ip query string false "Client IP address"
+// @Param from query string false "Created at or after (RFC 3339)"
+// @Param to query string false "Created before (RFC 3339)"
+// @Param archived query bool false "Search entries moved out by retention"
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} models.SuccessResponse{data=models.AuditLogListResponse}
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/admin/audit-logs [get]
+func (h *AuthHandler) ListAuditLogs(c *gin.Context) {
+	var query models.AdminAuditLogQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	logs, err := h.authService.ListAuditLogs(&query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to list audit logs",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    logs,
+	})
+}
+
+// ExportAuditLogs godoc
+// @Summary Export audit logs as CSV
+// @Description Every entry matching the filters, oldest first (at most 100000 rows)
+// @Tags admin
+// @Produce text/csv
+// @Security BearerAuth
+// @Param user_id query string false "User ID"
+// @Param event_type query string false "Event type, e.g. login"
+// @Param status query string false "success or failed"
+// @Param ip query string false "Client IP address"
+// @Param from query string false "Created at or after (RFC 3339)"
+// @Param to query string false "Created before (RFC 3339)"
+// @Param archived query bool false "Export entries moved out by retention"
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/admin/audit-logs/export [get]
+func (h *AuthHandler) ExportAuditLogs(c *gin.Context) {
+	var query models.AdminAuditLogQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	filename := fmt.Sprintf("audit-logs-%s.csv", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// The response is streamed, so a failure can only cut it short
+	if err := h.authService.ExportAuditLogs(&query, c.Writer); err != nil {
+		log.Printf("[Auth-Service] ERROR: Audit log export failed: %v", err)
+		c.Abort()
+	}
+}
+
+// GetSecurityActivity godoc
+// @Summary Recent security activity
+// @Description Sign-ins, failed attempts and security changes on the account in the last 90 days, newest first
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Number of entries" default(20)
+// @Success 200 {object} models.SuccessResponse{data=[]models.SecurityActivityResponse}
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/security-activity [get]
+func (h *AuthHandler) GetSecurityActivity(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var query models.SecurityActivityQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	activity, err := h.authService.GetSecurityActivity(userID, query.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to load security activity",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    activity,
+	})
+}
+
+func bindQuery(c *gin.Context, query interface{}) bool {
+	if err := c.ShouldBindQuery(query); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "VALIDATION_ERROR",
+				Message: err.Error(),
+			},
+		})
+		return false
+	}
+	return true
+}
@@ -1,10 +1,9 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"os"
 
 	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
 	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
@@ -13,14 +12,14 @@ import (
 )
 
 type AuthHandler struct {
-	authService        service.AuthService
-	googleOAuthService service.GoogleOAuthService
+	authService  service.AuthService
+	oauthService service.OAuthService
 }
 
-func NewAuthHandler(authService service.AuthService, googleOAuthService service.GoogleOAuthService) *AuthHandler {
+func NewAuthHandler(authService service.AuthService, oauthService service.OAuthService) *AuthHandler {
 	return &AuthHandler{
-		authService:        authService,
-		googleOAuthService: googleOAuthService,
+		authService:  authService,
+		oauthService: oauthService,
 	}
 }
 
@@ -132,7 +131,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		statusCode := http.StatusUnauthorized
 		if response.Error.Code == "ACCOUNT_LOCKED" {
 			statusCode = http.StatusLocked
-		} else if response.Error.Code == "ACCOUNT_INACTIVE" {
+		} else if response.Error.Code == "ACCOUNT_INACTIVE" || response.Error.Code == "PASSWORD_RESET_REQUIRED" {
 			statusCode = http.StatusForbidden
 		}
 		c.JSON(statusCode, response)
@@ -144,6 +143,51 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// VerifyLoginStepUp godoc
+// @Summary Confirm a high-risk login
+// @Description Exchange the step_up_token from login and the code emailed to the account for tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyLoginStepUpRequest true "Step-up token and code"
+// @Success 200 {object} models.AuthResponse
+// @Failure 401 {object} models.AuthResponse
+// @Failure 403 {object} models.AuthResponse
+// @Failure 423 {object} models.AuthResponse
+// @Router /auth/login/step-up/verify [post]
+func (h *AuthHandler) VerifyLoginStepUp(c *gin.Context) {
+	var req models.VerifyLoginStepUpRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.authService.VerifyLoginStepUp(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		log.Printf("[Login] ERROR: step-up verification failed: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to verify sign-in code",
+			},
+		})
+		return
+	}
+
+	if !response.Success {
+		statusCode := http.StatusUnauthorized
+		if response.Error.Code == "ACCOUNT_LOCKED" {
+			statusCode = http.StatusLocked
+		} else if response.Error.Code == "ACCOUNT_INACTIVE" || response.Error.Code == "PASSWORD_RESET_REQUIRED" {
+			statusCode = http.StatusForbidden
+		}
+		c.JSON(statusCode, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 // RefreshToken godoc
 // @Summary Refresh access token
 // @Description Get a new access token using refresh token
@@ -153,6 +197,9 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // @Param request body models.RefreshTokenRequest true "Refresh token request"
 // @Success 200 {object} models.AuthResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.AuthResponse
+// @Failure 403 {object} models.AuthResponse
+// @Failure 409 {object} models.AuthResponse "Rotated by a concurrent request, use its tokens"
 // @Router /auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	var req models.RefreshTokenRequest
@@ -184,7 +231,14 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	if !response.Success {
-		c.JSON(http.StatusUnauthorized, response)
+		statusCode := http.StatusUnauthorized
+		switch response.Error.Code {
+		case "ACCOUNT_INACTIVE", "PASSWORD_RESET_REQUIRED":
+			statusCode = http.StatusForbidden
+		case "TOKEN_ALREADY_ROTATED":
+			statusCode = http.StatusConflict
+		}
+		c.JSON(statusCode, response)
 		return
 	}
 
@@ -228,8 +282,10 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	}
 
 	userUUID, _ := uuid.Parse(userID.(string))
+	claims, _ := c.Get("token_claims")
+	accessToken, _ := claims.(*service.TokenClaims)
 
-	if err := h.authService.Logout(userUUID, req.RefreshToken); err != nil {
+	if err := h.authService.Logout(userUUID, req.RefreshToken, accessToken); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Success: false,
 			Error: &models.ErrorData{
@@ -285,6 +341,9 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userUUID, _ := uuid.Parse(userID.(string))
 
 	if err := h.authService.ChangePassword(userUUID, &req); err != nil {
+		if respondWeakPassword(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Success: false,
 			Error: &models.ErrorData{
@@ -346,55 +405,15 @@ func (h *AuthHandler) HealthCheck(c *gin.Context) {
 	})
 }
 
-// GetGoogleAuthURL godoc
-// @Summary Get Google OAuth URL
-// @Description Get Google OAuth authorization URL for API clients
-// @Tags auth
-// @Produce json
-// @Success 200 {object} map[string]interface{} "OAuth URL and state"
-// @Router /auth/google/url [get]
-func (h *AuthHandler) GetGoogleAuthURL(c *gin.Context) {
-	// Generate random state for CSRF protection
-	state := uuid.New().String()
-
-	// Get authorization URL
-	url := h.googleOAuthService.GetAuthURL(state)
-
-	// Return JSON response for API clients
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"url":   url,
-			"state": state,
-		},
-	})
-}
-
 // GetGoogleOAuthURL godoc
 // @Summary Get Google OAuth URL
-// @Description Get Google OAuth authorization URL (for API clients)
+// @Description Get Google OAuth authorization URL and state (for API clients)
 // @Tags auth
 // @Produce json
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} models.SuccessResponse{data=models.OAuthURLResponse}
 // @Router /auth/google/url [get]
 func (h *AuthHandler) GetGoogleOAuthURL(c *gin.Context) {
-	// Generate random state for CSRF protection
-	state := uuid.New().String()
-
-	// Store state in session or cookie (simplified here)
-	c.SetCookie("oauth_state", state, 600, "/", "", false, true)
-
-	// Get authorization URL
-	url := h.googleOAuthService.GetAuthURL(state)
-
-	// Return JSON response with URL
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"url":   url,
-			"state": state,
-		},
-	})
+	h.oauthURL(c, "google")
 }
 
 // GoogleLogin godoc
@@ -402,260 +421,36 @@ func (h *AuthHandler) GetGoogleOAuthURL(c *gin.Context) {
 // @Description Redirect user to Google OAuth consent screen (for web browsers)
 // @Tags auth
 // @Produce json
-// @Success 302 {string} string "Redirect to Google"
+// @Success 307 {string} string "Redirect to Google"
 // @Router /auth/google [get]
 func (h *AuthHandler) GoogleLogin(c *gin.Context) {
-	// Generate random state for CSRF protection
-	state := uuid.New().String()
-
-	// Store state in session or cookie (simplified here)
-	c.SetCookie("oauth_state", state, 600, "/", "", false, true)
-
-	// Get authorization URL
-	url := h.googleOAuthService.GetAuthURL(state)
-
-	// Redirect to Google
-	c.Redirect(http.StatusTemporaryRedirect, url)
+	h.oauthLogin(c, "google")
 }
 
 // GoogleCallback godoc
 // @Summary Handle Google OAuth callback
-// @Description Process Google OAuth callback and authenticate user
+// @Description Process Google OAuth callback and redirect to the frontend with tokens
 // @Tags auth
 // @Produce json
 // @Param code query string true "Authorization code"
 // @Param state query string true "State parameter"
-// @Success 200 {object} models.AuthResponse
+// @Success 307 {string} string "Redirect to the frontend"
 // @Failure 400 {object} models.ErrorResponse
 // @Router /auth/google/callback [get]
 func (h *AuthHandler) GoogleCallback(c *gin.Context) {
-    // If Google returned an error (e.g., user not in Test Users or access denied), handle gracefully
-    if errParam := c.Query("error"); errParam != "" {
-        frontendURL := os.Getenv("FRONTEND_URL")
-        if frontendURL == "" {
-            frontendURL = "http://localhost:3000"
-        }
-
-        // Prefer Google's description if present
-        errorDesc := c.Query("error_description")
-        message := errorDesc
-        if message == "" {
-            // Common case: access_denied for users not in Test Users when app is in Testing/Internal
-            switch errParam {
-            case "access_denied":
-                message = "Google denied access. If the app is in Testing/Internal, add this email to Test Users or publish the app."
-            default:
-                message = "Google OAuth error: " + errParam
-            }
-        }
-
-        redirectURL := fmt.Sprintf("%s/login?error=%s", frontendURL, message)
-        c.Redirect(http.StatusTemporaryRedirect, redirectURL)
-        return
-    }
-    // Verify state for CSRF protection (skip in development if cookie not found)
-    state := c.Query("state")
-    storedState, err := c.Cookie("oauth_state")
-
-	// Only verify state if cookie exists (for proper OAuth flow from /auth/google)
-	// Skip verification if cookie doesn't exist (for direct URL access in testing)
-	if err == nil && state != storedState {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error: &models.ErrorData{
-				Code:    "INVALID_STATE",
-				Message: "Invalid state parameter",
-			},
-		})
-		return
-	}
-
-	// Clear state cookie if it exists
-	if err == nil {
-		c.SetCookie("oauth_state", "", -1, "/", "", false, true)
-	}
-
-	// Get authorization code
-	code := c.Query("code")
-	if code == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error: &models.ErrorData{
-				Code:    "MISSING_CODE",
-				Message: "Authorization code is required",
-			},
-		})
-		return
-	}
-
-	// Exchange code for token
-	log.Printf("[GoogleCallback] Starting token exchange for code: %s...", code[:10])
-	token, err := h.googleOAuthService.ExchangeCode(code)
-	if err != nil {
-		log.Printf("[GoogleCallback] ❌ Failed to exchange code: %v", err)
-		// Redirect to frontend with error
-		frontendURL := os.Getenv("FRONTEND_URL")
-		if frontendURL == "" {
-			frontendURL = "http://localhost:3000"
-		}
-		redirectURL := fmt.Sprintf("%s/login?error=%s", frontendURL, "Failed to exchange authorization code")
-		c.Redirect(http.StatusTemporaryRedirect, redirectURL)
-		return
-	}
-	log.Printf("[GoogleCallback] ✅ Token exchange successful")
-
-	// Get user info from Google
-	log.Printf("[GoogleCallback] Getting user info from Google...")
-	googleUser, err := h.googleOAuthService.GetUserInfo(token)
-	if err != nil {
-		log.Printf("[GoogleCallback] ❌ Failed to get user info: %v", err)
-		frontendURL := os.Getenv("FRONTEND_URL")
-		if frontendURL == "" {
-			frontendURL = "http://localhost:3000"
-		}
-		redirectURL := fmt.Sprintf("%s/login?error=%s", frontendURL, "Failed to get user information from Google")
-		c.Redirect(http.StatusTemporaryRedirect, redirectURL)
-		return
-	}
-	log.Printf("[GoogleCallback] ✅ Got user info: %s (%s)", googleUser.Email, googleUser.ID)
-
-	// Authenticate or create user
-	ip := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
-
-	log.Printf("[GoogleCallback] Authenticating user...")
-	authResp, err := h.googleOAuthService.AuthenticateUser(googleUser, ip, userAgent)
-	if err != nil {
-		log.Printf("[GoogleCallback] ❌ Failed to authenticate user: %v", err)
-		frontendURL := os.Getenv("FRONTEND_URL")
-		if frontendURL == "" {
-			frontendURL = "http://localhost:3000"
-		}
-		redirectURL := fmt.Sprintf("%s/login?error=%s", frontendURL, "Failed to authenticate user")
-		c.Redirect(http.StatusTemporaryRedirect, redirectURL)
-		return
-	}
-	log.Printf("[GoogleCallback] ✅ User authenticated successfully")
-	log.Printf("[GoogleCallback] 🔍 authResp.Success = %v", authResp.Success)
-	log.Printf("[GoogleCallback] 🔍 authResp.Data != nil = %v", authResp.Data != nil)
-	if authResp.Data != nil {
-		log.Printf("[GoogleCallback] 🔍 authResp.Data.AccessToken = %s...", authResp.Data.AccessToken[:20])
-		log.Printf("[GoogleCallback] 🔍 authResp.Data.UserID = %s", authResp.Data.UserID)
-	}
-
-	// For web flow: Redirect to frontend with tokens
-	// Frontend URL from environment or default
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:3000"
-	}
-
-	if authResp.Success && authResp.Data != nil {
-		// Success: Redirect to frontend callback with tokens
-		redirectURL := fmt.Sprintf(
-			"%s/auth/google/callback?success=true&access_token=%s&refresh_token=%s&user_id=%s&email=%s&role=%s",
-			frontendURL,
-			authResp.Data.AccessToken,
-			authResp.Data.RefreshToken,
-			authResp.Data.UserID,
-			authResp.Data.Email,
-			authResp.Data.Role,
-		)
-		log.Printf("[GoogleCallback] 🎉 Redirecting to frontend: %s", frontendURL+"/auth/google/callback?success=true&...")
-		c.Redirect(http.StatusTemporaryRedirect, redirectURL)
-	} else {
-		// Error: Redirect to frontend login with error
-		errorMessage := "Authentication failed"
-		if authResp.Error != nil {
-			errorMessage = authResp.Error.Message
-		}
-		redirectURL := fmt.Sprintf("%s/login?error=%s", frontendURL, errorMessage)
-		log.Printf("[GoogleCallback] ⚠️ Redirecting to login with error: %s", errorMessage)
-		c.Redirect(http.StatusTemporaryRedirect, redirectURL)
-	}
+	h.oauthCallback(c, "google")
 }
 
 // GoogleExchangeToken handles Google OAuth token exchange for mobile/API clients
 // Mobile app flow:
-// 1. Mobile gets URL from GET /auth/google/url
+// 1. Mobile gets URL and state from GET /auth/google/url
 // 2. Mobile opens URL in WebView/Browser
 // 3. User logs in with Google
-// 4. Mobile captures redirect URL with code parameter
-// 5. Mobile sends code to this endpoint
+// 4. Mobile captures redirect URL with code and state parameters
+// 5. Mobile sends code and state to this endpoint
 // 6. Backend exchanges code for tokens and returns to mobile
 func (h *AuthHandler) GoogleExchangeToken(c *gin.Context) {
-	var req struct {
-		Code  string `json:"code" binding:"required"`
-		State string `json:"state"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error: &models.ErrorData{
-				Code:    "VALIDATION_ERROR",
-				Message: "Code is required",
-			},
-		})
-		return
-	}
-
-	// Exchange code for token
-	token, err := h.googleOAuthService.ExchangeCode(req.Code)
-	if err != nil {
-		log.Printf("[GoogleExchangeToken] Failed to exchange code: %v", err)
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Success: false,
-			Error: &models.ErrorData{
-				Code:    "EXCHANGE_FAILED",
-				Message: "Failed to exchange authorization code. Code may be expired or invalid.",
-			},
-		})
-		return
-	}
-
-	// Get user info from Google
-	googleUser, err := h.googleOAuthService.GetUserInfo(token)
-	if err != nil {
-		log.Printf("[GoogleExchangeToken] Failed to get user info: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Success: false,
-			Error: &models.ErrorData{
-				Code:    "USER_INFO_FAILED",
-				Message: "Failed to get user information from Google",
-			},
-		})
-		return
-	}
-
-	// Authenticate or create user
-	ip := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
-
-	authResp, err := h.googleOAuthService.AuthenticateUser(googleUser, ip, userAgent)
-	if err != nil {
-		log.Printf("[GoogleExchangeToken] Failed to authenticate user: %v", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Success: false,
-			Error: &models.ErrorData{
-				Code:    "AUTH_FAILED",
-				Message: "Failed to authenticate user",
-			},
-		})
-		return
-	}
-
-	// Return response
-	if authResp.Success {
-		c.JSON(http.StatusOK, authResp)
-	} else {
-		// Handle specific error cases
-		statusCode := http.StatusBadRequest
-		if authResp.Error.Code == "ACCOUNT_INACTIVE" {
-			statusCode = http.StatusForbidden
-		}
-		c.JSON(statusCode, authResp)
-	}
+	h.oauthExchange(c, "google")
 }
 
 // ForgotPassword godoc
@@ -729,6 +524,9 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	ip := c.ClientIP()
 
 	if err := h.authService.ResetPassword(&req, ip); err != nil {
+		if respondWeakPassword(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Success: false,
 			Error: &models.ErrorData{
@@ -859,6 +657,9 @@ func (h *AuthHandler) ResetPasswordByCode(c *gin.Context) {
 	ip := c.ClientIP()
 
 	if err := h.authService.ResetPasswordByCode(req.Code, req.NewPassword, ip); err != nil {
+		if respondWeakPassword(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Success: false,
 			Error: &models.ErrorData{
@@ -915,3 +716,24 @@ func (h *AuthHandler) VerifyEmailByCode(c *gin.Context) {
 		Message: "Email verified successfully",
 	})
 }
+
+// respondWeakPassword answers password policy violations with WEAK_PASSWORD
+// and the violated rules; reports whether err was one
+func respondWeakPassword(c *gin.Context, err error) bool {
+	var policyErr *service.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, models.ErrorResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    "WEAK_PASSWORD",
+			Message: policyErr.Error(),
+			Details: map[string]interface{}{
+				"violations": policyErr.Violations,
+			},
+		},
+	})
+	return true
+}
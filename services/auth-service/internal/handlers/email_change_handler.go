@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ChangeEmail godoc
+// @Summary Change email
+// @Description Email a 6-digit code to the new address and notify the current one.
+// @Description The change applies once the code is confirmed.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangeEmailRequest true "New email and current password (required when the account has one)"
+// @Success 200 {object} models.SuccessResponse{data=models.EmailChangeResponse}
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /auth/change-email [post]
+func (h *AuthHandler) ChangeEmail(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	change, err := h.authService.RequestEmailChange(userID, &req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondEmailChangeError(c, err, "Failed to request email change")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    change,
+		Message: "A confirmation code has been sent to the new email address",
+	})
+}
+
+// ConfirmEmailChange godoc
+// @Summary Confirm email change
+// @Description Apply the pending email change and sign out every other session
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ConfirmEmailChangeRequest true "Code sent to the new address"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /auth/change-email/confirm [post]
+func (h *AuthHandler) ConfirmEmailChange(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ConfirmEmailChangeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	err := h.authService.ConfirmEmailChange(userID, c.GetString("session_id"), &req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondEmailChangeError(c, err, "Failed to change email")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Email changed. Other sessions have been signed out; refresh your token to see the new email.",
+	})
+}
+
+// respondEmailChangeError maps email change errors to status codes
+func respondEmailChangeError(c *gin.Context, err error, fallback string) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, service.ErrInvalidPassword):
+		status, code = http.StatusBadRequest, "INVALID_PASSWORD"
+	case errors.Is(err, service.ErrEmailUnchanged):
+		status, code = http.StatusBadRequest, "EMAIL_UNCHANGED"
+	case errors.Is(err, service.ErrInvalidEmailChangeCode):
+		status, code = http.StatusBadRequest, "INVALID_CODE"
+	case errors.Is(err, service.ErrEmailTaken):
+		status, code = http.StatusConflict, "EMAIL_EXISTS"
+	case errors.Is(err, service.ErrEmailChangeTooSoon):
+		status, code = http.StatusTooManyRequests, "TOO_MANY_REQUESTS"
+	case errors.Is(err, service.ErrEmailChangeSyncFailed):
+		status, code = http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE"
+	case errors.Is(err, service.ErrUserNotFound):
+		status, code = http.StatusNotFound, "USER_NOT_FOUND"
+	}
+
+	message := fallback
+	if status != http.StatusInternalServerError {
+		message = err.Error()
+	}
+
+	c.JSON(status, models.ErrorResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListEmails godoc
+// @Summary List outgoing emails
+// @Description Queued, sent and dead emails, newest first. Dead emails failed every retry.
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "pending, sent or dead"
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} models.SuccessResponse{data=models.EmailOutboxListResponse}
+// @Router /auth/admin/emails [get]
+func (h *AuthHandler) ListEmails(c *gin.Context) {
+	var query models.AdminEmailOutboxQuery
+	if !bindQuery(c, &query) {
+		return
+	}
+
+	emails, err := h.authService.ListEmails(&query)
+	if err != nil {
+		respondEmailOutboxError(c, err, "Failed to list emails")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    emails,
+	})
+}
+
+// RetryEmail godoc
+// @Summary Retry a dead email
+// @Description Queue the email again with a fresh set of attempts
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Email ID"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/admin/emails/{id}/retry [post]
+func (h *AuthHandler) RetryEmail(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid email ID",
+			},
+		})
+		return
+	}
+
+	if err := h.authService.RetryEmail(adminID, messageID, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondEmailOutboxError(c, err, "Failed to retry email")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Email queued again",
+	})
+}
+
+// respondEmailOutboxError maps email outbox errors to status codes
+func respondEmailOutboxError(c *gin.Context, err error, fallback string) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, service.ErrEmailNotFound):
+		status, code = http.StatusNotFound, "EMAIL_NOT_FOUND"
+	case errors.Is(err, service.ErrEmailNotDead):
+		status, code = http.StatusConflict, "EMAIL_NOT_DEAD"
+	}
+
+	message := fallback
+	if status != http.StatusInternalServerError {
+		message = err.Error()
+	}
+
+	c.JSON(status, models.ErrorResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
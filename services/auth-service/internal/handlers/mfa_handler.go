@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// GetMFAStatus godoc
+// @Summary Get 2FA status
+// @Tags 2fa
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.MFAStatusResponse}
+// @Router /auth/2fa/status [get]
+func (h *AuthHandler) GetMFAStatus(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.authService.GetMFAStatus(userID)
+	if err != nil {
+		respondMFAError(c, err, "Failed to get two-factor status")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// SetupMFA godoc
+// @Summary Start 2FA enrollment
+// @Description Generate a TOTP secret; 2FA is enabled once a code from it is confirmed
+// @Tags 2fa
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=models.MFASetupResponse}
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/2fa/setup [post]
+func (h *AuthHandler) SetupMFA(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	setup, err := h.authService.SetupMFA(userID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondMFAError(c, err, "Failed to start two-factor setup")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    setup,
+	})
+}
+
+// EnableMFA godoc
+// @Summary Confirm 2FA enrollment
+// @Description Verify the first code and return recovery codes (shown only once)
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFACodeRequest true "Authenticator code"
+// @Success 200 {object} models.SuccessResponse{data=models.MFARecoveryCodesResponse}
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/2fa/enable [post]
+func (h *AuthHandler) EnableMFA(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.MFACodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	codes, err := h.authService.EnableMFA(userID, req.Code, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondMFAError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    codes,
+		Message: "Two-factor authentication enabled",
+	})
+}
+
+// DisableMFA godoc
+// @Summary Disable 2FA
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.DisableMFARequest true "Password and authenticator code"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.DisableMFARequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.DisableMFA(userID, &req, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondMFAError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes; the old ones stop working
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFACodeRequest true "Authenticator code"
+// @Success 200 {object} models.SuccessResponse{data=models.MFARecoveryCodesResponse}
+// @Router /auth/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.MFACodeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(userID, req.Code, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondMFAError(c, err, "Failed to regenerate recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    codes,
+	})
+}
+
+// SetupMFAChallenge godoc
+// @Summary Enroll 2FA during login
+// @Description For logins that returned mfa_enrollment_required
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Param request body models.MFAChallengeRequest true "Login challenge"
+// @Success 200 {object} models.SuccessResponse{data=models.MFASetupResponse}
+// @Failure 401 {object} models.ErrorResponse
+// @Router /auth/2fa/challenge/setup [post]
+func (h *AuthHandler) SetupMFAChallenge(c *gin.Context) {
+	var req models.MFAChallengeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	setup, err := h.authService.SetupMFAChallenge(req.MFAToken, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondMFAError(c, err, "Failed to start two-factor setup")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    setup,
+	})
+}
+
+// VerifyMFAChallenge godoc
+// @Summary Complete a 2FA login
+// @Description Exchange a login challenge and an authenticator or recovery code for tokens
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyMFAChallengeRequest true "Challenge and code"
+// @Success 200 {object} models.AuthResponse
+// @Failure 401 {object} models.AuthResponse
+// @Router /auth/2fa/challenge/verify [post]
+func (h *AuthHandler) VerifyMFAChallenge(c *gin.Context) {
+	var req models.VerifyMFAChallengeRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.authService.VerifyMFAChallenge(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to verify two-factor code",
+			},
+		})
+		return
+	}
+
+	if !response.Success {
+		statusCode := http.StatusUnauthorized
+		if response.Error.Code == "ACCOUNT_INACTIVE" || response.Error.Code == "PASSWORD_RESET_REQUIRED" {
+			statusCode = http.StatusForbidden
+		} else if response.Error.Code == "ACCOUNT_LOCKED" {
+			statusCode = http.StatusLocked
+		}
+		c.JSON(statusCode, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListMFAPolicies godoc
+// @Summary List 2FA policy per role
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.MFAPolicyResponse}
+// @Router /auth/admin/2fa/policies [get]
+func (h *AuthHandler) ListMFAPolicies(c *gin.Context) {
+	policies, err := h.authService.ListMFAPolicies()
+	if err != nil {
+		respondMFAError(c, err, "Failed to list two-factor policies")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    policies,
+	})
+}
+
+// SetMFAPolicy godoc
+// @Summary Require 2FA for a role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param role path string true "Role name"
+// @Param request body models.MFAPolicyRequest true "Policy"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/admin/2fa/policies/{role} [put]
+func (h *AuthHandler) SetMFAPolicy(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.MFAPolicyRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	role := c.Param("role")
+	if err := h.authService.SetMFAPolicy(adminID, role, *req.RequireMFA, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		if errors.Is(err, service.ErrRoleNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Success: false,
+				Error: &models.ErrorData{
+					Code:    "ROLE_NOT_FOUND",
+					Message: "Role not found",
+				},
+			})
+			return
+		}
+		respondMFAError(c, err, "Failed to update two-factor policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    models.MFAPolicyResponse{Role: role, RequireMFA: *req.RequireMFA},
+	})
+}
+
+// respondMFAError maps 2FA service errors to status codes; anything
+// unexpected becomes a 500 with fallback as the message.
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, service.ErrMFAInvalidCode):
+		status, code = http.StatusBadRequest, "INVALID_MFA_CODE"
+	case errors.Is(err, service.ErrInvalidPassword):
+		status, code = http.StatusBadRequest, "INVALID_PASSWORD"
+	case errors.Is(err, service.ErrMFASetupRequired):
+		status, code = http.StatusBadRequest, "MFA_SETUP_REQUIRED"
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		status, code = http.StatusConflict, "MFA_ALREADY_ENABLED"
+	case errors.Is(err, service.ErrMFANotEnabled):
+		status, code = http.StatusConflict, "MFA_NOT_ENABLED"
+	case errors.Is(err, service.ErrMFARequiredByPolicy):
+		status, code = http.StatusForbidden, "MFA_REQUIRED_BY_POLICY"
+	case errors.Is(err, service.ErrMFAChallengeInvalid):
+		status, code = http.StatusUnauthorized, "MFA_CHALLENGE_EXPIRED"
+	}
+
+	message := fallback
+	if status != http.StatusInternalServerError {
+		message = err.Error()
+	}
+
+	c.JSON(status, models.ErrorResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+// bindJSON binds the request body, writing a 400 on validation errors
+func bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "VALIDATION_ERROR",
+				Message: err.Error(),
+			},
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// The browser that starts a flow gets a cookie with a hash of its state, so
+// a callback carrying a code and state obtained by someone else is refused
+const (
+	oauthStateCookie     = "oauth_state"
+	oauthStateCookiePath = "/api/v1/auth"
+)
+
+// ListOAuthProviders godoc
+// @Summary List sign-in providers
+// @Description List the configured OAuth/OIDC providers users can sign in with
+// @Tags auth
+// @Produce json
+// @Success 200 {object} models.SuccessResponse{data=[]models.OAuthProviderResponse}
+// @Router /auth/oauth/providers [get]
+func (h *AuthHandler) ListOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    h.oauthService.Providers(),
+	})
+}
+
+// GetOAuthURL godoc
+// @Summary Get provider OAuth URL
+// @Description Get the provider's authorization URL and the state to send back with the code
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} models.SuccessResponse{data=models.OAuthURLResponse}
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/oauth/{provider}/url [get]
+func (h *AuthHandler) GetOAuthURL(c *gin.Context) {
+	h.oauthURL(c, c.Param("provider"))
+}
+
+// OAuthLogin godoc
+// @Summary Initiate provider login
+// @Description Redirect the browser to the provider's consent screen
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Success 307 {string} string "Redirect to the provider"
+// @Router /auth/oauth/{provider} [get]
+func (h *AuthHandler) OAuthLogin(c *gin.Context) {
+	h.oauthLogin(c, c.Param("provider"))
+}
+
+// OAuthCallback godoc
+// @Summary Handle provider callback
+// @Description Complete a sign-in or identity link started in this browser and redirect to the frontend
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State parameter"
+// @Success 307 {string} string "Redirect to the frontend"
+// @Router /auth/oauth/{provider}/callback [get]
+func (h *AuthHandler) OAuthCallback(c *gin.Context) {
+	h.oauthCallback(c, c.Param("provider"))
+}
+
+// OAuthExchangeToken godoc
+// @Summary Exchange provider authorization code
+// @Description Complete a sign-in or identity link for clients that capture the redirect themselves
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param request body models.OAuthTokenRequest true "Code and state"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/oauth/{provider}/token [post]
+func (h *AuthHandler) OAuthExchangeToken(c *gin.Context) {
+	h.oauthExchange(c, c.Param("provider"))
+}
+
+// ListIdentities godoc
+// @Summary List linked identities
+// @Description List the external accounts linked to the current user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.IdentityResponse}
+// @Router /auth/identities [get]
+func (h *AuthHandler) ListIdentities(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	identities, err := h.oauthService.ListIdentities(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to list identities",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    identities,
+	})
+}
+
+// LinkIdentity godoc
+// @Summary Start linking a provider
+// @Description Get the provider's authorization URL; completing it links the account to the current user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Provider name"
+// @Success 200 {object} models.SuccessResponse{data=models.OAuthURLResponse}
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/identities/{provider}/link [post]
+func (h *AuthHandler) LinkIdentity(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	authURL, err := h.oauthService.AuthURL(c.Param("provider"), &userID)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+	setOAuthStateCookie(c, authURL.State)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    authURL,
+	})
+}
+
+// UnlinkIdentity godoc
+// @Summary Unlink a provider
+// @Description Remove a linked external account
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Provider name"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/identities/{provider} [delete]
+func (h *AuthHandler) UnlinkIdentity(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.oauthService.UnlinkIdentity(userID, c.Param("provider"), c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Identity unlinked",
+	})
+}
+
+func (h *AuthHandler) oauthURL(c *gin.Context, provider string) {
+	authURL, err := h.oauthService.AuthURL(provider, nil)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+	setOAuthStateCookie(c, authURL.State)
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    authURL,
+	})
+}
+
+func (h *AuthHandler) oauthLogin(c *gin.Context, provider string) {
+	authURL, err := h.oauthService.AuthURL(provider, nil)
+	if err != nil {
+		respondOAuthError(c, err)
+		return
+	}
+	setOAuthStateCookie(c, authURL.State)
+
+	c.Redirect(http.StatusTemporaryRedirect, authURL.URL)
+}
+
+// oauthCallback completes the flow for providers that redirect to the backend.
+// Sign-ins land on <frontend>/auth/<provider>/callback, links on the security settings page.
+func (h *AuthHandler) oauthCallback(c *gin.Context, provider string) {
+	// The provider reports denied consent and similar failures as ?error=
+	if errParam := c.Query("error"); errParam != "" {
+		message := c.Query("error_description")
+		if message == "" {
+			switch {
+			case errParam == "access_denied" && provider == "google":
+				message = "Google denied access. If the app is in Testing/Internal, add this email to Test Users or publish the app."
+			case errParam == "access_denied":
+				message = "Access was denied by the provider"
+			default:
+				message = "OAuth error: " + errParam
+			}
+		}
+		redirectToFrontend(c, "/login", url.Values{"error": {message}})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "MISSING_CODE",
+				Message: "Authorization code is required",
+			},
+		})
+		return
+	}
+
+	state := c.Query("state")
+	if !oauthStateCookieMatches(c, state) {
+		log.Printf("[OAuthCallback] ❌ %s state was not started by this browser", provider)
+		redirectToFrontend(c, "/login", url.Values{"error": {service.ErrInvalidOAuthState.Error()}})
+		return
+	}
+
+	result, err := h.oauthService.Complete(provider, state, code, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		log.Printf("[OAuthCallback] ❌ %s sign-in failed: %v", provider, err)
+		if errors.Is(err, repository.ErrIdentityLinkedElsewhere) || errors.Is(err, repository.ErrProviderAlreadyLinked) {
+			redirectToFrontend(c, "/settings/security", url.Values{"link_error": {err.Error()}})
+			return
+		}
+		redirectToFrontend(c, "/login", url.Values{"error": {oauthErrorMessage(err)}})
+		return
+	}
+
+	if result.Linked != nil {
+		redirectToFrontend(c, "/settings/security", url.Values{"linked": {provider}})
+		return
+	}
+
+	authResp := result.Auth
+	callbackPath := "/auth/" + provider + "/callback"
+	switch {
+	case authResp.Success && authResp.Data != nil && authResp.Data.MFARequired:
+		// Second factor pending: the frontend completes it via /auth/2fa/challenge/verify
+		redirectToFrontend(c, callbackPath, url.Values{
+			"mfa_required":            {"true"},
+			"mfa_token":               {authResp.Data.MFAToken},
+			"mfa_enrollment_required": {strconv.FormatBool(authResp.Data.MFAEnrollmentRequired)},
+		})
+	case authResp.Success && authResp.Data != nil:
+		redirectToFrontend(c, callbackPath, url.Values{
+			"success":       {"true"},
+			"access_token":  {authResp.Data.AccessToken},
+			"refresh_token": {authResp.Data.RefreshToken},
+			"user_id":       {authResp.Data.UserID},
+			"email":         {authResp.Data.Email},
+			"role":          {authResp.Data.Role},
+		})
+	default:
+		message := "Authentication failed"
+		if authResp.Error != nil {
+			message = authResp.Error.Message
+		}
+		redirectToFrontend(c, "/login", url.Values{"error": {message}})
+	}
+}
+
+// oauthExchange completes the flow for clients (mobile, SPA) that capture the
+// redirect themselves and post the code with the state from the URL endpoint
+func (h *AuthHandler) oauthExchange(c *gin.Context, provider string) {
+	var req models.OAuthTokenRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	result, err := h.oauthService.Complete(provider, req.State, req.Code, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		log.Printf("[OAuthExchangeToken] %s sign-in failed: %v", provider, err)
+		respondOAuthError(c, err)
+		return
+	}
+
+	if result.Linked != nil {
+		c.JSON(http.StatusOK, models.SuccessResponse{
+			Success: true,
+			Data:    result.Linked,
+			Message: "Identity linked",
+		})
+		return
+	}
+
+	authResp := result.Auth
+	if authResp.Success {
+		c.JSON(http.StatusOK, authResp)
+		return
+	}
+
+	statusCode := http.StatusBadRequest
+	switch authResp.Error.Code {
+	case "ACCOUNT_INACTIVE", "PASSWORD_RESET_REQUIRED":
+		statusCode = http.StatusForbidden
+	case "ACCOUNT_LOCKED":
+		statusCode = http.StatusLocked
+	case "ACCOUNT_EXISTS":
+		statusCode = http.StatusConflict
+	}
+	c.JSON(statusCode, authResp)
+}
+
+func respondOAuthError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	code := "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, service.ErrUnknownOAuthProvider):
+		status, code = http.StatusNotFound, "PROVIDER_NOT_FOUND"
+	case errors.Is(err, service.ErrInvalidOAuthState):
+		status, code = http.StatusBadRequest, "INVALID_STATE"
+	case errors.Is(err, service.ErrOAuthExchange):
+		status, code = http.StatusBadRequest, "EXCHANGE_FAILED"
+	case errors.Is(err, repository.ErrIdentityNotFound):
+		status, code = http.StatusNotFound, "IDENTITY_NOT_FOUND"
+	case errors.Is(err, repository.ErrIdentityLinkedElsewhere):
+		status, code = http.StatusConflict, "IDENTITY_LINKED_ELSEWHERE"
+	case errors.Is(err, repository.ErrProviderAlreadyLinked):
+		status, code = http.StatusConflict, "PROVIDER_ALREADY_LINKED"
+	case errors.Is(err, service.ErrLastSignInMethod):
+		status, code = http.StatusConflict, "LAST_SIGN_IN_METHOD"
+	}
+
+	c.JSON(status, models.ErrorResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: oauthErrorMessage(err),
+		},
+	})
+}
+
+func oauthErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, service.ErrUnknownOAuthProvider),
+		errors.Is(err, service.ErrInvalidOAuthState),
+		errors.Is(err, service.ErrOAuthExchange),
+		errors.Is(err, repository.ErrIdentityNotFound),
+		errors.Is(err, repository.ErrIdentityLinkedElsewhere),
+		errors.Is(err, repository.ErrProviderAlreadyLinked),
+		errors.Is(err, service.ErrLastSignInMethod):
+		return err.Error()
+	default:
+		return "Failed to authenticate user"
+	}
+}
+
+func setOAuthStateCookie(c *gin.Context, state string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    hashOAuthState(state),
+		Path:     oauthStateCookiePath,
+		MaxAge:   int(service.OAuthStateTTL.Seconds()),
+		Secure:   isHTTPS(c),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode, // still sent on the provider's redirect back
+	})
+}
+
+// oauthStateCookieMatches reports whether this browser started the flow for
+// state. The cookie is cleared either way, it is good for one callback.
+func oauthStateCookieMatches(c *gin.Context, state string) bool {
+	cookie, err := c.Cookie(oauthStateCookie)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oauthStateCookie,
+		Path:     oauthStateCookiePath,
+		MaxAge:   -1,
+		Secure:   isHTTPS(c),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if err != nil || state == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(hashOAuthState(state))) == 1
+}
+
+func hashOAuthState(state string) string {
+	hash := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(hash[:])
+}
+
+// isHTTPS reports whether the browser reached us over TLS, directly or through the gateway
+func isHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+func redirectToFrontend(c *gin.Context, path string, params url.Values) {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	c.Redirect(http.StatusTemporaryRedirect, frontendURL+path+"?"+params.Encode())
+}
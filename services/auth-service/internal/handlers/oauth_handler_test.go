@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// stubOAuth hands out a fixed state and records the states it completes
+type stubOAuth struct {
+	service.OAuthService
+	completed []string
+}
+
+func (s *stubOAuth) AuthURL(provider string, linkUserID *uuid.UUID) (*models.OAuthURLResponse, error) {
+	return &models.OAuthURLResponse{URL: "https://idp.example.com/authorize?state=good-state", State: "good-state"}, nil
+}
+
+func (s *stubOAuth) Complete(provider, state, code, ip, userAgent string) (*service.OAuthResult, error) {
+	s.completed = append(s.completed, state)
+	return &service.OAuthResult{Linked: &models.IdentityResponse{Provider: provider}}, nil
+}
+
+func newOAuthRouter(oauth *stubOAuth) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := NewAuthHandler(nil, oauth)
+	router := gin.New()
+	router.GET("/api/v1/auth/oauth/:provider", h.OAuthLogin)
+	router.GET("/api/v1/auth/oauth/:provider/callback", h.OAuthCallback)
+	return router
+}
+
+func TestOAuthCallbackChecksStateCookie(t *testing.T) {
+	oauth := &stubOAuth{}
+	router := newOAuthRouter(oauth)
+
+	start := httptest.NewRecorder()
+	router.ServeHTTP(start, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oauth/testidp", nil))
+	cookies := start.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oauthStateCookie || !cookies[0].HttpOnly {
+		t.Fatalf("starting the flow set %v, want one HttpOnly %s cookie", cookies, oauthStateCookie)
+	}
+	if cookies[0].Value == "good-state" {
+		t.Error("the cookie holds the state itself")
+	}
+
+	tests := []struct {
+		name       string
+		state      string
+		withCookie bool
+		wantLinked bool
+	}{
+		{name: "started here", state: "good-state", withCookie: true, wantLinked: true},
+		{name: "no cookie", state: "good-state"},
+		{name: "someone else's state", state: "attacker-state", withCookie: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oauth.completed = nil
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/oauth/testidp/callback?code=abc&state="+url.QueryEscape(tt.state), nil)
+			if tt.withCookie {
+				req.AddCookie(cookies[0])
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			location := w.Header().Get("Location")
+			if linked := strings.Contains(location, "linked=testidp"); linked != tt.wantLinked {
+				t.Errorf("redirected to %s, want linked %v", location, tt.wantLinked)
+			}
+			if tt.wantLinked != (len(oauth.completed) == 1) {
+				t.Errorf("completed %v", oauth.completed)
+			}
+			// The cookie is good for one callback
+			if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+				t.Errorf("callback set %v, want the state cookie cleared", cleared)
+			}
+		})
+	}
+}
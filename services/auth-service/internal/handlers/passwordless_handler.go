@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestPasswordlessLogin godoc
+// @Summary Request a passwordless login code
+// @Description Email a one-time 6-digit code and magic link. The response does not reveal whether the email has an account.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordlessLoginRequest true "Email"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/passwordless/request [post]
+func (h *AuthHandler) RequestPasswordlessLogin(c *gin.Context) {
+	var req models.PasswordlessLoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	if err := h.authService.RequestPasswordlessLogin(&req, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		log.Printf("[PasswordlessLogin] ERROR: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to send login code",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "If the email exists, a login code has been sent",
+	})
+}
+
+// VerifyPasswordlessLogin godoc
+// @Summary Sign in with a login code or magic link
+// @Description Exchange the emailed code (with the email) or the magic link token for tokens. Codes are single use and invalidated after 5 wrong attempts. Users with 2FA get mfa_required and an mfa_token.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyPasswordlessLoginRequest true "Email and code, or token"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.AuthResponse
+// @Router /auth/passwordless/verify [post]
+func (h *AuthHandler) VerifyPasswordlessLogin(c *gin.Context) {
+	var req models.VerifyPasswordlessLoginRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	response, err := h.authService.PasswordlessLogin(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		log.Printf("[PasswordlessLogin] ERROR: %v", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "INTERNAL_ERROR",
+				Message: "Failed to login. Please try again later.",
+			},
+		})
+		return
+	}
+
+	if !response.Success {
+		statusCode := http.StatusUnauthorized
+		switch response.Error.Code {
+		case "ACCOUNT_LOCKED":
+			statusCode = http.StatusLocked
+		case "ACCOUNT_INACTIVE", "PASSWORD_RESET_REQUIRED":
+			statusCode = http.StatusForbidden
+		}
+		c.JSON(statusCode, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
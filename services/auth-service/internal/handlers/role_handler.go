@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListRoles godoc
+// @Summary List roles with their permissions
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.RoleResponse}
+// @Router /auth/admin/roles [get]
+func (h *AuthHandler) ListRoles(c *gin.Context) {
+	roles, err := h.authService.ListRoles()
+	if err != nil {
+		respondRoleError(c, err, "Failed to list roles")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    roles,
+	})
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateRoleRequest true "Role"
+// @Success 201 {object} models.SuccessResponse{data=models.RoleResponse}
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/admin/roles [post]
+func (h *AuthHandler) CreateRole(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateRoleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	role, err := h.authService.CreateRole(adminID, &req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondRoleError(c, err, "Failed to create role")
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    role,
+	})
+}
+
+// DeleteRole godoc
+// @Summary Delete a custom role
+// @Description Built-in roles (student, instructor, admin) cannot be deleted
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param role path string true "Role name"
+// @Success 200 {object} models.SuccessResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /auth/admin/roles/{role} [delete]
+func (h *AuthHandler) DeleteRole(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.authService.DeleteRole(adminID, c.Param("role"), c.ClientIP(), c.Request.UserAgent()); err != nil {
+		respondRoleError(c, err, "Failed to delete role")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Role deleted",
+	})
+}
+
+// SetRolePermissions godoc
+// @Summary Replace the permissions of a role
+// @Description Takes effect for each user at their next login or token refresh
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param role path string true "Role name"
+// @Param request body models.SetRolePermissionsRequest true "Permission names"
+// @Success 200 {object} models.SuccessResponse{data=models.RoleResponse}
+// @Failure 400 {object} models.ErrorResponse
+// @Router /auth/admin/roles/{role}/permissions [put]
+func (h *AuthHandler) SetRolePermissions(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetRolePermissionsRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	role, err := h.authService.SetRolePermissions(adminID, c.Param("role"), req.Permissions, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondRoleError(c, err, "Failed to update role permissions")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    role,
+	})
+}
+
+// ListPermissions godoc
+// @Summary List permissions
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SuccessResponse{data=[]models.Permission}
+// @Router /auth/admin/permissions [get]
+func (h *AuthHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.authService.ListPermissions()
+	if err != nil {
+		respondRoleError(c, err, "Failed to list permissions")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    permissions,
+	})
+}
+
+// CreatePermission godoc
+// @Summary Create a permission
+// @Description The permission is named "<resource>:<action>"; services must check it to have any effect
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreatePermissionRequest true "Permission"
+// @Success 201 {object} models.SuccessResponse{data=models.Permission}
+// @Failure 409 {object} models.ErrorResponse
+// @Router /auth/admin/permissions [post]
+func (h *AuthHandler) CreatePermission(c *gin.Context) {
+	adminID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreatePermissionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	permission, err := h.authService.CreatePermission(adminID, &req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondRoleError(c, err, "Failed to create permission")
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Data:    permission,
+	})
+}
+
+// GetUserPermissionsInternal godoc
+// @Summary Resolve a user's current roles and permissions
+// @Description Service-to-service lookup that reflects role changes before the user's token is refreshed
+// @Tags internal
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.SuccessResponse{data=models.UserPermissionsResponse}
+// @Router /auth/internal/users/{id}/permissions [get]
+func (h *AuthHandler) GetUserPermissionsInternal(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "VALIDATION_ERROR",
+				Message: "Invalid user ID",
+			},
+		})
+		return
+	}
+
+	permissions, err := h.authService.GetUserPermissions(userID)
+	if err != nil {
+		respondRoleError(c, err, "Failed to resolve permissions")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Data:    permissions,
+	})
+}
+
+// respondRoleError maps role and permission errors to status codes
+func respondRoleError(c *gin.Context, err error, fallback string) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound):
+		status, code = http.StatusNotFound, "ROLE_NOT_FOUND"
+	case errors.Is(err, service.ErrPermissionNotFound):
+		status, code = http.StatusBadRequest, "PERMISSION_NOT_FOUND"
+	case errors.Is(err, service.ErrRoleExists):
+		status, code = http.StatusConflict, "ROLE_EXISTS"
+	case errors.Is(err, service.ErrPermissionExists):
+		status, code = http.StatusConflict, "PERMISSION_EXISTS"
+	case errors.Is(err, service.ErrInvalidName):
+		status, code = http.StatusBadRequest, "VALIDATION_ERROR"
+	case errors.Is(err, service.ErrBuiltInRole):
+		status, code = http.StatusConflict, "BUILT_IN_ROLE"
+	case errors.Is(err, service.ErrRoleManageLockout):
+		status, code = http.StatusConflict, "ROLE_MANAGE_LOCKOUT"
+	}
+
+	message := fallback
+	if status != http.StatusInternalServerError {
+		message = err.Error()
+	}
+
+	c.JSON(status, models.ErrorResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
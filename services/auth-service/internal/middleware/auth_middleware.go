@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
 	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/bisosad1501/DATN/shared/pkg/authz"
 	"github.com/gin-gonic/gin"
 )
 
@@ -42,11 +45,15 @@ func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
 		// Validate token
 		claims, err := authService.ValidateToken(tokenString)
 		if err != nil {
+			code, message := "INVALID_TOKEN", "Invalid or expired token"
+			if errors.Is(err, service.ErrTokenRevoked) {
+				code, message = "TOKEN_REVOKED", "Token has been revoked, please sign in again"
+			}
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 				Success: false,
 				Error: &models.ErrorData{
-					Code:    "INVALID_TOKEN",
-					Message: "Invalid or expired token",
+					Code:    code,
+					Message: message,
 				},
 			})
 			c.Abort()
@@ -57,42 +64,36 @@ func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
+		c.Set("token_claims", claims)
+		authz.Set(c, claims.Permissions)
 
 		c.Next()
 	}
 }
 
-// RoleMiddleware checks if user has required role
-func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
+// InternalAuth validates the internal API key for service-to-service calls
+func InternalAuth(internalAPIKey string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
+		apiKey := c.GetHeader("X-Internal-API-Key")
+		if apiKey == "" {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 				Success: false,
 				Error: &models.ErrorData{
-					Code:    "UNAUTHORIZED",
-					Message: "User not authenticated",
+					Code:    "MISSING_API_KEY",
+					Message: "Internal API key required",
 				},
 			})
 			c.Abort()
 			return
 		}
 
-		userRole := role.(string)
-		allowed := false
-		for _, r := range allowedRoles {
-			if userRole == r {
-				allowed = true
-				break
-			}
-		}
-
-		if !allowed {
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(internalAPIKey)) != 1 {
 			c.JSON(http.StatusForbidden, models.ErrorResponse{
 				Success: false,
 				Error: &models.ErrorData{
-					Code:    "FORBIDDEN",
-					Message: "Insufficient permissions",
+					Code:    "INVALID_API_KEY",
+					Message: "Invalid internal API key",
 				},
 			})
 			c.Abort()
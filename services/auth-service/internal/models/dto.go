@@ -1,9 +1,14 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
 	Email           string  `json:"email" binding:"required,email"`
-	Password        string  `json:"password" binding:"required,min=8"`
+	Password        string  `json:"password" binding:"required"`
 	Phone           string  `json:"phone" binding:"omitempty"`
 	Role            string  `json:"role" binding:"required,oneof=student instructor"`
 	FullName        string  `json:"fullName" binding:"omitempty"`
@@ -14,6 +19,15 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	DeviceInfo
+}
+
+// DeviceInfo is optional client metadata recorded on the session.
+// Missing fields are inferred from the User-Agent.
+type DeviceInfo struct {
+	DeviceID   string `json:"device_id" binding:"omitempty,max=255"`
+	DeviceName string `json:"device_name" binding:"omitempty,max=100"`
+	DeviceType string `json:"device_type" binding:"omitempty,oneof=web android ios"`
 }
 
 // RefreshTokenRequest represents a refresh token request
@@ -24,7 +38,25 @@ type RefreshTokenRequest struct {
 // ChangePasswordRequest represents a change password request
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=8"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangeEmailRequest starts an email change; the code goes to the new address
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	// Required when the account has a password
+	Password string `json:"password"`
+}
+
+// ConfirmEmailChangeRequest completes an email change with the emailed code
+type ConfirmEmailChangeRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// EmailChangeResponse describes a pending email change
+type EmailChangeResponse struct {
+	NewEmail  string    `json:"new_email"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // ForgotPasswordRequest represents a forgot password request
@@ -35,13 +67,13 @@ type ForgotPasswordRequest struct {
 // ResetPasswordRequest represents a reset password request
 type ResetPasswordRequest struct {
 	Token       string `json:"token" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=8"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 // ResetPasswordByCodeRequest represents a reset password request with code
 type ResetPasswordByCodeRequest struct {
 	Code        string `json:"code" binding:"required,len=6"`
-	NewPassword string `json:"new_password" binding:"required,min=8"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 // VerifyEmailByCodeRequest represents an email verification request with code
@@ -64,6 +96,110 @@ type AuthData struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	ExpiresIn    int64  `json:"expires_in"` // seconds
+
+	// Set instead of the tokens above when the login needs a second factor;
+	// ExpiresIn is then the lifetime of MFAToken.
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token,omitempty"`
+
+	// Set instead of the tokens when a high-risk login must be confirmed with
+	// a code emailed to the account; ExpiresIn is then the lifetime of StepUpToken.
+	StepUpRequired bool   `json:"step_up_required,omitempty"`
+	StepUpToken    string `json:"step_up_token,omitempty"`
+
+	// Returned once, when 2FA is enabled during the login challenge
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// MFACodeRequest carries a code from the authenticator app
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// DisableMFARequest requires both factors to turn 2FA off
+type DisableMFARequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// MFAChallengeRequest identifies a pending login challenge
+type MFAChallengeRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// PasswordlessLoginRequest asks for a one-time login code and magic link by email
+type PasswordlessLoginRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyPasswordlessLoginRequest signs in with the emailed code (with the
+// email) or with the token from the magic link
+type VerifyPasswordlessLoginRequest struct {
+	Email string `json:"email" binding:"required_without=Token,omitempty,email"`
+	Code  string `json:"code" binding:"required_without=Token,omitempty,len=6,numeric"`
+	Token string `json:"token" binding:"omitempty,max=128"`
+	DeviceInfo
+}
+
+// VerifyMFAChallengeRequest completes a login challenge with either an
+// authenticator code or a recovery code
+type VerifyMFAChallengeRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" binding:"omitempty,max=20"`
+}
+
+// VerifyLoginStepUpRequest completes a high-risk login with the emailed code
+type VerifyLoginStepUpRequest struct {
+	StepUpToken string `json:"step_up_token" binding:"required"`
+	Code        string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// MFAPolicyRequest sets whether a role must use 2FA
+type MFAPolicyRequest struct {
+	RequireMFA *bool `json:"require_mfa" binding:"required"`
+}
+
+// MFASetupResponse is the authenticator enrollment payload
+type MFASetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // render as a QR code
+	Issuer     string `json:"issuer"`
+	Account    string `json:"account"`
+}
+
+// MFAStatusResponse describes the user's 2FA state
+type MFAStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RequiredByPolicy       bool       `json:"required_by_policy"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// MFARecoveryCodesResponse shows freshly generated recovery codes; they are
+// stored hashed and cannot be retrieved again
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAPolicyResponse is the 2FA policy of one role
+type MFAPolicyResponse struct {
+	Role       string `json:"role"`
+	RequireMFA bool   `json:"require_mfa"`
+}
+
+// SessionResponse represents one signed-in device in the sessions list
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	DeviceID   *string   `json:"device_id,omitempty"`
+	DeviceName string    `json:"device_name"`
+	DeviceType string    `json:"device_type"`
+	IPAddress  *string   `json:"ip_address,omitempty"`
+	Location   string    `json:"location"`
+	SignedInAt time.Time `json:"signed_in_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
 }
 
 // ErrorData represents error data in response
@@ -85,3 +221,247 @@ type ErrorResponse struct {
 	Success bool       `json:"success"`
 	Error   *ErrorData `json:"error"`
 }
+
+// CreateRoleRequest creates a role, optionally with its initial permissions
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required,min=2,max=50"`
+	DisplayName string   `json:"display_name" binding:"required,max=100"`
+	Description string   `json:"description" binding:"max=500"`
+	Permissions []string `json:"permissions"`
+}
+
+// CreatePermissionRequest creates the permission "<resource>:<action>"
+type CreatePermissionRequest struct {
+	Resource    string `json:"resource" binding:"required,max=50"`
+	Action      string `json:"action" binding:"required,max=50"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// SetRolePermissionsRequest replaces every permission granted to a role
+type SetRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// RoleResponse is a role with the permissions it grants
+type RoleResponse struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name"`
+	Description string   `json:"description"`
+	RequireMFA  bool     `json:"require_mfa"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserPermissionsResponse is the live authorization state of a user
+type UserPermissionsResponse struct {
+	UserID      string   `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// AdminUserListQuery filters the admin user list
+type AdminUserListQuery struct {
+	Page          int       `form:"page" binding:"omitempty,min=1"`
+	Limit         int       `form:"limit" binding:"omitempty,min=1,max=100"`
+	Email         string    `form:"email" binding:"max=255"`
+	Role          string    `form:"role" binding:"max=50"`
+	Status        string    `form:"status" binding:"omitempty,oneof=active inactive locked unverified password_reset_required"`
+	CreatedFrom   time.Time `form:"created_from" time_format:"2006-01-02"`
+	CreatedBefore time.Time `form:"created_before" time_format:"2006-01-02"`
+}
+
+// AdminUserResponse is a user as seen by admins
+type AdminUserResponse struct {
+	ID                    string     `json:"id"`
+	Email                 string     `json:"email"`
+	Phone                 *string    `json:"phone,omitempty"`
+	OAuthProvider         *string    `json:"oauth_provider,omitempty"`
+	Roles                 []string   `json:"roles"`
+	IsActive              bool       `json:"is_active"`
+	IsVerified            bool       `json:"is_verified"`
+	Locked                bool       `json:"locked"`
+	LockedUntil           *time.Time `json:"locked_until,omitempty"`
+	FailedLoginAttempts   int        `json:"failed_login_attempts"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	LastLoginAt           *time.Time `json:"last_login_at,omitempty"`
+	LastLoginIP           *string    `json:"last_login_ip,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+}
+
+// AdminUserListResponse is one page of the admin user list
+type AdminUserListResponse struct {
+	Users      []AdminUserResponse `json:"users"`
+	Pagination PaginationResponse  `json:"pagination"`
+}
+
+// PaginationResponse represents pagination info
+type PaginationResponse struct {
+	Page       int `json:"page"`
+	Limit      int `json:"limit"`
+	TotalItems int `json:"total_items"`
+	TotalPages int `json:"total_pages"`
+}
+
+// AssignRoleRequest grants a role to a user
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required,max=50"`
+}
+
+// AdminAuditLogQuery filters the admin audit log list and export
+type AdminAuditLogQuery struct {
+	Page      int       `form:"page" binding:"omitempty,min=1"`
+	Limit     int       `form:"limit" binding:"omitempty,min=1,max=100"`
+	UserID    string    `form:"user_id" binding:"omitempty,uuid"`
+	EventType string    `form:"event_type" binding:"max=50"`
+	Status    string    `form:"status" binding:"omitempty,oneof=success failed"`
+	IPAddress string    `form:"ip" binding:"omitempty,ip"`
+	From      time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To        time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Archived  bool      `form:"archived"`
+}
+
+// AuditLogResponse is an audit log entry as seen by admins
+type AuditLogResponse struct {
+	ID           int64           `json:"id"`
+	UserID       *string         `json:"user_id,omitempty"`
+	UserEmail    *string         `json:"user_email,omitempty"`
+	EventType    string          `json:"event_type"`
+	EventStatus  string          `json:"event_status"`
+	IPAddress    *string         `json:"ip_address,omitempty"`
+	UserAgent    *string         `json:"user_agent,omitempty"`
+	DeviceInfo   json.RawMessage `json:"device_info,omitempty"`
+	Metadata     json.RawMessage `json:"metadata,omitempty"`
+	ErrorMessage *string         `json:"error_message,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// AuditLogListResponse is one page of the admin audit log list
+type AuditLogListResponse struct {
+	Logs       []AuditLogResponse `json:"logs"`
+	Pagination PaginationResponse `json:"pagination"`
+}
+
+// SecurityActivityQuery limits the security activity list
+type SecurityActivityQuery struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// SecurityActivityResponse is one sign-in or security change shown to the user
+type SecurityActivityResponse struct {
+	EventType  string    `json:"event_type"`
+	Status     string    `json:"status"`
+	DeviceName string    `json:"device_name,omitempty"`
+	DeviceType string    `json:"device_type,omitempty"`
+	IPAddress  *string   `json:"ip_address,omitempty"`
+	Location   string    `json:"location,omitempty"`
+	ByAdmin    bool      `json:"by_admin"` // the change was made by an administrator
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OAuthProviderResponse is a sign-in provider offered on the login page
+type OAuthProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// OAuthURLResponse is the provider consent URL and the state to send back
+type OAuthURLResponse struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+// OAuthTokenRequest exchanges an authorization code captured by the client
+type OAuthTokenRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// IdentityResponse is an external account linked to the user
+type IdentityResponse struct {
+	Provider   string     `json:"provider"`
+	Email      *string    `json:"email,omitempty"`
+	LinkedAt   time.Time  `json:"linked_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// DeleteAccountRequest confirms a self-service account deletion
+type DeleteAccountRequest struct {
+	// Required when the account has a password; OAuth-only and passwordless
+	// accounts confirm with their signed-in session
+	Password string `json:"password"`
+}
+
+// AccountDeletionResponse is an account deletion and the progress of its steps
+type AccountDeletionResponse struct {
+	ID           string                        `json:"id"`
+	UserID       string                        `json:"user_id"`
+	Status       string                        `json:"status"`
+	RequestedAt  time.Time                     `json:"requested_at"`
+	ScheduledFor time.Time                     `json:"scheduled_for"`
+	StartedAt    *time.Time                    `json:"started_at,omitempty"`
+	CompletedAt  *time.Time                    `json:"completed_at,omitempty"`
+	CancelledAt  *time.Time                    `json:"cancelled_at,omitempty"`
+	Steps        []AccountDeletionStepResponse `json:"steps"`
+}
+
+// AccountDeletionStepResponse is the erasure progress in one service
+type AccountDeletionStepResponse struct {
+	Service     string          `json:"service"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	LastError   *string         `json:"last_error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// AdminAccountDeletionQuery filters the admin account deletion list
+type AdminAccountDeletionQuery struct {
+	Page   int    `form:"page" binding:"omitempty,min=1"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Status string `form:"status" binding:"omitempty,oneof=scheduled cancelled in_progress completed failed"`
+}
+
+// AccountDeletionListResponse is one page of the admin account deletion list
+type AccountDeletionListResponse struct {
+	Requests   []AccountDeletionResponse `json:"requests"`
+	Pagination PaginationResponse        `json:"pagination"`
+}
+
+// DataExportResponse is a personal-data export job
+type DataExportResponse struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	RequestedAt time.Time  `json:"requested_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	FileSize    *int64     `json:"file_size,omitempty"`
+	Error       *string    `json:"error,omitempty"`
+}
+
+// AdminEmailOutboxQuery filters the admin email outbox list
+type AdminEmailOutboxQuery struct {
+	Page   int    `form:"page" binding:"omitempty,min=1"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Status string `form:"status" binding:"omitempty,oneof=pending sent dead"`
+}
+
+// EmailOutboxResponse is a queued email without its template data
+type EmailOutboxResponse struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"user_id"`
+	ToEmail       string     `json:"to_email"`
+	Template      string     `json:"template"`
+	Locale        *string    `json:"locale,omitempty"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+// EmailOutboxListResponse is one page of the admin email outbox list
+type EmailOutboxListResponse struct {
+	Emails     []EmailOutboxResponse `json:"emails"`
+	Pagination PaginationResponse    `json:"pagination"`
+}
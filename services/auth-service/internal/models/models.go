@@ -13,30 +13,37 @@ type User struct {
     Password *string   `db:"password_hash" json:"-"`
 	Phone    *string   `db:"phone" json:"phone,omitempty"`
 
-	// OAuth fields
-	GoogleID      *string `db:"google_id" json:"google_id,omitempty"`
+	// Provider the account was created with; linked identities are UserIdentity rows
 	OAuthProvider *string `db:"oauth_provider" json:"oauth_provider,omitempty"`
 
 	IsActive        bool       `db:"is_active" json:"is_active"`
 	IsVerified      bool       `db:"is_verified" json:"is_verified"`
 	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at,omitempty"`
 
-	FailedLoginAttempts int        `db:"failed_login_attempts" json:"-"`
-	LockedUntil         *time.Time `db:"locked_until" json:"-"`
-	LastLoginAt         *time.Time `db:"last_login_at" json:"last_login_at,omitempty"`
-	LastLoginIP         *string    `db:"last_login_ip" json:"-"`
+	FailedLoginAttempts   int        `db:"failed_login_attempts" json:"-"`
+	LockedUntil           *time.Time `db:"locked_until" json:"-"`
+	LastLoginAt           *time.Time `db:"last_login_at" json:"last_login_at,omitempty"`
+	LastLoginIP           *string    `db:"last_login_ip" json:"-"`
+	PasswordResetRequired bool       `db:"password_reset_required" json:"-"` // set by an admin, blocks password login
 
 	CreatedAt time.Time  `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`
 	DeletedAt *time.Time `db:"deleted_at" json:"-"`
 }
 
+// UserSummary is a user with the names of its roles, as listed to admins
+type UserSummary struct {
+	User
+	RoleNames string `db:"role_names"` // comma-separated
+}
+
 // Role represents a user role
 type Role struct {
 	ID          int       `db:"id" json:"id"`
 	Name        string    `db:"name" json:"name"`
 	DisplayName string    `db:"display_name" json:"display_name"`
 	Description string    `db:"description" json:"description"`
+	RequireMFA  bool      `db:"require_mfa" json:"require_mfa"`
 	CreatedAt   time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
 }
@@ -72,6 +79,9 @@ type RefreshToken struct {
 	UserAgent  *string `db:"user_agent" json:"-"`
 	IPAddress  *string `db:"ip_address" json:"ip_address,omitempty"`
 
+	FamilyID   uuid.UUID  `db:"family_id" json:"-"`
+	ReplacedBy *uuid.UUID `db:"replaced_by" json:"-"`
+
 	ExpiresAt     time.Time  `db:"expires_at" json:"expires_at"`
 	RevokedAt     *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
 	RevokedBy     *uuid.UUID `db:"revoked_by" json:"-"`
@@ -81,6 +91,29 @@ type RefreshToken struct {
 	LastUsedAt time.Time `db:"last_used_at" json:"last_used_at"`
 }
 
+// Session is the active refresh token of a token family, i.e. one login
+type Session struct {
+	FamilyID   uuid.UUID `db:"family_id"`
+	DeviceID   *string   `db:"device_id"`
+	DeviceName *string   `db:"device_name"`
+	DeviceType *string   `db:"device_type"`
+	UserAgent  *string   `db:"user_agent"`
+	IPAddress  *string   `db:"ip_address"`
+	SignedInAt time.Time `db:"signed_in_at"`
+	LastUsedAt time.Time `db:"last_used_at"`
+}
+
+// UserMFA holds a user's TOTP authenticator; EnabledAt is nil until the
+// first code has been verified
+type UserMFA struct {
+	UserID          uuid.UUID  `db:"user_id"`
+	SecretEncrypted string     `db:"secret_encrypted"`
+	EnabledAt       *time.Time `db:"enabled_at"`
+	LastUsedStep    *int64     `db:"last_used_step"`
+	CreatedAt       time.Time  `db:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at"`
+}
+
 // AuditLog represents an audit log entry
 type AuditLog struct {
 	ID           int64      `db:"id" json:"id"`
@@ -95,6 +128,12 @@ type AuditLog struct {
 	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
 }
 
+// AuditLogEntry is an audit log entry with the email of its user, as listed to admins
+type AuditLogEntry struct {
+	AuditLog
+	UserEmail *string `db:"user_email"`
+}
+
 // PasswordResetToken represents a password reset token
 type PasswordResetToken struct {
 	ID        uuid.UUID  `db:"id" json:"id"`
@@ -117,6 +156,141 @@ type EmailVerificationToken struct {
 	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
 }
 
+// UserIdentity is an external OAuth/OIDC account linked to a user
+type UserIdentity struct {
+	ID         uuid.UUID  `db:"id"`
+	UserID     uuid.UUID  `db:"user_id"`
+	Provider   string     `db:"provider"`
+	Subject    string     `db:"subject"`
+	Email      *string    `db:"email"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+}
+
+// LoginCode is a one-time passwordless login code, also sent as a magic link
+type LoginCode struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	CodeHash  string     `db:"code_hash"`
+	TokenHash string     `db:"token_hash"`
+	Attempts  int        `db:"attempts"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// EmailChangeRequest is a pending email change, confirmed with a code sent to
+// the new address
+type EmailChangeRequest struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	NewEmail  string     `db:"new_email"`
+	CodeHash  string     `db:"code_hash"`
+	Attempts  int        `db:"attempts"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// Account deletion request statuses
+const (
+	AccountDeletionScheduled  = "scheduled"
+	AccountDeletionCancelled  = "cancelled"
+	AccountDeletionInProgress = "in_progress"
+	AccountDeletionCompleted  = "completed"
+	AccountDeletionFailed     = "failed"
+)
+
+// AccountDeletionRequest is an account deletion waiting for its grace period or running
+type AccountDeletionRequest struct {
+	ID           uuid.UUID  `db:"id"`
+	UserID       uuid.UUID  `db:"user_id"`
+	Status       string     `db:"status"`
+	ScheduledFor time.Time  `db:"scheduled_for"`
+	RequestedAt  time.Time  `db:"requested_at"`
+	StartedAt    *time.Time `db:"started_at"`
+	CompletedAt  *time.Time `db:"completed_at"`
+	CancelledAt  *time.Time `db:"cancelled_at"`
+}
+
+// Account deletion step statuses
+const (
+	DeletionStepPending   = "pending"
+	DeletionStepCompleted = "completed"
+	DeletionStepFailed    = "failed"
+)
+
+// AccountDeletionStep is the erasure of the user's data in one service
+type AccountDeletionStep struct {
+	RequestID   uuid.UUID  `db:"request_id"`
+	Service     string     `db:"service"`
+	StepOrder   int        `db:"step_order"`
+	Status      string     `db:"status"`
+	Attempts    int        `db:"attempts"`
+	LastError   *string    `db:"last_error"`
+	Result      *string    `db:"result"`
+	CompletedAt *time.Time `db:"completed_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+}
+
+// Data export job statuses
+const (
+	DataExportPending    = "pending"
+	DataExportProcessing = "processing"
+	DataExportCompleted  = "completed"
+	DataExportFailed     = "failed"
+	DataExportExpired    = "expired"
+)
+
+// DataExportJob builds a ZIP of everything the platform stores about a user
+type DataExportJob struct {
+	ID           uuid.UUID  `db:"id"`
+	UserID       uuid.UUID  `db:"user_id"`
+	Status       string     `db:"status"`
+	FilePath     *string    `db:"file_path"`
+	FileSize     *int64     `db:"file_size"`
+	ErrorMessage *string    `db:"error_message"`
+	RequestedAt  time.Time  `db:"requested_at"`
+	CompletedAt  *time.Time `db:"completed_at"`
+	ExpiresAt    *time.Time `db:"expires_at"`
+}
+
+// Email outbox statuses
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailDead    = "dead" // gave up after the last retry
+)
+
+// EmailOutboxMessage is a queued email, rendered from its template when sent
+type EmailOutboxMessage struct {
+	ID            uuid.UUID  `db:"id"`
+	UserID        uuid.UUID  `db:"user_id"`
+	ToEmail       string     `db:"to_email"`
+	Template      string     `db:"template"`
+	Locale        *string    `db:"locale"`
+	Data          *string    `db:"data"` // JSON object of template fields
+	Status        string     `db:"status"`
+	Attempts      int        `db:"attempts"`
+	LastError     *string    `db:"last_error"`
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
+	CreatedAt     time.Time  `db:"created_at"`
+	SentAt        *time.Time `db:"sent_at"`
+}
+
+// LoginHistory is a successful login, compared against by the login risk checks
+type LoginHistory struct {
+	ID                int64     `db:"id"`
+	UserID            uuid.UUID `db:"user_id"`
+	DeviceFingerprint string    `db:"device_fingerprint"`
+	IPAddress         *string   `db:"ip_address"`
+	IPNetwork         *string   `db:"ip_network"`
+	CountryCode       *string   `db:"country_code"`
+	Latitude          *float64  `db:"latitude"`
+	Longitude         *float64  `db:"longitude"`
+	CreatedAt         time.Time `db:"created_at"`
+}
+
 // UserWithRoles represents a user with their roles
 type UserWithRoles struct {
 	User
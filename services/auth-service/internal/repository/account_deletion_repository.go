@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrAccountDeletionNotFound = errors.New("account deletion not found")
+	ErrAccountDeletionExists   = errors.New("account deletion already requested")
+)
+
+const accountDeletionColumns = `id, user_id, status, scheduled_for, requested_at, started_at, completed_at, cancelled_at`
+
+type AccountDeletionRepository interface {
+	// Create stores a scheduled deletion with one pending step per service, run in the given order
+	Create(req *models.AccountDeletionRequest, services []string) error
+	FindByID(requestID uuid.UUID) (*models.AccountDeletionRequest, error)
+	// FindLatestByUserID returns the user's most recent request in any status
+	FindLatestByUserID(userID uuid.UUID) (*models.AccountDeletionRequest, error)
+	List(status string, limit, offset int) ([]models.AccountDeletionRequest, int, error)
+	ListSteps(requestID uuid.UUID) ([]models.AccountDeletionStep, error)
+	// Cancel stops a deletion still in its grace period; false when it already started
+	Cancel(requestID uuid.UUID) (bool, error)
+	// ClaimDue marks up to limit requests past their grace period in progress and
+	// locks them for lease, so concurrent instances never run the same request
+	ClaimDue(limit int, lease time.Duration) ([]models.AccountDeletionRequest, error)
+	CompleteStep(requestID uuid.UUID, service, result string) error
+	// FailStep records a failed attempt and returns the attempt count; the step
+	// fails for good (failed == true) after maxAttempts
+	FailStep(requestID uuid.UUID, service, errMsg string, maxAttempts int) (attempts int, failed bool, err error)
+	// Finish closes a request as completed or failed and releases its lock
+	Finish(requestID uuid.UUID, status string) error
+	// Release keeps a request locked for retryAfter, after which the next run continues it
+	Release(requestID uuid.UUID, retryAfter time.Duration) error
+	// Retry reopens a failed request; its failed steps start over
+	Retry(requestID uuid.UUID) (bool, error)
+	// EraseUser deletes the user's auth data and strips their audit logs to the
+	// event history; counts are keyed by table
+	EraseUser(userID uuid.UUID) (deleted, anonymized map[string]int64, err error)
+}
+
+type accountDeletionRepository struct {
+	db *sqlx.DB
+}
+
+func NewAccountDeletionRepository(db *sqlx.DB) AccountDeletionRepository {
+	return &accountDeletionRepository{db: db}
+}
+
+func (r *accountDeletionRepository) Create(req *models.AccountDeletionRequest, services []string) error {
+	if req.ID == uuid.Nil {
+		req.ID = uuid.New()
+	}
+	if req.RequestedAt.IsZero() {
+		req.RequestedAt = time.Now()
+	}
+	if req.Status == "" {
+		req.Status = models.AccountDeletionScheduled
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO account_deletion_requests (id, user_id, status, scheduled_for, requested_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := tx.Exec(query, req.ID, req.UserID, req.Status, req.ScheduledFor, req.RequestedAt); err != nil {
+		if isUniqueViolation(err, "idx_account_deletion_requests_open") {
+			return ErrAccountDeletionExists
+		}
+		return fmt.Errorf("failed to create account deletion: %w", err)
+	}
+
+	for i, service := range services {
+		query := `INSERT INTO account_deletion_steps (request_id, service, step_order) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(query, req.ID, service, i+1); err != nil {
+			return fmt.Errorf("failed to create account deletion step: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *accountDeletionRepository) FindByID(requestID uuid.UUID) (*models.AccountDeletionRequest, error) {
+	query := `SELECT ` + accountDeletionColumns + ` FROM account_deletion_requests WHERE id = $1`
+	return r.findOne(query, requestID)
+}
+
+func (r *accountDeletionRepository) FindLatestByUserID(userID uuid.UUID) (*models.AccountDeletionRequest, error) {
+	query := `
+		SELECT ` + accountDeletionColumns + `
+		FROM account_deletion_requests
+		WHERE user_id = $1
+		ORDER BY requested_at DESC
+		LIMIT 1
+	`
+	return r.findOne(query, userID)
+}
+
+func (r *accountDeletionRepository) findOne(query string, arg interface{}) (*models.AccountDeletionRequest, error) {
+	var req models.AccountDeletionRequest
+	if err := r.db.Get(&req, query, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccountDeletionNotFound
+		}
+		return nil, fmt.Errorf("failed to find account deletion: %w", err)
+	}
+	return &req, nil
+}
+
+func (r *accountDeletionRepository) List(status string, limit, offset int) ([]models.AccountDeletionRequest, int, error) {
+	where := "WHERE ($1 = '' OR status = $1)"
+
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*) FROM account_deletion_requests "+where, status); err != nil {
+		return nil, 0, fmt.Errorf("failed to count account deletions: %w", err)
+	}
+
+	query := `SELECT ` + accountDeletionColumns + ` FROM account_deletion_requests ` + where + fmt.Sprintf(`
+		ORDER BY requested_at DESC
+		LIMIT %d OFFSET %d`, limit, offset)
+
+	requests := []models.AccountDeletionRequest{}
+	if err := r.db.Select(&requests, query, status); err != nil {
+		return nil, 0, fmt.Errorf("failed to list account deletions: %w", err)
+	}
+
+	return requests, total, nil
+}
+
+func (r *accountDeletionRepository) ListSteps(requestID uuid.UUID) ([]models.AccountDeletionStep, error) {
+	query := `
+		SELECT request_id, service, step_order, status, attempts, last_error, result, completed_at, updated_at
+		FROM account_deletion_steps
+		WHERE request_id = $1
+		ORDER BY step_order
+	`
+
+	steps := []models.AccountDeletionStep{}
+	if err := r.db.Select(&steps, query, requestID); err != nil {
+		return nil, fmt.Errorf("failed to list account deletion steps: %w", err)
+	}
+	return steps, nil
+}
+
+func (r *accountDeletionRepository) Cancel(requestID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE account_deletion_requests
+		SET status = $2, cancelled_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = $3
+	`
+	result, err := r.db.Exec(query, requestID, models.AccountDeletionCancelled, models.AccountDeletionScheduled)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
+
+func (r *accountDeletionRepository) ClaimDue(limit int, lease time.Duration) ([]models.AccountDeletionRequest, error) {
+	// SKIP LOCKED lets instances claim disjoint batches; the lease covers a
+	// worker that dies mid-saga, after which another instance resumes it
+	query := `
+		UPDATE account_deletion_requests
+		SET status = $3,
+		    started_at = COALESCE(started_at, NOW()),
+		    locked_until = NOW() + $2 * INTERVAL '1 second',
+		    updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM account_deletion_requests
+			WHERE status IN ($4, $3)
+			  AND scheduled_for <= NOW()
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY scheduled_for
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + accountDeletionColumns
+
+	requests := []models.AccountDeletionRequest{}
+	err := r.db.Select(&requests, query, limit, int(lease.Seconds()),
+		models.AccountDeletionInProgress, models.AccountDeletionScheduled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim account deletions: %w", err)
+	}
+	return requests, nil
+}
+
+func (r *accountDeletionRepository) CompleteStep(requestID uuid.UUID, service, result string) error {
+	query := `
+		UPDATE account_deletion_steps
+		SET status = $3, attempts = attempts + 1, last_error = NULL, result = $4,
+		    completed_at = NOW(), updated_at = NOW()
+		WHERE request_id = $1 AND service = $2
+	`
+	if _, err := r.db.Exec(query, requestID, service, models.DeletionStepCompleted, result); err != nil {
+		return fmt.Errorf("failed to complete account deletion step: %w", err)
+	}
+	return nil
+}
+
+func (r *accountDeletionRepository) FailStep(requestID uuid.UUID, service, errMsg string, maxAttempts int) (int, bool, error) {
+	query := `
+		UPDATE account_deletion_steps
+		SET attempts = attempts + 1,
+		    last_error = $3,
+		    status = CASE WHEN attempts + 1 >= $4 THEN $5 ELSE status END,
+		    updated_at = NOW()
+		WHERE request_id = $1 AND service = $2
+		RETURNING attempts, status
+	`
+
+	var step struct {
+		Attempts int    `db:"attempts"`
+		Status   string `db:"status"`
+	}
+	if err := r.db.Get(&step, query, requestID, service, errMsg, maxAttempts, models.DeletionStepFailed); err != nil {
+		return 0, false, fmt.Errorf("failed to record account deletion step failure: %w", err)
+	}
+	return step.Attempts, step.Status == models.DeletionStepFailed, nil
+}
+
+func (r *accountDeletionRepository) Finish(requestID uuid.UUID, status string) error {
+	query := `
+		UPDATE account_deletion_requests
+		SET status = $2,
+		    completed_at = CASE WHEN $2 = $3 THEN NOW() ELSE completed_at END,
+		    locked_until = NULL,
+		    updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.db.Exec(query, requestID, status, models.AccountDeletionCompleted); err != nil {
+		return fmt.Errorf("failed to finish account deletion: %w", err)
+	}
+	return nil
+}
+
+func (r *accountDeletionRepository) Release(requestID uuid.UUID, retryAfter time.Duration) error {
+	query := `
+		UPDATE account_deletion_requests
+		SET locked_until = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.db.Exec(query, requestID, int(retryAfter.Seconds())); err != nil {
+		return fmt.Errorf("failed to release account deletion: %w", err)
+	}
+	return nil
+}
+
+func (r *accountDeletionRepository) Retry(requestID uuid.UUID) (bool, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE account_deletion_requests
+		SET status = $2, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = $3
+	`
+	result, err := tx.Exec(query, requestID, models.AccountDeletionInProgress, models.AccountDeletionFailed)
+	if err != nil {
+		if isUniqueViolation(err, "idx_account_deletion_requests_open") {
+			return false, ErrAccountDeletionExists
+		}
+		return false, fmt.Errorf("failed to retry account deletion: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return false, nil
+	}
+
+	query = `
+		UPDATE account_deletion_steps
+		SET status = $2, attempts = 0, updated_at = NOW()
+		WHERE request_id = $1 AND status = $3
+	`
+	if _, err := tx.Exec(query, requestID, models.DeletionStepPending, models.DeletionStepFailed); err != nil {
+		return false, fmt.Errorf("failed to reset account deletion steps: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+func (r *accountDeletionRepository) EraseUser(userID uuid.UUID) (map[string]int64, map[string]int64, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// References the user made as an admin have no ON DELETE action
+	for _, query := range []string{
+		`UPDATE user_roles SET assigned_by = NULL WHERE assigned_by = $1`,
+		`UPDATE refresh_tokens SET revoked_by = NULL WHERE revoked_by = $1`,
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return nil, nil, fmt.Errorf("failed to clear admin references: %w", err)
+		}
+	}
+
+	// Audit logs keep the event history for security statistics, without
+	// anything that points back to the person
+	anonymized := make(map[string]int64)
+	for _, table := range []string{"audit_logs", "audit_logs_archive"} {
+		query := fmt.Sprintf(`
+			UPDATE %s
+			SET user_id = NULL, ip_address = NULL, user_agent = NULL, device_info = NULL,
+			    metadata = NULL, error_message = NULL
+			WHERE user_id = $1
+		`, table)
+		result, err := tx.Exec(query, userID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to anonymize %s: %w", table, err)
+		}
+		anonymized[table], _ = result.RowsAffected()
+	}
+
+	// Roles, sessions, MFA, identities, codes and export jobs cascade
+	result, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to delete user: %w", err)
+	}
+	deleted := make(map[string]int64)
+	deleted["users"], _ = result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, anonymized, nil
+}
@@ -2,13 +2,37 @@ package repository
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
+// AuditLogFilter narrows an audit log search; zero values match every entry
+type AuditLogFilter struct {
+	UserID    *uuid.UUID
+	EventType string
+	Status    string
+	IPAddress string
+	From      time.Time // inclusive
+	To        time.Time // exclusive
+	Archived  bool      // search audit_logs_archive instead of audit_logs
+	Limit     int
+	Offset    int
+}
+
 type AuditLogRepository interface {
 	Create(log *models.AuditLog) error
+	Search(filter AuditLogFilter) ([]models.AuditLogEntry, int, error)
+	// Each calls fn for every entry matching the filter, oldest first,
+	// stopping after filter.Limit entries when it is set
+	Each(filter AuditLogFilter, fn func(entry *models.AuditLogEntry) error) error
+	ListByUser(userID uuid.UUID, eventTypes []string, since time.Time, limit int) ([]models.AuditLog, error)
+	// Archive moves up to batchSize entries created before cutoff into
+	// audit_logs_archive and returns how many were moved
+	Archive(cutoff time.Time, batchSize int) (int64, error)
 }
 
 type auditLogRepository struct {
@@ -46,3 +70,143 @@ func (r *auditLogRepository) Create(log *models.AuditLog) error {
 
 	return nil
 }
+
+const auditLogColumns = `
+	a.id, a.user_id, a.event_type, a.event_status, a.ip_address, a.user_agent,
+	a.device_info, a.metadata, a.error_message, a.created_at, u.email AS user_email`
+
+// auditLogQuery builds the FROM and WHERE clauses of a filtered search
+func auditLogQuery(filter AuditLogFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("a.user_id = $%d", len(args)))
+	}
+	if filter.EventType != "" {
+		args = append(args, filter.EventType)
+		conditions = append(conditions, fmt.Sprintf("a.event_type = $%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("a.event_status = $%d", len(args)))
+	}
+	if filter.IPAddress != "" {
+		args = append(args, filter.IPAddress)
+		conditions = append(conditions, fmt.Sprintf("a.ip_address = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("a.created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("a.created_at < $%d", len(args)))
+	}
+
+	table := "audit_logs"
+	if filter.Archived {
+		table = "audit_logs_archive"
+	}
+	query := "FROM " + table + " a LEFT JOIN users u ON u.id = a.user_id"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return query, args
+}
+
+func (r *auditLogRepository) Search(filter AuditLogFilter) ([]models.AuditLogEntry, int, error) {
+	from, args := auditLogQuery(filter)
+
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*) "+from, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	query := "SELECT " + auditLogColumns + " " + from + fmt.Sprintf(`
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT %d OFFSET %d`, filter.Limit, filter.Offset)
+
+	entries := []models.AuditLogEntry{}
+	if err := r.db.Select(&entries, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to search audit logs: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+func (r *auditLogRepository) Each(filter AuditLogFilter, fn func(entry *models.AuditLogEntry) error) error {
+	from, args := auditLogQuery(filter)
+	query := "SELECT " + auditLogColumns + " " + from + " ORDER BY a.created_at, a.id"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := r.db.Queryx(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.AuditLogEntry
+		if err := rows.StructScan(&entry); err != nil {
+			return fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *auditLogRepository) ListByUser(userID uuid.UUID, eventTypes []string, since time.Time, limit int) ([]models.AuditLog, error) {
+	query, args, err := sqlx.In(`
+		SELECT id, user_id, event_type, event_status, ip_address, user_agent,
+		       device_info, metadata, error_message, created_at
+		FROM audit_logs
+		WHERE user_id = ? AND event_type IN (?) AND created_at >= ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, userID, eventTypes, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build audit log query: %w", err)
+	}
+
+	logs := []models.AuditLog{}
+	if err := r.db.Select(&logs, r.db.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+func (r *auditLogRepository) Archive(cutoff time.Time, batchSize int) (int64, error) {
+	query := `
+		WITH moved AS (
+			DELETE FROM audit_logs
+			WHERE id IN (
+				SELECT id FROM audit_logs
+				WHERE created_at < $1
+				ORDER BY id
+				LIMIT $2
+			)
+			RETURNING id, user_id, event_type, event_status, ip_address, user_agent,
+			          device_info, metadata, error_message, created_at
+		)
+		INSERT INTO audit_logs_archive (
+			id, user_id, event_type, event_status, ip_address, user_agent,
+			device_info, metadata, error_message, created_at
+		)
+		SELECT * FROM moved
+		ON CONFLICT (id) DO NOTHING
+	`
+
+	result, err := r.db.Exec(query, cutoff, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive audit logs: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrDataExportNotFound = errors.New("data export not found")
+	ErrDataExportExists   = errors.New("data export already in progress")
+)
+
+const dataExportColumns = `id, user_id, status, file_path, file_size, error_message, requested_at, completed_at, expires_at`
+
+// authDataQueries select what auth_db stores about a user, keyed by table.
+// Secrets (password, token and code hashes, the TOTP secret) are left out.
+var authDataQueries = map[string]string{
+	"users": `
+		SELECT id, email, phone, oauth_provider, is_active, is_verified, email_verified_at,
+		       last_login_at, last_login_ip, created_at, updated_at
+		FROM users WHERE id = $1`,
+	"user_roles": `
+		SELECT r.name AS role, ur.assigned_at
+		FROM user_roles ur INNER JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1`,
+	"user_identities": `
+		SELECT provider, subject, email, created_at, last_used_at
+		FROM user_identities WHERE user_id = $1`,
+	"user_mfa": `
+		SELECT enabled_at, created_at, updated_at
+		FROM user_mfa WHERE user_id = $1`,
+	"sessions": `
+		SELECT family_id AS session_id, device_name, device_type, user_agent, ip_address,
+		       created_at, last_used_at, expires_at, revoked_at, revoked_reason
+		FROM refresh_tokens WHERE user_id = $1`,
+	"login_history": `
+		SELECT ip_address, ip_network, country_code, latitude, longitude, created_at
+		FROM login_history WHERE user_id = $1`,
+	"audit_logs": `
+		SELECT event_type, event_status, ip_address, user_agent, device_info, metadata, created_at
+		FROM audit_logs WHERE user_id = $1
+		UNION ALL
+		SELECT event_type, event_status, ip_address, user_agent, device_info, metadata, created_at
+		FROM audit_logs_archive WHERE user_id = $1`,
+}
+
+type DataExportRepository interface {
+	Create(job *models.DataExportJob) error
+	FindByID(jobID uuid.UUID) (*models.DataExportJob, error)
+	FindLatestByUserID(userID uuid.UUID) (*models.DataExportJob, error)
+	// ClaimPending marks up to limit pending jobs processing and locks them for lease;
+	// jobs whose worker died are claimed again once the lease is over
+	ClaimPending(limit int, lease time.Duration) ([]models.DataExportJob, error)
+	Complete(jobID uuid.UUID, filePath string, fileSize int64, expiresAt time.Time) error
+	Fail(jobID uuid.UUID, errMsg string) error
+	// Expire marks completed jobs past their expiry expired and returns them
+	Expire(limit int) ([]models.DataExportJob, error)
+	// ListFiles returns the ZIP paths of the user's exports that still exist
+	ListFiles(userID uuid.UUID) ([]string, error)
+	// ExportUser returns the user's auth data as JSON arrays keyed by table
+	ExportUser(userID uuid.UUID) (map[string]json.RawMessage, error)
+}
+
+type dataExportRepository struct {
+	db *sqlx.DB
+}
+
+func NewDataExportRepository(db *sqlx.DB) DataExportRepository {
+	return &dataExportRepository{db: db}
+}
+
+func (r *dataExportRepository) Create(job *models.DataExportJob) error {
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
+	if job.RequestedAt.IsZero() {
+		job.RequestedAt = time.Now()
+	}
+	if job.Status == "" {
+		job.Status = models.DataExportPending
+	}
+
+	query := `INSERT INTO data_export_jobs (id, user_id, status, requested_at) VALUES ($1, $2, $3, $4)`
+	if _, err := r.db.Exec(query, job.ID, job.UserID, job.Status, job.RequestedAt); err != nil {
+		if isUniqueViolation(err, "idx_data_export_jobs_open") {
+			return ErrDataExportExists
+		}
+		return fmt.Errorf("failed to create data export: %w", err)
+	}
+	return nil
+}
+
+func (r *dataExportRepository) FindByID(jobID uuid.UUID) (*models.DataExportJob, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_export_jobs WHERE id = $1`
+	return r.findOne(query, jobID)
+}
+
+func (r *dataExportRepository) FindLatestByUserID(userID uuid.UUID) (*models.DataExportJob, error) {
+	query := `
+		SELECT ` + dataExportColumns + `
+		FROM data_export_jobs
+		WHERE user_id = $1
+		ORDER BY requested_at DESC
+		LIMIT 1
+	`
+	return r.findOne(query, userID)
+}
+
+func (r *dataExportRepository) findOne(query string, arg interface{}) (*models.DataExportJob, error) {
+	var job models.DataExportJob
+	if err := r.db.Get(&job, query, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDataExportNotFound
+		}
+		return nil, fmt.Errorf("failed to find data export: %w", err)
+	}
+	return &job, nil
+}
+
+func (r *dataExportRepository) ClaimPending(limit int, lease time.Duration) ([]models.DataExportJob, error) {
+	query := `
+		UPDATE data_export_jobs
+		SET status = $3, locked_until = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM data_export_jobs
+			WHERE status IN ($4, $3)
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY requested_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + dataExportColumns
+
+	jobs := []models.DataExportJob{}
+	err := r.db.Select(&jobs, query, limit, int(lease.Seconds()), models.DataExportProcessing, models.DataExportPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim data exports: %w", err)
+	}
+	return jobs, nil
+}
+
+func (r *dataExportRepository) Complete(jobID uuid.UUID, filePath string, fileSize int64, expiresAt time.Time) error {
+	query := `
+		UPDATE data_export_jobs
+		SET status = $2, file_path = $3, file_size = $4, expires_at = $5,
+		    completed_at = NOW(), locked_until = NULL
+		WHERE id = $1
+	`
+	if _, err := r.db.Exec(query, jobID, models.DataExportCompleted, filePath, fileSize, expiresAt); err != nil {
+		return fmt.Errorf("failed to complete data export: %w", err)
+	}
+	return nil
+}
+
+func (r *dataExportRepository) Fail(jobID uuid.UUID, errMsg string) error {
+	query := `
+		UPDATE data_export_jobs
+		SET status = $2, error_message = $3, completed_at = NOW(), locked_until = NULL
+		WHERE id = $1
+	`
+	if _, err := r.db.Exec(query, jobID, models.DataExportFailed, errMsg); err != nil {
+		return fmt.Errorf("failed to fail data export: %w", err)
+	}
+	return nil
+}
+
+func (r *dataExportRepository) Expire(limit int) ([]models.DataExportJob, error) {
+	query := `
+		UPDATE data_export_jobs
+		SET status = $2
+		WHERE id IN (
+			SELECT id FROM data_export_jobs
+			WHERE status = $3 AND expires_at < NOW()
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + dataExportColumns
+
+	jobs := []models.DataExportJob{}
+	if err := r.db.Select(&jobs, query, limit, models.DataExportExpired, models.DataExportCompleted); err != nil {
+		return nil, fmt.Errorf("failed to expire data exports: %w", err)
+	}
+	return jobs, nil
+}
+
+func (r *dataExportRepository) ListFiles(userID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT file_path FROM data_export_jobs
+		WHERE user_id = $1 AND status = $2 AND file_path IS NOT NULL
+	`
+
+	paths := []string{}
+	if err := r.db.Select(&paths, query, userID, models.DataExportCompleted); err != nil {
+		return nil, fmt.Errorf("failed to list data export files: %w", err)
+	}
+	return paths, nil
+}
+
+func (r *dataExportRepository) ExportUser(userID uuid.UUID) (map[string]json.RawMessage, error) {
+	data := make(map[string]json.RawMessage, len(authDataQueries))
+	for table, selectQuery := range authDataQueries {
+		query := `SELECT COALESCE(json_agg(t), '[]'::json) FROM (` + selectQuery + `) t`
+
+		var rows []byte
+		if err := r.db.Get(&rows, query, userID); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		data[table] = rows
+	}
+	return data, nil
+}
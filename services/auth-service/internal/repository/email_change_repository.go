@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrEmailChangeNotFound is returned when there is no pending, unexpired email change
+var ErrEmailChangeNotFound = errors.New("email change not found or expired")
+
+type EmailChangeRepository interface {
+	// Replace deletes the user's pending changes and stores a new one
+	Replace(change *models.EmailChangeRequest) error
+	FindPendingByUserID(userID uuid.UUID) (*models.EmailChangeRequest, error)
+	// IncrementAttempts records a wrong code and returns the new attempt count
+	IncrementAttempts(changeID uuid.UUID) (int, error)
+	// MarkUsed consumes the code; false means it was already used
+	MarkUsed(changeID uuid.UUID) (bool, error)
+}
+
+type emailChangeRepository struct {
+	db *sqlx.DB
+}
+
+func NewEmailChangeRepository(db *sqlx.DB) EmailChangeRepository {
+	return &emailChangeRepository{db: db}
+}
+
+func (r *emailChangeRepository) Replace(change *models.EmailChangeRequest) error {
+	if change.ID == uuid.Nil {
+		change.ID = uuid.New()
+	}
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM email_change_requests WHERE user_id = $1`, change.UserID); err != nil {
+		return fmt.Errorf("failed to delete pending email changes: %w", err)
+	}
+
+	query := `
+		INSERT INTO email_change_requests (id, user_id, new_email, code_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	if _, err := tx.Exec(query, change.ID, change.UserID, change.NewEmail, change.CodeHash, change.ExpiresAt, change.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create email change: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *emailChangeRepository) FindPendingByUserID(userID uuid.UUID) (*models.EmailChangeRequest, error) {
+	query := `
+		SELECT id, user_id, new_email, code_hash, attempts, expires_at, used_at, created_at
+		FROM email_change_requests
+		WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	var change models.EmailChangeRequest
+	if err := r.db.Get(&change, query, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrEmailChangeNotFound
+		}
+		return nil, fmt.Errorf("failed to find email change: %w", err)
+	}
+	return &change, nil
+}
+
+func (r *emailChangeRepository) IncrementAttempts(changeID uuid.UUID) (int, error) {
+	query := `UPDATE email_change_requests SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts`
+
+	var attempts int
+	if err := r.db.Get(&attempts, query, changeID); err != nil {
+		return 0, fmt.Errorf("failed to record email change attempt: %w", err)
+	}
+	return attempts, nil
+}
+
+func (r *emailChangeRepository) MarkUsed(changeID uuid.UUID) (bool, error) {
+	// used_at IS NULL makes concurrent confirmations race for a single success
+	result, err := r.db.Exec(`UPDATE email_change_requests SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`, changeID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark email change as used: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
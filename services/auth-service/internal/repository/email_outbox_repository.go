@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrEmailNotFound = errors.New("email not found")
+
+const emailOutboxColumns = `id, user_id, to_email, template, locale, data, status, attempts, last_error, next_attempt_at, created_at, sent_at`
+
+type EmailOutboxRepository interface {
+	// Enqueue stores a pending email; data is the JSON object of template fields
+	Enqueue(msg *models.EmailOutboxMessage) error
+	FindByID(messageID uuid.UUID) (*models.EmailOutboxMessage, error)
+	List(status string, limit, offset int) ([]models.EmailOutboxMessage, int, error)
+	// ClaimDue locks up to limit due emails for lease, so concurrent
+	// instances never send the same email
+	ClaimDue(limit int, lease time.Duration) ([]models.EmailOutboxMessage, error)
+	// MarkSent records delivery and drops the template data, which holds codes.
+	// An empty locale leaves the stored one unchanged.
+	MarkSent(messageID uuid.UUID, locale string) error
+	// Fail records a failed attempt and schedules the next one after
+	// retryAfter; the email goes dead (dead == true) after maxAttempts
+	Fail(messageID uuid.UUID, locale, errMsg string, retryAfter time.Duration, maxAttempts int) (dead bool, err error)
+	// Retry requeues a dead email; false when it is not dead
+	Retry(messageID uuid.UUID) (bool, error)
+}
+
+type emailOutboxRepository struct {
+	db *sqlx.DB
+}
+
+func NewEmailOutboxRepository(db *sqlx.DB) EmailOutboxRepository {
+	return &emailOutboxRepository{db: db}
+}
+
+func (r *emailOutboxRepository) Enqueue(msg *models.EmailOutboxMessage) error {
+	if msg.ID == uuid.Nil {
+		msg.ID = uuid.New()
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	msg.Status = models.EmailPending
+	msg.NextAttemptAt = msg.CreatedAt
+
+	query := `
+		INSERT INTO email_outbox (id, user_id, to_email, template, data, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6, $7, $8)
+	`
+	_, err := r.db.Exec(query, msg.ID, msg.UserID, msg.ToEmail, msg.Template, msg.Data,
+		msg.Status, msg.NextAttemptAt, msg.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue email: %w", err)
+	}
+	return nil
+}
+
+func (r *emailOutboxRepository) FindByID(messageID uuid.UUID) (*models.EmailOutboxMessage, error) {
+	query := `SELECT ` + emailOutboxColumns + ` FROM email_outbox WHERE id = $1`
+
+	var msg models.EmailOutboxMessage
+	if err := r.db.Get(&msg, query, messageID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrEmailNotFound
+		}
+		return nil, fmt.Errorf("failed to find email: %w", err)
+	}
+	return &msg, nil
+}
+
+func (r *emailOutboxRepository) List(status string, limit, offset int) ([]models.EmailOutboxMessage, int, error) {
+	where := "WHERE ($1 = '' OR status = $1)"
+
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*) FROM email_outbox "+where, status); err != nil {
+		return nil, 0, fmt.Errorf("failed to count emails: %w", err)
+	}
+
+	query := `SELECT ` + emailOutboxColumns + ` FROM email_outbox ` + where + fmt.Sprintf(`
+		ORDER BY created_at DESC
+		LIMIT %d OFFSET %d`, limit, offset)
+
+	messages := []models.EmailOutboxMessage{}
+	if err := r.db.Select(&messages, query, status); err != nil {
+		return nil, 0, fmt.Errorf("failed to list emails: %w", err)
+	}
+
+	return messages, total, nil
+}
+
+func (r *emailOutboxRepository) ClaimDue(limit int, lease time.Duration) ([]models.EmailOutboxMessage, error) {
+	// The lease covers a sender that dies mid-batch, after which another
+	// instance picks the emails up again
+	query := `
+		UPDATE email_outbox
+		SET locked_until = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = $3
+			  AND next_attempt_at <= NOW()
+			  AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + emailOutboxColumns
+
+	messages := []models.EmailOutboxMessage{}
+	if err := r.db.Select(&messages, query, limit, int(lease.Seconds()), models.EmailPending); err != nil {
+		return nil, fmt.Errorf("failed to claim emails: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *emailOutboxRepository) MarkSent(messageID uuid.UUID, locale string) error {
+	query := `
+		UPDATE email_outbox
+		SET status = $2, attempts = attempts + 1, locale = COALESCE(NULLIF($3, ''), locale),
+		    data = NULL, last_error = NULL, locked_until = NULL, sent_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := r.db.Exec(query, messageID, models.EmailSent, locale); err != nil {
+		return fmt.Errorf("failed to mark email as sent: %w", err)
+	}
+	return nil
+}
+
+func (r *emailOutboxRepository) Fail(messageID uuid.UUID, locale, errMsg string, retryAfter time.Duration, maxAttempts int) (bool, error) {
+	query := `
+		UPDATE email_outbox
+		SET attempts = attempts + 1,
+		    locale = COALESCE(NULLIF($2, ''), locale),
+		    last_error = $3,
+		    status = CASE WHEN attempts + 1 >= $5 THEN $6 ELSE status END,
+		    next_attempt_at = NOW() + $4 * INTERVAL '1 second',
+		    locked_until = NULL
+		WHERE id = $1
+		RETURNING status
+	`
+
+	var status string
+	err := r.db.Get(&status, query, messageID, locale, errMsg, int(retryAfter.Seconds()), maxAttempts, models.EmailDead)
+	if err != nil {
+		return false, fmt.Errorf("failed to record email failure: %w", err)
+	}
+	return status == models.EmailDead, nil
+}
+
+func (r *emailOutboxRepository) Retry(messageID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE email_outbox
+		SET status = $2, attempts = 0, next_attempt_at = NOW(), locked_until = NULL
+		WHERE id = $1 AND status = $3
+	`
+	result, err := r.db.Exec(query, messageID, models.EmailPending, models.EmailDead)
+	if err != nil {
+		return false, fmt.Errorf("failed to retry email: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrIdentityNotFound is returned when no identity matches
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrIdentityLinkedElsewhere is returned when the external account belongs to another user
+	ErrIdentityLinkedElsewhere = errors.New("identity is linked to another account")
+	// ErrProviderAlreadyLinked is returned when the user already has an identity from the provider
+	ErrProviderAlreadyLinked = errors.New("an identity from this provider is already linked")
+	// ErrEmailTaken is returned when creating a user, or changing an email, to an
+	// address that is already registered
+	ErrEmailTaken = errors.New("email is already registered")
+)
+
+type IdentityRepository interface {
+	// FindUser returns the active user linked to the provider's subject
+	FindUser(provider, subject string) (*models.User, error)
+	ListByUser(userID uuid.UUID) ([]models.UserIdentity, error)
+	Link(identity *models.UserIdentity) error
+	Unlink(userID uuid.UUID, provider string) error
+	MarkUsed(provider, subject string) error
+	// CreateUser creates a password-less user together with its identity
+	CreateUser(email string, emailVerified bool, identity *models.UserIdentity) (*models.User, error)
+}
+
+type identityRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdentityRepository(db *sqlx.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) FindUser(provider, subject string) (*models.User, error) {
+	query := `
+		SELECT u.id, u.email, u.password_hash, u.phone, u.oauth_provider,
+		       u.is_active, u.is_verified, u.email_verified_at,
+		       u.failed_login_attempts, u.locked_until, u.last_login_at, u.last_login_ip,
+		       u.password_reset_required, u.created_at, u.updated_at, u.deleted_at
+		FROM user_identities i
+		INNER JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2 AND u.deleted_at IS NULL
+	`
+
+	var user models.User
+	if err := r.db.Get(&user, query, provider, subject); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("failed to find user by identity: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (r *identityRepository) ListByUser(userID uuid.UUID) ([]models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, email, created_at, last_used_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	identities := []models.UserIdentity{}
+	if err := r.db.Select(&identities, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+func (r *identityRepository) Link(identity *models.UserIdentity) error {
+	return linkIdentity(r.db, identity)
+}
+
+func (r *identityRepository) Unlink(userID uuid.UUID, provider string) error {
+	result, err := r.db.Exec(`DELETE FROM user_identities WHERE user_id = $1 AND provider = $2`, userID, provider)
+	if err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrIdentityNotFound
+	}
+
+	return nil
+}
+
+func (r *identityRepository) MarkUsed(provider, subject string) error {
+	query := `UPDATE user_identities SET last_used_at = $3 WHERE provider = $1 AND subject = $2`
+
+	if _, err := r.db.Exec(query, provider, subject, time.Now()); err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+
+	return nil
+}
+
+func (r *identityRepository) CreateUser(email string, emailVerified bool, identity *models.UserIdentity) (*models.User, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	user := &models.User{
+		ID:            uuid.New(),
+		Email:         email,
+		OAuthProvider: &identity.Provider,
+		IsActive:      true,
+		IsVerified:    emailVerified,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if emailVerified {
+		user.EmailVerifiedAt = &now
+	}
+
+	query := `
+		INSERT INTO users (id, email, oauth_provider, is_active, is_verified,
+		                   email_verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err = tx.Exec(query, user.ID, user.Email, user.OAuthProvider, user.IsActive, user.IsVerified,
+		user.EmailVerifiedAt, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err, "idx_users_email_unique") {
+			return nil, ErrEmailTaken
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	identity.UserID = user.ID
+	if err := linkIdentity(tx, identity); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return user, nil
+}
+
+func linkIdentity(db sqlx.Execer, identity *models.UserIdentity) error {
+	if identity.ID == uuid.Nil {
+		identity.ID = uuid.New()
+	}
+	now := time.Now()
+	identity.CreatedAt = now
+	identity.LastUsedAt = &now
+
+	query := `
+		INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := db.Exec(query, identity.ID, identity.UserID, identity.Provider, identity.Subject,
+		identity.Email, identity.CreatedAt, identity.LastUsedAt)
+	if err != nil {
+		if isUniqueViolation(err, "user_identities_provider_subject_key") {
+			return ErrIdentityLinkedElsewhere
+		}
+		if isUniqueViolation(err, "user_identities_user_id_provider_key") {
+			return ErrProviderAlreadyLinked
+		}
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
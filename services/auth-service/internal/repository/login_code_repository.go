@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrLoginCodeNotFound is returned when there is no pending, unexpired code
+var ErrLoginCodeNotFound = errors.New("login code not found or expired")
+
+type LoginCodeRepository interface {
+	// Replace deletes the user's pending codes and stores a new one
+	Replace(code *models.LoginCode) error
+	FindPendingByUserID(userID uuid.UUID) (*models.LoginCode, error)
+	FindPendingByTokenHash(tokenHash string) (*models.LoginCode, error)
+	// IncrementAttempts records a wrong code and returns the new attempt count
+	IncrementAttempts(codeID uuid.UUID) (int, error)
+	// MarkUsed consumes the code; false means it was already used
+	MarkUsed(codeID uuid.UUID) (bool, error)
+}
+
+type loginCodeRepository struct {
+	db *sqlx.DB
+}
+
+func NewLoginCodeRepository(db *sqlx.DB) LoginCodeRepository {
+	return &loginCodeRepository{db: db}
+}
+
+func (r *loginCodeRepository) Replace(code *models.LoginCode) error {
+	if code.ID == uuid.Nil {
+		code.ID = uuid.New()
+	}
+	if code.CreatedAt.IsZero() {
+		code.CreatedAt = time.Now()
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM login_codes WHERE user_id = $1`, code.UserID); err != nil {
+		return fmt.Errorf("failed to delete pending login codes: %w", err)
+	}
+
+	query := `
+		INSERT INTO login_codes (id, user_id, code_hash, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	if _, err := tx.Exec(query, code.ID, code.UserID, code.CodeHash, code.TokenHash, code.ExpiresAt, code.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create login code: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *loginCodeRepository) FindPendingByUserID(userID uuid.UUID) (*models.LoginCode, error) {
+	query := `
+		SELECT id, user_id, code_hash, token_hash, attempts, expires_at, used_at, created_at
+		FROM login_codes
+		WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+	return r.findOne(query, userID)
+}
+
+func (r *loginCodeRepository) FindPendingByTokenHash(tokenHash string) (*models.LoginCode, error) {
+	query := `
+		SELECT id, user_id, code_hash, token_hash, attempts, expires_at, used_at, created_at
+		FROM login_codes
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+	`
+	return r.findOne(query, tokenHash)
+}
+
+func (r *loginCodeRepository) findOne(query string, arg interface{}) (*models.LoginCode, error) {
+	var code models.LoginCode
+	if err := r.db.Get(&code, query, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrLoginCodeNotFound
+		}
+		return nil, fmt.Errorf("failed to find login code: %w", err)
+	}
+	return &code, nil
+}
+
+func (r *loginCodeRepository) IncrementAttempts(codeID uuid.UUID) (int, error) {
+	query := `UPDATE login_codes SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts`
+
+	var attempts int
+	if err := r.db.Get(&attempts, query, codeID); err != nil {
+		return 0, fmt.Errorf("failed to record login code attempt: %w", err)
+	}
+	return attempts, nil
+}
+
+func (r *loginCodeRepository) MarkUsed(codeID uuid.UUID) (bool, error) {
+	// used_at IS NULL makes concurrent attempts race for a single success
+	result, err := r.db.Exec(`UPDATE login_codes SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`, codeID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark login code as used: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const loginHistoryColumns = `id, user_id, device_fingerprint, ip_address, ip_network, country_code, latitude, longitude, created_at`
+
+type LoginHistoryRepository interface {
+	Create(entry *models.LoginHistory) error
+	// ListSince returns the user's logins after since, newest first
+	ListSince(userID uuid.UUID, since time.Time, limit int) ([]models.LoginHistory, error)
+}
+
+type loginHistoryRepository struct {
+	db *sqlx.DB
+}
+
+func NewLoginHistoryRepository(db *sqlx.DB) LoginHistoryRepository {
+	return &loginHistoryRepository{db: db}
+}
+
+func (r *loginHistoryRepository) Create(entry *models.LoginHistory) error {
+	query := `
+		INSERT INTO login_history (
+			user_id, device_fingerprint, ip_address, ip_network, country_code, latitude, longitude
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowx(query,
+		entry.UserID,
+		entry.DeviceFingerprint,
+		entry.IPAddress,
+		entry.IPNetwork,
+		entry.CountryCode,
+		entry.Latitude,
+		entry.Longitude,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record login: %w", err)
+	}
+	return nil
+}
+
+func (r *loginHistoryRepository) ListSince(userID uuid.UUID, since time.Time, limit int) ([]models.LoginHistory, error) {
+	query := `
+		SELECT ` + loginHistoryColumns + `
+		FROM login_history
+		WHERE user_id = $1 AND created_at >= $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`
+
+	entries := []models.LoginHistory{}
+	if err := r.db.Select(&entries, query, userID, since, limit); err != nil {
+		return nil, fmt.Errorf("failed to list login history: %w", err)
+	}
+	return entries, nil
+}
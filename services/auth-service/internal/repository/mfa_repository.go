@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrMFANotEnrolled is returned when the user has no authenticator on file
+	ErrMFANotEnrolled = errors.New("mfa not enrolled")
+	// ErrMFAAlreadyEnabled is returned when replacing or enabling an active authenticator
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+)
+
+type MFARepository interface {
+	FindByUserID(userID uuid.UUID) (*models.UserMFA, error)
+	// SavePendingSecret stores a new secret that is not active until Enable
+	SavePendingSecret(userID uuid.UUID, secretEncrypted string) error
+	Enable(userID uuid.UUID, step int64) error
+	// Disable removes the authenticator and every recovery code
+	Disable(userID uuid.UUID) error
+	// MarkStepUsed records an accepted TOTP step; false means it was already used
+	MarkStepUsed(userID uuid.UUID, step int64) (bool, error)
+
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	// UseRecoveryCode consumes a code; false means it was unknown or already used
+	UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error)
+	CountRecoveryCodes(userID uuid.UUID) (int, error)
+}
+
+type mfaRepository struct {
+	db *sqlx.DB
+}
+
+func NewMFARepository(db *sqlx.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) FindByUserID(userID uuid.UUID) (*models.UserMFA, error) {
+	query := `
+		SELECT user_id, secret_encrypted, enabled_at, last_used_step, created_at, updated_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	var mfa models.UserMFA
+	err := r.db.Get(&mfa, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("failed to find mfa: %w", err)
+	}
+
+	return &mfa, nil
+}
+
+func (r *mfaRepository) SavePendingSecret(userID uuid.UUID, secretEncrypted string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret_encrypted, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret_encrypted = EXCLUDED.secret_encrypted, enabled_at = NULL,
+		    last_used_step = NULL, updated_at = EXCLUDED.updated_at
+		WHERE user_mfa.enabled_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, secretEncrypted, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save mfa secret: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) Enable(userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_mfa
+		SET enabled_at = $2, last_used_step = $3, updated_at = $2
+		WHERE user_id = $1 AND enabled_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, time.Now(), step)
+	if err != nil {
+		return fmt.Errorf("failed to enable mfa: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) Disable(userID uuid.UUID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to disable mfa: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit mfa disable: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) MarkStepUsed(userID uuid.UUID, step int64) (bool, error) {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step = $2, updated_at = $3
+		WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)
+	`
+
+	result, err := r.db.Exec(query, userID, step, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to record mfa step: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	for _, hash := range codeHashes {
+		query := `INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(query, userID, hash, now); err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit recovery codes: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $3
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, codeHash, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+func (r *mfaRepository) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	if err := r.db.Get(&count, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type PasswordHistoryRepository interface {
+	// Add records a password hash and keeps only the user's newest keep entries
+	Add(userID uuid.UUID, passwordHash string, keep int) error
+	// ListRecent returns the user's newest password hashes, newest first
+	ListRecent(userID uuid.UUID, limit int) ([]string, error)
+}
+
+type passwordHistoryRepository struct {
+	db *sqlx.DB
+}
+
+func NewPasswordHistoryRepository(db *sqlx.DB) PasswordHistoryRepository {
+	return &passwordHistoryRepository{db: db}
+}
+
+func (r *passwordHistoryRepository) Add(userID uuid.UUID, passwordHash string, keep int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO password_history (user_id, password_hash) VALUES ($1, $2)`, userID, passwordHash); err != nil {
+		return fmt.Errorf("failed to add password history: %w", err)
+	}
+
+	query := `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		)
+	`
+	if _, err := tx.Exec(query, userID, keep); err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *passwordHistoryRepository) ListRecent(userID uuid.UUID, limit int) ([]string, error) {
+	query := `
+		SELECT password_hash FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	hashes := []string{}
+	if err := r.db.Select(&hashes, query, userID, limit); err != nil {
+		return nil, fmt.Errorf("failed to list password history: %w", err)
+	}
+	return hashes, nil
+}
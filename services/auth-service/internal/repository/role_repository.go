@@ -2,18 +2,45 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
+var (
+	// ErrRoleNotFound is returned when no role has the given name
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleExists is returned when creating a role whose name is taken
+	ErrRoleExists = errors.New("role already exists")
+	// ErrPermissionNotFound is returned when a permission name is unknown
+	ErrPermissionNotFound = errors.New("permission not found")
+	// ErrPermissionExists is returned when creating a permission whose name is taken
+	ErrPermissionExists = errors.New("permission already exists")
+)
+
 type RoleRepository interface {
 	FindByName(name string) (*models.Role, error)
 	FindByUserID(userID uuid.UUID) ([]models.Role, error)
 	AssignRoleToUser(userID uuid.UUID, roleID int, assignedBy *uuid.UUID) error
 	RemoveRoleFromUser(userID uuid.UUID, roleID int) error
+	ListRoles() ([]models.Role, error)
+	SetRequireMFA(roleName string, require bool) error
+	CreateRole(role *models.Role) error
+	DeleteRole(roleName string) error
+
+	// FindPermissionsByUserID returns the names of every permission granted
+	// through any of the user's roles
+	FindPermissionsByUserID(userID uuid.UUID) ([]string, error)
+	// ListRolePermissions maps role IDs to their permission names
+	ListRolePermissions() (map[int][]string, error)
+	ListPermissions() ([]models.Permission, error)
+	CreatePermission(permission *models.Permission) error
+	// SetRolePermissions replaces the permissions granted to a role
+	SetRolePermissions(roleID int, permissionNames []string) error
 }
 
 type roleRepository struct {
@@ -25,13 +52,13 @@ func NewRoleRepository(db *sqlx.DB) RoleRepository {
 }
 
 func (r *roleRepository) FindByName(name string) (*models.Role, error) {
-	query := `SELECT id, name, display_name, description, created_at, updated_at FROM roles WHERE name = $1`
+	query := `SELECT id, name, display_name, description, require_mfa, created_at, updated_at FROM roles WHERE name = $1`
 
 	var role models.Role
 	err := r.db.Get(&role, query, name)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("role not found")
+			return nil, ErrRoleNotFound
 		}
 		return nil, fmt.Errorf("failed to find role: %w", err)
 	}
@@ -41,7 +68,7 @@ func (r *roleRepository) FindByName(name string) (*models.Role, error) {
 
 func (r *roleRepository) FindByUserID(userID uuid.UUID) ([]models.Role, error) {
 	query := `
-		SELECT r.id, r.name, r.display_name, r.description, r.created_at, r.updated_at
+		SELECT r.id, r.name, r.display_name, r.description, r.require_mfa, r.created_at, r.updated_at
 		FROM roles r
 		INNER JOIN user_roles ur ON r.id = ur.role_id
 		WHERE ur.user_id = $1
@@ -81,3 +108,170 @@ func (r *roleRepository) RemoveRoleFromUser(userID uuid.UUID, roleID int) error
 
 	return nil
 }
+
+func (r *roleRepository) ListRoles() ([]models.Role, error) {
+	query := `SELECT id, name, display_name, description, require_mfa, created_at, updated_at FROM roles ORDER BY id`
+
+	roles := []models.Role{}
+	if err := r.db.Select(&roles, query); err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (r *roleRepository) SetRequireMFA(roleName string, require bool) error {
+	query := `UPDATE roles SET require_mfa = $2, updated_at = $3 WHERE name = $1`
+
+	result, err := r.db.Exec(query, roleName, require, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update role policy: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func (r *roleRepository) CreateRole(role *models.Role) error {
+	query := `
+		INSERT INTO roles (name, display_name, description, require_mfa, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, created_at, updated_at
+	`
+
+	err := r.db.QueryRowx(query, role.Name, role.DisplayName, role.Description, role.RequireMFA, time.Now()).
+		Scan(&role.ID, &role.CreatedAt, &role.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRoleExists
+		}
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	return nil
+}
+
+func (r *roleRepository) DeleteRole(roleName string) error {
+	result, err := r.db.Exec(`DELETE FROM roles WHERE name = $1`, roleName)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrRoleNotFound
+	}
+
+	return nil
+}
+
+func (r *roleRepository) FindPermissionsByUserID(userID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT DISTINCT p.name
+		FROM permissions p
+		INNER JOIN role_permissions rp ON p.id = rp.permission_id
+		INNER JOIN user_roles ur ON rp.role_id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+
+	permissions := []string{}
+	if err := r.db.Select(&permissions, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to find permissions: %w", err)
+	}
+
+	return permissions, nil
+}
+
+func (r *roleRepository) ListRolePermissions() (map[int][]string, error) {
+	query := `
+		SELECT rp.role_id, p.name
+		FROM role_permissions rp
+		INNER JOIN permissions p ON p.id = rp.permission_id
+		ORDER BY rp.role_id, p.name
+	`
+
+	var rows []struct {
+		RoleID int    `db:"role_id"`
+		Name   string `db:"name"`
+	}
+	if err := r.db.Select(&rows, query); err != nil {
+		return nil, fmt.Errorf("failed to list role permissions: %w", err)
+	}
+
+	grants := make(map[int][]string)
+	for _, row := range rows {
+		grants[row.RoleID] = append(grants[row.RoleID], row.Name)
+	}
+
+	return grants, nil
+}
+
+func (r *roleRepository) ListPermissions() ([]models.Permission, error) {
+	query := `SELECT id, name, resource, action, COALESCE(description, '') AS description, created_at FROM permissions ORDER BY name`
+
+	permissions := []models.Permission{}
+	if err := r.db.Select(&permissions, query); err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	return permissions, nil
+}
+
+func (r *roleRepository) CreatePermission(permission *models.Permission) error {
+	query := `
+		INSERT INTO permissions (name, resource, action, description, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowx(query, permission.Name, permission.Resource, permission.Action, permission.Description, time.Now()).
+		Scan(&permission.ID, &permission.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPermissionExists
+		}
+		return fmt.Errorf("failed to create permission: %w", err)
+	}
+
+	return nil
+}
+
+func (r *roleRepository) SetRolePermissions(roleID int, permissionNames []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role_id = $1`, roleID); err != nil {
+		return fmt.Errorf("failed to clear role permissions: %w", err)
+	}
+
+	for _, name := range permissionNames {
+		query := `
+			INSERT INTO role_permissions (role_id, permission_id)
+			SELECT $1, id FROM permissions WHERE name = $2
+			ON CONFLICT (role_id, permission_id) DO NOTHING
+		`
+		result, err := tx.Exec(query, roleID, name)
+		if err != nil {
+			return fmt.Errorf("failed to grant permission: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return fmt.Errorf("%w: %s", ErrPermissionNotFound, name)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE roles SET updated_at = $2 WHERE id = $1`, roleID, time.Now()); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role permissions: %w", err)
+	}
+
+	return nil
+}
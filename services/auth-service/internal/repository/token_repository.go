@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,15 +11,44 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
+// Revocation reasons recorded on refresh tokens
+const (
+	RevokeReasonRotated     = "rotated"
+	RevokeReasonReuse       = "reuse_detected"
+	RevokeReasonAdmin       = "admin_revoked"
+	RevokeReasonDeactivated = "account_deactivated"
+	RevokeReasonForcedReset = "password_reset_forced"
+)
+
+// ErrTokenAlreadyRotated is returned by RotateRefreshToken when the token
+// was revoked or rotated by a concurrent request.
+var ErrTokenAlreadyRotated = errors.New("refresh token already rotated")
+
 type TokenRepository interface {
 	CreateRefreshToken(token *models.RefreshToken) error
 	FindRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	// FindRefreshTokenByHash returns the token whether or not it is revoked
+	FindRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error)
+	// RotateRefreshToken revokes current and stores next in the same family
+	RotateRefreshToken(current *models.RefreshToken, next *models.RefreshToken) error
+	RevokeTokenFamily(familyID uuid.UUID, reason string) (int64, error)
+	// Sessions: one per token family with an active token
+	ListActiveSessions(userID uuid.UUID) ([]models.Session, error)
+	RevokeSession(userID, familyID uuid.UUID, reason string) (int64, error)
+	RevokeOtherSessions(userID, keepFamilyID uuid.UUID, reason string) (int64, error)
 	UpdateLastUsed(tokenID uuid.UUID) error
 	RevokeToken(tokenID uuid.UUID, revokedBy uuid.UUID, reason string) error
 	RevokeAllUserTokens(userID uuid.UUID) error
+	// RevokeUserSessions revokes every active token of userID on behalf of revokedBy
+	RevokeUserSessions(userID, revokedBy uuid.UUID, reason string) (int64, error)
 	CleanupExpiredTokens() error
 }
 
+const refreshTokenColumns = `
+		id, user_id, token_hash, device_id, device_name, device_type,
+		user_agent, ip_address, family_id, replaced_by, expires_at, revoked_at,
+		revoked_by, revoked_reason, created_at, last_used_at`
+
 type tokenRepository struct {
 	db *sqlx.DB
 }
@@ -27,13 +57,14 @@ func NewTokenRepository(db *sqlx.DB) TokenRepository {
 	return &tokenRepository{db: db}
 }
 
-func (r *tokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+// insertRefreshToken works with both *sqlx.DB and *sqlx.Tx
+func insertRefreshToken(q sqlx.Queryer, token *models.RefreshToken) error {
 	query := `
 		INSERT INTO refresh_tokens (
 			id, user_id, token_hash, device_id, device_name, device_type,
-			user_agent, ip_address, expires_at, created_at, last_used_at
+			user_agent, ip_address, family_id, expires_at, created_at, last_used_at
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		RETURNING id, created_at
 	`
 
@@ -42,7 +73,12 @@ func (r *tokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
 	token.CreatedAt = now
 	token.LastUsedAt = now
 
-	err := r.db.QueryRowx(query,
+	// A token without a family starts a new one
+	if token.FamilyID == uuid.Nil {
+		token.FamilyID = token.ID
+	}
+
+	return q.QueryRowx(query,
 		token.ID,
 		token.UserID,
 		token.TokenHash,
@@ -51,12 +87,15 @@ func (r *tokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
 		token.DeviceType,
 		token.UserAgent,
 		token.IPAddress,
+		token.FamilyID,
 		token.ExpiresAt,
 		token.CreatedAt,
 		token.LastUsedAt,
 	).Scan(&token.ID, &token.CreatedAt)
+}
 
-	if err != nil {
+func (r *tokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	if err := insertRefreshToken(r.db, token); err != nil {
 		return fmt.Errorf("failed to create refresh token: %w", err)
 	}
 
@@ -64,10 +103,7 @@ func (r *tokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
 }
 
 func (r *tokenRepository) FindRefreshToken(tokenHash string) (*models.RefreshToken, error) {
-	query := `
-		SELECT id, user_id, token_hash, device_id, device_name, device_type,
-		       user_agent, ip_address, expires_at, revoked_at, revoked_by,
-		       revoked_reason, created_at, last_used_at
+	query := `SELECT` + refreshTokenColumns + `
 		FROM refresh_tokens
 		WHERE token_hash = $1 AND revoked_at IS NULL
 	`
@@ -84,6 +120,128 @@ func (r *tokenRepository) FindRefreshToken(tokenHash string) (*models.RefreshTok
 	return &token, nil
 }
 
+func (r *tokenRepository) FindRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	query := `SELECT` + refreshTokenColumns + `
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	var token models.RefreshToken
+	err := r.db.Get(&token, query, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("token not found")
+		}
+		return nil, fmt.Errorf("failed to find token: %w", err)
+	}
+
+	return &token, nil
+}
+
+func (r *tokenRepository) RotateRefreshToken(current *models.RefreshToken, next *models.RefreshToken) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	if err := insertRefreshToken(tx, next); err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	// Only an active token can be rotated; losing the race to a concurrent
+	// refresh means the presented token has already been spent.
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2, revoked_reason = $3, replaced_by = $4, last_used_at = $2
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := tx.Exec(query, current.ID, time.Now(), RevokeReasonRotated, next.ID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke rotated token: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrTokenAlreadyRotated
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit token rotation: %w", err)
+	}
+
+	return nil
+}
+
+func (r *tokenRepository) RevokeTokenFamily(familyID uuid.UUID, reason string) (int64, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2, revoked_reason = $3
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, familyID, time.Now(), reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke token family: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
+func (r *tokenRepository) ListActiveSessions(userID uuid.UUID) ([]models.Session, error) {
+	// Rotation keeps exactly one active token per family; the family's first
+	// token tells when the session was started.
+	query := `
+		SELECT t.family_id, t.device_id, t.device_name, t.device_type,
+		       t.user_agent, t.ip_address, t.last_used_at,
+		       (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = t.family_id) AS signed_in_at
+		FROM refresh_tokens t
+		WHERE t.user_id = $1 AND t.revoked_at IS NULL AND t.expires_at > $2
+		ORDER BY t.last_used_at DESC
+	`
+
+	sessions := []models.Session{}
+	if err := r.db.Select(&sessions, query, userID, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (r *tokenRepository) RevokeSession(userID, familyID uuid.UUID, reason string) (int64, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $3, revoked_by = $1, revoked_reason = $4
+		WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, familyID, time.Now(), reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
+func (r *tokenRepository) RevokeOtherSessions(userID, keepFamilyID uuid.UUID, reason string) (int64, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $3, revoked_by = $1, revoked_reason = $4
+		WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, keepFamilyID, time.Now(), reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke other sessions: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
 func (r *tokenRepository) UpdateLastUsed(tokenID uuid.UUID) error {
 	query := `UPDATE refresh_tokens SET last_used_at = $2 WHERE id = $1`
 
@@ -125,6 +283,22 @@ func (r *tokenRepository) RevokeAllUserTokens(userID uuid.UUID) error {
 	return nil
 }
 
+func (r *tokenRepository) RevokeUserSessions(userID, revokedBy uuid.UUID, reason string) (int64, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $2, revoked_by = $3, revoked_reason = $4
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, time.Now(), revokedBy, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
 func (r *tokenRepository) CleanupExpiredTokens() error {
 	query := `DELETE FROM refresh_tokens WHERE expires_at < $1 OR revoked_at IS NOT NULL`
 
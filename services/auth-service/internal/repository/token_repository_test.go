@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+func newMockTokenRepository(t *testing.T) (TokenRepository, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewTokenRepository(sqlx.NewDb(db, "postgres")), mock
+}
+
+func rotationFixture() (*models.RefreshToken, *models.RefreshToken) {
+	current := &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uuid.New(),
+		TokenHash: "current-hash",
+		FamilyID:  uuid.New(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	next := &models.RefreshToken{
+		TokenHash: "next-hash",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	return current, next
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	repo, mock := newMockTokenRepository(t)
+	current, next := rotationFixture()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WithArgs(sqlmock.AnyArg(), current.UserID, "next-hash", nil, nil, nil, nil, nil, current.FamilyID,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New(), time.Now()))
+	mock.ExpectExec(`UPDATE refresh_tokens\s+SET revoked_at = \$2, revoked_reason = \$3, replaced_by = \$4.*WHERE id = \$1 AND revoked_at IS NULL`).
+		WithArgs(current.ID, sqlmock.AnyArg(), RevokeReasonRotated, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.RotateRefreshToken(current, next); err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	if next.FamilyID != current.FamilyID || next.UserID != current.UserID {
+		t.Errorf("next token not in the current family: %+v", next)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRotateRefreshTokenAlreadyRotated(t *testing.T) {
+	repo, mock := newMockTokenRepository(t)
+	current, next := rotationFixture()
+
+	// A concurrent refresh revoked the token first, so the guarded update
+	// matches nothing and the new token must not be kept
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New(), time.Now()))
+	mock.ExpectExec(`UPDATE refresh_tokens`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := repo.RotateRefreshToken(current, next)
+	if !errors.Is(err, ErrTokenAlreadyRotated) {
+		t.Fatalf("got %v, want ErrTokenAlreadyRotated", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
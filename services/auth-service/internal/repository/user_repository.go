@@ -2,8 +2,8 @@ package repository
 
 import (
     "database/sql"
+    "errors"
     "fmt"
-    "log"
     "strings"
     "time"
 
@@ -12,19 +12,46 @@ import (
     "github.com/jmoiron/sqlx"
 )
 
+// ErrUserNotFound is returned when no active (not deleted) user matches
+var ErrUserNotFound = errors.New("user not found")
+// User statuses an admin search can filter on
+const (
+	UserStatusActive                = "active"
+	UserStatusInactive              = "inactive"
+	UserStatusLocked                = "locked"
+	UserStatusUnverified            = "unverified"
+	UserStatusPasswordResetRequired = "password_reset_required"
+)
+
+// UserFilter narrows an admin user search; zero values match every user
+type UserFilter struct {
+	Email         string // case-insensitive substring
+	Role          string
+	Status        string
+	CreatedFrom   time.Time
+	CreatedBefore time.Time
+	Limit         int
+	Offset        int
+}
+
 type UserRepository interface {
 	Create(user *models.User) error
 	Delete(userID uuid.UUID) error
 	FindByID(id uuid.UUID) (*models.User, error)
 	FindByEmail(email string) (*models.User, error)
-	FindByGoogleID(googleID string) (*models.User, error)
-	FindOrCreateByGoogleID(googleID, email, name string) (*models.User, error)
 	Update(user *models.User) error
+	// UpdateEmail sets a new, verified email address
+	UpdateEmail(userID uuid.UUID, email string) error
 	UpdateLoginInfo(userID uuid.UUID, ip string) error
 	IncrementFailedAttempts(userID uuid.UUID) error
 	ResetFailedAttempts(userID uuid.UUID) error
 	LockAccount(userID uuid.UUID, duration time.Duration) error
 	IsAccountLocked(userID uuid.UUID) (bool, error)
+
+	// Admin user management
+	Search(filter UserFilter) ([]models.UserSummary, int, error)
+	SetActive(userID uuid.UUID, active bool) error
+	SetPasswordResetRequired(userID uuid.UUID, required bool) error
 }
 
 type userRepository struct {
@@ -69,9 +96,10 @@ func (r *userRepository) Create(user *models.User) error {
 
 func (r *userRepository) FindByID(id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, phone, is_active, is_verified, email_verified_at,
+		SELECT id, email, password_hash, phone, oauth_provider,
+		       is_active, is_verified, email_verified_at,
 		       failed_login_attempts, locked_until, last_login_at, last_login_ip,
-		       created_at, updated_at, deleted_at
+		       password_reset_required, created_at, updated_at, deleted_at
 		FROM users
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -80,7 +108,7 @@ func (r *userRepository) FindByID(id uuid.UUID) (*models.User, error) {
 	err := r.db.Get(&user, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, ErrUserNotFound
 		}
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
@@ -90,10 +118,10 @@ func (r *userRepository) FindByID(id uuid.UUID) (*models.User, error) {
 
 func (r *userRepository) FindByEmail(email string) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, phone, google_id, oauth_provider, 
+		SELECT id, email, password_hash, phone, oauth_provider, 
 		       is_active, is_verified, email_verified_at,
 		       failed_login_attempts, locked_until, last_login_at, last_login_ip,
-		       created_at, updated_at, deleted_at
+		       password_reset_required, created_at, updated_at, deleted_at
 		FROM users
 		WHERE email = $1 AND deleted_at IS NULL
 	`
@@ -102,29 +130,7 @@ func (r *userRepository) FindByEmail(email string) (*models.User, error) {
     err := r.db.Get(&user, query, email)
     if err != nil {
         if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("user not found")
-        }
-        return nil, fmt.Errorf("failed to find user: %w", err)
-    }
-
-	return &user, nil
-}
-
-func (r *userRepository) FindByGoogleID(googleID string) (*models.User, error) {
-	query := `
-		SELECT id, email, password_hash, phone, google_id, oauth_provider,
-		       is_active, is_verified, email_verified_at,
-		       failed_login_attempts, locked_until, last_login_at, last_login_ip,
-		       created_at, updated_at, deleted_at
-		FROM users
-		WHERE google_id = $1 AND deleted_at IS NULL
-	`
-
-    var user models.User
-    err := r.db.Get(&user, query, googleID)
-    if err != nil {
-        if err == sql.ErrNoRows {
-            return nil, fmt.Errorf("user not found")
+            return nil, ErrUserNotFound
         }
         return nil, fmt.Errorf("failed to find user: %w", err)
     }
@@ -132,117 +138,6 @@ func (r *userRepository) FindByGoogleID(googleID string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) FindOrCreateByGoogleID(googleID, email, name string) (*models.User, error) {
-	// Try to find existing user
-	log.Printf("[FindOrCreateByGoogleID] Searching for Google ID: %s", googleID)
-	user, err := r.FindByGoogleID(googleID)
-	if err == nil {
-		log.Printf("[FindOrCreateByGoogleID] Found existing user by Google ID: %s", user.ID)
-		return user, nil
-	}
-	log.Printf("[FindOrCreateByGoogleID] No user found by Google ID, checking email: %s", email)
-
-	// Check if user exists with this email (non-Google account)
-    existingUser, err := r.FindByEmail(email)
-    if err == nil {
-        log.Printf("[FindOrCreateByGoogleID] Found existing user by email: ID=%s, GoogleID=%v", existingUser.ID, existingUser.GoogleID)
-        if existingUser.GoogleID == nil {
-            log.Printf("[FindOrCreateByGoogleID] Linking Google ID to existing account...")
-            // Link Google ID to existing account
-            query := `
-                UPDATE users 
-                SET google_id = $1, oauth_provider = $2, is_verified = true, 
-                    email_verified_at = $3, updated_at = $4
-                WHERE id = $5
-                RETURNING id, email, password_hash, phone, google_id, oauth_provider,
-                          is_active, is_verified, email_verified_at,
-                          failed_login_attempts, locked_until, last_login_at, last_login_ip,
-                          created_at, updated_at, deleted_at
-            `
-            now := time.Now()
-            provider := "google"
-            err := r.db.Get(existingUser, query, googleID, provider, now, now, existingUser.ID)
-            if err != nil {
-                log.Printf("[FindOrCreateByGoogleID] ❌ Failed to link: %v", err)
-                return nil, fmt.Errorf("failed to link Google account: %w", err)
-            }
-            log.Printf("[FindOrCreateByGoogleID] ✅ Successfully linked Google ID to existing account")
-            return existingUser, nil
-        }
-        // Already linked to Google; return existing user instead of attempting to create duplicate
-        log.Printf("[FindOrCreateByGoogleID] ⚠️ User already has Google ID: %v", existingUser.GoogleID)
-        return existingUser, nil
-    }
-    // No user found by email; proceed to create a new Google user
-    log.Printf("[FindOrCreateByGoogleID] No user found by email, creating new user...")
-
-	// Create new user
-	log.Printf("[FindOrCreateByGoogleID] Creating new user with email: %s, Google ID: %s", email, googleID)
-	query := `
-		INSERT INTO users (id, email, google_id, oauth_provider, is_active, is_verified,
-		                   email_verified_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
-		RETURNING id, email, password_hash, phone, google_id, oauth_provider,
-		          is_active, is_verified, email_verified_at,
-		          failed_login_attempts, locked_until, last_login_at, last_login_ip,
-		          created_at, updated_at, deleted_at
-	`
-
-    now := time.Now()
-    newUserID := uuid.New()
-    newUser := &models.User{}
-    err = r.db.Get(newUser, query,
-        newUserID, // $1 - id
-        email,     // $2 - email
-        googleID,  // $3 - google_id
-        "google",  // $4 - oauth_provider
-        true,      // $5 - is_active
-        true,      // $6 - is_verified
-        now,       // $7 - email_verified_at
-        now,       // $8 - created_at AND updated_at (reused)
-    )
-
-    if err != nil {
-        log.Printf("[FindOrCreateByGoogleID] ❌ Failed to create user: %v", err)
-        // Handle duplicate email: link Google ID to existing account and return it
-        if strings.Contains(err.Error(), "idx_users_email_unique") || strings.Contains(strings.ToLower(err.Error()), "duplicate key value") {
-            log.Printf("[FindOrCreateByGoogleID] 🔄 Detected duplicate email, attempting to link to existing account")
-            existingUserByEmail, err2 := r.FindByEmail(email)
-            if err2 == nil && existingUserByEmail != nil {
-                if existingUserByEmail.GoogleID == nil {
-                    log.Printf("[FindOrCreateByGoogleID] Linking Google ID to existing account after duplicate...")
-                    linkQuery := `
-                        UPDATE users 
-                        SET google_id = $1, oauth_provider = $2, is_verified = true, 
-                            email_verified_at = $3, updated_at = $4
-                        WHERE id = $5
-                        RETURNING id, email, password_hash, phone, google_id, oauth_provider,
-                                  is_active, is_verified, email_verified_at,
-                                  failed_login_attempts, locked_until, last_login_at, last_login_ip,
-                                  created_at, updated_at, deleted_at
-                    `
-                    now2 := time.Now()
-                    provider := "google"
-                    if err3 := r.db.Get(existingUserByEmail, linkQuery, googleID, provider, now2, now2, existingUserByEmail.ID); err3 != nil {
-                        log.Printf("[FindOrCreateByGoogleID] ❌ Failed to link after duplicate: %v", err3)
-                        return nil, fmt.Errorf("failed to link Google account: %w", err3)
-                    }
-                    log.Printf("[FindOrCreateByGoogleID] ✅ Successfully linked Google ID to existing account after duplicate")
-                    return existingUserByEmail, nil
-                }
-                // Already linked; return existing user
-                log.Printf("[FindOrCreateByGoogleID] ⚠️ Existing account already linked to Google, returning existing user")
-                return existingUserByEmail, nil
-            }
-            log.Printf("[FindOrCreateByGoogleID] ⚠️ Could not fetch existing account by email after duplicate: %v", err2)
-        }
-        return nil, fmt.Errorf("failed to create user: %w", err)
-    }
-
-	log.Printf("[FindOrCreateByGoogleID] ✅ Successfully created new user: %s", newUser.ID)
-	return newUser, nil
-}
-
 func (r *userRepository) Update(user *models.User) error {
 	query := `
 		UPDATE users
@@ -275,6 +170,27 @@ func (r *userRepository) Update(user *models.User) error {
 	return nil
 }
 
+func (r *userRepository) UpdateEmail(userID uuid.UUID, email string) error {
+	query := `
+		UPDATE users
+		SET email = $2, is_verified = true, email_verified_at = $3, updated_at = $3
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, email, time.Now())
+	if err != nil {
+		if isUniqueViolation(err, "idx_users_email_unique") {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 func (r *userRepository) UpdateLoginInfo(userID uuid.UUID, ip string) error {
 	query := `
 		UPDATE users
@@ -381,3 +297,103 @@ func (r *userRepository) Delete(userID uuid.UUID) error {
 
 	return nil
 }
+
+func (r *userRepository) Search(filter UserFilter) ([]models.UserSummary, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Email != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Email)+"%")
+		conditions = append(conditions, fmt.Sprintf("u.email ILIKE $%d", len(args)))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM user_roles ur INNER JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = u.id AND r.name = $%d)`, len(args)))
+	}
+	switch filter.Status {
+	case UserStatusActive:
+		conditions = append(conditions, "u.is_active = true")
+	case UserStatusInactive:
+		conditions = append(conditions, "u.is_active = false")
+	case UserStatusLocked:
+		args = append(args, time.Now())
+		conditions = append(conditions, fmt.Sprintf("u.locked_until > $%d", len(args)))
+	case UserStatusUnverified:
+		conditions = append(conditions, "u.is_verified = false")
+	case UserStatusPasswordResetRequired:
+		conditions = append(conditions, "u.password_reset_required = true")
+	}
+	if !filter.CreatedFrom.IsZero() {
+		args = append(args, filter.CreatedFrom)
+		conditions = append(conditions, fmt.Sprintf("u.created_at >= $%d", len(args)))
+	}
+	if !filter.CreatedBefore.IsZero() {
+		args = append(args, filter.CreatedBefore)
+		conditions = append(conditions, fmt.Sprintf("u.created_at < $%d", len(args)))
+	}
+
+	where := "WHERE u.deleted_at IS NULL"
+	if len(conditions) > 0 {
+		where += " AND " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*) FROM users u "+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := `
+		SELECT u.id, u.email, u.phone, u.oauth_provider,
+		       u.is_active, u.is_verified, u.email_verified_at,
+		       u.failed_login_attempts, u.locked_until, u.last_login_at, u.last_login_ip,
+		       u.password_reset_required, u.created_at, u.updated_at,
+		       COALESCE((
+		           SELECT string_agg(r.name, ',' ORDER BY r.name)
+		           FROM user_roles ur INNER JOIN roles r ON r.id = ur.role_id
+		           WHERE ur.user_id = u.id
+		       ), '') AS role_names
+		FROM users u
+		` + where + fmt.Sprintf(`
+		ORDER BY u.created_at DESC
+		LIMIT %d OFFSET %d`, filter.Limit, filter.Offset)
+
+	users := []models.UserSummary{}
+	if err := r.db.Select(&users, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// likeEscaper escapes the wildcards of user input used in LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *userRepository) SetActive(userID uuid.UUID, active bool) error {
+	query := `UPDATE users SET is_active = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.Exec(query, userID, active, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update user status: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepository) SetPasswordResetRequired(userID uuid.UUID, required bool) error {
+	query := `UPDATE users SET password_reset_required = $2, updated_at = $3 WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.Exec(query, userID, required, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update password reset flag: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
@@ -4,13 +4,18 @@ import (
 	"github.com/bisosad1501/DATN/services/auth-service/internal/handlers"
 	"github.com/bisosad1501/DATN/services/auth-service/internal/middleware"
 	"github.com/bisosad1501/DATN/services/auth-service/internal/service"
+	"github.com/bisosad1501/DATN/shared/pkg/authz"
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, authService service.AuthService) {
+func SetupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, authService service.AuthService, internalAPIKey string) {
 	// Health check
 	router.GET("/health", authHandler.HealthCheck)
 
+	// Public keys that verify access tokens
+	router.GET(jwks.Path, authHandler.JWKS)
+
 	// API v1 group
 	v1 := router.Group("/api/v1")
 	{
@@ -28,6 +33,13 @@ func SetupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, authServ
 			auth.GET("/google/callback", authHandler.GoogleCallback)    // Web flow: Handle callback
 			auth.POST("/google/token", authHandler.GoogleExchangeToken) // Mobile flow: Exchange code for tokens
 
+			// OAuth/OIDC providers configured with OIDC_PROVIDERS (same flows as Google)
+			auth.GET("/oauth/providers", authHandler.ListOAuthProviders)
+			auth.GET("/oauth/:provider", authHandler.OAuthLogin)
+			auth.GET("/oauth/:provider/url", authHandler.GetOAuthURL)
+			auth.GET("/oauth/:provider/callback", authHandler.OAuthCallback)
+			auth.POST("/oauth/:provider/token", authHandler.OAuthExchangeToken)
+
 			// Password reset endpoints
 			auth.POST("/forgot-password", authHandler.ForgotPassword)             // Request password reset (sends 6-digit code)
 			auth.POST("/reset-password", authHandler.ResetPassword)               // Reset password with token (legacy)
@@ -38,6 +50,17 @@ func SetupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, authServ
 			auth.POST("/verify-email-by-code", authHandler.VerifyEmailByCode) // Verify email with 6-digit code
 			auth.POST("/resend-verification", authHandler.ResendVerification) // Resend verification email (sends 6-digit code)
 
+			// Passwordless login (emailed one-time code or magic link)
+			auth.POST("/passwordless/request", authHandler.RequestPasswordlessLogin)
+			auth.POST("/passwordless/verify", authHandler.VerifyPasswordlessLogin)
+
+			// Two-factor login step (authorized by the mfa_token returned from login)
+			auth.POST("/2fa/challenge/setup", authHandler.SetupMFAChallenge)   // Enroll when the role requires 2FA
+			auth.POST("/2fa/challenge/verify", authHandler.VerifyMFAChallenge) // Exchange code or recovery code for tokens
+
+			// High-risk login step (authorized by the step_up_token returned from login)
+			auth.POST("/login/step-up/verify", authHandler.VerifyLoginStepUp)
+
 			// Protected endpoints (require authentication)
 			protected := auth.Group("")
 			protected.Use(middleware.AuthMiddleware(authService))
@@ -45,6 +68,96 @@ func SetupRoutes(router *gin.Engine, authHandler *handlers.AuthHandler, authServ
 				protected.GET("/validate", authHandler.ValidateToken)
 				protected.POST("/logout", authHandler.Logout)
 				protected.POST("/change-password", authHandler.ChangePassword)
+				protected.POST("/change-email", authHandler.ChangeEmail)                // Sends a code to the new address
+				protected.POST("/change-email/confirm", authHandler.ConfirmEmailChange) // Applies the change, signs out other sessions
+
+				// Sessions (one per signed-in device)
+				protected.GET("/sessions", authHandler.ListSessions)
+				protected.DELETE("/sessions/:id", authHandler.RevokeSession)
+				protected.POST("/sessions/revoke-others", authHandler.RevokeOtherSessions)
+				protected.GET("/security-activity", authHandler.GetSecurityActivity)
+
+				// Linked sign-in providers
+				protected.GET("/identities", authHandler.ListIdentities)
+				protected.POST("/identities/:provider/link", authHandler.LinkIdentity)
+				protected.DELETE("/identities/:provider", authHandler.UnlinkIdentity)
+
+				// Two-factor authentication
+				protected.GET("/2fa/status", authHandler.GetMFAStatus)
+				protected.POST("/2fa/setup", authHandler.SetupMFA)
+				protected.POST("/2fa/enable", authHandler.EnableMFA)
+				protected.POST("/2fa/disable", authHandler.DisableMFA)
+				protected.POST("/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+
+				// Account deletion (after a grace period) and personal data export
+				protected.POST("/account/deletion", authHandler.RequestAccountDeletion)
+				protected.GET("/account/deletion", authHandler.GetAccountDeletion)
+				protected.DELETE("/account/deletion", authHandler.CancelAccountDeletion)
+				protected.POST("/account/export", authHandler.RequestDataExport)
+				protected.GET("/account/export", authHandler.GetDataExport)
+				protected.GET("/account/export/:id/download", authHandler.DownloadDataExport)
+			}
+
+			// Admin endpoints
+			admin := auth.Group("/admin")
+			admin.Use(middleware.AuthMiddleware(authService), authz.RequirePermission(service.PermissionRoleManage))
+			{
+				admin.GET("/2fa/policies", authHandler.ListMFAPolicies)
+				admin.PUT("/2fa/policies/:role", authHandler.SetMFAPolicy)
+
+				// Roles and permissions
+				admin.GET("/roles", authHandler.ListRoles)
+				admin.POST("/roles", authHandler.CreateRole)
+				admin.DELETE("/roles/:role", authHandler.DeleteRole)
+				admin.PUT("/roles/:role/permissions", authHandler.SetRolePermissions)
+				admin.GET("/permissions", authHandler.ListPermissions)
+				admin.POST("/permissions", authHandler.CreatePermission)
+			}
+
+			// Admin user management
+			users := auth.Group("/admin/users")
+			users.Use(middleware.AuthMiddleware(authService), authz.RequirePermission(service.PermissionUserManage))
+			{
+				users.GET("", authHandler.ListUsers)
+				users.GET("/:id", authHandler.GetUser)
+				users.POST("/:id/activate", authHandler.ActivateUser)
+				users.POST("/:id/deactivate", authHandler.DeactivateUser)
+				users.POST("/:id/unlock", authHandler.UnlockUser)
+				users.POST("/:id/force-password-reset", authHandler.ForcePasswordReset)
+				users.POST("/:id/roles", authHandler.AssignUserRole)
+				users.DELETE("/:id/roles/:role", authHandler.RemoveUserRole)
+				users.DELETE("/:id/sessions", authHandler.RevokeUserSessions)
+			}
+
+			// Account deletions
+			deletions := auth.Group("/admin/account-deletions")
+			deletions.Use(middleware.AuthMiddleware(authService), authz.RequirePermission(service.PermissionUserManage))
+			{
+				deletions.GET("", authHandler.ListAccountDeletions)
+				deletions.POST("/:id/retry", authHandler.RetryAccountDeletion)
+			}
+
+			// Outgoing emails
+			emails := auth.Group("/admin/emails")
+			emails.Use(middleware.AuthMiddleware(authService), authz.RequirePermission(service.PermissionUserManage))
+			{
+				emails.GET("", authHandler.ListEmails)
+				emails.POST("/:id/retry", authHandler.RetryEmail)
+			}
+
+			// Audit logs
+			audit := auth.Group("/admin/audit-logs")
+			audit.Use(middleware.AuthMiddleware(authService), authz.RequirePermission(service.PermissionAuditRead))
+			{
+				audit.GET("", authHandler.ListAuditLogs)
+				audit.GET("/export", authHandler.ExportAuditLogs)
+			}
+
+			// Internal endpoints (service-to-service)
+			internal := auth.Group("/internal")
+			internal.Use(middleware.InternalAuth(internalAPIKey))
+			{
+				internal.GET("/users/:id/permissions", authHandler.GetUserPermissionsInternal)
 			}
 		}
 	}
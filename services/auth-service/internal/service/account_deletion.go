@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrAccountDeletionNotFound  = repository.ErrAccountDeletionNotFound
+	ErrAccountDeletionExists    = repository.ErrAccountDeletionExists
+	ErrAccountDeletionStarted   = errors.New("account deletion has already started and can no longer be cancelled")
+	ErrAccountDeletionNotFailed = errors.New("only failed account deletions can be retried")
+)
+
+const (
+	// authDataStep erases auth_db last: until then the request can still be
+	// traced to the user and the saga resumed
+	authDataStep = "auth"
+
+	accountDeletionBatchSize   = 10
+	accountDeletionLease       = 10 * time.Minute
+	accountDeletionMaxAttempts = 10
+	accountDeletionMaxBackoff  = time.Hour
+)
+
+// RequestAccountDeletion schedules the user's account for deletion after the
+// grace period. Accounts with a password must confirm it.
+func (s *authService) RequestAccountDeletion(userID uuid.UUID, req *models.DeleteAccountRequest, ip, userAgent string) (*models.AccountDeletionResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Password != nil && bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.Password)) != nil {
+		s.logAudit(&userID, "account_deletion_requested", "failed", ip, userAgent, "invalid password")
+		return nil, ErrInvalidPassword
+	}
+
+	deletion := &models.AccountDeletionRequest{
+		UserID:       userID,
+		ScheduledFor: time.Now().Add(s.config.AccountDeletionGracePeriod),
+	}
+	if err := s.deletionRepo.Create(deletion, s.accountDeletionSteps()); err != nil {
+		s.logAudit(&userID, "account_deletion_requested", "failed", ip, userAgent, err.Error())
+		return nil, err
+	}
+
+	s.logAudit(&userID, "account_deletion_requested", "success", ip, userAgent, "")
+	log.Printf("[Auth-Service] Account %s scheduled for deletion at %s", userID, deletion.ScheduledFor.Format(time.RFC3339))
+	return s.accountDeletionResponse(deletion)
+}
+
+// GetAccountDeletion returns the user's latest deletion request and its steps
+func (s *authService) GetAccountDeletion(userID uuid.UUID) (*models.AccountDeletionResponse, error) {
+	deletion, err := s.deletionRepo.FindLatestByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.accountDeletionResponse(deletion)
+}
+
+// CancelAccountDeletion stops a deletion that is still in its grace period
+func (s *authService) CancelAccountDeletion(userID uuid.UUID, ip, userAgent string) error {
+	deletion, err := s.deletionRepo.FindLatestByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if deletion.Status != models.AccountDeletionScheduled {
+		if deletion.Status == models.AccountDeletionCancelled || deletion.Status == models.AccountDeletionCompleted {
+			return ErrAccountDeletionNotFound
+		}
+		return ErrAccountDeletionStarted
+	}
+
+	cancelled, err := s.deletionRepo.Cancel(deletion.ID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		// The grace period ran out between the lookup and the update
+		return ErrAccountDeletionStarted
+	}
+
+	s.logAudit(&userID, "account_deletion_cancelled", "success", ip, userAgent, "")
+	return nil
+}
+
+// ListAccountDeletions lists deletion requests for admins, newest first
+func (s *authService) ListAccountDeletions(query *models.AdminAccountDeletionQuery) (*models.AccountDeletionListResponse, error) {
+	page := query.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := query.Limit
+	if limit < 1 {
+		limit = defaultAdminUserPageSize
+	}
+	if limit > maxAdminUserPageSize {
+		limit = maxAdminUserPageSize
+	}
+
+	deletions, total, err := s.deletionRepo.List(query.Status, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.AccountDeletionListResponse{
+		Requests: make([]models.AccountDeletionResponse, 0, len(deletions)),
+		Pagination: models.PaginationResponse{
+			Page:       page,
+			Limit:      limit,
+			TotalItems: total,
+			TotalPages: (total + limit - 1) / limit,
+		},
+	}
+	for i := range deletions {
+		item, err := s.accountDeletionResponse(&deletions[i])
+		if err != nil {
+			return nil, err
+		}
+		resp.Requests = append(resp.Requests, *item)
+	}
+	return resp, nil
+}
+
+// RetryAccountDeletion restarts a failed deletion from its failed step
+func (s *authService) RetryAccountDeletion(adminID, requestID uuid.UUID, ip, userAgent string) error {
+	deletion, err := s.deletionRepo.FindByID(requestID)
+	if err != nil {
+		return err
+	}
+
+	retried, err := s.deletionRepo.Retry(requestID)
+	if err != nil {
+		return err
+	}
+	if !retried {
+		return ErrAccountDeletionNotFailed
+	}
+
+	s.logAdminAudit(adminID, deletion.UserID, "admin_account_deletion_retried", "success", ip, userAgent, "",
+		map[string]interface{}{"request_id": requestID.String()})
+	return nil
+}
+
+// RunDueAccountDeletions runs the deletion saga for requests whose grace
+// period is over. Each step erases the user's data in one service; a failed
+// step is retried with backoff and the later steps wait for it.
+func (s *authService) RunDueAccountDeletions(ctx context.Context) {
+	deletions, err := s.deletionRepo.ClaimDue(accountDeletionBatchSize, accountDeletionLease)
+	if err != nil {
+		log.Printf("[AccountDeletion] ERROR: %v", err)
+		return
+	}
+
+	for i := range deletions {
+		if ctx.Err() != nil {
+			// Claimed but not started; the lease runs out and another run picks them up
+			return
+		}
+		s.runAccountDeletion(ctx, &deletions[i])
+	}
+}
+
+func (s *authService) runAccountDeletion(ctx context.Context, deletion *models.AccountDeletionRequest) {
+	// Sign the user out everywhere before any data goes, so nothing new is
+	// written while the saga runs. Repeating it on resume is harmless.
+	if err := s.userRepo.SetActive(deletion.UserID, false); err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		log.Printf("[AccountDeletion] ERROR: deactivate user %s: %v", deletion.UserID, err)
+	}
+	if err := s.tokenRepo.RevokeAllUserTokens(deletion.UserID); err != nil {
+		log.Printf("[AccountDeletion] ERROR: revoke sessions of user %s: %v", deletion.UserID, err)
+	}
+	s.revokeUserAccessTokens(deletion.UserID)
+
+	steps, err := s.deletionRepo.ListSteps(deletion.ID)
+	if err != nil {
+		log.Printf("[AccountDeletion] ERROR: %v", err)
+		s.releaseAccountDeletion(deletion.ID, 0)
+		return
+	}
+
+	for _, step := range steps {
+		switch step.Status {
+		case models.DeletionStepCompleted:
+			continue
+		case models.DeletionStepFailed:
+			s.failAccountDeletion(deletion, step.Service)
+			return
+		}
+
+		result, err := s.eraseServiceData(ctx, deletion.UserID, step.Service)
+		if err != nil {
+			if ctx.Err() != nil {
+				// Shutting down: not the service's fault, resume on the next start
+				s.releaseAccountDeletion(deletion.ID, 0)
+				return
+			}
+
+			attempts, failed, ferr := s.deletionRepo.FailStep(deletion.ID, step.Service, err.Error(), accountDeletionMaxAttempts)
+			if ferr != nil {
+				log.Printf("[AccountDeletion] ERROR: %v", ferr)
+			}
+			log.Printf("[AccountDeletion] Step %s of request %s failed (attempt %d): %v", step.Service, deletion.ID, attempts, err)
+			if failed {
+				s.failAccountDeletion(deletion, step.Service)
+				return
+			}
+			s.releaseAccountDeletion(deletion.ID, accountDeletionBackoff(attempts))
+			return
+		}
+
+		if err := s.deletionRepo.CompleteStep(deletion.ID, step.Service, result); err != nil {
+			// The erasure is idempotent, so running the step again is safe
+			log.Printf("[AccountDeletion] ERROR: %v", err)
+			s.releaseAccountDeletion(deletion.ID, 0)
+			return
+		}
+	}
+
+	if err := s.deletionRepo.Finish(deletion.ID, models.AccountDeletionCompleted); err != nil {
+		log.Printf("[AccountDeletion] ERROR: %v", err)
+		return
+	}
+
+	// The user and their audit trail are gone; the request ID links this entry
+	// to account_deletion_requests
+	s.logJobAudit(nil, "account_deleted", "success", "", map[string]interface{}{"request_id": deletion.ID.String()})
+	log.Printf("[AccountDeletion] Account deletion %s completed", deletion.ID)
+}
+
+// eraseServiceData runs one saga step and returns what was erased as JSON
+func (s *authService) eraseServiceData(ctx context.Context, userID uuid.UUID, service string) (string, error) {
+	var result interface{}
+	if service == authDataStep {
+		// Export ZIPs live outside the database; remove them before the rows
+		// that point to them cascade away
+		paths, err := s.exportRepo.ListFiles(userID)
+		if err != nil {
+			return "", err
+		}
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return "", fmt.Errorf("remove data export: %w", err)
+			}
+		}
+
+		deleted, anonymized, err := s.deletionRepo.EraseUser(userID)
+		if err != nil {
+			return "", err
+		}
+		result = map[string]interface{}{"deleted": deleted, "anonymized": anonymized}
+	} else {
+		dataClient := s.userDataClient(service)
+		if dataClient == nil {
+			return "", fmt.Errorf("no client for service %q", service)
+		}
+		erasure, err := dataClient.WithContext(ctx).EraseUserData(userID.String())
+		if err != nil {
+			return "", err
+		}
+		result = erasure
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func (s *authService) failAccountDeletion(deletion *models.AccountDeletionRequest, service string) {
+	if err := s.deletionRepo.Finish(deletion.ID, models.AccountDeletionFailed); err != nil {
+		log.Printf("[AccountDeletion] ERROR: %v", err)
+	}
+	s.logJobAudit(&deletion.UserID, "account_deletion_failed", "failed", "step "+service+" failed",
+		map[string]interface{}{"request_id": deletion.ID.String(), "step": service})
+	log.Printf("[AccountDeletion] Account deletion %s failed at step %s; an admin can retry it", deletion.ID, service)
+}
+
+func (s *authService) releaseAccountDeletion(requestID uuid.UUID, retryAfter time.Duration) {
+	if err := s.deletionRepo.Release(requestID, retryAfter); err != nil {
+		log.Printf("[AccountDeletion] ERROR: %v", err)
+	}
+}
+
+// accountDeletionBackoff grows quadratically (1m, 4m, 9m, ...) up to an hour
+func accountDeletionBackoff(attempts int) time.Duration {
+	backoff := time.Duration(attempts*attempts) * time.Minute
+	if backoff > accountDeletionMaxBackoff {
+		return accountDeletionMaxBackoff
+	}
+	return backoff
+}
+
+// accountDeletionSteps lists the saga steps in order. Notifications go first
+// so the user hears nothing more; the profile goes after the learning data
+// whose updates could otherwise recreate it; auth_db goes last.
+func (s *authService) accountDeletionSteps() []string {
+	steps := make([]string, 0, len(s.userDataClients)+1)
+	for _, dataClient := range s.userDataClients {
+		steps = append(steps, dataClient.Service())
+	}
+	return append(steps, authDataStep)
+}
+
+func (s *authService) userDataClient(service string) *client.UserDataClient {
+	for _, dataClient := range s.userDataClients {
+		if dataClient.Service() == service {
+			return dataClient
+		}
+	}
+	return nil
+}
+
+func (s *authService) accountDeletionResponse(deletion *models.AccountDeletionRequest) (*models.AccountDeletionResponse, error) {
+	steps, err := s.deletionRepo.ListSteps(deletion.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.AccountDeletionResponse{
+		ID:           deletion.ID.String(),
+		UserID:       deletion.UserID.String(),
+		Status:       deletion.Status,
+		RequestedAt:  deletion.RequestedAt,
+		ScheduledFor: deletion.ScheduledFor,
+		StartedAt:    deletion.StartedAt,
+		CompletedAt:  deletion.CompletedAt,
+		CancelledAt:  deletion.CancelledAt,
+		Steps:        make([]models.AccountDeletionStepResponse, 0, len(steps)),
+	}
+	for _, step := range steps {
+		item := models.AccountDeletionStepResponse{
+			Service:     step.Service,
+			Status:      step.Status,
+			Attempts:    step.Attempts,
+			LastError:   step.LastError,
+			CompletedAt: step.CompletedAt,
+		}
+		if step.Result != nil {
+			item.Result = json.RawMessage(*step.Result)
+		}
+		resp.Steps = append(resp.Steps, item)
+	}
+	return resp, nil
+}
+
+// logJobAudit records an event of the deletion or export workers, which have
+// no request IP or user agent
+func (s *authService) logJobAudit(userID *uuid.UUID, eventType, status, errorMsg string, details map[string]interface{}) {
+	encoded, _ := json.Marshal(details)
+	metadata := string(encoded)
+
+	entry := &models.AuditLog{
+		UserID:      userID,
+		EventType:   eventType,
+		EventStatus: status,
+		Metadata:    &metadata,
+	}
+	if errorMsg != "" {
+		entry.ErrorMessage = &errorMsg
+	}
+	s.auditRepo.Create(entry)
+}
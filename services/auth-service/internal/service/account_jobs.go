@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
+)
+
+const accountJobsInterval = time.Minute
+
+// AccountJobsService runs due account deletions and builds queued data exports
+type AccountJobsService struct {
+	auth      AuthService
+	lifecycle *lifecycle.Lifecycle // stops the workers on shutdown
+}
+
+func NewAccountJobsService(auth AuthService, lc *lifecycle.Lifecycle) *AccountJobsService {
+	return &AccountJobsService{
+		auth:      auth,
+		lifecycle: lc,
+	}
+}
+
+// Start runs both jobs on start, then every minute until shutdown. Work is
+// claimed with a lease in the database, so several replicas can run it.
+func (s *AccountJobsService) Start() {
+	s.lifecycle.Go(s.lifecycle.Context(), "account deletion", func(ctx context.Context) {
+		s.every(ctx, s.auth.RunDueAccountDeletions)
+	})
+	s.lifecycle.Go(s.lifecycle.Context(), "data export", func(ctx context.Context) {
+		s.every(ctx, s.auth.RunPendingDataExports)
+	})
+}
+
+func (s *AccountJobsService) every(ctx context.Context, run func(ctx context.Context)) {
+	ticker := time.NewTicker(accountJobsInterval)
+	defer ticker.Stop()
+
+	run(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			run(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUserNotFound    = repository.ErrUserNotFound
+	ErrSelfAction      = errors.New("admins cannot do this to their own account")
+	ErrLastRole        = errors.New("a user must keep at least one role")
+	ErrRoleNotAssigned = errors.New("user does not have this role")
+)
+
+// PermissionUserManage guards the admin user management APIs
+const PermissionUserManage = "user:manage"
+
+const (
+	defaultAdminUserPageSize = 20
+	maxAdminUserPageSize     = 100
+)
+
+func (s *authService) ListUsers(query *models.AdminUserListQuery) (*models.AdminUserListResponse, error) {
+	page := query.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := query.Limit
+	if limit < 1 || limit > maxAdminUserPageSize {
+		limit = defaultAdminUserPageSize
+	}
+
+	filter := repository.UserFilter{
+		Email:       strings.TrimSpace(query.Email),
+		Role:        query.Role,
+		Status:      query.Status,
+		CreatedFrom: query.CreatedFrom,
+		Limit:       limit,
+		Offset:      (page - 1) * limit,
+	}
+	// created_before is a date; include the whole day
+	if !query.CreatedBefore.IsZero() {
+		filter.CreatedBefore = query.CreatedBefore.AddDate(0, 0, 1)
+	}
+
+	users, total, err := s.userRepo.Search(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.AdminUserResponse, 0, len(users))
+	for _, u := range users {
+		roles := []string{}
+		if u.RoleNames != "" {
+			roles = strings.Split(u.RoleNames, ",")
+		}
+		responses = append(responses, adminUserResponse(&u.User, roles))
+	}
+
+	return &models.AdminUserListResponse{
+		Users: responses,
+		Pagination: models.PaginationResponse{
+			Page:       page,
+			Limit:      limit,
+			TotalItems: total,
+			TotalPages: (total + limit - 1) / limit,
+		},
+	}, nil
+}
+
+func (s *authService) GetUserForAdmin(userID uuid.UUID) (*models.AdminUserResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.roleRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := adminUserResponse(user, roleNames(roles))
+	return &response, nil
+}
+
+// SetUserActive activates or deactivates an account. Deactivation also
+// signs the user out everywhere.
+func (s *authService) SetUserActive(adminID, userID uuid.UUID, active bool, ip, userAgent string) error {
+	eventType := "admin_user_activated"
+	if !active {
+		eventType = "admin_user_deactivated"
+		if adminID == userID {
+			return ErrSelfAction
+		}
+	}
+
+	if err := s.userRepo.SetActive(userID, active); err != nil {
+		s.logAdminAudit(adminID, userID, eventType, "failed", ip, userAgent, err.Error(), nil)
+		return err
+	}
+
+	details := map[string]interface{}{}
+	if !active {
+		revoked, err := s.tokenRepo.RevokeUserSessions(userID, adminID, repository.RevokeReasonDeactivated)
+		if err != nil {
+			s.logAdminAudit(adminID, userID, eventType, "failed", ip, userAgent, err.Error(), nil)
+			return err
+		}
+		details["sessions_revoked"] = revoked
+		s.revokeUserAccessTokens(userID)
+	}
+
+	s.logAdminAudit(adminID, userID, eventType, "success", ip, userAgent, "", details)
+	return nil
+}
+
+// UnlockUser clears a lock caused by too many failed logins
+func (s *authService) UnlockUser(adminID, userID uuid.UUID, ip, userAgent string) error {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.ResetFailedAttempts(userID); err != nil {
+		s.logAdminAudit(adminID, userID, "admin_user_unlocked", "failed", ip, userAgent, err.Error(), nil)
+		return err
+	}
+
+	s.logAdminAudit(adminID, userID, "admin_user_unlocked", "success", ip, userAgent, "", nil)
+	return nil
+}
+
+// ForcePasswordReset signs the user out, blocks password login until a new
+// password is set and emails a reset code.
+func (s *authService) ForcePasswordReset(adminID, userID uuid.UUID, ip, userAgent string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetPasswordResetRequired(userID, true); err != nil {
+		s.logAdminAudit(adminID, userID, "admin_password_reset_forced", "failed", ip, userAgent, err.Error(), nil)
+		return err
+	}
+	revoked, err := s.tokenRepo.RevokeUserSessions(userID, adminID, repository.RevokeReasonForcedReset)
+	if err != nil {
+		s.logAdminAudit(adminID, userID, "admin_password_reset_forced", "failed", ip, userAgent, err.Error(), nil)
+		return err
+	}
+	s.revokeUserAccessTokens(userID)
+	if err := s.sendPasswordResetCode(user); err != nil {
+		s.logAdminAudit(adminID, userID, "admin_password_reset_forced", "failed", ip, userAgent, err.Error(), nil)
+		return err
+	}
+
+	s.logAdminAudit(adminID, userID, "admin_password_reset_forced", "success", ip, userAgent, "",
+		map[string]interface{}{"sessions_revoked": revoked})
+	return nil
+}
+
+func (s *authService) AssignUserRole(adminID, userID uuid.UUID, roleName, ip, userAgent string) error {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return err
+	}
+	role, err := s.roleRepo.FindByName(roleName)
+	if err != nil {
+		return err
+	}
+
+	details := map[string]interface{}{"role": roleName}
+	if err := s.roleRepo.AssignRoleToUser(userID, role.ID, &adminID); err != nil {
+		s.logAdminAudit(adminID, userID, "admin_role_assigned", "failed", ip, userAgent, err.Error(), details)
+		return err
+	}
+	// Tokens carry the old permissions; the client refreshes to get the new ones
+	s.revokeUserAccessTokens(userID)
+
+	s.logAdminAudit(adminID, userID, "admin_role_assigned", "success", ip, userAgent, "", details)
+	return nil
+}
+
+func (s *authService) RemoveUserRole(adminID, userID uuid.UUID, roleName, ip, userAgent string) error {
+	if adminID == userID {
+		return ErrSelfAction
+	}
+
+	roles, err := s.roleRepo.FindByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	var role *models.Role
+	for i := range roles {
+		if roles[i].Name == roleName {
+			role = &roles[i]
+		}
+	}
+	if role == nil {
+		return ErrRoleNotAssigned
+	}
+	// Login needs at least one role to fill the token's role claim
+	if len(roles) == 1 {
+		return ErrLastRole
+	}
+
+	details := map[string]interface{}{"role": roleName}
+	if err := s.roleRepo.RemoveRoleFromUser(userID, role.ID); err != nil {
+		s.logAdminAudit(adminID, userID, "admin_role_removed", "failed", ip, userAgent, err.Error(), details)
+		return err
+	}
+	s.revokeUserAccessTokens(userID)
+
+	s.logAdminAudit(adminID, userID, "admin_role_removed", "success", ip, userAgent, "", details)
+	return nil
+}
+
+// RevokeUserSessions signs the user out of every device
+func (s *authService) RevokeUserSessions(adminID, userID uuid.UUID, ip, userAgent string) (int64, error) {
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return 0, err
+	}
+
+	revoked, err := s.tokenRepo.RevokeUserSessions(userID, adminID, repository.RevokeReasonAdmin)
+	if err != nil {
+		s.logAdminAudit(adminID, userID, "admin_sessions_revoked", "failed", ip, userAgent, err.Error(), nil)
+		return 0, err
+	}
+	s.revokeUserAccessTokens(userID)
+
+	s.logAdminAudit(adminID, userID, "admin_sessions_revoked", "success", ip, userAgent, "",
+		map[string]interface{}{"sessions_revoked": revoked})
+	return revoked, nil
+}
+
+// logAdminAudit records an admin action against the affected user, with the
+// acting admin in the metadata.
+func (s *authService) logAdminAudit(adminID, userID uuid.UUID, eventType, status, ip, userAgent, errorMsg string, details map[string]interface{}) {
+	metadata := map[string]interface{}{"actor_id": adminID.String()}
+	for k, v := range details {
+		metadata[k] = v
+	}
+	encoded, _ := json.Marshal(metadata)
+	metadataStr := string(encoded)
+
+	entry := &models.AuditLog{
+		UserID:      &userID,
+		EventType:   eventType,
+		EventStatus: status,
+		Metadata:    &metadataStr,
+	}
+	if ip != "" {
+		entry.IPAddress = &ip
+	}
+	if userAgent != "" {
+		entry.UserAgent = &userAgent
+	}
+	if errorMsg != "" {
+		entry.ErrorMessage = &errorMsg
+	}
+
+	s.auditRepo.Create(entry)
+}
+
+func adminUserResponse(user *models.User, roles []string) models.AdminUserResponse {
+	return models.AdminUserResponse{
+		ID:                    user.ID.String(),
+		Email:                 user.Email,
+		Phone:                 user.Phone,
+		OAuthProvider:         user.OAuthProvider,
+		Roles:                 roles,
+		IsActive:              user.IsActive,
+		IsVerified:            user.IsVerified,
+		Locked:                user.LockedUntil != nil && user.LockedUntil.After(time.Now()),
+		LockedUntil:           user.LockedUntil,
+		FailedLoginAttempts:   user.FailedLoginAttempts,
+		PasswordResetRequired: user.PasswordResetRequired,
+		LastLoginAt:           user.LastLoginAt,
+		LastLoginIP:           user.LastLoginIP,
+		CreatedAt:             user.CreatedAt,
+	}
+}
+
+func roleNames(roles []models.Role) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
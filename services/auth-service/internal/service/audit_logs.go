@@ -0,0 +1,199 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+// PermissionAuditRead guards the admin audit log APIs
+const PermissionAuditRead = "audit:read"
+
+const (
+	defaultAuditLogPageSize = 20
+	maxAuditLogPageSize     = 100
+
+	// maxAuditLogExportRows caps one CSV export; narrow the filter for more
+	maxAuditLogExportRows = 100000
+
+	defaultSecurityActivityLimit = 20
+	// securityActivityWindow is how far back the security activity view looks
+	securityActivityWindow = 90 * 24 * time.Hour
+)
+
+// securityActivityEvents are the audit events shown to users about their own
+// account: sign-ins and their failures, and changes to how they sign in
+var securityActivityEvents = []string{
+	"login", "google_login", "oauth_login", "passwordless_login", "mfa_verify", "refresh_token_reuse",
+	"login_risk", "login_step_up_verify",
+	"identity_linked", "identity_unlinked",
+	"change_password", "reset_password", "reset_password_by_code", "email_change_requested", "email_changed",
+	"mfa_enabled", "mfa_disabled", "mfa_recovery_code_used", "mfa_recovery_codes_regenerated",
+	"session_revoked", "sessions_revoked_others",
+	"admin_password_reset_forced", "admin_sessions_revoked",
+	"account_deletion_requested", "account_deletion_cancelled", "data_export_requested",
+}
+
+var auditLogCSVHeader = []string{
+	"id", "created_at", "user_id", "user_email", "event_type", "event_status",
+	"ip_address", "user_agent", "error_message", "metadata",
+}
+
+func (s *authService) ListAuditLogs(query *models.AdminAuditLogQuery) (*models.AuditLogListResponse, error) {
+	page := query.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := query.Limit
+	if limit < 1 || limit > maxAuditLogPageSize {
+		limit = defaultAuditLogPageSize
+	}
+
+	filter := auditLogFilter(query)
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	entries, total, err := s.auditRepo.Search(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := make([]models.AuditLogResponse, 0, len(entries))
+	for i := range entries {
+		logs = append(logs, auditLogResponse(&entries[i]))
+	}
+
+	return &models.AuditLogListResponse{
+		Logs: logs,
+		Pagination: models.PaginationResponse{
+			Page:       page,
+			Limit:      limit,
+			TotalItems: total,
+			TotalPages: (total + limit - 1) / limit,
+		},
+	}, nil
+}
+
+// ExportAuditLogs writes every entry matching the query as CSV, oldest first
+func (s *authService) ExportAuditLogs(query *models.AdminAuditLogQuery, w io.Writer) error {
+	filter := auditLogFilter(query)
+	filter.Limit = maxAuditLogExportRows
+
+	out := csv.NewWriter(w)
+	if err := out.Write(auditLogCSVHeader); err != nil {
+		return err
+	}
+
+	err := s.auditRepo.Each(filter, func(entry *models.AuditLogEntry) error {
+		userID := ""
+		if entry.UserID != nil {
+			userID = entry.UserID.String()
+		}
+		return out.Write([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			userID,
+			csvSafe(stringValue(entry.UserEmail)),
+			entry.EventType,
+			entry.EventStatus,
+			stringValue(entry.IPAddress),
+			csvSafe(stringValue(entry.UserAgent)),
+			csvSafe(stringValue(entry.ErrorMessage)),
+			csvSafe(stringValue(entry.Metadata)),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// GetSecurityActivity lists the user's recent sign-ins, failed attempts and
+// security changes, newest first
+func (s *authService) GetSecurityActivity(userID uuid.UUID, limit int) ([]models.SecurityActivityResponse, error) {
+	if limit < 1 || limit > maxAuditLogPageSize {
+		limit = defaultSecurityActivityLimit
+	}
+
+	logs, err := s.auditRepo.ListByUser(userID, securityActivityEvents, time.Now().Add(-securityActivityWindow), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := make([]models.SecurityActivityResponse, 0, len(logs))
+	for _, entry := range logs {
+		item := models.SecurityActivityResponse{
+			EventType: entry.EventType,
+			Status:    entry.EventStatus,
+			CreatedAt: entry.CreatedAt,
+		}
+		// The IP and device of admin actions are the administrator's
+		if strings.HasPrefix(entry.EventType, "admin_") {
+			item.ByAdmin = true
+		} else {
+			item.DeviceName, item.DeviceType = parseUserAgent(stringValue(entry.UserAgent))
+			item.IPAddress = entry.IPAddress
+			item.Location = coarseLocation(stringValue(entry.IPAddress))
+		}
+		activity = append(activity, item)
+	}
+
+	return activity, nil
+}
+
+func auditLogFilter(query *models.AdminAuditLogQuery) repository.AuditLogFilter {
+	filter := repository.AuditLogFilter{
+		EventType: strings.TrimSpace(query.EventType),
+		Status:    query.Status,
+		IPAddress: query.IPAddress,
+		// created_at has no time zone and is written in UTC
+		From:     query.From.UTC(),
+		To:       query.To.UTC(),
+		Archived: query.Archived,
+	}
+	if userID, err := uuid.Parse(query.UserID); err == nil {
+		filter.UserID = &userID
+	}
+	return filter
+}
+
+func auditLogResponse(entry *models.AuditLogEntry) models.AuditLogResponse {
+	response := models.AuditLogResponse{
+		ID:           entry.ID,
+		UserEmail:    entry.UserEmail,
+		EventType:    entry.EventType,
+		EventStatus:  entry.EventStatus,
+		IPAddress:    entry.IPAddress,
+		UserAgent:    entry.UserAgent,
+		ErrorMessage: entry.ErrorMessage,
+		CreatedAt:    entry.CreatedAt,
+	}
+	if entry.UserID != nil {
+		userID := entry.UserID.String()
+		response.UserID = &userID
+	}
+	if entry.DeviceInfo != nil {
+		response.DeviceInfo = json.RawMessage(*entry.DeviceInfo)
+	}
+	if entry.Metadata != nil {
+		response.Metadata = json.RawMessage(*entry.Metadata)
+	}
+	return response
+}
+
+// csvSafe stops spreadsheet apps from evaluating user-controlled cells as formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
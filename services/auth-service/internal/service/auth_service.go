@@ -1,31 +1,52 @@
 package service
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/bisosad1501/DATN/services/auth-service/internal/config"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/geoip"
 	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
 	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
 	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
 	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	passwordResetCodeExpiry     = 15 * time.Minute
+	emailVerificationCodeExpiry = 24 * time.Hour
+)
+
 type AuthService interface {
 	Register(req *models.RegisterRequest, ip, userAgent string) (*models.AuthResponse, error)
 	Login(req *models.LoginRequest, ip, userAgent string) (*models.AuthResponse, error)
 	RefreshToken(req *models.RefreshTokenRequest, ip, userAgent string) (*models.AuthResponse, error)
-	Logout(userID uuid.UUID, refreshToken string) error
+	RequestPasswordlessLogin(req *models.PasswordlessLoginRequest, ip, userAgent string) error
+	PasswordlessLogin(req *models.VerifyPasswordlessLoginRequest, ip, userAgent string) (*models.AuthResponse, error)
+	Logout(userID uuid.UUID, refreshToken string, accessToken *TokenClaims) error
 	ChangePassword(userID uuid.UUID, req *models.ChangePasswordRequest) error
+	RequestEmailChange(userID uuid.UUID, req *models.ChangeEmailRequest, ip, userAgent string) (*models.EmailChangeResponse, error)
+	ConfirmEmailChange(userID uuid.UUID, currentSessionID string, req *models.ConfirmEmailChangeRequest, ip, userAgent string) error
 	ValidateToken(tokenString string) (*TokenClaims, error)
+	JWKS() jwks.Set
+
+	// Login risk: alerts on new devices and suspicious logins, step-up codes
+	VerifyLoginStepUp(req *models.VerifyLoginStepUpRequest, ip, userAgent string) (*models.AuthResponse, error)
+	TrackLogin(user *models.User, ip, userAgent string, device models.DeviceInfo)
+	// IssueTokens starts a session for a user who passed every login step
+	IssueTokens(user *models.User, role, ip, userAgent string, device models.DeviceInfo) (string, string, int64, error)
 
 	// Password reset
 	ForgotPassword(req *models.ForgotPasswordRequest, ip string) error
@@ -38,6 +59,63 @@ type AuthService interface {
 
 	// Reset password with code
 	ResetPasswordByCode(code, newPassword, ip string) error
+
+	// Sessions (one per login, identified by the sid claim)
+	ListSessions(userID uuid.UUID, currentSessionID string) ([]models.SessionResponse, error)
+	RevokeSession(userID, sessionID uuid.UUID, ip, userAgent string) error
+	RevokeOtherSessions(userID uuid.UUID, currentSessionID, ip, userAgent string) (int64, error)
+
+	// Two-factor authentication
+	GetMFAStatus(userID uuid.UUID) (*models.MFAStatusResponse, error)
+	SetupMFA(userID uuid.UUID, ip, userAgent string) (*models.MFASetupResponse, error)
+	EnableMFA(userID uuid.UUID, code, ip, userAgent string) (*models.MFARecoveryCodesResponse, error)
+	DisableMFA(userID uuid.UUID, req *models.DisableMFARequest, ip, userAgent string) error
+	RegenerateRecoveryCodes(userID uuid.UUID, code, ip, userAgent string) (*models.MFARecoveryCodesResponse, error)
+	BeginMFAChallenge(user *models.User, roles []models.Role, ip, userAgent string, device models.DeviceInfo) (*models.AuthResponse, error)
+	SetupMFAChallenge(mfaToken, ip, userAgent string) (*models.MFASetupResponse, error)
+	VerifyMFAChallenge(req *models.VerifyMFAChallengeRequest, ip, userAgent string) (*models.AuthResponse, error)
+	ListMFAPolicies() ([]models.MFAPolicyResponse, error)
+	SetMFAPolicy(adminID uuid.UUID, role string, require bool, ip, userAgent string) error
+
+	// Roles and permissions
+	GetUserPermissions(userID uuid.UUID) (*models.UserPermissionsResponse, error)
+	ListRoles() ([]models.RoleResponse, error)
+	CreateRole(adminID uuid.UUID, req *models.CreateRoleRequest, ip, userAgent string) (*models.RoleResponse, error)
+	DeleteRole(adminID uuid.UUID, roleName, ip, userAgent string) error
+	SetRolePermissions(adminID uuid.UUID, roleName string, permissions []string, ip, userAgent string) (*models.RoleResponse, error)
+	ListPermissions() ([]models.Permission, error)
+	CreatePermission(adminID uuid.UUID, req *models.CreatePermissionRequest, ip, userAgent string) (*models.Permission, error)
+
+	// Admin user management
+	ListUsers(query *models.AdminUserListQuery) (*models.AdminUserListResponse, error)
+	GetUserForAdmin(userID uuid.UUID) (*models.AdminUserResponse, error)
+	SetUserActive(adminID, userID uuid.UUID, active bool, ip, userAgent string) error
+	UnlockUser(adminID, userID uuid.UUID, ip, userAgent string) error
+	ForcePasswordReset(adminID, userID uuid.UUID, ip, userAgent string) error
+	AssignUserRole(adminID, userID uuid.UUID, roleName, ip, userAgent string) error
+	RemoveUserRole(adminID, userID uuid.UUID, roleName, ip, userAgent string) error
+	RevokeUserSessions(adminID, userID uuid.UUID, ip, userAgent string) (int64, error)
+
+	// Audit logs
+	ListAuditLogs(query *models.AdminAuditLogQuery) (*models.AuditLogListResponse, error)
+	ExportAuditLogs(query *models.AdminAuditLogQuery, w io.Writer) error
+	GetSecurityActivity(userID uuid.UUID, limit int) ([]models.SecurityActivityResponse, error)
+
+	// Account deletion and data export
+	RequestAccountDeletion(userID uuid.UUID, req *models.DeleteAccountRequest, ip, userAgent string) (*models.AccountDeletionResponse, error)
+	GetAccountDeletion(userID uuid.UUID) (*models.AccountDeletionResponse, error)
+	CancelAccountDeletion(userID uuid.UUID, ip, userAgent string) error
+	ListAccountDeletions(query *models.AdminAccountDeletionQuery) (*models.AccountDeletionListResponse, error)
+	RetryAccountDeletion(adminID, requestID uuid.UUID, ip, userAgent string) error
+	RequestDataExport(userID uuid.UUID, ip, userAgent string) (*models.DataExportResponse, error)
+	GetDataExport(userID uuid.UUID) (*models.DataExportResponse, error)
+	DataExportFile(userID, jobID uuid.UUID) (string, error)
+	RunDueAccountDeletions(ctx context.Context)
+	RunPendingDataExports(ctx context.Context)
+
+	// Outgoing emails
+	ListEmails(query *models.AdminEmailOutboxQuery) (*models.EmailOutboxListResponse, error)
+	RetryEmail(adminID, messageID uuid.UUID, ip, userAgent string) error
 }
 
 type authService struct {
@@ -47,17 +125,34 @@ type authService struct {
 	auditRepo             repository.AuditLogRepository
 	passwordResetRepo     repository.PasswordResetRepository
 	emailVerificationRepo repository.EmailVerificationRepository
+	mfaRepo               repository.MFARepository
+	loginCodeRepo         repository.LoginCodeRepository
+	emailChangeRepo       repository.EmailChangeRepository
+	deletionRepo          repository.AccountDeletionRepository
+	exportRepo            repository.DataExportRepository
+	emailOutboxRepo       repository.EmailOutboxRepository
+	passwordHistoryRepo   repository.PasswordHistoryRepository
+	loginHistoryRepo      repository.LoginHistoryRepository
 	emailService          EmailService
 	redisClient           *redis.Client
 	config                *config.Config
+	signingKeys           *SigningKeys
+	secretCipher          *secretCipher
+	passwordPolicy        *passwordPolicy
+	geoIP                 geoip.Locator
 	userServiceClient     *client.UserServiceClient
 	notificationClient    *client.NotificationServiceClient
+	userDataClients       []*client.UserDataClient // erased in this order, auth_db last
 }
 
 type TokenClaims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	Role   string `json:"role"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"` // refresh token family of the login
+	// Permissions granted through the user's roles when the token was issued;
+	// role changes apply from the next refresh
+	Permissions []string `json:"permissions"`
 	jwt.RegisteredClaims
 }
 
@@ -68,13 +163,49 @@ func NewAuthService(
 	auditRepo repository.AuditLogRepository,
 	passwordResetRepo repository.PasswordResetRepository,
 	emailVerificationRepo repository.EmailVerificationRepository,
+	mfaRepo repository.MFARepository,
+	loginCodeRepo repository.LoginCodeRepository,
+	emailChangeRepo repository.EmailChangeRepository,
+	deletionRepo repository.AccountDeletionRepository,
+	exportRepo repository.DataExportRepository,
+	emailOutboxRepo repository.EmailOutboxRepository,
+	passwordHistoryRepo repository.PasswordHistoryRepository,
+	loginHistoryRepo repository.LoginHistoryRepository,
 	emailService EmailService,
 	redisClient *redis.Client,
+	signingKeys *SigningKeys,
 	config *config.Config,
 ) AuthService {
 	// Initialize service clients
 	userServiceClient := client.NewUserServiceClient(config.UserServiceURL, config.InternalAPIKey)
 	notificationClient := client.NewNotificationServiceClient(config.NotificationServiceURL, config.InternalAPIKey)
+	userDataClients := []*client.UserDataClient{
+		client.NewUserDataClient("notification", config.NotificationServiceURL, "/api/v1/notifications/internal", config.InternalAPIKey),
+		client.NewUserDataClient("exercise", config.ExerciseServiceURL, "/api/v1/exercises/internal", config.InternalAPIKey),
+		client.NewUserDataClient("course", config.CourseServiceURL, "/api/v1/courses/internal", config.InternalAPIKey),
+		client.NewUserDataClient("user", config.UserServiceURL, "/api/v1/user/internal", config.InternalAPIKey),
+	}
+
+	if err := checkMFAEncryptionKey(config); err != nil {
+		log.Fatalf("[Auth-Service] %v", err)
+	}
+	mfaCipher, err := newSecretCipher(config.MFAEncryptionKey)
+	if err != nil {
+		log.Fatalf("[Auth-Service] Failed to initialize MFA cipher: %v", err)
+	}
+
+	policy, err := newPasswordPolicy(config)
+	if err != nil {
+		log.Fatalf("[Auth-Service] Failed to load password policy: %v", err)
+	}
+
+	geoIP := geoip.None
+	if config.GeoIPDatabase != "" {
+		if geoIP, err = geoip.Open(config.GeoIPDatabase); err != nil {
+			log.Fatalf("[Auth-Service] Failed to load GeoIP database: %v", err)
+		}
+		log.Printf("[Auth-Service] Loaded GeoIP database %s", config.GeoIPDatabase)
+	}
 
 	return &authService{
 		userRepo:              userRepo,
@@ -83,11 +214,24 @@ func NewAuthService(
 		auditRepo:             auditRepo,
 		passwordResetRepo:     passwordResetRepo,
 		emailVerificationRepo: emailVerificationRepo,
+		mfaRepo:               mfaRepo,
+		loginCodeRepo:         loginCodeRepo,
+		emailChangeRepo:       emailChangeRepo,
+		deletionRepo:          deletionRepo,
+		exportRepo:            exportRepo,
+		emailOutboxRepo:       emailOutboxRepo,
+		passwordHistoryRepo:   passwordHistoryRepo,
+		loginHistoryRepo:      loginHistoryRepo,
 		emailService:          emailService,
 		redisClient:           redisClient,
 		config:                config,
+		signingKeys:           signingKeys,
+		secretCipher:          mfaCipher,
+		passwordPolicy:        policy,
+		geoIP:                 geoIP,
 		userServiceClient:     userServiceClient,
 		notificationClient:    notificationClient,
+		userDataClients:       userDataClients,
 	}
 }
 
@@ -123,13 +267,20 @@ func (s *authService) Register(req *models.RegisterRequest, ip, userAgent string
 	}
 
 	// Validate password strength
-	if len(req.Password) < 8 {
-		s.logAudit(nil, "register", "failed", ip, userAgent, "password too short")
+	if err := s.validateNewPassword(nil, req.Email, req.FullName, req.Password); err != nil {
+		var policyErr *PasswordPolicyError
+		if !errors.As(err, &policyErr) {
+			return nil, err
+		}
+		s.logAudit(nil, "register", "failed", ip, userAgent, "weak password")
 		return &models.AuthResponse{
 			Success: false,
 			Error: &models.ErrorData{
 				Code:    "WEAK_PASSWORD",
-				Message: "Password must be at least 8 characters long",
+				Message: policyErr.Error(),
+				Details: map[string]interface{}{
+					"violations": policyErr.Violations,
+				},
 			},
 		}, nil
 	}
@@ -166,6 +317,7 @@ func (s *authService) Register(req *models.RegisterRequest, ip, userAgent string
 		}
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
+	s.recordPasswordHistory(user.ID, pw)
 
 	// Assign role
 	role, err := s.roleRepo.FindByName(req.Role)
@@ -179,10 +331,11 @@ func (s *authService) Register(req *models.RegisterRequest, ip, userAgent string
 	}
 
 	// Generate tokens
-	accessToken, refreshToken, expiresIn, err := s.generateTokens(user.ID, req.Email, req.Role, ip, userAgent)
+	accessToken, refreshToken, expiresIn, err := s.generateTokens(user.ID, req.Email, req.Role, ip, userAgent, models.DeviceInfo{})
 	if err != nil {
 		return nil, err
 	}
+	s.recordLogin(user.ID, s.describeLogin(ip, userAgent, models.DeviceInfo{}))
 
 	log.Printf("[Auth-Service] Starting post-registration tasks for user %s (%s)", user.ID, user.Email)
 
@@ -258,6 +411,7 @@ func (s *authService) Login(req *models.LoginRequest, ip, userAgent string) (*mo
 	user, err := s.userRepo.FindByEmail(req.Email)
 	if err != nil {
 		if err.Error() == "user not found" {
+			s.recordLoginFailure(ip, req.Email, userAgent)
 			s.logAudit(nil, "login", "failed", ip, userAgent, fmt.Sprintf("user not found: %s", req.Email))
 			return &models.AuthResponse{
 				Success: false,
@@ -291,16 +445,8 @@ func (s *authService) Login(req *models.LoginRequest, ip, userAgent string) (*mo
 
     // Verify password (handle OAuth users where password may be nil)
     if user.Password == nil || bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.Password)) != nil {
-		// Increment failed attempts
-		s.userRepo.IncrementFailedAttempts(user.ID)
-
-		// Check if should lock account
-		user, _ := s.userRepo.FindByID(user.ID)
-		if user.FailedLoginAttempts >= s.config.MaxLoginAttempts {
-			lockDuration := time.Duration(s.config.AccountLockDuration) * time.Minute
-			s.userRepo.LockAccount(user.ID, lockDuration)
-		}
-
+		s.recordFailedAttempt(user.ID)
+		s.recordLoginFailure(ip, req.Email, userAgent)
 		s.logAudit(&user.ID, "login", "failed", ip, userAgent, "invalid password")
 		return &models.AuthResponse{
 			Success: false,
@@ -323,6 +469,18 @@ func (s *authService) Login(req *models.LoginRequest, ip, userAgent string) (*mo
 		}, nil
 	}
 
+	// An admin required a new password; the emailed reset code still works
+	if user.PasswordResetRequired {
+		s.logAudit(&user.ID, "login", "failed", ip, userAgent, "password reset required")
+		return &models.AuthResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "PASSWORD_RESET_REQUIRED",
+				Message: "You must reset your password before signing in",
+			},
+		}, nil
+	}
+
 	// Get user roles
 	roles, err := s.roleRepo.FindByUserID(user.ID)
 	if err != nil || len(roles) == 0 {
@@ -331,17 +489,31 @@ func (s *authService) Login(req *models.LoginRequest, ip, userAgent string) (*mo
 
 	roleName := roles[0].Name
 
-	// Reset failed attempts
-	s.userRepo.ResetFailedAttempts(user.ID)
+	// The password is right; warn the owner before any further step, which
+	// whoever holds the password may never finish
+	risk := s.assessLogin(user.ID, ip, userAgent, req.DeviceInfo)
+	s.alertLogin(user, risk, ip, userAgent)
+
+	// Second factor: tokens are only issued once the challenge is verified
+	challenge, err := s.BeginMFAChallenge(user, roles, ip, userAgent, req.DeviceInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start mfa challenge: %w", err)
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
 
-	// Update login info
-	s.userRepo.UpdateLoginInfo(user.ID, ip)
+	// Without 2FA, a high-risk login is confirmed with a code sent by email
+	if s.config.LoginStepUpEnabled && risk.Level() == LoginRiskHigh {
+		return s.beginLoginStepUp(user, roleName, risk, ip, userAgent, req.DeviceInfo)
+	}
 
 	// Generate tokens
-	accessToken, refreshToken, expiresIn, err := s.generateTokens(user.ID, user.Email, roleName, ip, userAgent)
+	accessToken, refreshToken, expiresIn, err := s.IssueTokens(user, roleName, ip, userAgent, req.DeviceInfo)
 	if err != nil {
 		return nil, err
 	}
+	s.recordLogin(user.ID, risk)
 
 	s.logAudit(&user.ID, "login", "success", ip, userAgent, "")
 
@@ -362,16 +534,22 @@ func (s *authService) RefreshToken(req *models.RefreshTokenRequest, ip, userAgen
 	// Hash the refresh token
 	tokenHash := s.hashToken(req.RefreshToken)
 
-	// Find token in database
-	token, err := s.tokenRepo.FindRefreshToken(tokenHash)
+	// Find token in database, including revoked ones so reuse can be detected
+	token, err := s.tokenRepo.FindRefreshTokenByHash(tokenHash)
 	if err != nil {
-		return &models.AuthResponse{
-			Success: false,
-			Error: &models.ErrorData{
-				Code:    "INVALID_TOKEN",
-				Message: "Invalid or expired refresh token",
-			},
-		}, nil
+		return invalidRefreshTokenResponse(), nil
+	}
+
+	if token.RevokedAt != nil {
+		// A rotated token should never be presented again; if it is, either
+		// the client or an attacker holds a stale copy, so end the session.
+		if token.RevokedReason != nil && *token.RevokedReason == repository.RevokeReasonRotated {
+			if s.isConcurrentRefresh(token, ip, userAgent) {
+				return alreadyRotatedResponse(), nil
+			}
+			s.handleRefreshTokenReuse(token, ip, userAgent)
+		}
+		return invalidRefreshTokenResponse(), nil
 	}
 
 	// Check if token is expired
@@ -391,6 +569,17 @@ func (s *authService) RefreshToken(req *models.RefreshTokenRequest, ip, userAgen
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
 
+	// Same account checks as Login, so a deactivation or forced reset ends
+	// the session even if revoking its tokens failed
+	if !user.IsActive {
+		s.logAudit(&user.ID, "refresh_token", "failed", ip, userAgent, "account inactive")
+		return loginErrorResponse("ACCOUNT_INACTIVE", "Account is inactive"), nil
+	}
+	if user.PasswordResetRequired {
+		s.logAudit(&user.ID, "refresh_token", "failed", ip, userAgent, "password reset required")
+		return loginErrorResponse("PASSWORD_RESET_REQUIRED", "You must reset your password before signing in"), nil
+	}
+
 	// Get user roles
 	roles, err := s.roleRepo.FindByUserID(user.ID)
 	if err != nil || len(roles) == 0 {
@@ -399,15 +588,29 @@ func (s *authService) RefreshToken(req *models.RefreshTokenRequest, ip, userAgen
 
 	roleName := roles[0].Name
 
-	// Update last used
-	s.tokenRepo.UpdateLastUsed(token.ID)
-
-	// Generate new access token
-	accessToken, _, expiresIn, err := s.generateTokens(user.ID, user.Email, roleName, ip, userAgent)
+	accessToken, expiresIn, err := s.signAccessToken(user.ID, user.Email, roleName, token.FamilyID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Rotate: the presented token is revoked and replaced within its family
+	refreshTokenStr, nextToken := s.newRefreshToken(user.ID, ip, userAgent)
+	nextToken.DeviceID = token.DeviceID
+	nextToken.DeviceName = token.DeviceName
+	nextToken.DeviceType = token.DeviceType
+
+	if err := s.tokenRepo.RotateRefreshToken(token, nextToken); err != nil {
+		if errors.Is(err, repository.ErrTokenAlreadyRotated) {
+			// Another request rotated it between the lookup and now
+			if s.isConcurrentRefresh(token, ip, userAgent) {
+				return alreadyRotatedResponse(), nil
+			}
+			s.handleRefreshTokenReuse(token, ip, userAgent)
+			return invalidRefreshTokenResponse(), nil
+		}
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
 	s.logAudit(&user.ID, "refresh_token", "success", ip, userAgent, "")
 
 	return &models.AuthResponse{
@@ -417,13 +620,16 @@ func (s *authService) RefreshToken(req *models.RefreshTokenRequest, ip, userAgen
 			Email:        user.Email,
 			Role:         roleName,
 			AccessToken:  accessToken,
-			RefreshToken: req.RefreshToken,
+			RefreshToken: refreshTokenStr,
 			ExpiresIn:    expiresIn,
 		},
 	}, nil
 }
 
-func (s *authService) Logout(userID uuid.UUID, refreshToken string) error {
+func (s *authService) Logout(userID uuid.UUID, refreshToken string, accessToken *TokenClaims) error {
+	// The access token used to log out stops working immediately
+	s.revokeAccessToken(accessToken)
+
 	tokenHash := s.hashToken(refreshToken)
 
 	token, err := s.tokenRepo.FindRefreshToken(tokenHash)
@@ -445,21 +651,18 @@ func (s *authService) ChangePassword(userID uuid.UUID, req *models.ChangePasswor
 		return fmt.Errorf("invalid old password")
 	}
 
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), s.config.BcryptRounds)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+	if err := s.validateNewPassword(user, user.Email, s.profileName(user.ID), req.NewPassword); err != nil {
+		return err
 	}
 
-    newPw := string(hashedPassword)
-    user.Password = &newPw
-
-	if err := s.userRepo.Update(user); err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	if err := s.setPassword(user, req.NewPassword); err != nil {
+		return err
 	}
+	s.clearPasswordResetRequired(user)
 
-	// Revoke all refresh tokens
+	// Revoke all refresh and access tokens
 	s.tokenRepo.RevokeAllUserTokens(userID)
+	s.revokeUserAccessTokens(userID)
 
 	s.logAudit(&userID, "change_password", "success", "", "", "")
 
@@ -467,50 +670,100 @@ func (s *authService) ChangePassword(userID uuid.UUID, req *models.ChangePasswor
 }
 
 func (s *authService) ValidateToken(tokenString string) (*TokenClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.config.JWTSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, s.signingKeys.Keyfunc, jwt.WithValidMethods(jwks.Algorithms))
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*TokenClaims); ok && token.Valid {
+		revoked, err := s.isAccessTokenRevoked(claims)
+		if err != nil {
+			// Fail open like the gateway: refresh tokens are still checked in the database
+			log.Printf("[Auth-Service] WARNING: Token revocation check failed: %v", err)
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
 		return claims, nil
 	}
 
 	return nil, fmt.Errorf("invalid token")
 }
 
+// JWKS returns the public keys that verify access tokens
+func (s *authService) JWKS() jwks.Set {
+	return s.signingKeys.JWKS()
+}
+
+// IssueTokens clears the failed attempts, records the login and creates the
+// session. Failed attempts are only cleared once every step has passed, so
+// the second factor cannot be guessed by logging in again.
+func (s *authService) IssueTokens(user *models.User, role, ip, userAgent string, device models.DeviceInfo) (string, string, int64, error) {
+	if err := s.userRepo.ResetFailedAttempts(user.ID); err != nil {
+		log.Printf("Failed to reset failed attempts: %v", err)
+	}
+	if err := s.userRepo.UpdateLoginInfo(user.ID, ip); err != nil {
+		log.Printf("Failed to update login info: %v", err)
+	}
+	return s.generateTokens(user.ID, user.Email, role, ip, userAgent, device)
+}
+
 // Helper functions
 
-func (s *authService) generateTokens(userID uuid.UUID, email, role, ip, userAgent string) (string, string, int64, error) {
+func (s *authService) generateTokens(userID uuid.UUID, email, role, ip, userAgent string, device models.DeviceInfo) (string, string, int64, error) {
+	// Each login starts a new refresh token family, which is also the session ID
+	refreshTokenStr, refreshToken := s.newRefreshToken(userID, ip, userAgent)
+	refreshToken.FamilyID = uuid.New()
+	applyDeviceInfo(refreshToken, device, userAgent)
+
+	accessToken, expiresIn, err := s.signAccessToken(userID, email, role, refreshToken.FamilyID)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	if err := s.tokenRepo.CreateRefreshToken(refreshToken); err != nil {
+		return "", "", 0, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return accessToken, refreshTokenStr, expiresIn, nil
+}
+
+func (s *authService) signAccessToken(userID uuid.UUID, email, role string, sessionID uuid.UUID) (string, int64, error) {
+	permissions, err := s.permissionsForUser(userID)
+	if err != nil {
+		return "", 0, err
+	}
+
 	// Parse JWT expiry
 	expiryDuration, _ := time.ParseDuration(s.config.JWTExpiry)
 	expiresAt := time.Now().Add(expiryDuration)
 
 	// Create access token
 	claims := TokenClaims{
-		UserID: userID.String(),
-		Email:  email,
-		Role:   role,
+		UserID:      userID.String(),
+		Email:       email,
+		Role:        role,
+		SessionID:   sessionID.String(),
+		Permissions: permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(), // jti, for revocation
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	accessToken, err := token.SignedString([]byte(s.config.JWTSecret))
+	accessToken, err := s.signingKeys.Sign(claims)
 	if err != nil {
-		return "", "", 0, fmt.Errorf("failed to sign token: %w", err)
+		return "", 0, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	// Create refresh token
+	return accessToken, int64(expiryDuration.Seconds()), nil
+}
+
+// newRefreshToken returns an opaque refresh token and its unsaved record
+func (s *authService) newRefreshToken(userID uuid.UUID, ip, userAgent string) (string, *models.RefreshToken) {
 	refreshTokenStr := uuid.New().String()
 	refreshTokenHash := s.hashToken(refreshTokenStr)
 
@@ -530,11 +783,78 @@ func (s *authService) generateTokens(userID uuid.UUID, email, role, ip, userAgen
 		refreshToken.UserAgent = &userAgent
 	}
 
-	if err := s.tokenRepo.CreateRefreshToken(refreshToken); err != nil {
-		return "", "", 0, fmt.Errorf("failed to create refresh token: %w", err)
+	return refreshTokenStr, refreshToken
+}
+
+// isConcurrentRefresh reports whether a spent token comes back from the
+// client it was issued to within RefreshTokenReuseGrace of its rotation, as
+// when two tabs refresh with the same token at once. The trade-off: a thief
+// replaying the token from the same IP and browser within those seconds is
+// not detected, but the tokens issued to the winning request stay the only
+// valid ones, so the replay gains nothing. A token whose rotation has not
+// been read yet (RevokedAt nil) was rotated by a concurrent request.
+func (s *authService) isConcurrentRefresh(token *models.RefreshToken, ip, userAgent string) bool {
+	if s.config.RefreshTokenReuseGrace <= 0 {
+		return false
+	}
+	if token.RevokedAt != nil && time.Since(*token.RevokedAt) > s.config.RefreshTokenReuseGrace {
+		return false
+	}
+	return token.IPAddress != nil && *token.IPAddress == ip &&
+		token.UserAgent != nil && *token.UserAgent == userAgent
+}
+
+// handleRefreshTokenReuse revokes every token in the family of a reused
+// refresh token and alerts the owner.
+func (s *authService) handleRefreshTokenReuse(token *models.RefreshToken, ip, userAgent string) {
+	revoked, err := s.tokenRepo.RevokeTokenFamily(token.FamilyID, repository.RevokeReasonReuse)
+	if err != nil {
+		log.Printf("[Auth-Service] ERROR: Failed to revoke token family %s: %v", token.FamilyID, err)
+	}
+
+	s.revokeSessionAccessTokens(token.FamilyID)
+
+	log.Printf("[Auth-Service] SECURITY: Refresh token reuse for user %s, revoked %d tokens in family %s", token.UserID, revoked, token.FamilyID)
+	s.logAudit(&token.UserID, "refresh_token_reuse", "failed", ip, userAgent,
+		fmt.Sprintf("rotated refresh token reused, family %s revoked", token.FamilyID))
+
+	// Only alert on the first detection; later replays find nothing left to revoke
+	if revoked == 0 {
+		return
+	}
+
+	if err := s.notificationClient.SendNotification(client.SendNotificationRequest{
+		UserID:   token.UserID.String(),
+		Title:    "Security alert",
+		Message:  "A previously used sign-in token was presented again, so we signed you out on that device. If this wasn't you, change your password.",
+		Type:     "system",
+		Category: "alert",
+		Priority: "high",
+	}); err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to send security notification to user %s: %v", token.UserID, err)
+	}
+}
+
+// alreadyRotatedResponse tells a client that lost a refresh race to use the
+// tokens the other request received
+func alreadyRotatedResponse() *models.AuthResponse {
+	return &models.AuthResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    "TOKEN_ALREADY_ROTATED",
+			Message: "Refresh token was just rotated by another request, use the new token",
+		},
 	}
+}
 
-	return accessToken, refreshTokenStr, int64(expiryDuration.Seconds()), nil
+func invalidRefreshTokenResponse() *models.AuthResponse {
+	return &models.AuthResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    "INVALID_TOKEN",
+			Message: "Invalid or expired refresh token",
+		},
+	}
 }
 
 func (s *authService) hashToken(token string) string {
@@ -577,6 +897,17 @@ func (s *authService) ForgotPassword(req *models.ForgotPasswordRequest, ip strin
 		return nil
 	}
 
+	if err := s.sendPasswordResetCode(user); err != nil {
+		return err
+	}
+
+	s.logAudit(&user.ID, "forgot_password", "success", ip, "", "")
+
+	return nil
+}
+
+// sendPasswordResetCode replaces any pending reset token of user and emails a new 6-digit code
+func (s *authService) sendPasswordResetCode(user *models.User) error {
 	// Delete any existing password reset tokens for this user
 	s.passwordResetRepo.DeleteByUserID(user.ID)
 
@@ -592,26 +923,52 @@ func (s *authService) ForgotPassword(req *models.ForgotPasswordRequest, ip strin
 		UserID:    user.ID,
 		TokenHash: tokenHash,
 		Code:      &code,
-		ExpiresAt: time.Now().Add(15 * time.Minute),
+		ExpiresAt: time.Now().Add(passwordResetCodeExpiry),
 	}
 
 	if err := s.passwordResetRepo.Create(token); err != nil {
 		return fmt.Errorf("failed to create reset token: %w", err)
 	}
 
-	// Send email with 6-digit code
-	if err := s.emailService.SendPasswordResetEmail(user.Email, code); err != nil {
-		fmt.Printf("Failed to send email to %s: %v\n", user.Email, err)
+	// Queue email with 6-digit code
+	if err := s.emailService.SendPasswordResetEmail(user.ID, user.Email, code, passwordResetCodeExpiry); err != nil {
+		fmt.Printf("Failed to queue email to %s: %v\n", user.Email, err)
 		// Don't fail the request if email fails, but log it
 	} else {
-		fmt.Printf("Password reset code sent to %s: %s\n", user.Email, code)
+		fmt.Printf("Password reset code queued for %s\n", user.Email)
 	}
 
-	s.logAudit(&user.ID, "forgot_password", "success", ip, "", "")
-
 	return nil
 }
 
+// recordFailedAttempt counts a wrong password or second-factor code and locks
+// the account once MaxLoginAttempts is reached
+func (s *authService) recordFailedAttempt(userID uuid.UUID) {
+	if err := s.userRepo.IncrementFailedAttempts(userID); err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to count failed login for user %s: %v", userID, err)
+		return
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return
+	}
+	if user.FailedLoginAttempts >= s.config.MaxLoginAttempts {
+		lockDuration := time.Duration(s.config.AccountLockDuration) * time.Minute
+		s.userRepo.LockAccount(userID, lockDuration)
+	}
+}
+
+// clearPasswordResetRequired lifts an admin-forced reset once the user has set a new password
+func (s *authService) clearPasswordResetRequired(user *models.User) {
+	if !user.PasswordResetRequired {
+		return
+	}
+	if err := s.userRepo.SetPasswordResetRequired(user.ID, false); err != nil {
+		log.Printf("[Auth-Service] Failed to clear password reset flag for %s: %v", user.ID, err)
+	}
+}
+
 // ResetPassword resets user password with token
 func (s *authService) ResetPassword(req *models.ResetPasswordRequest, ip string) error {
 	// Hash the token
@@ -623,34 +980,28 @@ func (s *authService) ResetPassword(req *models.ResetPasswordRequest, ip string)
 		return fmt.Errorf("invalid or expired token")
 	}
 
-	// Validate password strength
-	if len(req.NewPassword) < 8 {
-		return fmt.Errorf("password must be at least 8 characters long")
-	}
-
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), s.config.BcryptRounds)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	// Update user password
 	user, err := s.userRepo.FindByID(token.UserID)
 	if err != nil {
 		return fmt.Errorf("user not found: %w", err)
 	}
 
-    newPw := string(hashedPassword)
-    user.Password = &newPw
-	if err := s.userRepo.Update(user); err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// Validate password strength
+	if err := s.validateNewPassword(user, user.Email, s.profileName(user.ID), req.NewPassword); err != nil {
+		return err
+	}
+
+	// Update user password
+	if err := s.setPassword(user, req.NewPassword); err != nil {
+		return err
 	}
+	s.clearPasswordResetRequired(user)
 
 	// Mark token as used
 	s.passwordResetRepo.MarkAsUsed(token.ID)
 
-	// Revoke all refresh tokens for security
+	// Revoke all refresh and access tokens for security
 	s.tokenRepo.RevokeAllUserTokens(token.UserID)
+	s.revokeUserAccessTokens(token.UserID)
 
 	s.logAudit(&token.UserID, "reset_password", "success", ip, "", "")
 
@@ -720,19 +1071,19 @@ func (s *authService) ResendVerification(email string) error {
 		UserID:    user.ID,
 		TokenHash: tokenHash,
 		Code:      &code,
-		ExpiresAt: time.Now().Add(24 * time.Hour),
+		ExpiresAt: time.Now().Add(emailVerificationCodeExpiry),
 	}
 
 	if err := s.emailVerificationRepo.Create(token); err != nil {
 		return fmt.Errorf("failed to create verification token: %w", err)
 	}
 
-	// Send email with 6-digit code
-	if err := s.emailService.SendVerificationEmail(user.Email, code); err != nil {
-		fmt.Printf("Failed to send email to %s: %v\n", user.Email, err)
+	// Queue email with 6-digit code
+	if err := s.emailService.SendVerificationEmail(user.ID, user.Email, code, emailVerificationCodeExpiry); err != nil {
+		fmt.Printf("Failed to queue email to %s: %v\n", user.Email, err)
 		// Don't fail the request if email fails
 	} else {
-		fmt.Printf("Email verification code sent to %s: %s\n", user.Email, code)
+		fmt.Printf("Email verification code queued for %s\n", user.Email)
 	}
 
 	s.logAudit(&user.ID, "resend_verification", "success", "", "", "")
@@ -779,34 +1130,28 @@ func (s *authService) ResetPasswordByCode(code, newPassword, ip string) error {
 		return fmt.Errorf("invalid or expired code")
 	}
 
-	// Validate password strength
-	if len(newPassword) < 8 {
-		return fmt.Errorf("password must be at least 8 characters long")
-	}
-
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), s.config.BcryptRounds)
-	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	// Update user password
 	user, err := s.userRepo.FindByID(token.UserID)
 	if err != nil {
 		return fmt.Errorf("user not found: %w", err)
 	}
 
-    newPw := string(hashedPassword)
-    user.Password = &newPw
-	if err := s.userRepo.Update(user); err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// Validate password strength
+	if err := s.validateNewPassword(user, user.Email, s.profileName(user.ID), newPassword); err != nil {
+		return err
+	}
+
+	// Update user password
+	if err := s.setPassword(user, newPassword); err != nil {
+		return err
 	}
+	s.clearPasswordResetRequired(user)
 
 	// Mark token as used
 	s.passwordResetRepo.MarkAsUsed(token.ID)
 
-	// Revoke all refresh tokens for security
+	// Revoke all refresh and access tokens for security
 	s.tokenRepo.RevokeAllUserTokens(token.UserID)
+	s.revokeUserAccessTokens(token.UserID)
 
 	s.logAudit(&token.UserID, "reset_password_by_code", "success", ip, "", "")
 
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDataExportNotFound = repository.ErrDataExportNotFound
+	ErrDataExportExists   = repository.ErrDataExportExists
+	ErrDataExportNotReady = errors.New("data export is not ready for download")
+	ErrDataExportExpired  = errors.New("data export has expired, please request a new one")
+)
+
+const (
+	dataExportBatchSize = 5
+	dataExportLease     = 15 * time.Minute
+)
+
+// RequestDataExport queues an export of everything the platform stores about the user
+func (s *authService) RequestDataExport(userID uuid.UUID, ip, userAgent string) (*models.DataExportResponse, error) {
+	job := &models.DataExportJob{UserID: userID}
+	if err := s.exportRepo.Create(job); err != nil {
+		s.logAudit(&userID, "data_export_requested", "failed", ip, userAgent, err.Error())
+		return nil, err
+	}
+
+	s.logAudit(&userID, "data_export_requested", "success", ip, userAgent, "")
+	return dataExportResponse(job), nil
+}
+
+// GetDataExport returns the user's latest export job
+func (s *authService) GetDataExport(userID uuid.UUID) (*models.DataExportResponse, error) {
+	job, err := s.exportRepo.FindLatestByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return dataExportResponse(job), nil
+}
+
+// DataExportFile returns the path of a finished export owned by the user
+func (s *authService) DataExportFile(userID, jobID uuid.UUID) (string, error) {
+	job, err := s.exportRepo.FindByID(jobID)
+	if err != nil {
+		return "", err
+	}
+	if job.UserID != userID {
+		return "", ErrDataExportNotFound
+	}
+
+	switch {
+	case job.Status == models.DataExportExpired,
+		job.Status == models.DataExportCompleted && job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt):
+		return "", ErrDataExportExpired
+	case job.Status != models.DataExportCompleted || job.FilePath == nil:
+		return "", ErrDataExportNotReady
+	}
+	return *job.FilePath, nil
+}
+
+// RunPendingDataExports builds queued exports and deletes expired ones
+func (s *authService) RunPendingDataExports(ctx context.Context) {
+	jobs, err := s.exportRepo.ClaimPending(dataExportBatchSize, dataExportLease)
+	if err != nil {
+		log.Printf("[DataExport] ERROR: %v", err)
+	}
+	for i := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		s.runDataExport(ctx, &jobs[i])
+	}
+
+	expired, err := s.exportRepo.Expire(dataExportBatchSize * 10)
+	if err != nil {
+		log.Printf("[DataExport] ERROR: %v", err)
+	}
+	for _, job := range expired {
+		if job.FilePath == nil {
+			continue
+		}
+		if err := os.Remove(*job.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("[DataExport] ERROR: remove expired export %s: %v", job.ID, err)
+		}
+	}
+}
+
+func (s *authService) runDataExport(ctx context.Context, job *models.DataExportJob) {
+	path, size, err := s.writeDataExport(ctx, job)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shutting down: the lease runs out and the job is built again
+			return
+		}
+		log.Printf("[DataExport] Export %s failed: %v", job.ID, err)
+		if ferr := s.exportRepo.Fail(job.ID, err.Error()); ferr != nil {
+			log.Printf("[DataExport] ERROR: %v", ferr)
+		}
+		s.logJobAudit(&job.UserID, "data_export_generated", "failed", err.Error(), map[string]interface{}{"export_id": job.ID.String()})
+		return
+	}
+
+	if err := s.exportRepo.Complete(job.ID, path, size, time.Now().Add(s.config.DataExportExpiry)); err != nil {
+		log.Printf("[DataExport] ERROR: %v", err)
+		return
+	}
+	s.logJobAudit(&job.UserID, "data_export_generated", "success", "", map[string]interface{}{"export_id": job.ID.String()})
+	log.Printf("[DataExport] Export %s ready (%d bytes)", job.ID, size)
+}
+
+// writeDataExport collects the user's data from every service and writes
+// <DataExportDir>/<job ID>.zip with one JSON file per service
+func (s *authService) writeDataExport(ctx context.Context, job *models.DataExportJob) (string, int64, error) {
+	authData, err := s.exportRepo.ExportUser(job.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	files := map[string]interface{}{authDataStep + ".json": authData}
+	services := []string{authDataStep}
+
+	for _, dataClient := range s.userDataClients {
+		data, err := dataClient.WithContext(ctx).ExportUserData(job.UserID.String())
+		if err != nil {
+			return "", 0, err
+		}
+		files[dataClient.Service()+".json"] = data
+		services = append(services, dataClient.Service())
+	}
+
+	files["manifest.json"] = map[string]interface{}{
+		"export_id":    job.ID.String(),
+		"user_id":      job.UserID.String(),
+		"generated_at": time.Now().UTC(),
+		"services":     services,
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		encoded, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			return "", 0, fmt.Errorf("encode %s: %w", name, err)
+		}
+		w, err := archive.Create(name)
+		if err != nil {
+			return "", 0, err
+		}
+		if _, err := w.Write(encoded); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(s.config.DataExportDir, 0o700); err != nil {
+		return "", 0, fmt.Errorf("create export dir: %w", err)
+	}
+	// Write then rename so a download never sees a partial file
+	path := filepath.Join(s.config.DataExportDir, job.ID.String()+".zip")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return "", 0, fmt.Errorf("write export: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", 0, fmt.Errorf("write export: %w", err)
+	}
+	return path, int64(buf.Len()), nil
+}
+
+func dataExportResponse(job *models.DataExportJob) *models.DataExportResponse {
+	return &models.DataExportResponse{
+		ID:          job.ID.String(),
+		Status:      job.Status,
+		RequestedAt: job.RequestedAt,
+		CompletedAt: job.CompletedAt,
+		ExpiresAt:   job.ExpiresAt,
+		FileSize:    job.FileSize,
+		Error:       job.ErrorMessage,
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"net"
+	"strings"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+)
+
+// applyDeviceInfo records client metadata on a new refresh token, falling back
+// to what can be read from the User-Agent for anything the client left out.
+func applyDeviceInfo(token *models.RefreshToken, device models.DeviceInfo, userAgent string) {
+	name, deviceType := parseUserAgent(userAgent)
+	if device.DeviceName != "" {
+		name = device.DeviceName
+	}
+	if device.DeviceType != "" {
+		deviceType = device.DeviceType
+	}
+
+	if device.DeviceID != "" {
+		token.DeviceID = &device.DeviceID
+	}
+	token.DeviceName = &name
+	token.DeviceType = &deviceType
+}
+
+// parseUserAgent returns a display name such as "Chrome on Windows" and the
+// device type (web, android, ios).
+func parseUserAgent(ua string) (string, string) {
+	if ua == "" {
+		return "Unknown device", "web"
+	}
+
+	os := ""
+	deviceType := "web"
+	switch {
+	case strings.Contains(ua, "Android"):
+		os, deviceType = "Android", "android"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iOS"):
+		os, deviceType = "iOS", "ios"
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(ua, "CrOS"):
+		os = "ChromeOS"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	}
+
+	// Order matters: Edge and Opera also announce Chrome, Chrome announces Safari
+	browser := ""
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os, deviceType
+	case browser != "":
+		return browser, deviceType
+	case os != "":
+		return os + " device", deviceType
+	default:
+		return "Unknown device", deviceType
+	}
+}
+
+// coarseLocation describes where a session connects from without a GeoIP
+// database: private addresses are reported as such, public ones are reduced
+// to their /24 (IPv4) or /48 (IPv6) network.
+func coarseLocation(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "Unknown"
+	}
+
+	if parsed.IsLoopback() || parsed.IsPrivate() || parsed.IsLinkLocalUnicast() {
+		return "Local network"
+	}
+
+	if v4 := parsed.To4(); v4 != nil {
+		network := &net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+		return network.String()
+	}
+
+	network := &net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}
+	return network.String()
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrEmailTaken             = repository.ErrEmailTaken
+	ErrEmailUnchanged         = errors.New("new email is the same as the current one")
+	ErrEmailChangeTooSoon     = errors.New("a code was sent recently, please wait before requesting another")
+	ErrInvalidEmailChangeCode = errors.New("invalid or expired code")
+	ErrEmailChangeSyncFailed  = errors.New("failed to update the profile email, please try again")
+)
+
+const (
+	emailChangeExpiry = 15 * time.Minute
+	// emailChangeMaxAttempts wrong codes invalidate the pending change
+	emailChangeMaxAttempts = 5
+)
+
+// RequestEmailChange sends a confirmation code to the new address and tells
+// the old one. Accounts with a password must confirm it.
+func (s *authService) RequestEmailChange(userID uuid.UUID, req *models.ChangeEmailRequest, ip, userAgent string) (*models.EmailChangeResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return nil, ErrEmailUnchanged
+	}
+	if user.Password != nil && bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.Password)) != nil {
+		s.logAudit(&userID, "email_change_requested", "failed", ip, userAgent, "invalid password")
+		return nil, ErrInvalidPassword
+	}
+
+	if _, err := s.userRepo.FindByEmail(newEmail); err == nil {
+		s.logAudit(&userID, "email_change_requested", "failed", ip, userAgent, "email already registered")
+		return nil, ErrEmailTaken
+	} else if !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, err
+	}
+
+	pending, err := s.emailChangeRepo.FindPendingByUserID(userID)
+	if err != nil && !errors.Is(err, repository.ErrEmailChangeNotFound) {
+		return nil, err
+	}
+	if pending != nil && time.Since(pending.CreatedAt) < loginCodeResendInterval {
+		return nil, ErrEmailChangeTooSoon
+	}
+
+	// Requesting a new code invalidates the previous one
+	code := Generate6DigitCode()
+	change := &models.EmailChangeRequest{
+		UserID:    userID,
+		NewEmail:  newEmail,
+		CodeHash:  s.hashToken(code),
+		ExpiresAt: time.Now().Add(emailChangeExpiry),
+	}
+	if err := s.emailChangeRepo.Replace(change); err != nil {
+		return nil, err
+	}
+
+	if err := s.emailService.SendEmailChangeCodeEmail(userID, newEmail, code, emailChangeExpiry); err != nil {
+		s.logAudit(&userID, "email_change_requested", "failed", ip, userAgent, "failed to send code")
+		return nil, fmt.Errorf("failed to send email change code: %w", err)
+	}
+	if err := s.emailService.SendEmailChangeNoticeEmail(userID, user.Email, newEmail); err != nil {
+		log.Printf("[Auth-Service] ERROR: Failed to queue email change notice to %s: %v", user.Email, err)
+	}
+
+	s.logAudit(&userID, "email_change_requested", "success", ip, userAgent, "")
+	return &models.EmailChangeResponse{
+		NewEmail:  change.NewEmail,
+		ExpiresAt: change.ExpiresAt,
+	}, nil
+}
+
+// ConfirmEmailChange applies the pending change after checking the code. The
+// profile in user-service is updated first so the two never disagree, then
+// every other session is signed out.
+func (s *authService) ConfirmEmailChange(userID uuid.UUID, currentSessionID string, req *models.ConfirmEmailChangeRequest, ip, userAgent string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	change, err := s.emailChangeRepo.FindPendingByUserID(userID)
+	if errors.Is(err, repository.ErrEmailChangeNotFound) {
+		s.logAudit(&userID, "email_changed", "failed", ip, userAgent, "no pending email change")
+		return ErrInvalidEmailChangeCode
+	}
+	if err != nil {
+		return err
+	}
+
+	// Every attempt counts, so concurrent guesses cannot exceed the limit
+	attempts, err := s.emailChangeRepo.IncrementAttempts(change.ID)
+	if err != nil {
+		return err
+	}
+	if attempts > emailChangeMaxAttempts {
+		s.emailChangeRepo.MarkUsed(change.ID)
+		s.logAudit(&userID, "email_changed", "failed", ip, userAgent, "too many attempts")
+		return ErrInvalidEmailChangeCode
+	}
+	if subtle.ConstantTimeCompare([]byte(s.hashToken(req.Code)), []byte(change.CodeHash)) != 1 {
+		if attempts == emailChangeMaxAttempts {
+			s.emailChangeRepo.MarkUsed(change.ID)
+		}
+		s.logAudit(&userID, "email_changed", "failed", ip, userAgent, "invalid code")
+		return ErrInvalidEmailChangeCode
+	}
+
+	// The address may have been registered since the code was sent
+	if _, err := s.userRepo.FindByEmail(change.NewEmail); err == nil {
+		s.emailChangeRepo.MarkUsed(change.ID)
+		s.logAudit(&userID, "email_changed", "failed", ip, userAgent, "email already registered")
+		return ErrEmailTaken
+	} else if !errors.Is(err, repository.ErrUserNotFound) {
+		return err
+	}
+
+	// The code stays valid when user-service is unreachable, so the user can retry
+	if err := s.userServiceClient.UpdateEmail(userID.String(), change.NewEmail); err != nil {
+		log.Printf("[Auth-Service] ERROR: Failed to update profile email of %s: %v", userID, err)
+		s.logAudit(&userID, "email_changed", "failed", ip, userAgent, "failed to update user-service profile")
+		return ErrEmailChangeSyncFailed
+	}
+
+	// Single use: of concurrent requests with the same code only one gets here
+	used, err := s.emailChangeRepo.MarkUsed(change.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidEmailChangeCode
+	}
+
+	if err := s.userRepo.UpdateEmail(userID, change.NewEmail); err != nil {
+		// Put the profile back so it matches auth_db again
+		if rerr := s.userServiceClient.UpdateEmail(userID.String(), user.Email); rerr != nil {
+			log.Printf("[Auth-Service] ERROR: Failed to restore profile email of %s: %v", userID, rerr)
+		}
+		s.logAudit(&userID, "email_changed", "failed", ip, userAgent, err.Error())
+		return err
+	}
+
+	// Codes sent to the old address must not verify the new one
+	if err := s.emailVerificationRepo.DeleteByUserID(userID); err != nil {
+		log.Printf("[Auth-Service] ERROR: Failed to delete verification tokens of %s: %v", userID, err)
+	}
+
+	s.logAudit(&userID, "email_changed", "success", ip, userAgent, "")
+	log.Printf("[Auth-Service] Email of user %s changed", userID)
+
+	// Sign out every other device; without a known current session, all of them
+	if _, err := s.RevokeOtherSessions(userID, currentSessionID, ip, userAgent); err != nil {
+		if !errors.Is(err, ErrCurrentSessionUnknown) {
+			log.Printf("[Auth-Service] ERROR: Failed to revoke sessions of %s: %v", userID, err)
+		} else if err := s.tokenRepo.RevokeAllUserTokens(userID); err != nil {
+			log.Printf("[Auth-Service] ERROR: Failed to revoke sessions of %s: %v", userID, err)
+		} else {
+			s.revokeUserAccessTokens(userID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEmailNotFound = repository.ErrEmailNotFound
+	ErrEmailNotDead  = errors.New("only dead emails can be retried")
+)
+
+// emailOutboxInterval is how often the sender looks for due retries; new
+// emails wake it straight away
+const emailOutboxInterval = 10 * time.Second
+
+// EmailOutboxService sends queued emails in the background
+type EmailOutboxService struct {
+	emails    EmailService
+	lifecycle *lifecycle.Lifecycle // stops the sender on shutdown
+}
+
+func NewEmailOutboxService(emails EmailService, lc *lifecycle.Lifecycle) *EmailOutboxService {
+	return &EmailOutboxService{
+		emails:    emails,
+		lifecycle: lc,
+	}
+}
+
+// Start sends due emails on start, whenever one is queued and every 10
+// seconds until shutdown. Emails are claimed with a lease in the database,
+// so several replicas can run it.
+func (s *EmailOutboxService) Start() {
+	s.lifecycle.Go(s.lifecycle.Context(), "email outbox", func(ctx context.Context) {
+		ticker := time.NewTicker(emailOutboxInterval)
+		defer ticker.Stop()
+
+		s.emails.DeliverDue(ctx)
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.emails.Queued():
+			case <-ctx.Done():
+				return
+			}
+			s.emails.DeliverDue(ctx)
+		}
+	})
+}
+
+// ListEmails pages through the outbox, newest first; filter by dead to see
+// the emails that need attention
+func (s *authService) ListEmails(query *models.AdminEmailOutboxQuery) (*models.EmailOutboxListResponse, error) {
+	page := query.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := query.Limit
+	if limit < 1 {
+		limit = defaultAdminUserPageSize
+	}
+	if limit > maxAdminUserPageSize {
+		limit = maxAdminUserPageSize
+	}
+
+	messages, total, err := s.emailOutboxRepo.List(query.Status, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.EmailOutboxListResponse{
+		Emails: make([]models.EmailOutboxResponse, 0, len(messages)),
+		Pagination: models.PaginationResponse{
+			Page:       page,
+			Limit:      limit,
+			TotalItems: total,
+			TotalPages: (total + limit - 1) / limit,
+		},
+	}
+	for _, msg := range messages {
+		resp.Emails = append(resp.Emails, models.EmailOutboxResponse{
+			ID:            msg.ID.String(),
+			UserID:        msg.UserID.String(),
+			ToEmail:       msg.ToEmail,
+			Template:      msg.Template,
+			Locale:        msg.Locale,
+			Status:        msg.Status,
+			Attempts:      msg.Attempts,
+			LastError:     msg.LastError,
+			NextAttemptAt: msg.NextAttemptAt,
+			CreatedAt:     msg.CreatedAt,
+			SentAt:        msg.SentAt,
+		})
+	}
+	return resp, nil
+}
+
+// RetryEmail queues a dead email again with a fresh set of attempts
+func (s *authService) RetryEmail(adminID, messageID uuid.UUID, ip, userAgent string) error {
+	msg, err := s.emailOutboxRepo.FindByID(messageID)
+	if err != nil {
+		return err
+	}
+
+	retried, err := s.emailOutboxRepo.Retry(messageID)
+	if err != nil {
+		return err
+	}
+	if !retried {
+		return ErrEmailNotDead
+	}
+
+	s.logAdminAudit(adminID, msg.UserID, "admin_email_retried", "success", ip, userAgent, "",
+		map[string]interface{}{"email_id": messageID.String(), "template": msg.Template})
+	return nil
+}
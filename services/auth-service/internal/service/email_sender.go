@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/config"
+	"github.com/google/uuid"
+)
+
+// ErrRecipientRejected means the SMTP server refused the address for good,
+// so retrying cannot help
+var ErrRecipientRejected = errors.New("recipient rejected")
+
+// smtpTimeout bounds a whole SMTP conversation, so a stalled server cannot
+// hold up the sender
+const smtpTimeout = 30 * time.Second
+
+// EmailSender delivers rendered emails
+type EmailSender interface {
+	Send(ctx context.Context, to string, email *RenderedEmail) error
+}
+
+// NewEmailSender returns the sender picked by EMAIL_DELIVERY: smtp, or sink
+// to write every email to EMAIL_SINK_DIR instead of sending it
+func NewEmailSender(cfg *config.Config) (EmailSender, error) {
+	from := mail.Address{Name: cfg.SMTPFromName, Address: cfg.SMTPFromEmail}
+
+	switch cfg.EmailDelivery {
+	case "smtp":
+		return &smtpSender{
+			host:     cfg.SMTPHost,
+			port:     cfg.SMTPPort,
+			username: cfg.SMTPUsername,
+			password: cfg.SMTPPassword,
+			from:     from,
+		}, nil
+	case "sink":
+		if err := os.MkdirAll(cfg.EmailSinkDir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create email sink directory: %w", err)
+		}
+		return &sinkSender{dir: cfg.EmailSinkDir, from: from}, nil
+	default:
+		return nil, fmt.Errorf("unknown EMAIL_DELIVERY %q (want smtp or sink)", cfg.EmailDelivery)
+	}
+}
+
+type smtpSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     mail.Address
+}
+
+func (s *smtpSender) Send(ctx context.Context, to string, email *RenderedEmail) error {
+	msg, err := buildMIMEMessage(s.from, to, email)
+	if err != nil {
+		return err
+	}
+
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.host, s.port))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(s.from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM failed: %w", err)
+	}
+	if err := c.Rcpt(to); err != nil {
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return fmt.Errorf("%w: %v", ErrRecipientRejected, err)
+		}
+		return fmt.Errorf("SMTP RCPT TO failed: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return c.Quit()
+}
+
+// sinkSender writes emails as .eml files for development and tests; open
+// them with any mail client
+type sinkSender struct {
+	dir  string
+	from mail.Address
+}
+
+func (s *sinkSender) Send(ctx context.Context, to string, email *RenderedEmail) error {
+	msg, err := buildMIMEMessage(s.from, to, email)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), uuid.New().String()[:8])
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path, msg, 0o600); err != nil {
+		return fmt.Errorf("failed to write email to sink: %w", err)
+	}
+
+	log.Printf("[Email] Sink: %q to %s written to %s", email.Subject, to, path)
+	return nil
+}
+
+// buildMIMEMessage encodes email as multipart/alternative with the plain-text
+// part first, so clients that cannot show HTML still get the content
+func buildMIMEMessage(from mail.Address, to string, email *RenderedEmail) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain", email.Text},
+		{"text/html", email.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + `; charset="UTF-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+		if err := qp.Close(); err != nil {
+			return nil, fmt.Errorf("failed to build email: %w", err)
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %w", err)
+	}
+
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var msg bytes.Buffer
+	for _, header := range [][2]string{
+		{"From", from.String()},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("UTF-8", email.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", uuid.New().String(), domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, parts.Boundary())},
+	} {
+		fmt.Fprintf(&msg, "%s: %s\r\n", header[0], header[1])
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
@@ -1,140 +1,250 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"math/big"
-	"net/smtp"
+	"strconv"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/google/uuid"
 )
 
-// ---- Brand palette (aligned to the logo) ----
 const (
-	BrandRed      = "#E53935"
-	TextDark      = "#111827"
-	SoftBg        = "#FFF7F5"
-	BorderSoftRed = "#FAD8D6"
+	emailBatchSize = 10
+	// emailLease outlasts a batch of SMTP conversations that all hit smtpTimeout
+	emailLease         = 10 * time.Minute
+	emailRetryBackoff  = 30 * time.Second
+	emailMaxRetryDelay = time.Hour
 )
 
+// EmailService queues emails in the outbox, so a request never waits for
+// SMTP; DeliverDue renders and sends them in the background
 type EmailService interface {
-	SendPasswordResetEmail(toEmail, resetCode string) error
-	SendVerificationEmail(toEmail, verificationCode string) error
+	SendPasswordResetEmail(userID uuid.UUID, toEmail, resetCode string, expiry time.Duration) error
+	SendVerificationEmail(userID uuid.UUID, toEmail, verificationCode string, expiry time.Duration) error
+	SendLoginCodeEmail(userID uuid.UUID, toEmail, loginCode, magicLink string, expiry time.Duration) error
+	SendEmailChangeCodeEmail(userID uuid.UUID, toEmail, code string, expiry time.Duration) error
+	SendEmailChangeNoticeEmail(userID uuid.UUID, toEmail, newEmail string) error
+	SendLoginAlertEmail(userID uuid.UUID, toEmail string, alert LoginAlert) error
+	SendLoginStepUpCodeEmail(userID uuid.UUID, toEmail, code string, alert LoginAlert, expiry time.Duration) error
+
+	// DeliverDue sends a batch of due emails in the recipient's locale.
+	// Failures are retried with exponential backoff; after the last attempt
+	// the email is marked dead.
+	DeliverDue(ctx context.Context)
+	// Queued signals that an email was queued, so the sender need not wait for its next poll
+	Queued() <-chan struct{}
 }
 
 type emailService struct {
-	smtpHost     string
-	smtpPort     string
-	smtpUsername string
-	smtpPassword string
-	fromEmail    string
-	fromName     string
+	outbox      repository.EmailOutboxRepository
+	templates   emailTemplates
+	sender      EmailSender
+	userClient  *client.UserServiceClient // looks up the recipient's locale
+	maxAttempts int
+	queued      chan struct{}
 }
 
-func NewEmailService(host, port, username, password, fromEmail, fromName string) EmailService {
+func NewEmailService(outbox repository.EmailOutboxRepository, sender EmailSender, userClient *client.UserServiceClient, maxAttempts int) (EmailService, error) {
+	templates, err := loadEmailTemplates()
+	if err != nil {
+		return nil, err
+	}
+
 	return &emailService{
-		smtpHost:     host,
-		smtpPort:     port,
-		smtpUsername: username,
-		smtpPassword: password,
-		fromEmail:    fromEmail,
-		fromName:     fromName,
-	}
-}
-
-// ---------- Shared minimal template ----------
-func minimalTemplate(title, headline, introHTML, code, noteHTML string) string {
-	return fmt.Sprintf(`<!DOCTYPE html>
-<html lang="vi">
-<head><meta charset="UTF-8"></head>
-<body style="margin:0;padding:24px;background:%s;font-family:Arial,Helvetica,sans-serif;">
-  <table role="presentation" width="100%%" cellspacing="0" cellpadding="0" style="max-width:600px;margin:0 auto;background:#FFFFFF;border-radius:10px;border:1px solid %s;">
-    <tr>
-      <td style="padding:24px;border-bottom:1px solid %s;">
-        <div style="font-size:22px;line-height:1.2;color:%s;font-weight:700;letter-spacing:-0.3px">
-          <span>IELTS</span><span style="color:%s">Go</span>
-        </div>
-        <div style="margin-top:6px;font-size:14px;color:#6B7280">%s</div>
-      </td>
-    </tr>
-    <tr>
-      <td style="padding:24px 24px 8px 24px;">
-        <h1 style="margin:0 0 12px 0;font-size:18px;color:%s">%s</h1>
-        <div style="font-size:14px;color:#374151;line-height:1.7">%s</div>
-        <div style="margin:20px 0;padding:18px;border:1px solid %s;border-radius:8px;background:%s;text-align:center">
-          <div style="font-family:Consolas,Menlo,monospace;font-size:28px;letter-spacing:6px;font-weight:700;color:%s">%s</div>
-        </div>
-        <div style="font-size:12px;color:#6B7280;line-height:1.8">%s</div>
-      </td>
-    </tr>
-    <tr>
-      <td style="padding:20px 24px 24px 24px;color:#9CA3AF;font-size:12px;border-top:1px solid %s;">
-        © 2025 IELTS<span style="color:%s">Go</span>. All rights reserved.
-      </td>
-    </tr>
-  </table>
-</body>
-</html>`, SoftBg, BorderSoftRed, BorderSoftRed, TextDark, BrandRed, title, TextDark, headline, introHTML, BorderSoftRed, SoftBg, BrandRed, code, noteHTML, BorderSoftRed, BrandRed)
-}
-
-// ---- Password reset (vi) – simple, elegant ----
-func (s *emailService) SendPasswordResetEmail(toEmail, resetCode string) error {
-	subject := "IELTSGo – Mã đặt lại mật khẩu"
-	intro := `Chúng tôi nhận được yêu cầu đặt lại mật khẩu cho tài khoản <strong>IELTSGo</strong> của bạn. 
-Vui lòng nhập mã dưới đây để tiếp tục.`
-	note := `Mã có hiệu lực trong <strong>15 phút</strong>. 
-Không chia sẻ mã này với bất kỳ ai. Nếu bạn không yêu cầu thao tác này, hãy bỏ qua email.`
-	body := minimalTemplate(
-		"Đặt lại mật khẩu",
-		"Mã xác thực",
-		intro,
-		resetCode,
-		note,
-	)
-	return s.sendEmail(toEmail, subject, body)
-}
-
-// ---- Email verification (en) – simple, elegant ----
-func (s *emailService) SendVerificationEmail(toEmail, verificationCode string) error {
-	subject := "IELTSGo – Verify your email"
-	intro := `Thanks for signing up for <strong>IELTSGo</strong>. 
-Please verify your email using the code below.`
-	note := `This code expires in <strong>24 hours</strong>. 
-If you didn’t create this account, you can safely ignore this email.`
-	body := minimalTemplate(
-		"Email verification",
-		"Your verification code",
-		intro,
-		verificationCode,
-		note,
-	)
-	return s.sendEmail(toEmail, subject, body)
-}
-
-// ---- Core send ----
-func (s *emailService) sendEmail(to, subject, body string) error {
-	auth := smtp.PlainAuth("", s.smtpUsername, s.smtpPassword, s.smtpHost)
-	from := fmt.Sprintf("%s <%s>", s.fromName, s.fromEmail)
-
-	headers := map[string]string{
-		"From":         from,
-		"To":           to,
-		"Subject":      subject,
-		"MIME-Version": "1.0",
-		"Content-Type": `text/html; charset="UTF-8"`,
-	}
-
-	msg := ""
-	for k, v := range headers {
-		msg += fmt.Sprintf("%s: %s\r\n", k, v)
-	}
-	msg += "\r\n" + body
-
-	addr := fmt.Sprintf("%s:%s", s.smtpHost, s.smtpPort)
-	if err := smtp.SendMail(addr, auth, s.fromEmail, []string{to}, []byte(msg)); err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+		outbox:      outbox,
+		templates:   templates,
+		sender:      sender,
+		userClient:  userClient,
+		maxAttempts: maxAttempts,
+		queued:      make(chan struct{}, 1),
+	}, nil
+}
+
+func (s *emailService) SendPasswordResetEmail(userID uuid.UUID, toEmail, resetCode string, expiry time.Duration) error {
+	return s.enqueue(userID, toEmail, emailTemplatePasswordReset, map[string]string{
+		"Code":          resetCode,
+		"ExpiryMinutes": strconv.Itoa(int(expiry.Minutes())),
+	})
+}
+
+func (s *emailService) SendVerificationEmail(userID uuid.UUID, toEmail, verificationCode string, expiry time.Duration) error {
+	return s.enqueue(userID, toEmail, emailTemplateEmailVerification, map[string]string{
+		"Code":        verificationCode,
+		"ExpiryHours": strconv.Itoa(int(expiry.Hours())),
+	})
+}
+
+func (s *emailService) SendLoginCodeEmail(userID uuid.UUID, toEmail, loginCode, magicLink string, expiry time.Duration) error {
+	return s.enqueue(userID, toEmail, emailTemplateLoginCode, map[string]string{
+		"Code":          loginCode,
+		"Link":          magicLink,
+		"ExpiryMinutes": strconv.Itoa(int(expiry.Minutes())),
+	})
+}
+
+func (s *emailService) SendEmailChangeCodeEmail(userID uuid.UUID, toEmail, code string, expiry time.Duration) error {
+	return s.enqueue(userID, toEmail, emailTemplateEmailChangeCode, map[string]string{
+		"Code":          code,
+		"ExpiryMinutes": strconv.Itoa(int(expiry.Minutes())),
+	})
+}
+
+func (s *emailService) SendEmailChangeNoticeEmail(userID uuid.UUID, toEmail, newEmail string) error {
+	return s.enqueue(userID, toEmail, emailTemplateEmailChangeNotice, map[string]string{
+		"NewEmail": newEmail,
+	})
+}
+
+func (s *emailService) SendLoginAlertEmail(userID uuid.UUID, toEmail string, alert LoginAlert) error {
+	return s.enqueue(userID, toEmail, emailTemplateLoginAlert, map[string]string{
+		"Device":     alert.Device,
+		"Location":   alert.Location,
+		"IP":         alert.IP,
+		"Time":       alert.Time.UTC().Format("2006-01-02 15:04 UTC"),
+		"Suspicious": strconv.FormatBool(alert.Suspicious),
+	})
+}
+
+func (s *emailService) SendLoginStepUpCodeEmail(userID uuid.UUID, toEmail, code string, alert LoginAlert, expiry time.Duration) error {
+	return s.enqueue(userID, toEmail, emailTemplateLoginStepUpCode, map[string]string{
+		"Code":          code,
+		"Device":        alert.Device,
+		"Location":      alert.Location,
+		"ExpiryMinutes": strconv.Itoa(int(expiry.Minutes())),
+	})
+}
+
+func (s *emailService) enqueue(userID uuid.UUID, toEmail, template string, data map[string]string) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode email data: %w", err)
+	}
+	fields := string(raw)
+
+	err = s.outbox.Enqueue(&models.EmailOutboxMessage{
+		UserID:   userID,
+		ToEmail:  toEmail,
+		Template: template,
+		Data:     &fields,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Wake the sender; a signal already pending covers this email too
+	select {
+	case s.queued <- struct{}{}:
+	default:
 	}
 	return nil
 }
 
+func (s *emailService) Queued() <-chan struct{} {
+	return s.queued
+}
+
+func (s *emailService) DeliverDue(ctx context.Context) {
+	messages, err := s.outbox.ClaimDue(emailBatchSize, emailLease)
+	if err != nil {
+		log.Printf("[EmailOutbox] ERROR: %v", err)
+		return
+	}
+
+	for i := range messages {
+		if ctx.Err() != nil {
+			// Claimed but not sent; the lease runs out and another run picks them up
+			return
+		}
+		s.deliver(ctx, &messages[i])
+	}
+}
+
+func (s *emailService) deliver(ctx context.Context, msg *models.EmailOutboxMessage) {
+	locale := s.recipientLocale(ctx, msg)
+
+	data := map[string]string{}
+	if msg.Data != nil {
+		if err := json.Unmarshal([]byte(*msg.Data), &data); err != nil {
+			s.fail(msg, locale, fmt.Errorf("invalid email data: %w", err), true)
+			return
+		}
+	}
+
+	// Template errors will not fix themselves, so they skip the retries
+	email, err := s.templates.render(msg.Template, locale, data)
+	if err != nil {
+		s.fail(msg, locale, err, true)
+		return
+	}
+
+	if err := s.sender.Send(ctx, msg.ToEmail, email); err != nil {
+		s.fail(msg, locale, err, errors.Is(err, ErrRecipientRejected))
+		return
+	}
+
+	if err := s.outbox.MarkSent(msg.ID, locale); err != nil {
+		log.Printf("[EmailOutbox] ERROR: %v", err)
+		return
+	}
+	log.Printf("[EmailOutbox] Sent %s email %s to %s", msg.Template, msg.ID, msg.ToEmail)
+}
+
+// recipientLocale is the locale stored on a retry, else the one the user
+// picked in user-service; empty when that is unknown, which renders the default
+func (s *emailService) recipientLocale(ctx context.Context, msg *models.EmailOutboxMessage) string {
+	if msg.Locale != nil {
+		return *msg.Locale
+	}
+
+	locale, err := s.userClient.WithContext(ctx).GetLocale(msg.UserID.String())
+	if err != nil {
+		log.Printf("[EmailOutbox] WARNING: Failed to get locale of user %s, using %s: %v", msg.UserID, defaultEmailLocale, err)
+		return ""
+	}
+	return locale
+}
+
+func (s *emailService) fail(msg *models.EmailOutboxMessage, locale string, sendErr error, permanent bool) {
+	attempts := msg.Attempts + 1
+	maxAttempts := s.maxAttempts
+	if permanent {
+		maxAttempts = attempts
+	}
+
+	dead, err := s.outbox.Fail(msg.ID, locale, sendErr.Error(), emailRetryDelay(attempts), maxAttempts)
+	if err != nil {
+		log.Printf("[EmailOutbox] ERROR: %v", err)
+		return
+	}
+	if dead {
+		log.Printf("[EmailOutbox] ERROR: Gave up on %s email %s to %s after %d attempts: %v", msg.Template, msg.ID, msg.ToEmail, attempts, sendErr)
+		return
+	}
+	log.Printf("[EmailOutbox] WARNING: %s email %s to %s failed (attempt %d), retrying in %s: %v",
+		msg.Template, msg.ID, msg.ToEmail, attempts, emailRetryDelay(attempts), sendErr)
+}
+
+// emailRetryDelay doubles from 30s after each failed attempt, up to an hour
+func emailRetryDelay(attempts int) time.Duration {
+	delay := emailRetryBackoff
+	for i := 1; i < attempts && delay < emailMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > emailMaxRetryDelay {
+		return emailMaxRetryDelay
+	}
+	return delay
+}
+
 // ---- Secure 6-digit code ----
 func Generate6DigitCode() string {
 	// crypto/rand in [0, 999999]
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Each email has an HTML and a plain-text template per locale:
+// <name>.<locale>.html fills the blocks of layout.html, and
+// <name>.<locale>.txt defines "subject" followed by the text body.
+//
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+// Email templates
+const (
+	emailTemplatePasswordReset     = "password_reset"
+	emailTemplateEmailVerification = "email_verification"
+	emailTemplateLoginCode         = "login_code"
+	emailTemplateEmailChangeCode   = "email_change_code"
+	emailTemplateEmailChangeNotice = "email_change_notice"
+	emailTemplateLoginAlert        = "login_alert"
+	emailTemplateLoginStepUpCode   = "login_step_up_code"
+)
+
+var emailTemplateNames = []string{
+	emailTemplatePasswordReset,
+	emailTemplateEmailVerification,
+	emailTemplateLoginCode,
+	emailTemplateEmailChangeCode,
+	emailTemplateEmailChangeNotice,
+	emailTemplateLoginAlert,
+	emailTemplateLoginStepUpCode,
+}
+
+// Locales every template is written in; users without a supported one get the default
+var emailLocales = []string{"vi", "en"}
+
+const defaultEmailLocale = "vi"
+
+// RenderedEmail is an email ready to send
+type RenderedEmail struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// emailTemplates holds every template variant, keyed by "<name>.<locale>"
+type emailTemplates map[string]emailTemplate
+
+// loadEmailTemplates parses the embedded templates and fails when a variant
+// is missing, so a broken template stops the service at startup rather than
+// dead-lettering emails
+func loadEmailTemplates() (emailTemplates, error) {
+	layout, err := htmltemplate.New("layout.html").Option("missingkey=error").ParseFS(emailTemplateFS, "templates/email/layout.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email layout: %w", err)
+	}
+
+	templates := make(emailTemplates)
+	for _, name := range emailTemplateNames {
+		for _, locale := range emailLocales {
+			variant := name + "." + locale
+
+			html, err := htmltemplate.Must(layout.Clone()).ParseFS(emailTemplateFS, "templates/email/"+variant+".html")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse email template %s.html: %w", variant, err)
+			}
+			for _, block := range []string{"title", "headline", "intro", "code", "note"} {
+				if html.Lookup(block) == nil {
+					return nil, fmt.Errorf("email template %s.html does not define %q", variant, block)
+				}
+			}
+
+			text, err := texttemplate.New(variant+".txt").Option("missingkey=error").ParseFS(emailTemplateFS, "templates/email/"+variant+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse email template %s.txt: %w", variant, err)
+			}
+			if text.Lookup("subject") == nil {
+				return nil, fmt.Errorf("email template %s.txt does not define \"subject\"", variant)
+			}
+
+			templates[variant] = emailTemplate{html: html, text: text.Lookup(variant + ".txt")}
+		}
+	}
+	return templates, nil
+}
+
+// render builds an email in locale, or in the default locale when the
+// template has no such variant
+func (t emailTemplates) render(name, locale string, data map[string]string) (*RenderedEmail, error) {
+	tmpl, ok := t[name+"."+locale]
+	if !ok {
+		locale = defaultEmailLocale
+		if tmpl, ok = t[name+"."+locale]; !ok {
+			return nil, fmt.Errorf("unknown email template %q", name)
+		}
+	}
+
+	fields := make(map[string]string, len(data)+1)
+	for k, v := range data {
+		fields[k] = v
+	}
+	fields["Locale"] = locale
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", fields); err != nil {
+		return nil, fmt.Errorf("failed to render subject of %s.%s: %w", name, locale, err)
+	}
+	if err := tmpl.text.Execute(&text, fields); err != nil {
+		return nil, fmt.Errorf("failed to render text of %s.%s: %w", name, locale, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "layout", fields); err != nil {
+		return nil, fmt.Errorf("failed to render HTML of %s.%s: %w", name, locale, err)
+	}
+
+	return &RenderedEmail{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
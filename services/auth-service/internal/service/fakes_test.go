@@ -0,0 +1,603 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/config"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/geoip"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The fakes embed the repository interfaces so each only implements what the
+// tests reach; anything else panics and shows up as a failing test.
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	mu    sync.Mutex
+	users map[uuid.UUID]*models.User
+}
+
+func (r *fakeUserRepo) add(user *models.User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *user
+	r.users[user.ID] = &copied
+}
+
+func (r *fakeUserRepo) get(id uuid.UUID) *models.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *r.users[id]
+	return &copied
+}
+
+func (r *fakeUserRepo) Create(user *models.User) error {
+	user.ID = uuid.New()
+	user.IsActive = true
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	r.add(user)
+	return nil
+}
+
+func (r *fakeUserRepo) FindByID(id uuid.UUID) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) FindByEmail(email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) Update(user *models.User) error {
+	r.add(user)
+	return nil
+}
+
+func (r *fakeUserRepo) update(id uuid.UUID, fn func(user *models.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	fn(user)
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateLoginInfo(id uuid.UUID, ip string) error {
+	return r.update(id, func(user *models.User) {
+		now := time.Now()
+		user.LastLoginAt = &now
+		user.LastLoginIP = &ip
+	})
+}
+
+func (r *fakeUserRepo) IncrementFailedAttempts(id uuid.UUID) error {
+	return r.update(id, func(user *models.User) { user.FailedLoginAttempts++ })
+}
+
+func (r *fakeUserRepo) ResetFailedAttempts(id uuid.UUID) error {
+	return r.update(id, func(user *models.User) {
+		user.FailedLoginAttempts = 0
+		user.LockedUntil = nil
+	})
+}
+
+func (r *fakeUserRepo) LockAccount(id uuid.UUID, duration time.Duration) error {
+	return r.update(id, func(user *models.User) {
+		until := time.Now().Add(duration)
+		user.LockedUntil = &until
+	})
+}
+
+func (r *fakeUserRepo) IsAccountLocked(id uuid.UUID) (bool, error) {
+	user, err := r.FindByID(id)
+	if err != nil {
+		return false, err
+	}
+	return user.LockedUntil != nil && user.LockedUntil.After(time.Now()), nil
+}
+
+func (r *fakeUserRepo) SetActive(id uuid.UUID, active bool) error {
+	return r.update(id, func(user *models.User) { user.IsActive = active })
+}
+
+func (r *fakeUserRepo) SetPasswordResetRequired(id uuid.UUID, required bool) error {
+	return r.update(id, func(user *models.User) { user.PasswordResetRequired = required })
+}
+
+type fakeRoleRepo struct {
+	repository.RoleRepository
+}
+
+func (fakeRoleRepo) FindByUserID(uuid.UUID) ([]models.Role, error) {
+	return []models.Role{{ID: 1, Name: "student"}}, nil
+}
+
+func (fakeRoleRepo) FindByName(name string) (*models.Role, error) {
+	return &models.Role{ID: 1, Name: name}, nil
+}
+
+func (fakeRoleRepo) AssignRoleToUser(uuid.UUID, int, *uuid.UUID) error { return nil }
+
+func (fakeRoleRepo) FindPermissionsByUserID(uuid.UUID) ([]string, error) {
+	return []string{"course:view"}, nil
+}
+
+type fakeTokenRepo struct {
+	repository.TokenRepository
+	mu     sync.Mutex
+	tokens map[string]*models.RefreshToken // by hash
+
+	// beforeRotate runs inside RotateRefreshToken, to simulate a request
+	// that rotates the same token concurrently
+	beforeRotate func()
+}
+
+func (r *fakeTokenRepo) CreateRefreshToken(token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.insert(token)
+	return nil
+}
+
+func (r *fakeTokenRepo) insert(token *models.RefreshToken) {
+	token.ID = uuid.New()
+	token.CreatedAt = time.Now()
+	token.LastUsedAt = token.CreatedAt
+	if token.FamilyID == uuid.Nil {
+		token.FamilyID = token.ID
+	}
+	copied := *token
+	r.tokens[token.TokenHash] = &copied
+}
+
+func (r *fakeTokenRepo) FindRefreshToken(hash string) (*models.RefreshToken, error) {
+	token, err := r.FindRefreshTokenByHash(hash)
+	if err != nil || token.RevokedAt != nil {
+		return nil, fmt.Errorf("token not found")
+	}
+	return token, nil
+}
+
+func (r *fakeTokenRepo) FindRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[hash]
+	if !ok {
+		return nil, fmt.Errorf("token not found")
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *fakeTokenRepo) RotateRefreshToken(current, next *models.RefreshToken) error {
+	if r.beforeRotate != nil {
+		hook := r.beforeRotate
+		r.beforeRotate = nil
+		hook()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := r.tokens[current.TokenHash]
+	if stored == nil || stored.RevokedAt != nil {
+		return repository.ErrTokenAlreadyRotated
+	}
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	r.insert(next)
+
+	now := time.Now()
+	reason := repository.RevokeReasonRotated
+	stored.RevokedAt = &now
+	stored.RevokedReason = &reason
+	stored.ReplacedBy = &next.ID
+	return nil
+}
+
+func (r *fakeTokenRepo) RevokeTokenFamily(familyID uuid.UUID, reason string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var revoked int64
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+			token.RevokedReason = &reason
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (r *fakeTokenRepo) RevokeAllUserTokens(userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			now := time.Now()
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// active returns the unrevoked tokens of a family
+func (r *fakeTokenRepo) active(familyID uuid.UUID) []*models.RefreshToken {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []*models.RefreshToken
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+type fakeIdentityRepo struct {
+	repository.IdentityRepository
+	users      *fakeUserRepo
+	mu         sync.Mutex
+	identities []models.UserIdentity
+}
+
+func (r *fakeIdentityRepo) FindUser(provider, subject string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return r.users.FindByID(identity.UserID)
+		}
+	}
+	return nil, repository.ErrIdentityNotFound
+}
+
+func (r *fakeIdentityRepo) ListByUser(userID uuid.UUID) ([]models.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identities := []models.UserIdentity{}
+	for _, identity := range r.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *fakeIdentityRepo) Link(identity *models.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.identities {
+		if existing.Provider == identity.Provider && existing.Subject == identity.Subject {
+			return repository.ErrIdentityLinkedElsewhere
+		}
+		if existing.Provider == identity.Provider && existing.UserID == identity.UserID {
+			return repository.ErrProviderAlreadyLinked
+		}
+	}
+	identity.ID = uuid.New()
+	identity.CreatedAt = time.Now()
+	r.identities = append(r.identities, *identity)
+	return nil
+}
+
+func (r *fakeIdentityRepo) Unlink(userID uuid.UUID, provider string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, identity := range r.identities {
+		if identity.UserID == userID && identity.Provider == provider {
+			r.identities = append(r.identities[:i], r.identities[i+1:]...)
+			return nil
+		}
+	}
+	return repository.ErrIdentityNotFound
+}
+
+func (r *fakeIdentityRepo) MarkUsed(string, string) error { return nil }
+
+func (r *fakeIdentityRepo) CreateUser(email string, emailVerified bool, identity *models.UserIdentity) (*models.User, error) {
+	user := &models.User{Email: email, IsVerified: emailVerified}
+	if err := r.users.Create(user); err != nil {
+		return nil, err
+	}
+	identity.UserID = user.ID
+	if err := r.Link(identity); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+type fakeMFARepo struct {
+	repository.MFARepository
+	mu      sync.Mutex
+	enabled map[uuid.UUID]*models.UserMFA
+}
+
+func (r *fakeMFARepo) FindByUserID(userID uuid.UUID) (*models.UserMFA, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mfa, ok := r.enabled[userID]
+	if !ok {
+		return nil, repository.ErrMFANotEnrolled
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (r *fakeMFARepo) MarkStepUsed(userID uuid.UUID, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mfa := r.enabled[userID]
+	if mfa.LastUsedStep != nil && step <= *mfa.LastUsedStep {
+		return false, nil
+	}
+	mfa.LastUsedStep = &step
+	return true, nil
+}
+
+func (r *fakeMFARepo) UseRecoveryCode(uuid.UUID, string) (bool, error) { return false, nil }
+
+type fakeAuditRepo struct {
+	repository.AuditLogRepository
+	mu      sync.Mutex
+	entries []models.AuditLog
+}
+
+func (r *fakeAuditRepo) Create(entry *models.AuditLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+// events returns the audit entries of one event type
+func (r *fakeAuditRepo) events(eventType string) []models.AuditLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []models.AuditLog
+	for _, entry := range r.entries {
+		if entry.EventType == eventType {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+type fakeLoginHistoryRepo struct {
+	repository.LoginHistoryRepository
+	mu      sync.Mutex
+	entries []models.LoginHistory
+}
+
+func (r *fakeLoginHistoryRepo) Create(entry *models.LoginHistory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.ID = int64(len(r.entries) + 1)
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func (r *fakeLoginHistoryRepo) ListSince(userID uuid.UUID, since time.Time, limit int) ([]models.LoginHistory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var entries []models.LoginHistory
+	for i := len(r.entries) - 1; i >= 0 && len(entries) < limit; i-- {
+		if r.entries[i].UserID == userID && !r.entries[i].CreatedAt.Before(since) {
+			entries = append(entries, r.entries[i])
+		}
+	}
+	return entries, nil
+}
+
+type fakePasswordHistoryRepo struct {
+	repository.PasswordHistoryRepository
+}
+
+func (fakePasswordHistoryRepo) Add(uuid.UUID, string, int) error { return nil }
+
+func (fakePasswordHistoryRepo) ListRecent(uuid.UUID, int) ([]string, error) { return nil, nil }
+
+// fakeEmailService records the emails the service asks to send
+type fakeEmailService struct {
+	EmailService
+	mu         sync.Mutex
+	sent       []string // "<kind> <to>"
+	stepUpCode string   // the last login step-up code
+}
+
+func (e *fakeEmailService) record(kind, to string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sent = append(e.sent, kind+" "+to)
+	return nil
+}
+
+func (e *fakeEmailService) SendLoginAlertEmail(_ uuid.UUID, to string, _ LoginAlert) error {
+	return e.record("login_alert", to)
+}
+
+func (e *fakeEmailService) SendLoginStepUpCodeEmail(_ uuid.UUID, to, code string, _ LoginAlert, _ time.Duration) error {
+	e.mu.Lock()
+	e.stepUpCode = code
+	e.mu.Unlock()
+	return e.record("login_step_up_code", to)
+}
+
+// notificationSink stands in for notification-service
+type notificationSink struct {
+	mu   sync.Mutex
+	sent []client.SendNotificationRequest
+}
+
+func (n *notificationSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req client.SendNotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n.mu.Lock()
+	n.sent = append(n.sent, req)
+	n.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (n *notificationSink) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.sent)
+}
+
+// testEnv is an authService wired to in-memory fakes and a miniredis
+type testEnv struct {
+	svc           *authService
+	users         *fakeUserRepo
+	tokens        *fakeTokenRepo
+	mfa           *fakeMFARepo
+	audit         *fakeAuditRepo
+	logins        *fakeLoginHistoryRepo
+	emails        *fakeEmailService
+	notifications *notificationSink
+	redis         *miniredis.Miniredis
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	sink := &notificationSink{}
+	notificationServer := httptest.NewServer(sink)
+	t.Cleanup(notificationServer.Close)
+
+	cfg := &config.Config{
+		AppEnv:                     "development",
+		JWTExpiry:                  "15m",
+		RefreshTokenExpiry:         "168h",
+		RefreshTokenReuseGrace:     5 * time.Second,
+		BcryptRounds:               bcrypt.MinCost,
+		MaxLoginAttempts:           5,
+		AccountLockDuration:        30,
+		PasswordMinLength:          8,
+		PasswordMinCharClasses:     2,
+		LoginHistoryWindow:         90 * 24 * time.Hour,
+		LoginFailureBurstThreshold: 3,
+		LoginFailureBurstWindow:    15 * time.Minute,
+		MFAIssuer:                  "IELTS Platform",
+		MFAEncryptionKey:           "test-mfa-encryption-key",
+		MFAChallengeExpiry:         "5m",
+		LoginCodeExpiry:            "10m",
+	}
+
+	signingKeys, err := LoadSigningKeys(cfg)
+	if err != nil {
+		t.Fatalf("signing keys: %v", err)
+	}
+	cipher, err := newSecretCipher(cfg.MFAEncryptionKey)
+	if err != nil {
+		t.Fatalf("mfa cipher: %v", err)
+	}
+	policy, err := newPasswordPolicy(cfg)
+	if err != nil {
+		t.Fatalf("password policy: %v", err)
+	}
+
+	env := &testEnv{
+		users:         &fakeUserRepo{users: make(map[uuid.UUID]*models.User)},
+		tokens:        &fakeTokenRepo{tokens: make(map[string]*models.RefreshToken)},
+		mfa:           &fakeMFARepo{enabled: make(map[uuid.UUID]*models.UserMFA)},
+		audit:         &fakeAuditRepo{},
+		logins:        &fakeLoginHistoryRepo{},
+		emails:        &fakeEmailService{},
+		notifications: sink,
+		redis:         mr,
+	}
+	env.svc = &authService{
+		userRepo:            env.users,
+		roleRepo:            fakeRoleRepo{},
+		tokenRepo:           env.tokens,
+		mfaRepo:             env.mfa,
+		auditRepo:           env.audit,
+		passwordHistoryRepo: fakePasswordHistoryRepo{},
+		loginHistoryRepo:    env.logins,
+		emailService:        env.emails,
+		redisClient:         redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		config:              cfg,
+		signingKeys:         signingKeys,
+		secretCipher:        cipher,
+		passwordPolicy:      policy,
+		geoIP:               geoip.None,
+		notificationClient:  client.NewNotificationServiceClient(notificationServer.URL, "test-key"),
+	}
+	return env
+}
+
+const testPassword = "Correct-Horse-7"
+
+// addUser stores an active, verified user whose password is testPassword
+func (e *testEnv) addUser(t *testing.T, email string) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := string(hash)
+	user := &models.User{
+		ID:         uuid.New(),
+		Email:      email,
+		Password:   &password,
+		IsActive:   true,
+		IsVerified: true,
+	}
+	e.users.add(user)
+	return user
+}
+
+// enableMFA turns on an authenticator for user and returns its secret
+func (e *testEnv) enableMFA(t *testing.T, user *models.User) string {
+	t.Helper()
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := e.svc.secretCipher.encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	e.mfa.mu.Lock()
+	e.mfa.enabled[user.ID] = &models.UserMFA{UserID: user.ID, SecretEncrypted: encrypted, EnabledAt: &now}
+	e.mfa.mu.Unlock()
+	return secret
+}
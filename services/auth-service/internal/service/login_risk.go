@@ -0,0 +1,516 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/geoip"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Login risk signals, listed in the login_risk audit event
+const (
+	LoginSignalNewDevice        = "new_device"
+	LoginSignalNewNetwork       = "new_network"
+	LoginSignalNewCountry       = "new_country"
+	LoginSignalImpossibleTravel = "impossible_travel"
+	LoginSignalFailureBurst     = "ip_failure_burst" // the IP failed logins for many accounts
+)
+
+// Login risk levels
+const (
+	LoginRiskLow    = "low"
+	LoginRiskMedium = "medium"
+	LoginRiskHigh   = "high"
+)
+
+var loginSignalWeights = map[string]int{
+	LoginSignalNewDevice:        20,
+	LoginSignalNewNetwork:       10,
+	LoginSignalNewCountry:       40,
+	LoginSignalImpossibleTravel: 70,
+	LoginSignalFailureBurst:     70,
+}
+
+const (
+	loginRiskMediumScore = 30
+	loginRiskHighScore   = 60
+
+	// loginHistoryLimit bounds how many earlier logins a login is compared with
+	loginHistoryLimit = 200
+
+	// Moving faster than an airliner between two logins is impossible travel.
+	// GeoIP is only accurate to a city, so shorter distances never count.
+	maxTravelSpeedKmh   = 1000
+	minTravelDistanceKm = 500
+	earthRadiusKm       = 6371
+
+	loginFailureKeyPrefix = "login_failures:"
+)
+
+var ErrLoginStepUpInvalid = errors.New("sign-in verification expired, please sign in again")
+
+const (
+	loginStepUpKeyPrefix   = "login_step_up:"
+	loginStepUpExpiry      = 10 * time.Minute
+	loginStepUpMaxAttempts = 5
+)
+
+// LoginAlert describes a login in alert and verification emails
+type LoginAlert struct {
+	Device     string
+	Location   string
+	IP         string
+	Time       time.Time
+	Suspicious bool
+}
+
+// loginRisk describes a login and how it differs from the user's earlier ones
+type loginRisk struct {
+	IP          string
+	Device      string // display name, e.g. "Chrome on Windows"
+	Fingerprint string
+	Network     string // /24 or /48, or "Local network"
+	Location    geoip.Location
+	Located     bool
+	Signals     []string
+	Score       int
+}
+
+func (r *loginRisk) add(signal string) {
+	r.Signals = append(r.Signals, signal)
+	r.Score += loginSignalWeights[signal]
+}
+
+func (r *loginRisk) has(signal string) bool {
+	for _, s := range r.Signals {
+		if s == signal {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *loginRisk) Level() string {
+	switch {
+	case r.Score >= loginRiskHighScore:
+		return LoginRiskHigh
+	case r.Score >= loginRiskMediumScore:
+		return LoginRiskMedium
+	default:
+		return LoginRiskLow
+	}
+}
+
+func (r *loginRisk) alert() LoginAlert {
+	location := r.Network
+	if r.Located {
+		location = r.Location.Country + " (" + r.Network + ")"
+	}
+	return LoginAlert{
+		Device:     r.Device,
+		Location:   location,
+		IP:         r.IP,
+		Time:       time.Now(),
+		Suspicious: r.Level() != LoginRiskLow,
+	}
+}
+
+// describeLogin fingerprints the device and locates the IP. The client's
+// device ID identifies a device best; without one the browser and OS stand
+// in, which survive browser updates unlike the full User-Agent.
+func (s *authService) describeLogin(ip, userAgent string, device models.DeviceInfo) *loginRisk {
+	name, deviceType := parseUserAgent(userAgent)
+	fingerprint := s.hashToken("ua:" + name + "|" + deviceType)
+	if device.DeviceID != "" {
+		fingerprint = s.hashToken("device:" + device.DeviceID)
+	}
+	if device.DeviceName != "" {
+		name = device.DeviceName
+	}
+
+	risk := &loginRisk{
+		IP:          ip,
+		Device:      name,
+		Fingerprint: fingerprint,
+		Network:     coarseLocation(ip),
+	}
+	if parsed := net.ParseIP(ip); parsed != nil {
+		risk.Location, risk.Located = s.geoIP.Lookup(parsed)
+	}
+	return risk
+}
+
+// assessLogin compares a login with the user's logins in the history window.
+// The first login has nothing to compare with, so only the IP is judged.
+func (s *authService) assessLogin(userID uuid.UUID, ip, userAgent string, device models.DeviceInfo) *loginRisk {
+	risk := s.describeLogin(ip, userAgent, device)
+
+	if s.isFailureBurstIP(ip) {
+		risk.add(LoginSignalFailureBurst)
+	}
+
+	history, err := s.loginHistoryRepo.ListSince(userID, time.Now().Add(-s.config.LoginHistoryWindow), loginHistoryLimit)
+	if err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to load login history for user %s: %v", userID, err)
+		return risk
+	}
+	if len(history) == 0 {
+		return risk
+	}
+
+	var knownDevice, knownNetwork, knownCountry, countryBaseline bool
+	for _, entry := range history {
+		knownDevice = knownDevice || entry.DeviceFingerprint == risk.Fingerprint
+		knownNetwork = knownNetwork || stringValue(entry.IPNetwork) == risk.Network
+		if entry.CountryCode != nil {
+			countryBaseline = true
+			knownCountry = knownCountry || *entry.CountryCode == risk.Location.Country
+		}
+	}
+
+	if !knownDevice {
+		risk.add(LoginSignalNewDevice)
+	}
+	// Private and unparsable addresses say nothing about where the user is
+	if !knownNetwork && risk.Network != "Local network" && risk.Network != "Unknown" {
+		risk.add(LoginSignalNewNetwork)
+	}
+	if risk.Located && countryBaseline && !knownCountry {
+		risk.add(LoginSignalNewCountry)
+	}
+
+	// Compare with the latest login that has coordinates
+	if risk.Located && risk.Location.HasCoordinates {
+		for _, entry := range history {
+			if entry.Latitude == nil || entry.Longitude == nil {
+				continue
+			}
+			if impossibleTravel(*entry.Latitude, *entry.Longitude, risk.Location.Latitude, risk.Location.Longitude, time.Since(entry.CreatedAt)) {
+				risk.add(LoginSignalImpossibleTravel)
+			}
+			break
+		}
+	}
+
+	return risk
+}
+
+// impossibleTravel reports whether getting from one point to the other in
+// elapsed needs more than maxTravelSpeedKmh
+func impossibleTravel(lat1, lon1, lat2, lon2 float64, elapsed time.Duration) bool {
+	distance := distanceKm(lat1, lon1, lat2, lon2)
+	if distance < minTravelDistanceKm {
+		return false
+	}
+	hours := math.Max(elapsed.Hours(), 1.0/60)
+	return distance/hours > maxTravelSpeedKmh
+}
+
+// distanceKm is the great-circle distance between two points
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// recordLogin remembers a completed login so later ones can be compared with it
+func (s *authService) recordLogin(userID uuid.UUID, risk *loginRisk) {
+	entry := &models.LoginHistory{
+		UserID:            userID,
+		DeviceFingerprint: risk.Fingerprint,
+		IPNetwork:         &risk.Network,
+	}
+	if risk.IP != "" {
+		entry.IPAddress = &risk.IP
+	}
+	if risk.Located {
+		entry.CountryCode = &risk.Location.Country
+		if risk.Location.HasCoordinates {
+			entry.Latitude = &risk.Location.Latitude
+			entry.Longitude = &risk.Location.Longitude
+		}
+	}
+
+	if err := s.loginHistoryRepo.Create(entry); err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to record login history for user %s: %v", userID, err)
+	}
+}
+
+// TrackLogin checks, alerts on and records a login completed without a
+// password, where a step-up code would add nothing (OAuth, passwordless)
+func (s *authService) TrackLogin(user *models.User, ip, userAgent string, device models.DeviceInfo) {
+	risk := s.assessLogin(user.ID, ip, userAgent, device)
+	s.alertLogin(user, risk, ip, userAgent)
+	s.recordLogin(user.ID, risk)
+}
+
+// alertLogin audits a login that differs from the user's usual ones and tells
+// the user, in the app and by email, about new devices and suspicious logins.
+// A new network alone is too common (mobile data, travel) to alert on.
+func (s *authService) alertLogin(user *models.User, risk *loginRisk, ip, userAgent string) {
+	if len(risk.Signals) == 0 {
+		return
+	}
+
+	level := risk.Level()
+	log.Printf("[Auth-Service] Login risk %s (%d) for user %s: %s", level, risk.Score, user.ID, strings.Join(risk.Signals, ", "))
+	s.logAuditDetails(&user.ID, "login_risk", "success", ip, userAgent, "", map[string]interface{}{
+		"level":   level,
+		"score":   risk.Score,
+		"signals": risk.Signals,
+		"device":  risk.Device,
+		"country": risk.Location.Country,
+	})
+
+	alert := risk.alert()
+	if !alert.Suspicious && !risk.has(LoginSignalNewDevice) {
+		return
+	}
+
+	notification := client.SendNotificationRequest{
+		UserID:   user.ID.String(),
+		Title:    "New sign-in to your account",
+		Message:  fmt.Sprintf("Your account was signed in to from %s (%s). If this wasn't you, change your password.", alert.Device, alert.Location),
+		Type:     "system",
+		Category: "warning",
+		Priority: "normal",
+	}
+	if alert.Suspicious {
+		notification.Title = "Unusual sign-in to your account"
+		notification.Message = fmt.Sprintf("A sign-in from %s (%s) doesn't match how you usually sign in. If this wasn't you, change your password and sign out of your other devices.", alert.Device, alert.Location)
+		notification.Category = "alert"
+		notification.Priority = "high"
+	}
+	if err := s.notificationClient.SendNotification(notification); err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to send login alert notification to user %s: %v", user.ID, err)
+	}
+
+	if err := s.emailService.SendLoginAlertEmail(user.ID, user.Email, alert); err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to queue login alert email for user %s: %v", user.ID, err)
+	}
+}
+
+// recordLoginFailure remembers which accounts failed to sign in from an IP.
+// Many different accounts in a short window is credential stuffing or
+// password spraying, and a login that then succeeds from the IP is suspect.
+func (s *authService) recordLoginFailure(ip, email, userAgent string) {
+	if ip == "" {
+		return
+	}
+
+	ctx := context.Background()
+	key := loginFailureKeyPrefix + ip
+	now := time.Now()
+	window := s.config.LoginFailureBurstWindow
+
+	pipe := s.redisClient.TxPipeline()
+	added := pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixNano()), Member: s.hashToken(strings.ToLower(email))})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-window).UnixNano(), 10))
+	accounts := pipe.ZCard(ctx, key)
+	pipe.Expire(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to record login failure from %s: %v", ip, err)
+		return
+	}
+
+	// Audit once, when a new account brings the IP to the threshold
+	if added.Val() == 1 && accounts.Val() == int64(s.config.LoginFailureBurstThreshold) {
+		log.Printf("[Auth-Service] SECURITY: Failed logins for %d accounts from %s within %s", accounts.Val(), ip, window)
+		s.logAuditDetails(nil, "login_failure_burst", "failed", ip, userAgent, "", map[string]interface{}{
+			"accounts": accounts.Val(),
+			"window":   window.String(),
+		})
+	}
+}
+
+func (s *authService) isFailureBurstIP(ip string) bool {
+	if ip == "" {
+		return false
+	}
+
+	since := time.Now().Add(-s.config.LoginFailureBurstWindow).UnixNano()
+	accounts, err := s.redisClient.ZCount(context.Background(), loginFailureKeyPrefix+ip, strconv.FormatInt(since, 10), "+inf").Result()
+	if err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to check login failures from %s: %v", ip, err)
+		return false
+	}
+	return accounts >= int64(s.config.LoginFailureBurstThreshold)
+}
+
+// beginLoginStepUp pauses a high-risk login until the code emailed to the
+// account is entered, so a leaked password alone is not enough
+func (s *authService) beginLoginStepUp(user *models.User, roleName string, risk *loginRisk, ip, userAgent string, device models.DeviceInfo) (*models.AuthResponse, error) {
+	code := Generate6DigitCode()
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate step-up token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	key := loginStepUpKeyPrefix + s.hashToken(token)
+	ctx := context.Background()
+
+	pipe := s.redisClient.TxPipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"user_id":     user.ID.String(),
+		"code_hash":   s.hashToken(code),
+		"device_id":   device.DeviceID,
+		"device_name": device.DeviceName,
+		"device_type": device.DeviceType,
+		"attempts":    0,
+	})
+	pipe.Expire(ctx, key, loginStepUpExpiry)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to store step-up challenge: %w", err)
+	}
+
+	if err := s.emailService.SendLoginStepUpCodeEmail(user.ID, user.Email, code, risk.alert(), loginStepUpExpiry); err != nil {
+		s.redisClient.Del(ctx, key)
+		return nil, fmt.Errorf("failed to send step-up code: %w", err)
+	}
+
+	s.logAudit(&user.ID, "login_step_up_issued", "success", ip, userAgent, "")
+
+	return &models.AuthResponse{
+		Success: true,
+		Data: &models.AuthData{
+			UserID:         user.ID.String(),
+			Email:          user.Email,
+			Role:           roleName,
+			ExpiresIn:      int64(loginStepUpExpiry.Seconds()),
+			StepUpRequired: true,
+			StepUpToken:    token,
+		},
+	}, nil
+}
+
+// VerifyLoginStepUp finishes a high-risk login with the emailed code
+func (s *authService) VerifyLoginStepUp(req *models.VerifyLoginStepUpRequest, ip, userAgent string) (*models.AuthResponse, error) {
+	key := loginStepUpKeyPrefix + s.hashToken(req.StepUpToken)
+	ctx := context.Background()
+
+	fields, err := s.redisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load step-up challenge: %w", err)
+	}
+	userID, err := uuid.Parse(fields["user_id"])
+	if err != nil {
+		return mfaErrorResponse("STEP_UP_EXPIRED", ErrLoginStepUpInvalid.Error()), nil
+	}
+
+	// Every attempt counts, so the code cannot be brute-forced
+	attempts, err := s.redisClient.HIncrBy(ctx, key, "attempts", 1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record step-up attempt: %w", err)
+	}
+	if attempts > loginStepUpMaxAttempts {
+		s.redisClient.Del(ctx, key)
+		s.logAudit(&userID, "login_step_up_verify", "failed", ip, userAgent, "too many attempts")
+		return mfaErrorResponse("STEP_UP_EXPIRED", ErrLoginStepUpInvalid.Error()), nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(s.hashToken(req.Code)), []byte(fields["code_hash"])) != 1 {
+		s.recordFailedAttempt(userID)
+		s.logAudit(&userID, "login_step_up_verify", "failed", ip, userAgent, "invalid code")
+		return mfaErrorResponse("INVALID_STEP_UP_CODE", "Invalid verification code"), nil
+	}
+	s.redisClient.Del(ctx, key)
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	// The account may have changed while the code was in the mailbox
+	locked, err := s.userRepo.IsAccountLocked(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account lock: %w", err)
+	}
+	if locked {
+		s.logAudit(&user.ID, "login_step_up_verify", "failed", ip, userAgent, "account locked")
+		return mfaErrorResponse("ACCOUNT_LOCKED", "Account is locked due to too many failed login attempts. Please try again later."), nil
+	}
+	if !user.IsActive {
+		s.logAudit(&user.ID, "login_step_up_verify", "failed", ip, userAgent, "account inactive")
+		return mfaErrorResponse("ACCOUNT_INACTIVE", "Account is inactive"), nil
+	}
+	if user.PasswordResetRequired {
+		s.logAudit(&user.ID, "login_step_up_verify", "failed", ip, userAgent, "password reset required")
+		return mfaErrorResponse("PASSWORD_RESET_REQUIRED", "You must reset your password before signing in"), nil
+	}
+
+	s.logAudit(&user.ID, "login_step_up_verify", "success", ip, userAgent, "")
+
+	roles, err := s.roleRepo.FindByUserID(user.ID)
+	if err != nil || len(roles) == 0 {
+		return nil, fmt.Errorf("failed to find user roles: %w", err)
+	}
+	roleName := roles[0].Name
+
+	device := models.DeviceInfo{
+		DeviceID:   fields["device_id"],
+		DeviceName: fields["device_name"],
+		DeviceType: fields["device_type"],
+	}
+
+	accessToken, refreshToken, expiresIn, err := s.IssueTokens(user, roleName, ip, userAgent, device)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordLogin(user.ID, s.describeLogin(ip, userAgent, device))
+	s.logAudit(&user.ID, "login", "success", ip, userAgent, "")
+
+	return &models.AuthResponse{
+		Success: true,
+		Data: &models.AuthData{
+			UserID:       user.ID.String(),
+			Email:        user.Email,
+			Role:         roleName,
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			ExpiresIn:    expiresIn,
+		},
+	}, nil
+}
+
+// logAuditDetails writes an audit entry with details in its metadata
+func (s *authService) logAuditDetails(userID *uuid.UUID, eventType, status, ip, userAgent, errorMsg string, details map[string]interface{}) {
+	encoded, _ := json.Marshal(details)
+	metadata := string(encoded)
+
+	entry := &models.AuditLog{
+		UserID:      userID,
+		EventType:   eventType,
+		EventStatus: status,
+		Metadata:    &metadata,
+	}
+	if ip != "" {
+		entry.IPAddress = &ip
+	}
+	if userAgent != "" {
+		entry.UserAgent = &userAgent
+	}
+	if errorMsg != "" {
+		entry.ErrorMessage = &errorMsg
+	}
+	s.auditRepo.Create(entry)
+}
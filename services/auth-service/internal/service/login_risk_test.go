@@ -0,0 +1,321 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/geoip"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+)
+
+// fakeLocator resolves the IPs it was given
+type fakeLocator map[string]geoip.Location
+
+func (l fakeLocator) Lookup(ip net.IP) (geoip.Location, bool) {
+	loc, ok := l[ip.String()]
+	return loc, ok
+}
+
+var (
+	hanoi = geoip.Location{Country: "VN", Latitude: 21.03, Longitude: 105.85, HasCoordinates: true}
+	paris = geoip.Location{Country: "FR", Latitude: 48.86, Longitude: 2.35, HasCoordinates: true}
+)
+
+const (
+	parisIP     = "198.51.100.7"
+	phoneAgent  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile Safari/604.1"
+	burstIP     = "192.0.2.200"
+	vietnamIP   = "192.0.2.1" // country-level entry, no coordinates
+	sameSubnet  = "203.0.113.99"
+	privateAddr = "192.168.1.20"
+)
+
+func TestDistanceKm(t *testing.T) {
+	// London to Paris is about 344 km
+	if d := distanceKm(51.5074, -0.1278, 48.8566, 2.3522); math.Abs(d-344) > 5 {
+		t.Errorf("London-Paris = %.0f km, want about 344", d)
+	}
+	if d := distanceKm(hanoi.Latitude, hanoi.Longitude, hanoi.Latitude, hanoi.Longitude); d != 0 {
+		t.Errorf("distance to the same point = %f", d)
+	}
+}
+
+func TestImpossibleTravel(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    geoip.Location
+		to      geoip.Location
+		elapsed time.Duration
+		want    bool
+	}{
+		{name: "Hanoi to Paris in an hour", from: hanoi, to: paris, elapsed: time.Hour, want: true},
+		{name: "Hanoi to Paris on a flight", from: hanoi, to: paris, elapsed: 14 * time.Hour},
+		{name: "same instant far away", from: hanoi, to: paris, elapsed: 0, want: true},
+		{name: "short hop is city-level noise", from: hanoi,
+			to: geoip.Location{Latitude: 20.86, Longitude: 106.68}, elapsed: time.Second},
+		{name: "Hanoi to Ho Chi Minh City in an hour", from: hanoi,
+			to: geoip.Location{Latitude: 10.82, Longitude: 106.63}, elapsed: time.Hour, want: true},
+		{name: "Hanoi to Ho Chi Minh City in a day", from: hanoi,
+			to: geoip.Location{Latitude: 10.82, Longitude: 106.63}, elapsed: 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := impossibleTravel(tt.from.Latitude, tt.from.Longitude, tt.to.Latitude, tt.to.Longitude, tt.elapsed)
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newRiskEnv is a test env whose GeoIP knows the test addresses
+func newRiskEnv(t *testing.T) *testEnv {
+	t.Helper()
+	env := newTestEnv(t)
+	env.svc.geoIP = fakeLocator{
+		tabIP:      hanoi,
+		sameSubnet: hanoi,
+		parisIP:    paris,
+		vietnamIP:  {Country: "VN"},
+	}
+	return env
+}
+
+// seedLogin records a completed login made age ago
+func (e *testEnv) seedLogin(user *models.User, ip, userAgent string, age time.Duration) {
+	e.svc.recordLogin(user.ID, e.svc.describeLogin(ip, userAgent, models.DeviceInfo{}))
+	e.logins.mu.Lock()
+	e.logins.entries[len(e.logins.entries)-1].CreatedAt = time.Now().Add(-age)
+	e.logins.mu.Unlock()
+}
+
+func TestAssessLogin(t *testing.T) {
+	tests := []struct {
+		name        string
+		seed        bool
+		seedAge     time.Duration
+		ip          string
+		agent       string
+		wantSignals []string
+		wantLevel   string
+	}{
+		{name: "first login", ip: tabIP, agent: tabAgent, wantLevel: LoginRiskLow},
+		{name: "usual device and network", seed: true, ip: sameSubnet, agent: tabAgent, wantLevel: LoginRiskLow},
+		{name: "new device", seed: true, ip: tabIP, agent: phoneAgent,
+			wantSignals: []string{LoginSignalNewDevice}, wantLevel: LoginRiskLow},
+		{name: "new network at home", seed: true, ip: vietnamIP, agent: tabAgent,
+			wantSignals: []string{LoginSignalNewNetwork}, wantLevel: LoginRiskLow},
+		{name: "private network", seed: true, ip: privateAddr, agent: tabAgent, wantLevel: LoginRiskLow},
+		{name: "abroad after a flight", seed: true, seedAge: 20 * time.Hour, ip: parisIP, agent: tabAgent,
+			wantSignals: []string{LoginSignalNewCountry, LoginSignalNewNetwork}, wantLevel: LoginRiskMedium},
+		{name: "abroad minutes later", seed: true, seedAge: 10 * time.Minute, ip: parisIP, agent: tabAgent,
+			wantSignals: []string{LoginSignalImpossibleTravel, LoginSignalNewCountry, LoginSignalNewNetwork}, wantLevel: LoginRiskHigh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newRiskEnv(t)
+			user := env.addUser(t, "risk@example.com")
+			if tt.seed {
+				env.seedLogin(user, tabIP, tabAgent, tt.seedAge)
+			}
+
+			risk := env.svc.assessLogin(user.ID, tt.ip, tt.agent, models.DeviceInfo{})
+
+			signals := append([]string(nil), risk.Signals...)
+			sort.Strings(signals)
+			if strings.Join(signals, ",") != strings.Join(tt.wantSignals, ",") {
+				t.Errorf("signals = %v, want %v", signals, tt.wantSignals)
+			}
+			if level := risk.Level(); level != tt.wantLevel {
+				t.Errorf("level = %s (score %d), want %s", level, risk.Score, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestAssessLoginDeviceID(t *testing.T) {
+	env := newRiskEnv(t)
+	user := env.addUser(t, "device@example.com")
+	device := models.DeviceInfo{DeviceID: "install-1"}
+	env.svc.recordLogin(user.ID, env.svc.describeLogin(tabIP, tabAgent, device))
+
+	// The app's device ID outlives a changed User-Agent
+	if risk := env.svc.assessLogin(user.ID, tabIP, phoneAgent, device); len(risk.Signals) != 0 {
+		t.Errorf("known device ID flagged: %v", risk.Signals)
+	}
+	if risk := env.svc.assessLogin(user.ID, tabIP, tabAgent, models.DeviceInfo{DeviceID: "install-2"}); !risk.has(LoginSignalNewDevice) {
+		t.Error("other device ID not flagged as a new device")
+	}
+}
+
+func TestLoginFailureBurst(t *testing.T) {
+	env := newTestEnv(t)
+	threshold := env.svc.config.LoginFailureBurstThreshold
+
+	// Retrying one account is not a burst, however often
+	for i := 0; i < threshold+2; i++ {
+		env.svc.recordLoginFailure(burstIP, "victim-0@example.com", tabAgent)
+	}
+	if env.svc.isFailureBurstIP(burstIP) {
+		t.Fatal("failures for one account counted as a burst")
+	}
+
+	for i := 1; i < threshold; i++ {
+		env.svc.recordLoginFailure(burstIP, fmt.Sprintf("victim-%d@example.com", i), tabAgent)
+	}
+	if !env.svc.isFailureBurstIP(burstIP) {
+		t.Fatalf("%d accounts failing from one IP is not a burst", threshold)
+	}
+	env.svc.recordLoginFailure(burstIP, "VICTIM-0@example.com", tabAgent)
+	env.svc.recordLoginFailure(burstIP, "victim-x@example.com", tabAgent)
+	if entries := env.audit.events("login_failure_burst"); len(entries) != 1 {
+		t.Errorf("%d login_failure_burst audit entries, want 1", len(entries))
+	}
+
+	if env.svc.isFailureBurstIP(tabIP) {
+		t.Error("an IP without failures is in a burst")
+	}
+
+	// Failures older than the window do not count
+	old := float64(time.Now().Add(-2 * env.svc.config.LoginFailureBurstWindow).UnixNano())
+	for i := 0; i < threshold; i++ {
+		env.redis.ZAdd(loginFailureKeyPrefix+parisIP, old, fmt.Sprintf("old-%d", i))
+	}
+	if env.svc.isFailureBurstIP(parisIP) {
+		t.Error("failures outside the window counted")
+	}
+
+	// A login that then succeeds from the IP is high risk
+	user := env.addUser(t, "burst@example.com")
+	risk := env.svc.assessLogin(user.ID, burstIP, tabAgent, models.DeviceInfo{})
+	if !risk.has(LoginSignalFailureBurst) || risk.Level() != LoginRiskHigh {
+		t.Errorf("login from a burst IP: signals %v, level %s", risk.Signals, risk.Level())
+	}
+}
+
+// beginStepUp signs in from a burst IP and returns the step-up token and the
+// emailed code
+func beginStepUp(t *testing.T, env *testEnv, user *models.User) (string, string) {
+	t.Helper()
+	for i := 0; i < env.svc.config.LoginFailureBurstThreshold; i++ {
+		env.svc.recordLoginFailure(burstIP, fmt.Sprintf("other-%d@example.com", i), tabAgent)
+	}
+
+	resp, err := env.svc.Login(&models.LoginRequest{Email: user.Email, Password: testPassword}, burstIP, tabAgent)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !resp.Success || !resp.Data.StepUpRequired || resp.Data.AccessToken != "" {
+		t.Fatalf("high-risk login was not stepped up: %+v %s", resp.Data, errorCode(resp))
+	}
+	env.emails.mu.Lock()
+	defer env.emails.mu.Unlock()
+	return resp.Data.StepUpToken, env.emails.stepUpCode
+}
+
+func TestVerifyLoginStepUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(user *models.User)
+		wantCode string
+	}{
+		{name: "verified"},
+		{name: "locked meanwhile", change: func(u *models.User) {
+			until := time.Now().Add(time.Hour)
+			u.LockedUntil = &until
+		}, wantCode: "ACCOUNT_LOCKED"},
+		{name: "deactivated meanwhile", change: func(u *models.User) { u.IsActive = false }, wantCode: "ACCOUNT_INACTIVE"},
+		{name: "reset forced meanwhile", change: func(u *models.User) { u.PasswordResetRequired = true }, wantCode: "PASSWORD_RESET_REQUIRED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.svc.config.LoginStepUpEnabled = true
+			user := env.addUser(t, "stepup@example.com")
+			env.users.update(user.ID, func(u *models.User) { u.FailedLoginAttempts = 2 })
+
+			token, code := beginStepUp(t, env, user)
+			if code == "" {
+				t.Fatal("no step-up code was emailed")
+			}
+			if tt.change != nil {
+				env.users.update(user.ID, tt.change)
+			}
+
+			wrong := "000000"
+			if code == wrong {
+				wrong = "111111"
+			}
+			resp, err := env.svc.VerifyLoginStepUp(&models.VerifyLoginStepUpRequest{StepUpToken: token, Code: wrong}, burstIP, tabAgent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := errorCode(resp); got != "INVALID_STEP_UP_CODE" {
+				t.Fatalf("wrong code got %q", got)
+			}
+			if n := env.users.get(user.ID).FailedLoginAttempts; n != 3 {
+				t.Errorf("failed attempts = %d after a wrong code, want 3", n)
+			}
+
+			resp, err = env.svc.VerifyLoginStepUp(&models.VerifyLoginStepUpRequest{StepUpToken: token, Code: code}, burstIP, tabAgent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := errorCode(resp); got != tt.wantCode {
+				t.Fatalf("got %q, want %q", got, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				if resp.Data != nil {
+					t.Error("tokens issued to a blocked account")
+				}
+				return
+			}
+			if resp.Data.AccessToken == "" || resp.Data.RefreshToken == "" {
+				t.Error("verified step-up issued no tokens")
+			}
+			if n := env.users.get(user.ID).FailedLoginAttempts; n != 0 {
+				t.Errorf("failed attempts = %d after signing in, want 0", n)
+			}
+		})
+	}
+}
+
+func TestLoginStepUpFailuresLockAccount(t *testing.T) {
+	env := newTestEnv(t)
+	env.svc.config.LoginStepUpEnabled = true
+	user := env.addUser(t, "stepup-lock@example.com")
+
+	token, code := beginStepUp(t, env, user)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < env.svc.config.MaxLoginAttempts; i++ {
+		resp, err := env.svc.VerifyLoginStepUp(&models.VerifyLoginStepUpRequest{StepUpToken: token, Code: wrong}, burstIP, tabAgent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := errorCode(resp); got != "INVALID_STEP_UP_CODE" {
+			t.Fatalf("attempt %d got %q, want INVALID_STEP_UP_CODE", i+1, got)
+		}
+	}
+
+	if locked, _ := env.users.IsAccountLocked(user.ID); !locked {
+		t.Fatalf("account not locked after %d wrong codes", env.svc.config.MaxLoginAttempts)
+	}
+	resp, err := env.svc.Login(&models.LoginRequest{Email: user.Email, Password: testPassword}, tabIP, tabAgent)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if got := errorCode(resp); got != "ACCOUNT_LOCKED" {
+		t.Errorf("login while locked got %q, want ACCOUNT_LOCKED", got)
+	}
+}
@@ -0,0 +1,522 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrMFASetupRequired    = errors.New("start two-factor setup first")
+	ErrMFAInvalidCode      = errors.New("invalid authentication code")
+	ErrMFARequiredByPolicy = errors.New("two-factor authentication is required for your role")
+	ErrInvalidPassword     = errors.New("invalid password")
+	// ErrMFAChallengeInvalid is returned for unknown, expired or exhausted challenges
+	ErrMFAChallengeInvalid = errors.New("two-factor challenge expired, please sign in again")
+)
+
+const (
+	mfaChallengeKeyPrefix   = "mfa_challenge:"
+	mfaChallengeMaxAttempts = 5
+)
+
+// mfaChallenge is a login paused between the password and the second factor
+type mfaChallenge struct {
+	key    string
+	UserID uuid.UUID
+	Enroll bool // the role requires 2FA but the user has not set it up yet
+	Device models.DeviceInfo
+}
+
+func (s *authService) GetMFAStatus(userID uuid.UUID) (*models.MFAStatusResponse, error) {
+	status := &models.MFAStatusResponse{}
+
+	mfa, err := s.mfaRepo.FindByUserID(userID)
+	if err != nil && !errors.Is(err, repository.ErrMFANotEnrolled) {
+		return nil, err
+	}
+	if mfa != nil && mfa.EnabledAt != nil {
+		status.Enabled = true
+		status.EnabledAt = mfa.EnabledAt
+		if status.RecoveryCodesRemaining, err = s.mfaRepo.CountRecoveryCodes(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	roles, err := s.roleRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	status.RequiredByPolicy = mfaRequiredByRoles(roles)
+
+	return status, nil
+}
+
+// SetupMFA generates a new authenticator secret. It only takes effect once
+// EnableMFA verifies a code from it.
+func (s *authService) SetupMFA(userID uuid.UUID, ip, userAgent string) (*models.MFASetupResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	setup, err := s.createPendingSecret(user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logAudit(&userID, "mfa_setup_started", "success", ip, userAgent, "")
+	return setup, nil
+}
+
+func (s *authService) EnableMFA(userID uuid.UUID, code, ip, userAgent string) (*models.MFARecoveryCodesResponse, error) {
+	codes, err := s.enableMFA(userID, code)
+	if err != nil {
+		s.logAudit(&userID, "mfa_enabled", "failed", ip, userAgent, err.Error())
+		return nil, err
+	}
+
+	s.logAudit(&userID, "mfa_enabled", "success", ip, userAgent, "")
+	return &models.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *authService) DisableMFA(userID uuid.UUID, req *models.DisableMFARequest, ip, userAgent string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if user.Password == nil || bcrypt.CompareHashAndPassword([]byte(*user.Password), []byte(req.Password)) != nil {
+		s.logAudit(&userID, "mfa_disabled", "failed", ip, userAgent, "invalid password")
+		return ErrInvalidPassword
+	}
+
+	roles, err := s.roleRepo.FindByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if mfaRequiredByRoles(roles) {
+		s.logAudit(&userID, "mfa_disabled", "failed", ip, userAgent, "required by role policy")
+		return ErrMFARequiredByPolicy
+	}
+
+	if err := s.verifyUserTOTP(userID, req.Code); err != nil {
+		s.logAudit(&userID, "mfa_disabled", "failed", ip, userAgent, err.Error())
+		return err
+	}
+
+	if err := s.mfaRepo.Disable(userID); err != nil {
+		return err
+	}
+
+	s.logAudit(&userID, "mfa_disabled", "success", ip, userAgent, "")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not
+func (s *authService) RegenerateRecoveryCodes(userID uuid.UUID, code, ip, userAgent string) (*models.MFARecoveryCodesResponse, error) {
+	if err := s.verifyUserTOTP(userID, code); err != nil {
+		s.logAudit(&userID, "mfa_recovery_codes_regenerated", "failed", ip, userAgent, err.Error())
+		return nil, err
+	}
+
+	codes, err := s.issueRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logAudit(&userID, "mfa_recovery_codes_regenerated", "success", ip, userAgent, "")
+	return &models.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// SetupMFAChallenge lets a user whose role requires 2FA enroll during login,
+// before they hold an access token.
+func (s *authService) SetupMFAChallenge(mfaToken, ip, userAgent string) (*models.MFASetupResponse, error) {
+	challenge, err := s.loadMFAChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || !challenge.Enroll {
+		return nil, ErrMFAChallengeInvalid
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	setup, err := s.createPendingSecret(user)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logAudit(&user.ID, "mfa_setup_started", "success", ip, userAgent, "during login")
+	return setup, nil
+}
+
+// VerifyMFAChallenge completes a login paused by Login and issues the tokens
+func (s *authService) VerifyMFAChallenge(req *models.VerifyMFAChallengeRequest, ip, userAgent string) (*models.AuthResponse, error) {
+	challenge, err := s.loadMFAChallenge(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return mfaErrorResponse("MFA_CHALLENGE_EXPIRED", ErrMFAChallengeInvalid.Error()), nil
+	}
+
+	// Every attempt counts, so a challenge cannot be used to brute-force codes
+	attempts, err := s.redisClient.HIncrBy(context.Background(), challenge.key, "attempts", 1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record mfa attempt: %w", err)
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		s.redisClient.Del(context.Background(), challenge.key)
+		s.logAudit(&challenge.UserID, "mfa_verify", "failed", ip, userAgent, "too many attempts")
+		return mfaErrorResponse("MFA_CHALLENGE_EXPIRED", ErrMFAChallengeInvalid.Error()), nil
+	}
+
+	user, err := s.userRepo.FindByID(challenge.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if !user.IsActive {
+		s.redisClient.Del(context.Background(), challenge.key)
+		return mfaErrorResponse("ACCOUNT_INACTIVE", "Account is inactive"), nil
+	}
+
+	// Wrong codes lock the account like wrong passwords; a fresh challenge
+	// from logging in again does not reset the count
+	locked, err := s.userRepo.IsAccountLocked(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account lock: %w", err)
+	}
+	if locked {
+		s.redisClient.Del(context.Background(), challenge.key)
+		s.logAudit(&user.ID, "mfa_verify", "failed", ip, userAgent, "account locked")
+		return mfaErrorResponse("ACCOUNT_LOCKED", "Account is locked due to too many failed login attempts. Please try again later."), nil
+	}
+	if user.PasswordResetRequired {
+		s.redisClient.Del(context.Background(), challenge.key)
+		s.logAudit(&user.ID, "mfa_verify", "failed", ip, userAgent, "password reset required")
+		return mfaErrorResponse("PASSWORD_RESET_REQUIRED", "You must reset your password before signing in"), nil
+	}
+
+	var recoveryCodes []string
+	switch {
+	case challenge.Enroll:
+		recoveryCodes, err = s.enableMFA(user.ID, req.Code)
+		if err == nil {
+			s.logAudit(&user.ID, "mfa_enabled", "success", ip, userAgent, "during login")
+		} else if errors.Is(err, ErrMFAAlreadyEnabled) {
+			// Enrolled from another device since the challenge was issued
+			err = s.verifyUserTOTP(user.ID, req.Code)
+		}
+	case req.RecoveryCode != "":
+		err = s.useRecoveryCode(user.ID, req.RecoveryCode)
+		if err == nil {
+			s.logAudit(&user.ID, "mfa_recovery_code_used", "success", ip, userAgent, "")
+		}
+	default:
+		err = s.verifyUserTOTP(user.ID, req.Code)
+	}
+
+	if err != nil {
+		s.logAudit(&user.ID, "mfa_verify", "failed", ip, userAgent, err.Error())
+		if errors.Is(err, ErrMFAInvalidCode) {
+			s.recordFailedAttempt(user.ID)
+			return mfaErrorResponse("INVALID_MFA_CODE", err.Error()), nil
+		}
+		if errors.Is(err, ErrMFASetupRequired) {
+			return mfaErrorResponse("MFA_SETUP_REQUIRED", err.Error()), nil
+		}
+		return nil, err
+	}
+
+	s.redisClient.Del(context.Background(), challenge.key)
+	s.logAudit(&user.ID, "mfa_verify", "success", ip, userAgent, "")
+
+	roles, err := s.roleRepo.FindByUserID(user.ID)
+	if err != nil || len(roles) == 0 {
+		return nil, fmt.Errorf("failed to find user roles: %w", err)
+	}
+	roleName := roles[0].Name
+
+	accessToken, refreshToken, expiresIn, err := s.IssueTokens(user, roleName, ip, userAgent, challenge.Device)
+	if err != nil {
+		return nil, err
+	}
+	// Alerts went out when the password was accepted
+	s.recordLogin(user.ID, s.describeLogin(ip, userAgent, challenge.Device))
+
+	s.logAudit(&user.ID, "login", "success", ip, userAgent, "")
+
+	return &models.AuthResponse{
+		Success: true,
+		Data: &models.AuthData{
+			UserID:        user.ID.String(),
+			Email:         user.Email,
+			Role:          roleName,
+			AccessToken:   accessToken,
+			RefreshToken:  refreshToken,
+			ExpiresIn:     expiresIn,
+			RecoveryCodes: recoveryCodes,
+		},
+	}, nil
+}
+
+func (s *authService) ListMFAPolicies() ([]models.MFAPolicyResponse, error) {
+	roles, err := s.roleRepo.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]models.MFAPolicyResponse, 0, len(roles))
+	for _, role := range roles {
+		policies = append(policies, models.MFAPolicyResponse{Role: role.Name, RequireMFA: role.RequireMFA})
+	}
+	return policies, nil
+}
+
+func (s *authService) SetMFAPolicy(adminID uuid.UUID, role string, require bool, ip, userAgent string) error {
+	if err := s.roleRepo.SetRequireMFA(role, require); err != nil {
+		s.logAudit(&adminID, "mfa_policy_updated", "failed", ip, userAgent, err.Error())
+		return err
+	}
+
+	s.logAudit(&adminID, "mfa_policy_updated", "success", ip, userAgent, fmt.Sprintf("role=%s require_mfa=%t", role, require))
+	return nil
+}
+
+// BeginMFAChallenge pauses a login whose first factor has been verified when
+// the user has 2FA enabled or their role requires it. A nil response means
+// tokens can be issued right away.
+func (s *authService) BeginMFAChallenge(user *models.User, roles []models.Role, ip, userAgent string, device models.DeviceInfo) (*models.AuthResponse, error) {
+	mfa, err := s.mfaRepo.FindByUserID(user.ID)
+	if err != nil && !errors.Is(err, repository.ErrMFANotEnrolled) {
+		return nil, err
+	}
+
+	enabled := mfa != nil && mfa.EnabledAt != nil
+	if !enabled && !mfaRequiredByRoles(roles) {
+		return nil, nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate mfa token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	ttl, _ := time.ParseDuration(s.config.MFAChallengeExpiry)
+	key := mfaChallengeKeyPrefix + s.hashToken(token)
+	ctx := context.Background()
+
+	pipe := s.redisClient.TxPipeline()
+	pipe.HSet(ctx, key, map[string]interface{}{
+		"user_id":     user.ID.String(),
+		"enroll":      strconv.FormatBool(!enabled),
+		"device_id":   device.DeviceID,
+		"device_name": device.DeviceName,
+		"device_type": device.DeviceType,
+		"attempts":    0,
+	})
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to store mfa challenge: %w", err)
+	}
+
+	s.logAudit(&user.ID, "mfa_challenge_issued", "success", ip, userAgent, "")
+
+	return &models.AuthResponse{
+		Success: true,
+		Data: &models.AuthData{
+			UserID:                user.ID.String(),
+			Email:                 user.Email,
+			Role:                  roles[0].Name,
+			ExpiresIn:             int64(ttl.Seconds()),
+			MFARequired:           true,
+			MFAEnrollmentRequired: !enabled,
+			MFAToken:              token,
+		},
+	}, nil
+}
+
+// loadMFAChallenge returns nil when the token is unknown or expired
+func (s *authService) loadMFAChallenge(token string) (*mfaChallenge, error) {
+	key := mfaChallengeKeyPrefix + s.hashToken(token)
+
+	fields, err := s.redisClient.HGetAll(context.Background(), key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mfa challenge: %w", err)
+	}
+
+	userID, err := uuid.Parse(fields["user_id"])
+	if err != nil {
+		return nil, nil
+	}
+
+	enroll, _ := strconv.ParseBool(fields["enroll"])
+	return &mfaChallenge{
+		key:    key,
+		UserID: userID,
+		Enroll: enroll,
+		Device: models.DeviceInfo{
+			DeviceID:   fields["device_id"],
+			DeviceName: fields["device_name"],
+			DeviceType: fields["device_type"],
+		},
+	}, nil
+}
+
+func (s *authService) createPendingSecret(user *models.User) (*models.MFASetupResponse, error) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.secretCipher.encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.SavePendingSecret(user.ID, encrypted); err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &models.MFASetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totpURI(s.config.MFAIssuer, user.Email, secret),
+		Issuer:     s.config.MFAIssuer,
+		Account:    user.Email,
+	}, nil
+}
+
+// enableMFA activates a pending secret and returns the first recovery codes
+func (s *authService) enableMFA(userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.FindByUserID(userID)
+	if errors.Is(err, repository.ErrMFANotEnrolled) {
+		return nil, ErrMFASetupRequired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mfa.EnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.secretCipher.decrypt(mfa.SecretEncrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := verifyTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	if err := s.mfaRepo.Enable(userID, step); err != nil {
+		if errors.Is(err, repository.ErrMFAAlreadyEnabled) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return s.issueRecoveryCodes(userID)
+}
+
+// verifyUserTOTP checks a code against the enabled authenticator; each time
+// step is accepted at most once.
+func (s *authService) verifyUserTOTP(userID uuid.UUID, code string) error {
+	mfa, err := s.mfaRepo.FindByUserID(userID)
+	if errors.Is(err, repository.ErrMFANotEnrolled) {
+		return ErrMFANotEnabled
+	}
+	if err != nil {
+		return err
+	}
+	if mfa.EnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	secret, err := s.secretCipher.decrypt(mfa.SecretEncrypted)
+	if err != nil {
+		return err
+	}
+
+	step, ok := verifyTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrMFAInvalidCode
+	}
+
+	fresh, err := s.mfaRepo.MarkStepUsed(userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrMFAInvalidCode
+	}
+
+	return nil
+}
+
+func (s *authService) useRecoveryCode(userID uuid.UUID, code string) error {
+	used, err := s.mfaRepo.UseRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrMFAInvalidCode
+	}
+	return nil
+}
+
+func (s *authService) issueRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashRecoveryCode(code)
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func mfaRequiredByRoles(roles []models.Role) bool {
+	for _, role := range roles {
+		if role.RequireMFA {
+			return true
+		}
+	}
+	return false
+}
+
+func mfaErrorResponse(code, message string) *models.AuthResponse {
+	return &models.AuthResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
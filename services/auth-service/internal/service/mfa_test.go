@@ -0,0 +1,179 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/config"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+)
+
+func TestCheckMFAEncryptionKey(t *testing.T) {
+	tests := []struct {
+		env     string
+		key     string
+		wantErr bool
+	}{
+		{env: "development", key: config.DefaultMFAEncryptionKey},
+		{env: "production", key: config.DefaultMFAEncryptionKey, wantErr: true},
+		{env: "staging", key: "my_key_change_in_production", wantErr: true},
+		{env: "production", key: "3f9c1a7e5b2d4c8f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env+"/"+tt.key, func(t *testing.T) {
+			err := checkMFAEncryptionKey(&config.Config{AppEnv: tt.env, MFAEncryptionKey: tt.key})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// totpNow returns the authenticator code for the current step
+func totpNow(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(key, time.Now().Unix()/totpPeriod)
+}
+
+// wrongTOTP returns a code that no step in the verification window accepts
+func wrongTOTP(t *testing.T, secret string) string {
+	t.Helper()
+	for i := 0; ; i++ {
+		code := fmt.Sprintf("%06d", i)
+		if _, ok := verifyTOTP(secret, code, time.Now()); !ok {
+			return code
+		}
+	}
+}
+
+// beginChallenge logs in with the password and returns the mfa token
+func beginChallenge(t *testing.T, env *testEnv, user *models.User) string {
+	t.Helper()
+	resp, err := env.svc.Login(&models.LoginRequest{Email: user.Email, Password: testPassword}, tabIP, tabAgent)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !resp.Success || !resp.Data.MFARequired {
+		t.Fatalf("login did not ask for a second factor: %s", errorCode(resp))
+	}
+	return resp.Data.MFAToken
+}
+
+func verifyChallenge(t *testing.T, env *testEnv, token, code string) *models.AuthResponse {
+	t.Helper()
+	resp, err := env.svc.VerifyMFAChallenge(&models.VerifyMFAChallengeRequest{MFAToken: token, Code: code}, tabIP, tabAgent)
+	if err != nil {
+		t.Fatalf("VerifyMFAChallenge: %v", err)
+	}
+	return resp
+}
+
+func TestMFAChallengeFailuresLockAccount(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(t, "mfa-lock@example.com")
+	secret := env.enableMFA(t, user)
+	wrong := wrongTOTP(t, secret)
+
+	// A pending challenge from before the lock, with the right code in hand
+	pending := beginChallenge(t, env, user)
+
+	// Logging in again for a fresh challenge must not reset the count
+	for i := 0; i < env.svc.config.MaxLoginAttempts; i++ {
+		token := beginChallenge(t, env, user)
+		if code := errorCode(verifyChallenge(t, env, token, wrong)); code != "INVALID_MFA_CODE" {
+			t.Fatalf("attempt %d got %q, want INVALID_MFA_CODE", i+1, code)
+		}
+	}
+
+	if locked, _ := env.users.IsAccountLocked(user.ID); !locked {
+		t.Fatalf("account not locked after %d wrong codes", env.svc.config.MaxLoginAttempts)
+	}
+
+	resp, err := env.svc.Login(&models.LoginRequest{Email: user.Email, Password: testPassword}, tabIP, tabAgent)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if code := errorCode(resp); code != "ACCOUNT_LOCKED" {
+		t.Errorf("login while locked got %q, want ACCOUNT_LOCKED", code)
+	}
+
+	resp = verifyChallenge(t, env, pending, totpNow(t, secret))
+	if code := errorCode(resp); code != "ACCOUNT_LOCKED" {
+		t.Errorf("pending challenge while locked got %q, want ACCOUNT_LOCKED", code)
+	}
+	if resp.Data != nil {
+		t.Error("tokens issued to a locked account")
+	}
+}
+
+func TestMFAChallengeSuccessResetsFailures(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(t, "mfa-reset@example.com")
+	secret := env.enableMFA(t, user)
+
+	token := beginChallenge(t, env, user)
+	verifyChallenge(t, env, token, wrongTOTP(t, secret))
+	if n := env.users.get(user.ID).FailedLoginAttempts; n != 1 {
+		t.Fatalf("%d failed attempts after a wrong code, want 1", n)
+	}
+
+	// The password alone does not clear the count
+	token = beginChallenge(t, env, user)
+	if n := env.users.get(user.ID).FailedLoginAttempts; n != 1 {
+		t.Errorf("%d failed attempts after the password step, want 1", n)
+	}
+
+	resp := verifyChallenge(t, env, token, totpNow(t, secret))
+	if !resp.Success || resp.Data.AccessToken == "" {
+		t.Fatalf("correct code failed: %s", errorCode(resp))
+	}
+	if n := env.users.get(user.ID).FailedLoginAttempts; n != 0 {
+		t.Errorf("%d failed attempts after signing in, want 0", n)
+	}
+}
+
+func TestMFAChallengeRechecksAccount(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(user *models.User)
+		wantCode string
+	}{
+		{name: "deactivated meanwhile", change: func(u *models.User) { u.IsActive = false }, wantCode: "ACCOUNT_INACTIVE"},
+		{name: "reset forced meanwhile", change: func(u *models.User) { u.PasswordResetRequired = true }, wantCode: "PASSWORD_RESET_REQUIRED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.addUser(t, "mfa-recheck@example.com")
+			secret := env.enableMFA(t, user)
+
+			token := beginChallenge(t, env, user)
+			env.users.update(user.ID, tt.change)
+
+			resp := verifyChallenge(t, env, token, totpNow(t, secret))
+			if code := errorCode(resp); code != tt.wantCode {
+				t.Fatalf("got %q, want %q", code, tt.wantCode)
+			}
+			if resp.Data != nil {
+				t.Error("tokens issued to a blocked account")
+			}
+
+			// The challenge is gone even once the account is usable again
+			env.users.update(user.ID, func(u *models.User) {
+				u.IsActive = true
+				u.PasswordResetRequired = false
+			})
+			if code := errorCode(verifyChallenge(t, env, token, totpNow(t, secret))); code != "MFA_CHALLENGE_EXPIRED" {
+				t.Errorf("reused challenge got %q, want MFA_CHALLENGE_EXPIRED", code)
+			}
+		})
+	}
+}
@@ -0,0 +1,520 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/config"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrUnknownOAuthProvider is returned for providers that are not configured
+	ErrUnknownOAuthProvider = errors.New("unknown sign-in provider")
+	// ErrInvalidOAuthState is returned when the state is unknown, expired,
+	// already used or was issued for another provider
+	ErrInvalidOAuthState = errors.New("invalid or expired oauth state")
+	// ErrOAuthExchange is returned when the provider rejects the code or its ID token is invalid
+	ErrOAuthExchange = errors.New("failed to verify the sign-in with the provider")
+	// ErrOAuthEmailMissing is returned when the provider does not share an email
+	ErrOAuthEmailMissing = errors.New("the provider did not return an email address")
+	// ErrLastSignInMethod is returned when unlinking would leave an account
+	// without a password or any linked provider
+	ErrLastSignInMethod = errors.New("set a password or link another provider before unlinking this one")
+)
+
+const oauthStateKeyPrefix = "oauth_state:"
+
+// OAuthStateTTL is how long a started sign-in or link can be completed
+const OAuthStateTTL = 10 * time.Minute
+
+// OAuthService signs users in with, and links, external OAuth/OIDC accounts
+type OAuthService interface {
+	Providers() []models.OAuthProviderResponse
+	// AuthURL starts a sign-in, or links the provider to linkUserID when set
+	AuthURL(provider string, linkUserID *uuid.UUID) (*models.OAuthURLResponse, error)
+	// Complete redeems the code returned to the redirect URL for its state
+	Complete(provider, state, code, ip, userAgent string) (*OAuthResult, error)
+	ListIdentities(userID uuid.UUID) ([]models.IdentityResponse, error)
+	UnlinkIdentity(userID uuid.UUID, provider, ip, userAgent string) error
+}
+
+// OAuthResult is either a sign-in (Auth) or a newly linked identity (Linked)
+type OAuthResult struct {
+	Auth   *models.AuthResponse
+	Linked *models.IdentityResponse
+}
+
+type oauthService struct {
+	providers         map[string]OAuthProvider
+	userRepo          repository.UserRepository
+	roleRepo          repository.RoleRepository
+	auditRepo         repository.AuditLogRepository
+	identityRepo      repository.IdentityRepository
+	authService       AuthService
+	redisClient       *redis.Client
+	appConfig         *config.Config
+	userServiceClient *client.UserServiceClient
+}
+
+// oauthState is the pending authorization stored under its state parameter
+type oauthState struct {
+	Provider   string
+	Verifier   string
+	Nonce      string
+	LinkUserID *uuid.UUID
+}
+
+func NewOAuthService(
+	cfg *config.Config,
+	providers []OAuthProvider,
+	userRepo repository.UserRepository,
+	roleRepo repository.RoleRepository,
+	auditRepo repository.AuditLogRepository,
+	identityRepo repository.IdentityRepository,
+	authService AuthService,
+	redisClient *redis.Client,
+	userServiceClient *client.UserServiceClient,
+) OAuthService {
+	byName := make(map[string]OAuthProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+
+	return &oauthService{
+		providers:         byName,
+		userRepo:          userRepo,
+		roleRepo:          roleRepo,
+		auditRepo:         auditRepo,
+		identityRepo:      identityRepo,
+		authService:       authService,
+		redisClient:       redisClient,
+		appConfig:         cfg,
+		userServiceClient: userServiceClient,
+	}
+}
+
+func (s *oauthService) Providers() []models.OAuthProviderResponse {
+	providers := make([]models.OAuthProviderResponse, 0, len(s.providers))
+	for _, provider := range s.providers {
+		providers = append(providers, models.OAuthProviderResponse{
+			Name:        provider.Name(),
+			DisplayName: provider.DisplayName(),
+		})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	return providers
+}
+
+func (s *oauthService) AuthURL(providerName string, linkUserID *uuid.UUID) (*models.OAuthURLResponse, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOAuthProvider
+	}
+
+	state, err := randomHex(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate oauth state: %w", err)
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate oauth nonce: %w", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	ctx := context.Background()
+	url, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{
+		"provider": providerName,
+		"verifier": verifier,
+		"nonce":    nonce,
+	}
+	if linkUserID != nil {
+		fields["link_user_id"] = linkUserID.String()
+	}
+
+	key := oauthStateKeyPrefix + hashToken(state)
+	pipe := s.redisClient.TxPipeline()
+	pipe.HSet(ctx, key, fields)
+	pipe.Expire(ctx, key, OAuthStateTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to store oauth state: %w", err)
+	}
+
+	return &models.OAuthURLResponse{URL: url, State: state}, nil
+}
+
+// consumeState loads the pending authorization and deletes it so a state
+// (and the code it protects) is redeemed at most once
+func (s *oauthService) consumeState(providerName, state string) (*oauthState, error) {
+	if state == "" {
+		return nil, ErrInvalidOAuthState
+	}
+	key := oauthStateKeyPrefix + hashToken(state)
+	ctx := context.Background()
+
+	fields, err := s.redisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load oauth state: %w", err)
+	}
+	deleted, err := s.redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete oauth state: %w", err)
+	}
+	if deleted == 0 || fields["provider"] != providerName {
+		return nil, ErrInvalidOAuthState
+	}
+
+	pending := &oauthState{
+		Provider: fields["provider"],
+		Verifier: fields["verifier"],
+		Nonce:    fields["nonce"],
+	}
+	if raw := fields["link_user_id"]; raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, ErrInvalidOAuthState
+		}
+		pending.LinkUserID = &userID
+	}
+	return pending, nil
+}
+
+func (s *oauthService) Complete(providerName, state, code, ip, userAgent string) (*OAuthResult, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOAuthProvider
+	}
+
+	pending, err := s.consumeState(providerName, state)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*oidcRequestTimeout)
+	defer cancel()
+
+	identity, err := provider.Exchange(ctx, code, pending.Nonce, pending.Verifier)
+	if err != nil {
+		log.Printf("[OAuth] %s exchange failed: %v", providerName, err)
+		s.logAudit(pending.LinkUserID, "oauth_login", "failed", ip, userAgent, err.Error(), providerName)
+		return nil, ErrOAuthExchange
+	}
+
+	if pending.LinkUserID != nil {
+		linked, err := s.link(*pending.LinkUserID, identity, ip, userAgent)
+		if err != nil {
+			return nil, err
+		}
+		return &OAuthResult{Linked: linked}, nil
+	}
+
+	auth, err := s.authenticate(identity, ip, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthResult{Auth: auth}, nil
+}
+
+func (s *oauthService) link(userID uuid.UUID, identity *ExternalIdentity, ip, userAgent string) (*models.IdentityResponse, error) {
+	linked := &models.UserIdentity{
+		UserID:   userID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+	}
+	if identity.Email != "" {
+		linked.Email = &identity.Email
+	}
+
+	if err := s.identityRepo.Link(linked); err != nil {
+		s.logAudit(&userID, "identity_linked", "failed", ip, userAgent, err.Error(), identity.Provider)
+		return nil, err
+	}
+	s.logAudit(&userID, "identity_linked", "success", ip, userAgent, "", identity.Provider)
+
+	response := identityResponse(*linked)
+	return &response, nil
+}
+
+// resolveUser finds the account for an external identity: the linked user,
+// else the user with the same (provider-verified) email, else a new user
+func (s *oauthService) resolveUser(identity *ExternalIdentity) (*models.User, bool, *models.ErrorData, error) {
+	user, err := s.identityRepo.FindUser(identity.Provider, identity.Subject)
+	if err == nil {
+		if err := s.identityRepo.MarkUsed(identity.Provider, identity.Subject); err != nil {
+			log.Printf("[OAuth] Failed to update identity last use: %v", err)
+		}
+		return user, false, nil, nil
+	}
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return nil, false, nil, err
+	}
+
+	if identity.Email == "" {
+		return nil, false, nil, ErrOAuthEmailMissing
+	}
+	linked := &models.UserIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    &identity.Email,
+	}
+
+	user, err = s.userRepo.FindByEmail(identity.Email)
+	if err == nil {
+		// Only a provider-verified email proves the caller owns the existing account
+		if !identity.EmailVerified {
+			return nil, false, &models.ErrorData{
+				Code:    "ACCOUNT_EXISTS",
+				Message: "An account with this email already exists. Sign in and link this provider from your security settings.",
+			}, nil
+		}
+		linked.UserID = user.ID
+		if err := s.identityRepo.Link(linked); err != nil {
+			return nil, false, nil, err
+		}
+		return user, false, nil, nil
+	}
+	if !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, false, nil, err
+	}
+
+	user, err = s.identityRepo.CreateUser(identity.Email, identity.EmailVerified, linked)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	return user, true, nil, nil
+}
+
+func (s *oauthService) authenticate(identity *ExternalIdentity, ip, userAgent string) (*models.AuthResponse, error) {
+	log.Printf("[OAuth] Signing in %s identity %s (%s)", identity.Provider, identity.Subject, identity.Email)
+
+	user, isNewUser, denied, err := s.resolveUser(identity)
+	if err != nil {
+		log.Printf("[OAuth] ❌ Failed to find/create user: %v", err)
+		s.logAudit(nil, "oauth_login", "failed", ip, userAgent, fmt.Sprintf("Failed to find/create user: %v", err), identity.Provider)
+		message := "Failed to process sign-in"
+		if errors.Is(err, ErrOAuthEmailMissing) {
+			message = "The provider did not share an email address"
+		}
+		return &models.AuthResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "INTERNAL_ERROR",
+				Message: message,
+			},
+		}, nil
+	}
+	if denied != nil {
+		s.logAudit(nil, "oauth_login", "failed", ip, userAgent, denied.Message, identity.Provider)
+		return &models.AuthResponse{Success: false, Error: denied}, nil
+	}
+	log.Printf("[OAuth] ✅ Found/created user: ID=%s, Email=%s, IsActive=%v, IsNew=%v", user.ID, user.Email, user.IsActive, isNewUser)
+
+	// If this is a new user, create profile in User Service
+	if isNewUser && s.userServiceClient != nil {
+		profileReq := client.CreateProfileRequest{
+			UserID:   user.ID.String(),
+			Email:    user.Email,
+			Role:     "student", // Default role for OAuth users
+			FullName: identity.Name,
+		}
+		if err := s.userServiceClient.CreateProfile(profileReq); err != nil {
+			log.Printf("[OAuth] ⚠️ Failed to create user profile for %s: %v", user.Email, err)
+			// Don't fail login, but log the error
+		}
+	}
+
+	// Check if account is active
+	if !user.IsActive {
+		s.logAudit(&user.ID, "oauth_login", "failed", ip, userAgent, "account is not active", identity.Provider)
+		return &models.AuthResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "ACCOUNT_INACTIVE",
+				Message: "Account is not active",
+			},
+		}, nil
+	}
+
+	// The provider replaces the password, not the lockout
+	locked, err := s.userRepo.IsAccountLocked(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account lock: %w", err)
+	}
+	if locked {
+		s.logAudit(&user.ID, "oauth_login", "failed", ip, userAgent, "account locked", identity.Provider)
+		return &models.AuthResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "ACCOUNT_LOCKED",
+				Message: "Account is locked due to too many failed login attempts. Please try again later.",
+			},
+		}, nil
+	}
+
+	// A reset forced by an admin applies to every way of signing in
+	if user.PasswordResetRequired {
+		s.logAudit(&user.ID, "oauth_login", "failed", ip, userAgent, "password reset required", identity.Provider)
+		return &models.AuthResponse{
+			Success: false,
+			Error: &models.ErrorData{
+				Code:    "PASSWORD_RESET_REQUIRED",
+				Message: "You must reset your password before signing in",
+			},
+		}, nil
+	}
+
+	// Get user role
+	roles, err := s.roleRepo.FindByUserID(user.ID)
+	var role *models.Role
+	if err != nil || len(roles) == 0 {
+		// Assign default student role for new OAuth users
+		studentRole, err := s.roleRepo.FindByName("student")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get default role: %w", err)
+		}
+		if err := s.roleRepo.AssignRoleToUser(user.ID, studentRole.ID, nil); err != nil {
+			return nil, fmt.Errorf("failed to assign role: %w", err)
+		}
+		role = studentRole
+	} else {
+		role = &roles[0]
+	}
+
+	// The provider replaces the password, not the second factor
+	mfaRoles := roles
+	if len(mfaRoles) == 0 {
+		mfaRoles = []models.Role{*role}
+	}
+	challenge, err := s.authService.BeginMFAChallenge(user, mfaRoles, ip, userAgent, models.DeviceInfo{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start mfa challenge: %w", err)
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	accessToken, refreshToken, expiresIn, err := s.authService.IssueTokens(user, role.Name, ip, userAgent, models.DeviceInfo{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Alert on a new device or unusual location; 2FA logins are recorded
+	// when the challenge is verified
+	s.authService.TrackLogin(user, ip, userAgent, models.DeviceInfo{})
+
+	s.logAudit(&user.ID, "oauth_login", "success", ip, userAgent, "", identity.Provider)
+
+	return &models.AuthResponse{
+		Success: true,
+		Data: &models.AuthData{
+			UserID:       user.ID.String(),
+			Email:        user.Email,
+			Role:         role.Name,
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			ExpiresIn:    expiresIn,
+		},
+	}, nil
+}
+
+func (s *oauthService) ListIdentities(userID uuid.UUID) ([]models.IdentityResponse, error) {
+	identities, err := s.identityRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]models.IdentityResponse, 0, len(identities))
+	for _, identity := range identities {
+		response = append(response, identityResponse(identity))
+	}
+	return response, nil
+}
+
+// UnlinkIdentity removes a linked provider. An account without a password
+// keeps at least one provider, so it is never left to email codes alone.
+func (s *oauthService) UnlinkIdentity(userID uuid.UUID, provider, ip, userAgent string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Password == nil || *user.Password == "" {
+		identities, err := s.identityRepo.ListByUser(userID)
+		if err != nil {
+			return err
+		}
+		if len(identities) <= 1 {
+			s.logAudit(&userID, "identity_unlinked", "failed", ip, userAgent, ErrLastSignInMethod.Error(), provider)
+			return ErrLastSignInMethod
+		}
+	}
+
+	if err := s.identityRepo.Unlink(userID, provider); err != nil {
+		return err
+	}
+	s.logAudit(&userID, "identity_unlinked", "success", ip, userAgent, "", provider)
+	return nil
+}
+
+func (s *oauthService) logAudit(userID *uuid.UUID, eventType, status, ip, userAgent, errorMsg, provider string) {
+	encoded, _ := json.Marshal(map[string]string{"provider": provider})
+	metadata := string(encoded)
+
+	entry := &models.AuditLog{
+		UserID:      userID,
+		EventType:   eventType,
+		EventStatus: status,
+		Metadata:    &metadata,
+	}
+	if ip != "" {
+		entry.IPAddress = &ip
+	}
+	if userAgent != "" {
+		entry.UserAgent = &userAgent
+	}
+	if errorMsg != "" {
+		entry.ErrorMessage = &errorMsg
+	}
+
+	s.auditRepo.Create(entry)
+}
+
+func identityResponse(identity models.UserIdentity) models.IdentityResponse {
+	return models.IdentityResponse{
+		Provider:   identity.Provider,
+		Email:      identity.Email,
+		LinkedAt:   identity.CreatedAt,
+		LastUsedAt: identity.LastUsedAt,
+	}
+}
+
+func randomHex(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// Helper function
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,558 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/config"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	oidcClientID     = "test-client"
+	oidcClientSecret = "test-secret"
+	oidcRedirectURL  = "https://app.example.com/auth/callback"
+	oidcKeyID        = "idp-key-1"
+)
+
+// fakeOIDC is an OpenID provider serving discovery, a JWKS and a token
+// endpoint that enforces PKCE
+type fakeOIDC struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	signer *rsa.PrivateKey // signs ID tokens; a key other than key fails verification
+	grants map[string]oidcGrant
+
+	// discovery edits the discovery document before it is served
+	discovery func(doc map[string]string)
+}
+
+// oidcGrant is an authorization the user consented to, keyed by its code
+type oidcGrant struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newFakeOIDC(t *testing.T) *fakeOIDC {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeOIDC{t: t, key: key, signer: key, grants: make(map[string]oidcGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", f.serveDiscovery)
+	mux.HandleFunc("/jwks", f.serveJWKS)
+	mux.HandleFunc("/token", f.serveToken)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeOIDC) serveDiscovery(w http.ResponseWriter, r *http.Request) {
+	doc := map[string]string{
+		"issuer":                 f.server.URL,
+		"authorization_endpoint": f.server.URL + "/authorize",
+		"token_endpoint":         f.server.URL + "/token",
+		"jwks_uri":               f.server.URL + "/jwks",
+	}
+	if f.discovery != nil {
+		f.discovery(doc)
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (f *fakeOIDC) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	key, err := jwks.NewKey(oidcKeyID, &f.key.PublicKey)
+	if err != nil {
+		f.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(jwks.Set{Keys: []jwks.Key{key}})
+}
+
+func (f *fakeOIDC) serveToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+	if clientID != oidcClientID || clientSecret != oidcClientSecret {
+		tokenError(w, "invalid_client")
+		return
+	}
+	if r.FormValue("grant_type") != "authorization_code" || r.FormValue("redirect_uri") != oidcRedirectURL {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	f.mu.Lock()
+	grant, ok := f.grants[r.FormValue("code")]
+	f.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	f.mu.Lock()
+	delete(f.grants, r.FormValue("code"))
+	f.mu.Unlock()
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            f.server.URL,
+		"aud":            oidcClientID,
+		"sub":            "subject-1",
+		"email":          "oidc-user@example.com",
+		"email_verified": true,
+		"name":           "OIDC User",
+		"nonce":          grant.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+	for name, value := range grant.claims {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = oidcKeyID
+	idToken, err := token.SignedString(f.signer)
+	if err != nil {
+		f.t.Error(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// authorize plays the user consenting on the authorization URL and returns
+// the code sent back to the redirect URL. claims override the ID token's;
+// a nil value removes the claim.
+func (f *fakeOIDC) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != f.server.URL+"/authorize" {
+		t.Fatalf("auth URL points at %s", got)
+	}
+	if query.Get("client_id") != oidcClientID || query.Get("redirect_uri") != oidcRedirectURL {
+		t.Fatalf("auth URL has the wrong client: %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("auth URL has no S256 PKCE challenge: %s", authURL)
+	}
+	if query.Get("nonce") == "" || query.Get("state") == "" {
+		t.Fatalf("auth URL has no nonce or state: %s", authURL)
+	}
+
+	code := uuid.New().String()
+	f.mu.Lock()
+	f.grants[code] = oidcGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), claims: claims}
+	f.mu.Unlock()
+	return code
+}
+
+type oauthEnv struct {
+	*testEnv
+	oauth      OAuthService
+	idp        *fakeOIDC
+	identities *fakeIdentityRepo
+}
+
+// newOAuthEnv wires an OAuthService with the providers "testidp" and
+// "otheridp", both backed by the same fake issuer
+func newOAuthEnv(t *testing.T) *oauthEnv {
+	t.Helper()
+	env := newTestEnv(t)
+	idp := newFakeOIDC(t)
+	identities := &fakeIdentityRepo{users: env.users}
+
+	var providers []OAuthProvider
+	for _, name := range []string{"testidp", "otheridp"} {
+		providers = append(providers, NewOIDCProvider(oidcProviderConfig(name, idp.server.URL)))
+	}
+
+	oauth := NewOAuthService(env.svc.config, providers, env.users, fakeRoleRepo{}, env.audit, identities, env.svc, env.svc.redisClient, nil)
+	return &oauthEnv{testEnv: env, oauth: oauth, idp: idp, identities: identities}
+}
+
+func oidcProviderConfig(name, issuer string) config.OAuthProviderConfig {
+	return config.OAuthProviderConfig{
+		Name:         name,
+		DisplayName:  strings.ToUpper(name),
+		Issuer:       issuer,
+		ClientID:     oidcClientID,
+		ClientSecret: oidcClientSecret,
+		RedirectURL:  oidcRedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// complete runs the redirect flow against provider and returns the result
+func (e *oauthEnv) complete(t *testing.T, provider string, linkUserID *uuid.UUID, claims jwt.MapClaims) (*OAuthResult, error) {
+	t.Helper()
+	start, err := e.oauth.AuthURL(provider, linkUserID)
+	if err != nil {
+		t.Fatalf("AuthURL: %v", err)
+	}
+	code := e.idp.authorize(t, start.URL, claims)
+	return e.oauth.Complete(provider, start.State, code, tabIP, tabAgent)
+}
+
+func (e *oauthEnv) signIn(t *testing.T, claims jwt.MapClaims) *models.AuthResponse {
+	t.Helper()
+	result, err := e.complete(t, "testidp", nil, claims)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if result.Auth == nil {
+		t.Fatal("sign-in returned no auth response")
+	}
+	return result.Auth
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(doc map[string]string)
+		wantErr bool
+	}{
+		{name: "valid"},
+		{name: "other issuer", edit: func(doc map[string]string) { doc["issuer"] = "https://evil.example.com" }, wantErr: true},
+		{name: "no token endpoint", edit: func(doc map[string]string) { delete(doc, "token_endpoint") }, wantErr: true},
+		{name: "no jwks", edit: func(doc map[string]string) { delete(doc, "jwks_uri") }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newFakeOIDC(t)
+			idp.discovery = tt.edit
+			provider := NewOIDCProvider(oidcProviderConfig("testidp", idp.server.URL))
+
+			authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") {
+				t.Errorf("auth URL %s does not use the discovered endpoint", authURL)
+			}
+		})
+	}
+}
+
+func TestOIDCDiscoveryRetriesAfterFailure(t *testing.T) {
+	idp := newFakeOIDC(t)
+	idp.discovery = func(doc map[string]string) { doc["issuer"] = "https://evil.example.com" }
+	provider := NewOIDCProvider(oidcProviderConfig("testidp", idp.server.URL))
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Fatal("discovery with the wrong issuer succeeded")
+	}
+	idp.discovery = nil
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err != nil {
+		t.Errorf("discovery was not retried: %v", err)
+	}
+}
+
+func TestOAuthSignInCreatesUser(t *testing.T) {
+	env := newOAuthEnv(t)
+
+	resp := env.signIn(t, nil)
+	if !resp.Success || resp.Data.AccessToken == "" || resp.Data.RefreshToken == "" {
+		t.Fatalf("sign-in failed: %s", errorCode(resp))
+	}
+	user, err := env.users.FindByEmail("oidc-user@example.com")
+	if err != nil {
+		t.Fatalf("no user created: %v", err)
+	}
+	if !user.IsVerified || user.Password != nil {
+		t.Errorf("new user should be verified and password-less: %+v", user)
+	}
+	if user.LastLoginAt == nil {
+		t.Error("login info not updated")
+	}
+	if resp.Data.UserID != user.ID.String() || resp.Data.Role != "student" {
+		t.Errorf("tokens issued for %s/%s", resp.Data.UserID, resp.Data.Role)
+	}
+
+	// The session is a normal refresh token family
+	if refreshed := refresh(t, env.testEnv, resp.Data.RefreshToken, tabIP, tabAgent); !refreshed.Success {
+		t.Errorf("refresh of an OAuth session failed: %s", errorCode(refreshed))
+	}
+
+	// Signing in again finds the same account through the identity
+	again := env.signIn(t, jwt.MapClaims{"email": "renamed@example.com"})
+	if !again.Success || again.Data.UserID != user.ID.String() {
+		t.Errorf("second sign-in went to %s, want %s", again.Data.UserID, user.ID)
+	}
+	if entries := env.audit.events("oauth_login"); len(entries) != 2 {
+		t.Errorf("%d oauth_login audit entries, want 2", len(entries))
+	}
+}
+
+func TestOAuthSignInExistingEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		verified bool
+		wantCode string
+	}{
+		{name: "verified email links", verified: true},
+		{name: "unverified email is refused", verified: false, wantCode: "ACCOUNT_EXISTS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOAuthEnv(t)
+			user := env.addUser(t, "oidc-user@example.com")
+
+			resp := env.signIn(t, jwt.MapClaims{"email_verified": tt.verified})
+			if code := errorCode(resp); code != tt.wantCode {
+				t.Fatalf("got %q, want %q", code, tt.wantCode)
+			}
+			identities, _ := env.identities.ListByUser(user.ID)
+			if linked := len(identities) == 1; linked != tt.verified {
+				t.Errorf("identity linked = %v, want %v", linked, tt.verified)
+			}
+			if tt.verified && resp.Data.UserID != user.ID.String() {
+				t.Errorf("signed in as %s, want %s", resp.Data.UserID, user.ID)
+			}
+		})
+	}
+}
+
+func TestOAuthPKCE(t *testing.T) {
+	env := newOAuthEnv(t)
+	start, err := env.oauth.AuthURL("testidp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := env.idp.authorize(t, start.URL, nil)
+
+	// The code is useless without the verifier stored with the state
+	provider := NewOIDCProvider(oidcProviderConfig("testidp", env.idp.server.URL))
+	nonce, _ := url.Parse(start.URL)
+	if _, err := provider.Exchange(context.Background(), code, nonce.Query().Get("nonce"), "wrong-verifier"); err == nil {
+		t.Fatal("exchange with the wrong verifier succeeded")
+	}
+
+	if _, err := env.oauth.Complete("testidp", start.State, code, tabIP, tabAgent); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	// A state is redeemed once, and only for the provider it was issued for
+	if _, err := env.oauth.Complete("testidp", start.State, code, tabIP, tabAgent); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("reused state got %v, want ErrInvalidOAuthState", err)
+	}
+	other, _ := env.oauth.AuthURL("testidp", nil)
+	if _, err := env.oauth.Complete("otheridp", other.State, "code", tabIP, tabAgent); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Errorf("state of another provider got %v, want ErrInvalidOAuthState", err)
+	}
+}
+
+func TestOAuthIDTokenChecks(t *testing.T) {
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		badSigner bool
+	}{
+		{name: "signature", badSigner: true},
+		{name: "audience", claims: jwt.MapClaims{"aud": "another-client"}},
+		{name: "issuer", claims: jwt.MapClaims{"iss": "https://evil.example.com"}},
+		{name: "nonce", claims: jwt.MapClaims{"nonce": "replayed-nonce"}},
+		{name: "expired", claims: jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{name: "no expiry", claims: jwt.MapClaims{"exp": nil}},
+		{name: "no subject", claims: jwt.MapClaims{"sub": nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOAuthEnv(t)
+			if tt.badSigner {
+				other, err := rsa.GenerateKey(rand.Reader, 2048)
+				if err != nil {
+					t.Fatal(err)
+				}
+				env.idp.signer = other
+			}
+
+			_, err := env.complete(t, "testidp", nil, tt.claims)
+			if !errors.Is(err, ErrOAuthExchange) {
+				t.Fatalf("got %v, want ErrOAuthExchange", err)
+			}
+			if _, err := env.users.FindByEmail("oidc-user@example.com"); err == nil {
+				t.Error("a user was created from a rejected ID token")
+			}
+			if entries := env.audit.events("oauth_login"); len(entries) != 1 || entries[0].EventStatus != "failed" {
+				t.Errorf("want one failed oauth_login audit entry, got %+v", entries)
+			}
+		})
+	}
+}
+
+func TestOAuthSignInChecksAccount(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(user *models.User)
+		wantCode string
+	}{
+		{name: "inactive", change: func(u *models.User) { u.IsActive = false }, wantCode: "ACCOUNT_INACTIVE"},
+		{name: "locked", change: func(u *models.User) {
+			until := time.Now().Add(time.Hour)
+			u.LockedUntil = &until
+			u.FailedLoginAttempts = 5
+		}, wantCode: "ACCOUNT_LOCKED"},
+		{name: "forced reset", change: func(u *models.User) { u.PasswordResetRequired = true }, wantCode: "PASSWORD_RESET_REQUIRED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOAuthEnv(t)
+			user := env.addUser(t, "oidc-user@example.com")
+			env.users.update(user.ID, tt.change)
+
+			resp := env.signIn(t, nil)
+			if code := errorCode(resp); code != tt.wantCode {
+				t.Fatalf("got %q, want %q", code, tt.wantCode)
+			}
+			if resp.Data != nil {
+				t.Error("tokens issued to a blocked account")
+			}
+			if n := len(env.tokens.tokens); n != 0 {
+				t.Errorf("%d refresh tokens created", n)
+			}
+		})
+	}
+}
+
+func TestOAuthSignInKeepsFailuresUntilMFA(t *testing.T) {
+	env := newOAuthEnv(t)
+	user := env.addUser(t, "oidc-user@example.com")
+	secret := env.enableMFA(t, user)
+	env.users.update(user.ID, func(u *models.User) { u.FailedLoginAttempts = 3 })
+
+	resp := env.signIn(t, nil)
+	if !resp.Success || !resp.Data.MFARequired || resp.Data.AccessToken != "" {
+		t.Fatalf("sign-in did not stop at the second factor: %+v", resp.Data)
+	}
+	if n := env.users.get(user.ID).FailedLoginAttempts; n != 3 {
+		t.Errorf("failed attempts = %d before the challenge was passed, want 3", n)
+	}
+
+	verified := verifyChallenge(t, env.testEnv, resp.Data.MFAToken, totpNow(t, secret))
+	if !verified.Success {
+		t.Fatalf("challenge failed: %s", errorCode(verified))
+	}
+	if n := env.users.get(user.ID).FailedLoginAttempts; n != 0 {
+		t.Errorf("failed attempts = %d after signing in, want 0", n)
+	}
+}
+
+func TestOAuthLinkAndUnlink(t *testing.T) {
+	env := newOAuthEnv(t)
+	user := env.addUser(t, "owner@example.com")
+
+	// The provider account has another email; linking does not depend on it
+	result, err := env.complete(t, "testidp", &user.ID, nil)
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if result.Linked == nil || result.Linked.Provider != "testidp" {
+		t.Fatalf("link returned %+v", result)
+	}
+	if identities, _ := env.oauth.ListIdentities(user.ID); len(identities) != 1 {
+		t.Fatalf("%d identities after linking, want 1", len(identities))
+	}
+
+	// The linked provider now signs in to the account
+	resp := env.signIn(t, nil)
+	if !resp.Success || resp.Data.UserID != user.ID.String() {
+		t.Fatalf("sign-in through the linked provider went to %+v", resp.Data)
+	}
+
+	// The same provider account cannot be linked to a second user
+	other := env.addUser(t, "other@example.com")
+	if _, err := env.complete(t, "testidp", &other.ID, nil); !errors.Is(err, repository.ErrIdentityLinkedElsewhere) {
+		t.Errorf("linking a taken identity got %v, want ErrIdentityLinkedElsewhere", err)
+	}
+
+	// The password remains, so the only provider can go
+	if err := env.oauth.UnlinkIdentity(user.ID, "testidp", tabIP, tabAgent); err != nil {
+		t.Fatalf("UnlinkIdentity: %v", err)
+	}
+	if identities, _ := env.oauth.ListIdentities(user.ID); len(identities) != 0 {
+		t.Errorf("%d identities after unlinking, want 0", len(identities))
+	}
+	if err := env.oauth.UnlinkIdentity(user.ID, "testidp", tabIP, tabAgent); !errors.Is(err, repository.ErrIdentityNotFound) {
+		t.Errorf("unlinking twice got %v, want ErrIdentityNotFound", err)
+	}
+	if entries := env.audit.events("identity_unlinked"); len(entries) != 1 {
+		t.Errorf("%d identity_unlinked audit entries, want 1", len(entries))
+	}
+}
+
+func TestOAuthUnlinkLastSignInMethod(t *testing.T) {
+	env := newOAuthEnv(t)
+
+	// An account created by the provider has no password
+	resp := env.signIn(t, nil)
+	if !resp.Success {
+		t.Fatalf("sign-in failed: %s", errorCode(resp))
+	}
+	userID := uuid.MustParse(resp.Data.UserID)
+
+	if _, err := env.complete(t, "otheridp", &userID, jwt.MapClaims{"sub": "subject-2"}); err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	if err := env.oauth.UnlinkIdentity(userID, "testidp", tabIP, tabAgent); err != nil {
+		t.Fatalf("unlinking one of two providers: %v", err)
+	}
+
+	err := env.oauth.UnlinkIdentity(userID, "otheridp", tabIP, tabAgent)
+	if !errors.Is(err, ErrLastSignInMethod) {
+		t.Fatalf("unlinking the last provider got %v, want ErrLastSignInMethod", err)
+	}
+	if identities, _ := env.oauth.ListIdentities(userID); len(identities) != 1 {
+		t.Errorf("%d identities left, want the last one kept", len(identities))
+	}
+	entries := env.audit.events("identity_unlinked")
+	if len(entries) != 2 || entries[1].EventStatus != "failed" {
+		t.Errorf("want a failed identity_unlinked audit entry, got %+v", entries)
+	}
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/breached"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/config"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password policy violation codes, returned in the error details
+const (
+	PasswordTooShort          = "PASSWORD_TOO_SHORT"
+	PasswordTooLong           = "PASSWORD_TOO_LONG"
+	PasswordTooFewCharClasses = "PASSWORD_TOO_FEW_CHAR_CLASSES"
+	PasswordContainsEmail     = "PASSWORD_CONTAINS_EMAIL"
+	PasswordContainsName      = "PASSWORD_CONTAINS_NAME"
+	PasswordBreached          = "PASSWORD_BREACHED"
+	PasswordReused            = "PASSWORD_REUSED"
+)
+
+// maxPasswordBytes is where bcrypt stops reading; longer passwords would be
+// silently truncated
+const maxPasswordBytes = 72
+
+// minPasswordTokenLength is the shortest part of an email or name that a
+// password may not contain; shorter parts match too many passwords by chance
+const minPasswordTokenLength = 3
+
+// PasswordViolation is one rule a new password breaks
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a new password breaks
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// passwordPolicy holds the rules from config shared by registration, change
+// and both reset flows
+type passwordPolicy struct {
+	minLength      int
+	minCharClasses int
+	historySize    int
+	breached       *breached.Filter // nil when the breached check is off
+}
+
+func newPasswordPolicy(cfg *config.Config) (*passwordPolicy, error) {
+	policy := &passwordPolicy{
+		minLength:      cfg.PasswordMinLength,
+		minCharClasses: cfg.PasswordMinCharClasses,
+		historySize:    cfg.PasswordHistorySize,
+	}
+	if !cfg.PasswordBreachedCheck {
+		return policy, nil
+	}
+
+	var err error
+	if cfg.PasswordBreachedFilter != "" {
+		policy.breached, err = breached.Load(cfg.PasswordBreachedFilter)
+	} else {
+		policy.breached, err = breached.Bundled()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// check applies the rules that need only the password and whose it is; name
+// may be empty when it is not known
+func (p *passwordPolicy) check(password, email, name string) []PasswordViolation {
+	var violations []PasswordViolation
+
+	if utf8.RuneCountInString(password) < p.minLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.minLength),
+		})
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooLong,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", maxPasswordBytes),
+		})
+	}
+	if countCharClasses(password) < p.minCharClasses {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooFewCharClasses,
+			Message: fmt.Sprintf("Password must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.minCharClasses),
+		})
+	}
+
+	lower := strings.ToLower(password)
+	if containsAny(lower, emailTokens(email)) {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordContainsEmail,
+			Message: "Password must not contain your email address",
+		})
+	}
+	if containsAny(lower, nameTokens(name)) {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordContainsName,
+			Message: "Password must not contain your name",
+		})
+	}
+
+	// Lists are mostly lowercase, so "Password1" counts as "password1"
+	if p.breached != nil && (p.breached.Contains(password) || p.breached.Contains(lower)) {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordBreached,
+			Message: "Password appears in a list of breached passwords",
+		})
+	}
+
+	return violations
+}
+
+// countCharClasses counts which of lowercase, uppercase, digits and symbols
+// the password uses
+func countCharClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
+
+// emailTokens returns the address, its local part and the local part's
+// words ("nguyen.van.a" gives "nguyen" and "van")
+func emailTokens(email string) []string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	local, _, found := strings.Cut(email, "@")
+	if !found {
+		return nil
+	}
+	tokens := []string{email, local}
+	return append(tokens, strings.FieldsFunc(local, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})...)
+}
+
+func nameTokens(name string) []string {
+	return strings.Fields(strings.ToLower(name))
+}
+
+func containsAny(password string, tokens []string) bool {
+	for _, token := range tokens {
+		if utf8.RuneCountInString(token) >= minPasswordTokenLength && strings.Contains(password, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNewPassword checks a new password against the policy and, for an
+// existing user, against their recent passwords. Returns *PasswordPolicyError
+// listing every violation.
+func (s *authService) validateNewPassword(user *models.User, email, name, password string) error {
+	violations := s.passwordPolicy.check(password, email, name)
+
+	// Only compare with old hashes once the rest passes, bcrypt is slow
+	if user != nil && len(violations) == 0 {
+		reused, err := s.isRecentPassword(user, password)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, PasswordViolation{
+				Code:    PasswordReused,
+				Message: fmt.Sprintf("Password must differ from your last %d passwords", s.passwordPolicy.historySize),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// profileName returns the name on the user's profile for the name check.
+// It is best effort: when User Service is unreachable only the email is checked.
+func (s *authService) profileName(userID uuid.UUID) string {
+	if s.userServiceClient == nil {
+		return ""
+	}
+	name, err := s.userServiceClient.GetFullName(userID.String())
+	if err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to get profile name for %s: %v", userID, err)
+		return ""
+	}
+	return name
+}
+
+// isRecentPassword reports whether password matches the current password or
+// one of the last PasswordHistorySize ones
+func (s *authService) isRecentPassword(user *models.User, password string) (bool, error) {
+	if s.passwordPolicy.historySize <= 0 {
+		return false, nil
+	}
+
+	hashes, err := s.passwordHistoryRepo.ListRecent(user.ID, s.passwordPolicy.historySize)
+	if err != nil {
+		return false, err
+	}
+	// The current password may predate the history
+	if user.Password != nil {
+		hashes = append(hashes, *user.Password)
+	}
+
+	for _, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setPassword hashes and stores a validated password and records it in the
+// password history
+func (s *authService) setPassword(user *models.User, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), s.config.BcryptRounds)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	hash := string(hashedPassword)
+	user.Password = &hash
+	if err := s.userRepo.Update(user); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	s.recordPasswordHistory(user.ID, hash)
+	return nil
+}
+
+// recordPasswordHistory keeps the hash for reuse checks; a failure only
+// weakens the next check, so it is logged rather than returned
+func (s *authService) recordPasswordHistory(userID uuid.UUID, hash string) {
+	if s.passwordPolicy.historySize <= 0 {
+		return
+	}
+	if err := s.passwordHistoryRepo.Add(userID, hash, s.passwordPolicy.historySize); err != nil {
+		log.Printf("[Auth-Service] WARNING: Failed to record password history for user %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/shared/pkg/client"
+)
+
+// withUserService points the service at a fake User Service that answers
+// profile lookups with status and body
+func (e *testEnv) withUserService(t *testing.T, status int, body string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/v1/user/internal/users/") || !strings.HasSuffix(r.URL.Path, "/profile") {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	e.svc.userServiceClient = client.NewUserServiceClient(server.URL, "test-key")
+}
+
+func TestChangePasswordChecksProfileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantRefused bool
+	}{
+		{name: "full name", status: http.StatusOK, body: `{"success":true,"data":{"full_name":"Thanh Nguyen"}}`, wantRefused: true},
+		{name: "first and last name", status: http.StatusOK, body: `{"success":true,"data":{"first_name":"Thanh","last_name":"Nguyen"}}`, wantRefused: true},
+		{name: "no name", status: http.StatusOK, body: `{"success":true,"data":{}}`},
+		{name: "user service down", status: http.StatusServiceUnavailable, body: `{"success":false}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			env.withUserService(t, tt.status, tt.body)
+			user := env.addUser(t, "thanh@example.com")
+
+			err := env.svc.ChangePassword(user.ID, &models.ChangePasswordRequest{
+				OldPassword: testPassword,
+				NewPassword: "Nguyen-Lotus-2024",
+			})
+
+			var policyErr *PasswordPolicyError
+			refused := errors.As(err, &policyErr) && hasViolation(policyErr, PasswordContainsName)
+			if refused != tt.wantRefused {
+				t.Fatalf("got %v, want refused for the name %v", err, tt.wantRefused)
+			}
+			if !tt.wantRefused && err != nil {
+				t.Fatalf("ChangePassword: %v", err)
+			}
+		})
+	}
+}
+
+func hasViolation(err *PasswordPolicyError, code string) bool {
+	for _, violation := range err.Violations {
+		if violation.Code == code {
+			return true
+		}
+	}
+	return false
+}
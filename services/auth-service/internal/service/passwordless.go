@@ -0,0 +1,235 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+)
+
+const (
+	// loginCodeMaxAttempts wrong codes invalidate the pending code
+	loginCodeMaxAttempts = 5
+	// loginCodeResendInterval throttles emails to the same address
+	loginCodeResendInterval = time.Minute
+)
+
+// RequestPasswordlessLogin emails a one-time code and magic link. Like
+// ForgotPassword it never reveals whether the email has an account.
+func (s *authService) RequestPasswordlessLogin(req *models.PasswordlessLoginRequest, ip, userAgent string) error {
+	user, err := s.userRepo.FindByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if !user.IsActive {
+		s.logAudit(&user.ID, "passwordless_requested", "failed", ip, userAgent, "account inactive")
+		return nil
+	}
+
+	pending, err := s.loginCodeRepo.FindPendingByUserID(user.ID)
+	if err != nil && !errors.Is(err, repository.ErrLoginCodeNotFound) {
+		return err
+	}
+	if pending != nil && time.Since(pending.CreatedAt) < loginCodeResendInterval {
+		s.logAudit(&user.ID, "passwordless_requested", "failed", ip, userAgent, "requested again too soon")
+		return nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate login token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+	code := Generate6DigitCode()
+	expiry := s.loginCodeExpiry()
+
+	// Requesting a new code invalidates the previous one
+	err = s.loginCodeRepo.Replace(&models.LoginCode{
+		UserID:    user.ID,
+		CodeHash:  s.hashToken(code),
+		TokenHash: s.hashToken(token),
+		ExpiresAt: time.Now().Add(expiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.config.FrontendURL, "/") + "/auth/magic-link?token=" + url.QueryEscape(token)
+	if err := s.emailService.SendLoginCodeEmail(user.ID, user.Email, code, link, expiry); err != nil {
+		log.Printf("[Auth-Service] ERROR: Failed to queue login code to %s: %v", user.Email, err)
+	}
+
+	s.logAudit(&user.ID, "passwordless_requested", "success", ip, userAgent, "")
+	return nil
+}
+
+// PasswordlessLogin exchanges an emailed code or magic link token for tokens.
+// The code is the first factor, so users with 2FA still get a challenge. It
+// works for OAuth-only accounts that have no password.
+func (s *authService) PasswordlessLogin(req *models.VerifyPasswordlessLoginRequest, ip, userAgent string) (*models.AuthResponse, error) {
+	var code *models.LoginCode
+	var user *models.User
+	var err error
+
+	if req.Token != "" {
+		code, err = s.loginCodeRepo.FindPendingByTokenHash(s.hashToken(req.Token))
+		if errors.Is(err, repository.ErrLoginCodeNotFound) {
+			s.logAudit(nil, "passwordless_login", "failed", ip, userAgent, "unknown or expired magic link")
+			return invalidLoginCodeResponse(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if user, err = s.userRepo.FindByID(code.UserID); err != nil {
+			return nil, fmt.Errorf("failed to find user: %w", err)
+		}
+	} else {
+		user, err = s.userRepo.FindByEmail(req.Email)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			s.logAudit(nil, "passwordless_login", "failed", ip, userAgent, fmt.Sprintf("user not found: %s", req.Email))
+			return invalidLoginCodeResponse(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to find user: %w", err)
+		}
+
+		code, err = s.loginCodeRepo.FindPendingByUserID(user.ID)
+		if errors.Is(err, repository.ErrLoginCodeNotFound) {
+			s.logAudit(&user.ID, "passwordless_login", "failed", ip, userAgent, "no pending login code")
+			return invalidLoginCodeResponse(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Every attempt counts, so concurrent guesses cannot exceed the limit
+		attempts, err := s.loginCodeRepo.IncrementAttempts(code.ID)
+		if err != nil {
+			return nil, err
+		}
+		if attempts > loginCodeMaxAttempts {
+			s.loginCodeRepo.MarkUsed(code.ID)
+			s.logAudit(&user.ID, "passwordless_login", "failed", ip, userAgent, "too many attempts")
+			return invalidLoginCodeResponse(), nil
+		}
+		if subtle.ConstantTimeCompare([]byte(s.hashToken(req.Code)), []byte(code.CodeHash)) != 1 {
+			if attempts == loginCodeMaxAttempts {
+				s.loginCodeRepo.MarkUsed(code.ID)
+			}
+			s.logAudit(&user.ID, "passwordless_login", "failed", ip, userAgent, "invalid code")
+			return invalidLoginCodeResponse(), nil
+		}
+	}
+
+	isLocked, err := s.userRepo.IsAccountLocked(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check account lock: %w", err)
+	}
+	if isLocked {
+		s.logAudit(&user.ID, "passwordless_login", "failed", ip, userAgent, "account locked")
+		return loginErrorResponse("ACCOUNT_LOCKED", "Account is locked due to too many failed login attempts. Please try again later."), nil
+	}
+	if !user.IsActive {
+		s.logAudit(&user.ID, "passwordless_login", "failed", ip, userAgent, "account inactive")
+		return loginErrorResponse("ACCOUNT_INACTIVE", "Account is inactive"), nil
+	}
+	if user.PasswordResetRequired {
+		s.logAudit(&user.ID, "passwordless_login", "failed", ip, userAgent, "password reset required")
+		return loginErrorResponse("PASSWORD_RESET_REQUIRED", "You must reset your password before signing in"), nil
+	}
+
+	// Single use: of concurrent requests with the same code only one gets here
+	used, err := s.loginCodeRepo.MarkUsed(code.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		s.logAudit(&user.ID, "passwordless_login", "failed", ip, userAgent, "code already used")
+		return invalidLoginCodeResponse(), nil
+	}
+
+	// Receiving the code proves the user controls the address
+	if !user.IsVerified {
+		now := time.Now()
+		user.IsVerified = true
+		user.EmailVerifiedAt = &now
+		if err := s.userRepo.Update(user); err != nil {
+			log.Printf("[Auth-Service] Failed to mark %s as verified: %v", user.ID, err)
+		}
+	}
+
+	roles, err := s.roleRepo.FindByUserID(user.ID)
+	if err != nil || len(roles) == 0 {
+		return nil, fmt.Errorf("failed to find user roles: %w", err)
+	}
+	roleName := roles[0].Name
+
+	// The code proves access to the mailbox, so no step-up; still warn about
+	// a new device or an unusual location
+	risk := s.assessLogin(user.ID, ip, userAgent, req.DeviceInfo)
+	s.alertLogin(user, risk, ip, userAgent)
+
+	challenge, err := s.BeginMFAChallenge(user, roles, ip, userAgent, req.DeviceInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start mfa challenge: %w", err)
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	s.userRepo.UpdateLoginInfo(user.ID, ip)
+
+	accessToken, refreshToken, expiresIn, err := s.generateTokens(user.ID, user.Email, roleName, ip, userAgent, req.DeviceInfo)
+	if err != nil {
+		return nil, err
+	}
+	s.recordLogin(user.ID, risk)
+
+	s.logAudit(&user.ID, "passwordless_login", "success", ip, userAgent, "")
+
+	return &models.AuthResponse{
+		Success: true,
+		Data: &models.AuthData{
+			UserID:       user.ID.String(),
+			Email:        user.Email,
+			Role:         roleName,
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			ExpiresIn:    expiresIn,
+		},
+	}, nil
+}
+
+func (s *authService) loginCodeExpiry() time.Duration {
+	expiry, err := time.ParseDuration(s.config.LoginCodeExpiry)
+	if err != nil || expiry <= 0 {
+		return 10 * time.Minute
+	}
+	return expiry
+}
+
+func invalidLoginCodeResponse() *models.AuthResponse {
+	return loginErrorResponse("INVALID_LOGIN_CODE", "Invalid or expired login code")
+}
+
+func loginErrorResponse(code, message string) *models.AuthResponse {
+	return &models.AuthResponse{
+		Success: false,
+		Error: &models.ErrorData{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
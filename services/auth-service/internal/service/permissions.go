@@ -0,0 +1,205 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRoleNotFound       = repository.ErrRoleNotFound
+	ErrRoleExists         = repository.ErrRoleExists
+	ErrPermissionNotFound = repository.ErrPermissionNotFound
+	ErrPermissionExists   = repository.ErrPermissionExists
+	ErrInvalidName        = errors.New("names may only contain lowercase letters, digits and underscores")
+	ErrBuiltInRole        = errors.New("built-in roles cannot be deleted")
+	// ErrRoleManageLockout prevents admins from removing their own access to these APIs
+	ErrRoleManageLockout = errors.New("the admin role must keep the " + PermissionRoleManage + " permission")
+)
+
+// PermissionRoleManage guards the role and permission admin APIs
+const PermissionRoleManage = "role:manage"
+
+// builtInRoles are referenced by registration and OAuth sign-up
+var builtInRoles = map[string]bool{"student": true, "instructor": true, "admin": true}
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// permissionsForUser resolves the permissions embedded in access tokens
+func (s *authService) permissionsForUser(userID uuid.UUID) ([]string, error) {
+	permissions, err := s.roleRepo.FindPermissionsByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (s *authService) GetUserPermissions(userID uuid.UUID) (*models.UserPermissionsResponse, error) {
+	roles, err := s.roleRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := s.permissionsForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserPermissionsResponse{
+		UserID:      userID.String(),
+		Roles:       roleNames(roles),
+		Permissions: permissions,
+	}, nil
+}
+
+func (s *authService) ListRoles() ([]models.RoleResponse, error) {
+	roles, err := s.roleRepo.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+	grants, err := s.roleRepo.ListRolePermissions()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		responses = append(responses, roleResponse(role, grants[role.ID]))
+	}
+	return responses, nil
+}
+
+func (s *authService) CreateRole(adminID uuid.UUID, req *models.CreateRoleRequest, ip, userAgent string) (*models.RoleResponse, error) {
+	if !namePattern.MatchString(req.Name) {
+		return nil, ErrInvalidName
+	}
+	permissions := uniquePermissions(req.Permissions)
+
+	role := &models.Role{
+		Name:        req.Name,
+		DisplayName: req.DisplayName,
+		Description: req.Description,
+	}
+	if err := s.roleRepo.CreateRole(role); err != nil {
+		s.logAudit(&adminID, "role_created", "failed", ip, userAgent, err.Error())
+		return nil, err
+	}
+
+	if len(permissions) > 0 {
+		if err := s.roleRepo.SetRolePermissions(role.ID, permissions); err != nil {
+			// Do not leave a half-configured role behind
+			_ = s.roleRepo.DeleteRole(role.Name)
+			s.logAudit(&adminID, "role_created", "failed", ip, userAgent, err.Error())
+			return nil, err
+		}
+	}
+
+	s.logAudit(&adminID, "role_created", "success", ip, userAgent,
+		fmt.Sprintf("role=%s permissions=%s", role.Name, strings.Join(permissions, ",")))
+
+	response := roleResponse(*role, permissions)
+	return &response, nil
+}
+
+func (s *authService) DeleteRole(adminID uuid.UUID, roleName, ip, userAgent string) error {
+	if builtInRoles[roleName] {
+		return ErrBuiltInRole
+	}
+
+	if err := s.roleRepo.DeleteRole(roleName); err != nil {
+		s.logAudit(&adminID, "role_deleted", "failed", ip, userAgent, err.Error())
+		return err
+	}
+
+	s.logAudit(&adminID, "role_deleted", "success", ip, userAgent, "role="+roleName)
+	return nil
+}
+
+func (s *authService) SetRolePermissions(adminID uuid.UUID, roleName string, permissions []string, ip, userAgent string) (*models.RoleResponse, error) {
+	permissions = uniquePermissions(permissions)
+	if roleName == "admin" && !containsString(permissions, PermissionRoleManage) {
+		return nil, ErrRoleManageLockout
+	}
+
+	role, err := s.roleRepo.FindByName(roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.SetRolePermissions(role.ID, permissions); err != nil {
+		s.logAudit(&adminID, "role_permissions_updated", "failed", ip, userAgent, err.Error())
+		return nil, err
+	}
+
+	s.logAudit(&adminID, "role_permissions_updated", "success", ip, userAgent,
+		fmt.Sprintf("role=%s permissions=%s", roleName, strings.Join(permissions, ",")))
+
+	response := roleResponse(*role, permissions)
+	return &response, nil
+}
+
+func (s *authService) ListPermissions() ([]models.Permission, error) {
+	return s.roleRepo.ListPermissions()
+}
+
+func (s *authService) CreatePermission(adminID uuid.UUID, req *models.CreatePermissionRequest, ip, userAgent string) (*models.Permission, error) {
+	if !namePattern.MatchString(req.Resource) || !namePattern.MatchString(req.Action) {
+		return nil, ErrInvalidName
+	}
+
+	permission := &models.Permission{
+		Name:        req.Resource + ":" + req.Action,
+		Resource:    req.Resource,
+		Action:      req.Action,
+		Description: req.Description,
+	}
+	if err := s.roleRepo.CreatePermission(permission); err != nil {
+		s.logAudit(&adminID, "permission_created", "failed", ip, userAgent, err.Error())
+		return nil, err
+	}
+
+	s.logAudit(&adminID, "permission_created", "success", ip, userAgent, "permission="+permission.Name)
+	return permission, nil
+}
+
+func roleResponse(role models.Role, permissions []string) models.RoleResponse {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return models.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		DisplayName: role.DisplayName,
+		Description: role.Description,
+		RequireMFA:  role.RequireMFA,
+		Permissions: permissions,
+	}
+}
+
+// uniquePermissions trims and de-duplicates names, keeping their order
+func uniquePermissions(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		unique = append(unique, name)
+	}
+	return unique
+}
+
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/bisosad1501/DATN/services/auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	tabIP    = "203.0.113.10"
+	tabAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/126.0"
+)
+
+// signIn issues a refresh token to tabIP/tabAgent and returns it
+func signIn(t *testing.T, env *testEnv, user *models.User) string {
+	t.Helper()
+	_, refreshToken, _, err := env.svc.generateTokens(user.ID, user.Email, "student", tabIP, tabAgent, models.DeviceInfo{})
+	if err != nil {
+		t.Fatalf("generateTokens: %v", err)
+	}
+	return refreshToken
+}
+
+func refresh(t *testing.T, env *testEnv, token, ip, userAgent string) *models.AuthResponse {
+	t.Helper()
+	resp, err := env.svc.RefreshToken(&models.RefreshTokenRequest{RefreshToken: token}, ip, userAgent)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	return resp
+}
+
+func errorCode(resp *models.AuthResponse) string {
+	if resp.Error == nil {
+		return ""
+	}
+	return resp.Error.Code
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(t, "rotate@example.com")
+	first := signIn(t, env, user)
+
+	resp := refresh(t, env, first, tabIP, tabAgent)
+	if !resp.Success {
+		t.Fatalf("refresh failed: %s", errorCode(resp))
+	}
+	second := resp.Data.RefreshToken
+	if second == "" || second == first {
+		t.Fatalf("refresh returned %q, want a new token", second)
+	}
+	if resp.Data.AccessToken == "" {
+		t.Error("refresh returned no access token")
+	}
+
+	old, err := env.tokens.FindRefreshTokenByHash(env.svc.hashToken(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.RevokedAt == nil || old.RevokedReason == nil || *old.RevokedReason != repository.RevokeReasonRotated {
+		t.Errorf("old token not revoked as rotated: %+v", old)
+	}
+	if active := env.tokens.active(old.FamilyID); len(active) != 1 || active[0].TokenHash != env.svc.hashToken(second) {
+		t.Errorf("family should hold exactly the new token, got %d active", len(active))
+	}
+
+	// The new token keeps working
+	if resp := refresh(t, env, second, tabIP, tabAgent); !resp.Success {
+		t.Errorf("refresh with the new token failed: %s", errorCode(resp))
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(t, "reuse@example.com")
+	first := signIn(t, env, user)
+
+	resp := refresh(t, env, first, tabIP, tabAgent)
+	if !resp.Success {
+		t.Fatalf("refresh failed: %s", errorCode(resp))
+	}
+	second := resp.Data.RefreshToken
+	familyID := env.tokenFamily(t, first)
+
+	// Someone else replays the spent token
+	resp = refresh(t, env, first, "198.51.100.7", "curl/8.0")
+	if code := errorCode(resp); code != "INVALID_TOKEN" {
+		t.Fatalf("replay got %q, want INVALID_TOKEN", code)
+	}
+
+	if active := env.tokens.active(familyID); len(active) != 0 {
+		t.Errorf("%d tokens left active in the family", len(active))
+	}
+	if resp := refresh(t, env, second, tabIP, tabAgent); resp.Success {
+		t.Error("the token issued after rotation still works")
+	}
+	if entries := env.audit.events("refresh_token_reuse"); len(entries) != 1 || entries[0].EventStatus != "failed" {
+		t.Errorf("want one failed refresh_token_reuse audit entry, got %+v", entries)
+	}
+	if n := env.notifications.count(); n != 1 {
+		t.Errorf("sent %d notifications, want 1", n)
+	}
+	if !env.redis.Exists(revokedSessionKeyPrefix + familyID.String()) {
+		t.Error("access tokens of the session were not revoked")
+	}
+
+	// A second replay finds nothing left to revoke and does not alert again
+	refresh(t, env, first, "198.51.100.7", "curl/8.0")
+	if n := env.notifications.count(); n != 1 {
+		t.Errorf("sent %d notifications after a second replay, want 1", n)
+	}
+}
+
+func TestRefreshTokenReuseGrace(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(t, "tabs@example.com")
+	first := signIn(t, env, user)
+	familyID := env.tokenFamily(t, first)
+
+	if resp := refresh(t, env, first, tabIP, tabAgent); !resp.Success {
+		t.Fatalf("refresh failed: %s", errorCode(resp))
+	}
+
+	// A second tab of the same browser refreshes with the same token
+	resp := refresh(t, env, first, tabIP, tabAgent)
+	if code := errorCode(resp); code != "TOKEN_ALREADY_ROTATED" {
+		t.Fatalf("same-client reuse got %q, want TOKEN_ALREADY_ROTATED", code)
+	}
+	if active := env.tokens.active(familyID); len(active) != 1 {
+		t.Errorf("family has %d active tokens, want the rotated one kept", len(active))
+	}
+	if n := env.notifications.count(); n != 0 {
+		t.Errorf("sent %d notifications for a concurrent refresh", n)
+	}
+
+	// Past the window the same reuse is theft
+	env.tokens.mu.Lock()
+	revokedAt := time.Now().Add(-time.Minute)
+	env.tokens.tokens[env.svc.hashToken(first)].RevokedAt = &revokedAt
+	env.tokens.mu.Unlock()
+
+	resp = refresh(t, env, first, tabIP, tabAgent)
+	if code := errorCode(resp); code != "INVALID_TOKEN" {
+		t.Fatalf("late reuse got %q, want INVALID_TOKEN", code)
+	}
+	if active := env.tokens.active(familyID); len(active) != 0 {
+		t.Errorf("%d tokens left active after a late reuse", len(active))
+	}
+}
+
+func TestRefreshTokenConcurrentRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		ip, agent   string
+		wantCode    string
+		wantRevoked bool
+	}{
+		{name: "same client", ip: tabIP, agent: tabAgent, wantCode: "TOKEN_ALREADY_ROTATED"},
+		{name: "other client", ip: "198.51.100.7", agent: "curl/8.0", wantCode: "INVALID_TOKEN", wantRevoked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.addUser(t, "race@example.com")
+			first := signIn(t, env, user)
+			familyID := env.tokenFamily(t, first)
+
+			// The owner's refresh wins the race between our lookup and rotation
+			var winner *models.AuthResponse
+			env.tokens.beforeRotate = func() {
+				winner = refresh(t, env, first, tabIP, tabAgent)
+			}
+
+			resp := refresh(t, env, first, tt.ip, tt.agent)
+			if winner == nil || !winner.Success {
+				t.Fatal("the concurrent refresh did not succeed")
+			}
+			if code := errorCode(resp); code != tt.wantCode {
+				t.Fatalf("loser got %q, want %q", code, tt.wantCode)
+			}
+
+			revoked := len(env.tokens.active(familyID)) == 0
+			if revoked != tt.wantRevoked {
+				t.Errorf("family revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+			wantAlerts := 0
+			if tt.wantRevoked {
+				wantAlerts = 1
+			}
+			if n := env.notifications.count(); n != wantAlerts {
+				t.Errorf("sent %d notifications, want %d", n, wantAlerts)
+			}
+			if n := len(env.audit.events("refresh_token_reuse")); n != wantAlerts {
+				t.Errorf("%d refresh_token_reuse audit entries, want %d", n, wantAlerts)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenRechecksAccount(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(user *models.User)
+		wantCode string
+	}{
+		{name: "deactivated", change: func(u *models.User) { u.IsActive = false }, wantCode: "ACCOUNT_INACTIVE"},
+		{name: "forced reset", change: func(u *models.User) { u.PasswordResetRequired = true }, wantCode: "PASSWORD_RESET_REQUIRED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			user := env.addUser(t, "recheck@example.com")
+			token := signIn(t, env, user)
+
+			env.users.update(user.ID, tt.change)
+
+			resp := refresh(t, env, token, tabIP, tabAgent)
+			if code := errorCode(resp); code != tt.wantCode {
+				t.Fatalf("got %q, want %q", code, tt.wantCode)
+			}
+			if resp.Data != nil {
+				t.Error("tokens issued to a blocked account")
+			}
+		})
+	}
+}
+
+// tokenFamily returns the family of a refresh token
+func (e *testEnv) tokenFamily(t *testing.T, token string) uuid.UUID {
+	t.Helper()
+	stored, err := e.tokens.FindRefreshTokenByHash(e.svc.hashToken(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored.FamilyID
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSessionNotFound is returned when the session does not exist, has
+	// already ended, or belongs to another user.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrCurrentSessionUnknown is returned when the access token predates
+	// session IDs, so "everywhere else" cannot be determined.
+	ErrCurrentSessionUnknown = errors.New("current session unknown, please sign in again")
+)
+
+// Revocation reasons for user-initiated sign-outs
+const (
+	revokeReasonSessionRevoked = "session_revoked"
+	revokeReasonOtherSessions  = "revoke_other_sessions"
+)
+
+func (s *authService) ListSessions(userID uuid.UUID, currentSessionID string) ([]models.SessionResponse, error) {
+	sessions, err := s.tokenRepo.ListActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		name, deviceType := parseUserAgent(stringValue(session.UserAgent))
+		if session.DeviceName != nil && *session.DeviceName != "" {
+			name = *session.DeviceName
+		}
+		if session.DeviceType != nil && *session.DeviceType != "" {
+			deviceType = *session.DeviceType
+		}
+
+		result = append(result, models.SessionResponse{
+			ID:         session.FamilyID.String(),
+			DeviceID:   session.DeviceID,
+			DeviceName: name,
+			DeviceType: deviceType,
+			IPAddress:  session.IPAddress,
+			Location:   coarseLocation(stringValue(session.IPAddress)),
+			SignedInAt: session.SignedInAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.FamilyID.String() == currentSessionID,
+		})
+	}
+
+	return result, nil
+}
+
+// RevokeSession signs out one session. Its refresh token and the access
+// tokens issued to it stop working immediately.
+func (s *authService) RevokeSession(userID, sessionID uuid.UUID, ip, userAgent string) error {
+	revoked, err := s.tokenRepo.RevokeSession(userID, sessionID, revokeReasonSessionRevoked)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrSessionNotFound
+	}
+	s.revokeSessionAccessTokens(sessionID)
+
+	s.logAudit(&userID, "session_revoked", "success", ip, userAgent, "")
+	return nil
+}
+
+// RevokeOtherSessions signs out every session except the caller's and
+// returns how many were ended.
+func (s *authService) RevokeOtherSessions(userID uuid.UUID, currentSessionID, ip, userAgent string) (int64, error) {
+	current, err := uuid.Parse(currentSessionID)
+	if err != nil {
+		return 0, ErrCurrentSessionUnknown
+	}
+
+	sessions, err := s.tokenRepo.ListActiveSessions(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked, err := s.tokenRepo.RevokeOtherSessions(userID, current, revokeReasonOtherSessions)
+	if err != nil {
+		return 0, err
+	}
+	for _, session := range sessions {
+		if session.FamilyID != current {
+			s.revokeSessionAccessTokens(session.FamilyID)
+		}
+	}
+
+	s.logAudit(&userID, "sessions_revoked_others", "success", ip, userAgent, fmt.Sprintf("%d sessions revoked", revoked))
+	return revoked, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bisosad1501/DATN/services/auth-service/internal/config"
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+// SigningKeys holds the keys for access tokens. Every key is published in the
+// JWKS so tokens it signed keep verifying; only the active key signs.
+//
+// Keys are PEM files named <kid>.pem in JWT_KEYS_DIR: PKCS#8 RSA or Ed25519
+// private keys, or public keys for retired keys whose tokens may still be in
+// flight. JWT_ACTIVE_KEY_ID selects the signing key.
+type SigningKeys struct {
+	activeKID string
+	signer    crypto.Signer
+	method    jwt.SigningMethod
+	public    map[string]crypto.PublicKey
+	set       jwks.Set
+}
+
+// LoadSigningKeys reads the key directory. Without one, development gets an
+// ephemeral Ed25519 key that is lost on restart.
+func LoadSigningKeys(cfg *config.Config) (*SigningKeys, error) {
+	if cfg.JWTKeysDir == "" {
+		if cfg.AppEnv == "production" {
+			return nil, fmt.Errorf("JWT_KEYS_DIR is required in production")
+		}
+		log.Println("[Auth-Service] WARNING: JWT_KEYS_DIR not set, signing with an ephemeral key")
+		return ephemeralSigningKeys()
+	}
+
+	paths, err := filepath.Glob(filepath.Join(cfg.JWTKeysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := &SigningKeys{public: map[string]crypto.PublicKey{}}
+	signers := map[string]crypto.Signer{}
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		signer, public, err := readKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+		if signer != nil {
+			signers[kid] = signer
+		}
+		if err := keys.publish(kid, public); err != nil {
+			return nil, err
+		}
+	}
+
+	activeKID := cfg.JWTActiveKeyID
+	if activeKID == "" {
+		if len(signers) != 1 {
+			return nil, fmt.Errorf("JWT_ACTIVE_KEY_ID is required when %s holds %d private keys", cfg.JWTKeysDir, len(signers))
+		}
+		for kid := range signers {
+			activeKID = kid
+		}
+	}
+	signer, ok := signers[activeKID]
+	if !ok {
+		return nil, fmt.Errorf("no private key %s.pem in %s", activeKID, cfg.JWTKeysDir)
+	}
+	if err := keys.activate(activeKID, signer); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Auth-Service] Signing tokens with key %s (%s), publishing %d keys",
+		activeKID, keys.method.Alg(), len(keys.set.Keys))
+	return keys, nil
+}
+
+func ephemeralSigningKeys() (*SigningKeys, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	kid := "dev-" + hex.EncodeToString(suffix)
+
+	keys := &SigningKeys{public: map[string]crypto.PublicKey{}}
+	if err := keys.publish(kid, public); err != nil {
+		return nil, err
+	}
+	if err := keys.activate(kid, private); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (k *SigningKeys) publish(kid string, public crypto.PublicKey) error {
+	jwk, err := jwks.NewKey(kid, public)
+	if err != nil {
+		return fmt.Errorf("key %s: %w", kid, err)
+	}
+	k.public[kid] = public
+	k.set.Keys = append(k.set.Keys, jwk)
+	return nil
+}
+
+func (k *SigningKeys) activate(kid string, signer crypto.Signer) error {
+	alg, err := jwks.Algorithm(signer)
+	if err != nil {
+		return err
+	}
+	k.activeKID = kid
+	k.signer = signer
+	k.method = jwt.GetSigningMethod(alg)
+	return nil
+}
+
+// Sign signs claims with the active key, naming it in the kid header
+func (k *SigningKeys) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.activeKID
+	return token.SignedString(k.signer)
+}
+
+// Keyfunc resolves the verification key of a token issued by this service
+func (k *SigningKeys) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.public[kid]
+	if !ok {
+		return nil, jwks.ErrUnknownKey
+	}
+	alg, err := jwks.Algorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != alg {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key, nil
+}
+
+// JWKS returns the published public keys
+func (k *SigningKeys) JWKS() jwks.Set {
+	return k.set
+}
+
+// readKeyFile parses a PEM private key (signer and public part) or public key
+func readKeyFile(path string) (crypto.Signer, crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		return key, &key.PublicKey, nil
+	case ed25519.PrivateKey:
+		return key, key.Public(), nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		return nil, key, nil
+	case ed25519.PublicKey:
+		return nil, key, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %T; use RSA or Ed25519", parsed)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrTokenRevoked is returned for access tokens revoked before their expiry
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// Access token revocation entries in Redis. The gateway reads the same keys
+// (api-gateway/internal/revocation), so keep both in sync.
+const (
+	// + jti: one token, e.g. on logout; kept until the token expires
+	revokedTokenKeyPrefix = "auth:revoked:jti:"
+	// + session ID: every token of a signed-out session
+	revokedSessionKeyPrefix = "auth:revoked:sid:"
+	// + user ID: unix time before which the user's tokens are revoked
+	tokensValidAfterKeyPrefix = "auth:tokens_valid_after:"
+
+	revocationTimeout = 2 * time.Second
+)
+
+// revokeAccessToken denylists one access token until it expires
+func (s *authService) revokeAccessToken(claims *TokenClaims) {
+	if claims == nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return
+	}
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return
+	}
+	s.setRevocation(revokedTokenKeyPrefix+claims.ID, "1", ttl)
+}
+
+// revokeSessionAccessTokens revokes the access tokens issued to a session
+func (s *authService) revokeSessionAccessTokens(sessionID uuid.UUID) {
+	s.setRevocation(revokedSessionKeyPrefix+sessionID.String(), "1", s.accessTokenExpiry())
+}
+
+// revokeUserAccessTokens revokes every access token issued to the user so far
+func (s *authService) revokeUserAccessTokens(userID uuid.UUID) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	s.setRevocation(tokensValidAfterKeyPrefix+userID.String(), now, s.accessTokenExpiry())
+}
+
+// setRevocation is best effort: refresh tokens are already revoked in the
+// database, so a Redis outage only delays the access token's expiry.
+func (s *authService) setRevocation(key, value string, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), revocationTimeout)
+	defer cancel()
+
+	if err := s.redisClient.Set(ctx, key, value, ttl).Err(); err != nil {
+		log.Printf("[Auth-Service] ERROR: Failed to write token revocation %s: %v", key, err)
+	}
+}
+
+// isAccessTokenRevoked checks the denylist entries that apply to a token
+func (s *authService) isAccessTokenRevoked(claims *TokenClaims) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), revocationTimeout)
+	defer cancel()
+
+	values, err := s.redisClient.MGet(ctx,
+		revokedTokenKeyPrefix+claims.ID,
+		revokedSessionKeyPrefix+claims.SessionID,
+		tokensValidAfterKeyPrefix+claims.UserID,
+	).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if (claims.ID != "" && values[0] != nil) || (claims.SessionID != "" && values[1] != nil) {
+		return true, nil
+	}
+	if validAfter, ok := values[2].(string); ok && claims.IssuedAt != nil {
+		cutoff, err := strconv.ParseInt(validAfter, 10, 64)
+		if err == nil && claims.IssuedAt.Unix() < cutoff {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *authService) accessTokenExpiry() time.Duration {
+	expiry, err := time.ParseDuration(s.config.JWTExpiry)
+	if err != nil || expiry <= 0 {
+		return 24 * time.Hour
+	}
+	return expiry
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
+	"github.com/bisosad1501/DATN/shared/pkg/metrics"
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
 	"github.com/bisosad1501/ielts-platform/course-service/internal/config"
 	"github.com/bisosad1501/ielts-platform/course-service/internal/database"
 	"github.com/bisosad1501/ielts-platform/course-service/internal/handlers"
@@ -20,12 +24,20 @@ func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Initialize tracing (exporter selected with OTEL_TRACES_EXPORTER)
+	shutdownTracing, err := tracing.Init("course-service")
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Connect to database
 	db, err := database.NewDatabase(cfg)
 	if err != nil {
 		log.Fatalf("❌ Failed to connect to database: %v", err)
 	}
 	defer db.Close()
+	metrics.RegisterDBStats(cfg.DBName, db.DB)
 
 	// Initialize repository
 	repo := repository.NewCourseRepository(db.DB)
@@ -46,19 +58,19 @@ func main() {
 		log.Println("✅ YouTube service initialized")
 	}
 
+	// Graceful shutdown: drains requests and waits for notification/sync work
+	lc := lifecycle.New("course-service")
+
 	// Initialize service
-	svc := service.NewCourseService(repo, userServiceClient, notificationClient, exerciseClient, youtubeService)
+	svc := service.NewCourseService(repo, userServiceClient, notificationClient, exerciseClient, youtubeService, lc)
 	log.Println("✅ Service initialized")
 
 	// Initialize and start video sync service
 	var videoSyncService *service.VideoSyncService
 	if youtubeService != nil {
-		videoSyncService = service.NewVideoSyncService(repo, youtubeService)
+		videoSyncService = service.NewVideoSyncService(repo, youtubeService, lc)
 		videoSyncService.StartPeriodicSync()
 		log.Println("✅ Video sync service started (runs every 24 hours)")
-
-		// Graceful shutdown
-		defer videoSyncService.Stop()
 	}
 
 	// Initialize middleware
@@ -67,14 +79,18 @@ func main() {
 
 	// Initialize handlers
 	handler := handlers.NewCourseHandler(svc, videoSyncService)
+	internalHandler := handlers.NewInternalHandler(svc)
 	log.Println("✅ Handlers initialized")
 
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
+	router := gin.New()
+	router.Use(tracing.Middleware("course-service"), metrics.Middleware("course-service"), tracing.Logger(), gin.Recovery())
+	router.GET("/metrics", metrics.Handler())
+	router.GET("/ready", lc.ReadyHandler())
 
 	// Setup routes
-	routes.SetupRoutes(router, handler, authMiddleware)
+	routes.SetupRoutes(router, handler, internalHandler, authMiddleware)
 	log.Println("✅ Routes configured")
 
 	// Print routes
@@ -91,7 +107,7 @@ func main() {
 	// Start server
 	serverAddr := ":" + cfg.ServerPort
 	log.Printf("✅ Course Service started successfully on port %s\n", cfg.ServerPort)
-	if err := router.Run(serverAddr); err != nil {
+	if err := lc.Run(serverAddr, router); err != nil {
 		log.Fatalf("❌ Failed to start server: %v", err)
 	}
 }
@@ -12,7 +12,7 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
-	JWTSecret  string
+	JWKSURL    string // auth-service key set that verifies access tokens
 
 	// Service-to-Service Communication
 	UserServiceURL         string
@@ -29,7 +29,7 @@ func LoadConfig() *Config {
 		DBUser:     getEnv("DB_USER", "ielts_admin"),
 		DBPassword: getEnv("DB_PASSWORD", ""),
 		DBName:     getEnv("DB_NAME", "course_db"),
-		JWTSecret:  getEnv("JWT_SECRET", ""),
+		JWKSURL:    getEnv("JWKS_URL", "http://auth-service:8081/.well-known/jwks.json"),
 
 		// Service URLs for internal communication
 		UserServiceURL:         getEnv("USER_SERVICE_URL", "http://user-service:8082"),
@@ -42,10 +42,6 @@ func LoadConfig() *Config {
 		log.Fatal("❌ DB_PASSWORD is required")
 	}
 
-	if config.JWTSecret == "" {
-		log.Fatal("❌ JWT_SECRET is required")
-	}
-
 	log.Println("✅ Configuration loaded successfully")
 	return config
 }
@@ -1406,7 +1406,7 @@ func (h *CourseHandler) SyncAllVideoDurations(c *gin.Context) {
 	}
 
 	// Run sync in background
-	go h.videoSyncService.SyncMissingDurations()
+	h.videoSyncService.TriggerSync()
 
 	c.JSON(http.StatusOK, Response{
 		Success: true,
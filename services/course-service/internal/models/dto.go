@@ -197,3 +197,9 @@ type AddVideoToLessonRequest struct {
 	FileSize        *int64  `json:"file_size"`                         // File size in bytes
 	DisplayOrder    *int    `json:"display_order"`                     // Order in lesson
 }
+
+// UserDataErasure reports the rows removed or anonymized for a user, keyed by table
+type UserDataErasure struct {
+	Deleted    map[string]int64 `json:"deleted"`
+	Anonymized map[string]int64 `json:"anonymized,omitempty"`
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bisosad1501/ielts-platform/course-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// userDataTables lists every table holding a user's rows
+var userDataTables = []string{
+	"course_enrollments",
+	"lesson_progress",
+	"video_watch_history",
+	"course_reviews",
+}
+
+// ExportUserData returns the user's rows as JSON arrays keyed by table
+func (r *CourseRepository) ExportUserData(userID uuid.UUID) (map[string]json.RawMessage, error) {
+	data := make(map[string]json.RawMessage, len(userDataTables))
+	for _, table := range userDataTables {
+		query := fmt.Sprintf(`SELECT COALESCE(json_agg(t), '[]'::json) FROM (SELECT * FROM %s WHERE user_id = $1) t`, table)
+
+		var rows []byte
+		if err := r.db.QueryRow(query, userID).Scan(&rows); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		data[table] = rows
+	}
+
+	return data, nil
+}
+
+// EraseUserData deletes the user's enrollments and learning history. Reviews
+// are kept for the course ratings but detached from the user: each gets a
+// random owner and loses its title and comment. Safe to repeat.
+func (r *CourseRepository) EraseUserData(userID uuid.UUID) (*models.UserDataErasure, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	erasure := &models.UserDataErasure{
+		Deleted:    make(map[string]int64),
+		Anonymized: make(map[string]int64),
+	}
+	for _, table := range []string{"video_watch_history", "lesson_progress", "course_enrollments"} {
+		result, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, table), userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to erase %s: %w", table, err)
+		}
+		erasure.Deleted[table], _ = result.RowsAffected()
+	}
+
+	result, err := tx.Exec(`
+		UPDATE course_reviews
+		SET user_id = uuid_generate_v4(), title = NULL, comment = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to anonymize course_reviews: %w", err)
+	}
+	erasure.Anonymized["course_reviews"], _ = result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit erasure: %w", err)
+	}
+
+	return erasure, nil
+}
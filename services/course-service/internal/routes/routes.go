@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"github.com/bisosad1501/DATN/shared/pkg/authz"
 	"github.com/bisosad1501/ielts-platform/course-service/internal/handlers"
 	"github.com/bisosad1501/ielts-platform/course-service/internal/middleware"
 	"github.com/gin-gonic/gin"
@@ -9,6 +10,7 @@ import (
 func SetupRoutes(
 	router *gin.Engine,
 	handler *handlers.CourseHandler,
+	internalHandler *handlers.InternalHandler,
 	authMiddleware *middleware.AuthMiddleware,
 ) {
 	// Health check
@@ -85,30 +87,37 @@ func SetupRoutes(
 			progress.PUT("/lessons/:id", handler.UpdateLessonProgress) // Update lesson progress
 		}
 
-		// Admin routes (protected - instructor and admin only)
+		// Admin routes (protected - each route requires a permission)
 		admin := v1.Group("/admin")
 		admin.Use(authMiddleware.AuthRequired())
 		{
-			// Course management (instructor and admin)
-			admin.POST("/courses", authMiddleware.RequireRole("instructor", "admin"), handler.CreateCourse)
-			admin.PUT("/courses/:id", authMiddleware.RequireRole("instructor", "admin"), handler.UpdateCourse)
-			admin.POST("/courses/:id/publish", authMiddleware.RequireRole("instructor", "admin"), handler.PublishCourse)
-
-			// Course deletion (admin only)
-			admin.DELETE("/courses/:id", authMiddleware.RequireRole("admin"), handler.DeleteCourse)
-
-			// Module and lesson management (instructor and admin)
-			admin.POST("/modules", authMiddleware.RequireRole("instructor", "admin"), handler.CreateModule)
-			admin.POST("/lessons", authMiddleware.RequireRole("instructor", "admin"), handler.CreateLesson)
-
-			// Video management (instructor and admin)
-			admin.POST("/lessons/:lesson_id/videos", authMiddleware.RequireRole("instructor", "admin"), handler.AddVideoToLesson)
-
-			// Video duration sync (admin only)
-			admin.POST("/videos/sync-all", authMiddleware.RequireRole("admin"), handler.SyncAllVideoDurations)                      // Sync videos with missing duration
-			admin.POST("/videos/force-resync-all", authMiddleware.RequireRole("admin"), handler.ForceResyncAllVideos)               // Force re-sync ALL videos
-			admin.POST("/videos/:video_id/sync-duration", authMiddleware.RequireRole("admin"), handler.SyncSingleVideoDuration)     // Sync single video
-			admin.POST("/lessons/:lesson_id/sync-durations", authMiddleware.RequireRole("admin"), handler.SyncLessonVideoDurations) // Sync lesson videos
+			// Course management
+			admin.POST("/courses", authz.RequirePermission("course:create"), handler.CreateCourse)
+			admin.PUT("/courses/:id", authz.RequirePermission("course:update"), handler.UpdateCourse)
+			admin.POST("/courses/:id/publish", authz.RequirePermission("course:publish"), handler.PublishCourse)
+			admin.DELETE("/courses/:id", authz.RequirePermission("course:delete"), handler.DeleteCourse)
+
+			// Module and lesson management
+			admin.POST("/modules", authz.RequirePermission("course:manage_content"), handler.CreateModule)
+			admin.POST("/lessons", authz.RequirePermission("course:manage_content"), handler.CreateLesson)
+
+			// Video management
+			admin.POST("/lessons/:lesson_id/videos", authz.RequirePermission("course:manage_content"), handler.AddVideoToLesson)
+
+			// Video duration sync
+			admin.POST("/videos/sync-all", authz.RequirePermission("video:sync"), handler.SyncAllVideoDurations)                      // Sync videos with missing duration
+			admin.POST("/videos/force-resync-all", authz.RequirePermission("video:sync"), handler.ForceResyncAllVideos)               // Force re-sync ALL videos
+			admin.POST("/videos/:video_id/sync-duration", authz.RequirePermission("video:sync"), handler.SyncSingleVideoDuration)     // Sync single video
+			admin.POST("/lessons/:lesson_id/sync-durations", authz.RequirePermission("video:sync"), handler.SyncLessonVideoDurations) // Sync lesson videos
+		}
+
+		// Internal routes (service-to-service communication only)
+		internal := v1.Group("/courses/internal")
+		internal.Use(authMiddleware.InternalAuth())
+		{
+			// Account deletion and data export (Auth Service)
+			internal.DELETE("/users/:id/data", internalHandler.EraseUserData)
+			internal.GET("/users/:id/export", internalHandler.ExportUserData)
 		}
 	}
 }
@@ -1,12 +1,14 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math"
 	"time"
 
 	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
 	"github.com/bisosad1501/ielts-platform/course-service/internal/models"
 	"github.com/bisosad1501/ielts-platform/course-service/internal/repository"
 	"github.com/google/uuid"
@@ -18,15 +20,17 @@ type CourseService struct {
 	notificationClient *client.NotificationServiceClient
 	exerciseClient     *client.ExerciseServiceClient
 	youtubeService     *YouTubeService
+	lifecycle          *lifecycle.Lifecycle // tracks notification/progress work so shutdown waits for it
 }
 
-func NewCourseService(repo *repository.CourseRepository, userServiceClient *client.UserServiceClient, notificationClient *client.NotificationServiceClient, exerciseClient *client.ExerciseServiceClient, youtubeService *YouTubeService) *CourseService {
+func NewCourseService(repo *repository.CourseRepository, userServiceClient *client.UserServiceClient, notificationClient *client.NotificationServiceClient, exerciseClient *client.ExerciseServiceClient, youtubeService *YouTubeService, lc *lifecycle.Lifecycle) *CourseService {
 	return &CourseService{
 		repo:               repo,
 		userServiceClient:  userServiceClient,
 		notificationClient: notificationClient,
 		exerciseClient:     exerciseClient,
 		youtubeService:     youtubeService,
+		lifecycle:          lc,
 	}
 }
 
@@ -243,16 +247,10 @@ func (s *CourseService) EnrollCourse(userID uuid.UUID, req *models.EnrollmentReq
 	}
 
 	// Send enrollment notification (non-critical, async)
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("[Course-Service] PANIC in enrollment notification: %v", r)
-			}
-		}()
-		
+	s.lifecycle.Go(context.Background(), "enrollment notification", func(ctx context.Context) {
 		log.Printf("[Course-Service] Sending enrollment notification for user %s, course %s", userID, course.ID)
 		actionType := "navigate_to_course"
-		err := s.notificationClient.SendNotification(client.SendNotificationRequest{
+		err := s.notificationClient.WithContext(ctx).SendNotification(client.SendNotificationRequest{
 			UserID:     userID.String(),
 			Title:      "Đã đăng ký khóa học thành công",
 			Message:    fmt.Sprintf("Bạn đã đăng ký khóa học '%s'. Bắt đầu học ngay để đạt mục tiêu của bạn.", course.Title),
@@ -269,7 +267,7 @@ func (s *CourseService) EnrollCourse(userID uuid.UUID, req *models.EnrollmentReq
 		} else {
 			log.Printf("[Course-Service] ✅ SUCCESS: Sent enrollment notification for user %s, course %s", userID, course.ID)
 		}
-	}()
+	})
 
 	// Return the enrollment (might be existing one due to ON CONFLICT)
 	return s.repo.GetEnrollment(userID, req.CourseID)
@@ -482,14 +480,9 @@ func (s *CourseService) UpdateLessonProgress(userID, lessonID uuid.UUID, req *mo
 		// Refresh progress for notification
 		updatedProgress, _ := s.repo.GetLessonProgress(userID, lessonID)
 		if updatedProgress != nil {
-			go func() {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("[Course-Service] PANIC in handleLessonCompletion: %v", r)
-					}
-				}()
-				s.handleLessonCompletion(userID, lessonID, lesson, updatedProgress)
-			}()
+			s.lifecycle.Go(context.Background(), "handleLessonCompletion", func(ctx context.Context) {
+				s.handleLessonCompletion(ctx, userID, lessonID, lesson, updatedProgress)
+			})
 		}
 	}
 
@@ -808,7 +801,7 @@ func (s *CourseService) CreateLesson(userID uuid.UUID, userRole string, req *mod
 	}
 
 	// Send notification to enrolled users about new lesson (non-critical)
-	go func() {
+	s.lifecycle.Go(context.Background(), "new lesson notification", func(ctx context.Context) {
 		// Get course to get enrolled users
 		course, err := s.repo.GetCourseByID(courseID)
 		if err != nil || course == nil {
@@ -825,8 +818,13 @@ func (s *CourseService) CreateLesson(userID uuid.UUID, userRole string, req *mod
 
 		// Send notification to each enrolled user
 		actionType := "navigate_to_lesson"
-		for _, enrollment := range enrollments {
-			err = s.notificationClient.SendNotification(client.SendNotificationRequest{
+		notificationClient := s.notificationClient.WithContext(ctx)
+		for i, enrollment := range enrollments {
+			if ctx.Err() != nil {
+				log.Printf("[Course-Service] WARNING: Shutdown interrupted new lesson notifications for lesson %s, %d of %d users not notified", lesson.ID, len(enrollments)-i, len(enrollments))
+				return
+			}
+			err = notificationClient.SendNotification(client.SendNotificationRequest{
 				UserID:     enrollment.UserID.String(),
 				Title:      "Bài học mới đã được thêm vào khóa học",
 				Message:    fmt.Sprintf("Khóa học '%s' vừa có bài học mới: '%s'. Truy cập để bắt đầu học.", course.Title, lesson.Title),
@@ -844,7 +842,7 @@ func (s *CourseService) CreateLesson(userID uuid.UUID, userRole string, req *mod
 			}
 		}
 		log.Printf("[Course-Service] ✅ Sent new lesson notifications to %d enrolled users", len(enrollments))
-	}()
+	})
 
 	return lesson, nil
 }
@@ -912,7 +910,7 @@ func (s *CourseService) CreateReview(userID, courseID uuid.UUID, req *models.Cre
 	}
 
 	// Send notification to course instructor about new review (non-critical)
-	go func() {
+	s.lifecycle.Go(context.Background(), "review notification", func(ctx context.Context) {
 		// Get course to find instructor
 		course, err := s.repo.GetCourseByID(courseID)
 		if err != nil || course == nil {
@@ -926,7 +924,7 @@ func (s *CourseService) CreateReview(userID, courseID uuid.UUID, req *models.Cre
 
 		// Send notification to instructor
 		actionType := "navigate_to_course"
-		err = s.notificationClient.SendNotification(client.SendNotificationRequest{
+		err = s.notificationClient.WithContext(ctx).SendNotification(client.SendNotificationRequest{
 			UserID:     course.InstructorID.String(),
 			Title:      "Khóa học của bạn vừa nhận đánh giá mới",
 			Message:    fmt.Sprintf("Khóa học '%s' vừa nhận được đánh giá %d sao từ %s. Xem chi tiết đánh giá.", course.Title, req.Rating, reviewerName),
@@ -941,7 +939,7 @@ func (s *CourseService) CreateReview(userID, courseID uuid.UUID, req *models.Cre
 		if err != nil {
 			log.Printf("[Course-Service] WARNING: Failed to send review notification to instructor: %v", err)
 		}
-	}()
+	})
 
 	return review, nil
 }
@@ -1138,7 +1136,11 @@ func (s *CourseService) AddVideoToLesson(userID uuid.UUID, userRole string, less
 
 // handleLessonCompletion handles service-to-service integration when a lesson is completed
 // FIX #11: Added retry mechanism and better error handling
-func (s *CourseService) handleLessonCompletion(userID, lessonID uuid.UUID, lesson *models.Lesson, progress *models.LessonProgress) {
+// ctx is cancelled when the shutdown drain window expires; retries stop and the lost
+// update is logged for reconciliation.
+func (s *CourseService) handleLessonCompletion(ctx context.Context, userID, lessonID uuid.UUID, lesson *models.Lesson, progress *models.LessonProgress) {
+	userServiceClient := s.userServiceClient.WithContext(ctx)
+	notificationClient := s.notificationClient.WithContext(ctx)
 	log.Printf("[Course-Service] Handling lesson completion for user %s, lesson %s", userID, lessonID)
 
 	// Get course to determine skill type
@@ -1158,7 +1160,7 @@ func (s *CourseService) handleLessonCompletion(userID, lessonID uuid.UUID, lesso
 	log.Printf("[Course-Service] Updating user progress in User Service...")
 	progressUpdateSuccess := false
 	for attempt := 1; attempt <= 3; attempt++ {
-		err = s.userServiceClient.UpdateProgress(client.UpdateProgressRequest{
+		err = userServiceClient.UpdateProgress(client.UpdateProgressRequest{
 			UserID:           userID.String(),
 			LessonsCompleted: 1,
 			StudyMinutes:     studyMinutes,
@@ -1172,14 +1174,15 @@ func (s *CourseService) handleLessonCompletion(userID, lessonID uuid.UUID, lesso
 			break
 		}
 		log.Printf("[Course-Service] WARNING: Failed to update user progress (attempt %d/%d): %v", attempt, 3, err)
-		if attempt < 3 {
-			time.Sleep(time.Duration(attempt) * time.Second)
+		if attempt < 3 && !sleepContext(ctx, time.Duration(attempt)*time.Second) {
+			break
 		}
 	}
 
 	// If all retries failed, log critical error
 	if !progressUpdateSuccess {
-		log.Printf("[Course-Service] CRITICAL ERROR: Failed to update user progress after 3 attempts for user %s, lesson %s", userID, lessonID)
+		log.Printf("[Course-Service] CRITICAL ERROR: Failed to update user progress for user %s, lesson %s (skill=%s, study_minutes=%d): %v",
+			userID, lessonID, course.SkillType, studyMinutes, err)
 		// TODO: Store in dead letter queue or manual reconciliation table
 	}
 
@@ -1198,7 +1201,7 @@ func (s *CourseService) handleLessonCompletion(userID, lessonID uuid.UUID, lesso
 	notificationSuccess := false
 	for attempt := 1; attempt <= 2; attempt++ {
 		actionType := "navigate_to_lesson"
-		err = s.notificationClient.SendNotification(client.SendNotificationRequest{
+		err = notificationClient.SendNotification(client.SendNotificationRequest{
 			UserID:     userID.String(),
 			Title:      "Bạn đã hoàn thành bài học",
 			Message:    fmt.Sprintf("Chúc mừng! Bạn đã hoàn thành bài học '%s'. Tiến độ khóa học hiện tại: %d%%.", lesson.Title, overallProgress),
@@ -1217,8 +1220,8 @@ func (s *CourseService) handleLessonCompletion(userID, lessonID uuid.UUID, lesso
 			break
 		}
 		log.Printf("[Course-Service] WARNING: Failed to send notification (attempt %d/%d): %v", attempt, 2, err)
-		if attempt < 2 {
-			time.Sleep(1 * time.Second)
+		if attempt < 2 && !sleepContext(ctx, 1*time.Second) {
+			break
 		}
 	}
 
@@ -1229,17 +1232,25 @@ func (s *CourseService) handleLessonCompletion(userID, lessonID uuid.UUID, lesso
 	// 3. Check if course is completed and send completion notification
 	if isCourseCompleted && enrollment.Status != "completed" {
 		// Course just completed (first time reaching 100%)
-		go func() {
-			if err := s.notificationClient.SendCourseCompletionNotification(
-				userID.String(),
-				course.Title,
-				course.ID.String(),
-			); err != nil {
-				log.Printf("[Course-Service] WARNING: Failed to send course completion notification: %v", err)
-			} else {
-				log.Printf("[Course-Service] ✅ Sent course completion notification for course %s", course.ID)
-			}
-		}()
+		if err := notificationClient.SendCourseCompletionNotification(
+			userID.String(),
+			course.Title,
+			course.ID.String(),
+		); err != nil {
+			log.Printf("[Course-Service] WARNING: Failed to send course completion notification: %v", err)
+		} else {
+			log.Printf("[Course-Service] ✅ Sent course completion notification for course %s", course.ID)
+		}
+	}
+}
+
+// sleepContext waits for d and reports false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
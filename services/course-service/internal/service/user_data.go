@@ -0,0 +1,26 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/bisosad1501/ielts-platform/course-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// ExportUserData returns everything the service stores about the user, keyed by table
+func (s *CourseService) ExportUserData(userID uuid.UUID) (map[string]json.RawMessage, error) {
+	return s.repo.ExportUserData(userID)
+}
+
+// EraseUserData removes the user's enrollments, progress and watch history and
+// anonymizes their reviews (called by the auth service's deletion saga)
+func (s *CourseService) EraseUserData(userID uuid.UUID) (*models.UserDataErasure, error) {
+	erasure, err := s.repo.EraseUserData(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🗑️  Erased data for user %s: deleted=%v anonymized=%v", userID, erasure.Deleted, erasure.Anonymized)
+	return erasure, nil
+}
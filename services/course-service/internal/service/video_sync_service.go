@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
 	"github.com/bisosad1501/ielts-platform/course-service/internal/repository"
 	"github.com/google/uuid"
 )
@@ -13,16 +15,15 @@ import (
 type VideoSyncService struct {
 	repo           *repository.CourseRepository
 	youtubeService *YouTubeService
-	ticker         *time.Ticker
-	stopChan       chan bool
+	lifecycle      *lifecycle.Lifecycle // stops the periodic sync and waits for a running one on shutdown
 }
 
 // NewVideoSyncService creates a new video sync service
-func NewVideoSyncService(repo *repository.CourseRepository, youtubeService *YouTubeService) *VideoSyncService {
+func NewVideoSyncService(repo *repository.CourseRepository, youtubeService *YouTubeService, lc *lifecycle.Lifecycle) *VideoSyncService {
 	return &VideoSyncService{
 		repo:           repo,
 		youtubeService: youtubeService,
-		stopChan:       make(chan bool),
+		lifecycle:      lc,
 	}
 }
 
@@ -33,37 +34,35 @@ func (s *VideoSyncService) StartPeriodicSync() {
 		return
 	}
 
-	// Run immediately on start
-	go s.SyncMissingDurations()
+	// Run immediately on start, then every 24 hours until shutdown
+	s.lifecycle.Go(s.lifecycle.Context(), "periodic video sync", func(ctx context.Context) {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
 
-	// Then run every 24 hours
-	s.ticker = time.NewTicker(24 * time.Hour)
-
-	go func() {
 		log.Println("[VideoSync] ✅ Periodic sync started (every 24 hours)")
+		s.SyncMissingDurations(ctx)
 		for {
 			select {
-			case <-s.ticker.C:
+			case <-ticker.C:
 				log.Println("[VideoSync] Running scheduled sync...")
-				s.SyncMissingDurations()
-			case <-s.stopChan:
+				s.SyncMissingDurations(ctx)
+			case <-ctx.Done():
 				log.Println("[VideoSync] Stopping periodic sync...")
-				s.ticker.Stop()
 				return
 			}
 		}
-	}()
+	})
 }
 
-// Stop stops the periodic sync
-func (s *VideoSyncService) Stop() {
-	if s.ticker != nil {
-		s.stopChan <- true
-	}
+// TriggerSync runs SyncMissingDurations in the background
+func (s *VideoSyncService) TriggerSync() {
+	s.lifecycle.Go(context.Background(), "video duration sync", s.SyncMissingDurations)
 }
 
-// SyncMissingDurations syncs duration for all YouTube videos with missing or zero duration
-func (s *VideoSyncService) SyncMissingDurations() {
+// SyncMissingDurations syncs duration for all YouTube videos with missing or zero duration.
+// Each video is committed on its own, so stopping early on ctx cancellation loses nothing;
+// the remaining videos are picked up by the next run.
+func (s *VideoSyncService) SyncMissingDurations(ctx context.Context) {
 	log.Println("[VideoSync] 🔄 Starting duration sync for videos with missing/zero duration...")
 
 	// Get all YouTube videos without duration
@@ -83,7 +82,11 @@ func (s *VideoSyncService) SyncMissingDurations() {
 	successCount := 0
 	failCount := 0
 
-	for _, video := range videos {
+	for i, video := range videos {
+		if ctx.Err() != nil {
+			log.Printf("[VideoSync] Interrupted by shutdown, %d videos left for the next run", len(videos)-i)
+			break
+		}
 		if video.VideoProvider != "youtube" || video.VideoID == nil || *video.VideoID == "" {
 			continue
 		}
@@ -112,7 +115,7 @@ func (s *VideoSyncService) SyncMissingDurations() {
 		successCount++
 
 		// Rate limiting: sleep 100ms between API calls to avoid quota issues
-		time.Sleep(100 * time.Millisecond)
+		sleepContext(ctx, 100*time.Millisecond)
 	}
 
 	log.Printf("[VideoSync] 🎉 Sync complete: %d success, %d failed", successCount, failCount)
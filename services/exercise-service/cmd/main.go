@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
+	"github.com/bisosad1501/DATN/shared/pkg/metrics"
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
 	"github.com/bisosad1501/ielts-platform/exercise-service/internal/config"
 	"github.com/bisosad1501/ielts-platform/exercise-service/internal/database"
 	"github.com/bisosad1501/ielts-platform/exercise-service/internal/handlers"
@@ -18,12 +22,20 @@ func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Initialize tracing (exporter selected with OTEL_TRACES_EXPORTER)
+	shutdownTracing, err := tracing.Init("exercise-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Connect to database
 	db, err := database.Connect(cfg)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
+	metrics.RegisterDBStats(cfg.DBName, db)
 
 	log.Println("Connected to database successfully")
 
@@ -32,25 +44,32 @@ func main() {
 	notificationClient := client.NewNotificationServiceClient(cfg.NotificationServiceURL, cfg.InternalAPIKey)
 	log.Println("✅ Service clients initialized")
 
+	// Graceful shutdown: drains requests and waits for completion work
+	lc := lifecycle.New("exercise-service")
+
 	// Initialize layers
 	exerciseRepo := repository.NewExerciseRepository(db)
-	exerciseService := service.NewExerciseService(exerciseRepo, userServiceClient, notificationClient)
+	exerciseService := service.NewExerciseService(exerciseRepo, userServiceClient, notificationClient, lc)
 	exerciseHandler := handlers.NewExerciseHandler(exerciseService)
+	internalHandler := handlers.NewInternalHandler(exerciseService)
 	authMiddleware := middleware.NewAuthMiddleware(cfg)
 
 	// Setup Gin
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
+	router := gin.New()
+	router.Use(tracing.Middleware("exercise-service"), metrics.Middleware("exercise-service"), tracing.Logger(), gin.Recovery())
+	router.GET("/metrics", metrics.Handler())
+	router.GET("/ready", lc.ReadyHandler())
 
 	// Note: CORS is handled by API Gateway, no need to set here
 	// to avoid duplicate headers (Access-Control-Allow-Origin: *, *)
 
 	// Setup routes
-	routes.SetupRoutes(router, exerciseHandler, authMiddleware)
+	routes.SetupRoutes(router, exerciseHandler, internalHandler, authMiddleware)
 
 	// Start server
 	log.Printf("Exercise Service running on port %s", cfg.ServerPort)
-	if err := router.Run(":" + cfg.ServerPort); err != nil {
+	if err := lc.Run(":"+cfg.ServerPort, router); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
@@ -215,7 +215,7 @@ func (h *ExerciseHandler) SubmitAnswers(c *gin.Context) {
 		return
 	}
 
-	err = h.service.SubmitAnswers(submissionID, req.Answers)
+	err = h.service.SubmitAnswers(c.Request.Context(), submissionID, req.Answers)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
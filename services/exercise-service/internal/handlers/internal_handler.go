@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bisosad1501/ielts-platform/exercise-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InternalHandler handles internal service-to-service API calls
+type InternalHandler struct {
+	service *service.ExerciseService
+}
+
+// NewInternalHandler creates a new internal handler
+func NewInternalHandler(service *service.ExerciseService) *InternalHandler {
+	return &InternalHandler{
+		service: service,
+	}
+}
+
+// EraseUserData deletes or anonymizes all data kept for a user (called by Auth
+// Service when an account deletion runs). Safe to repeat.
+func (h *InternalHandler) EraseUserData(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	erasure, err := h.service.EraseUserData(userID)
+	if err != nil {
+		log.Printf("❌ Failed to erase data for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error: &ErrorInfo{
+				Code:    "ERASE_FAILED",
+				Message: "Failed to erase user data",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    erasure,
+	})
+}
+
+// ExportUserData returns all data kept for a user (called by Auth Service when
+// building a personal-data export)
+func (h *InternalHandler) ExportUserData(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.service.ExportUserData(userID)
+	if err != nil {
+		log.Printf("❌ Failed to export data for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error: &ErrorInfo{
+				Code:    "EXPORT_FAILED",
+				Message: "Failed to export user data",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+func parseUserIDParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error: &ErrorInfo{
+				Code:    "INVALID_USER_ID",
+				Message: "Invalid user ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
@@ -1,17 +1,19 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/bisosad1501/DATN/shared/pkg/authz"
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
 	"github.com/bisosad1501/ielts-platform/exercise-service/internal/config"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthMiddleware struct {
-	jwtSecret string
+	keys           *jwks.Cache
+	internalAPIKey string
 }
 
 type ErrorInfo struct {
@@ -27,7 +29,8 @@ type Response struct {
 
 func NewAuthMiddleware(cfg *config.Config) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret: cfg.JWTSecret,
+		keys:           jwks.NewCache(cfg.JWKSURL),
+		internalAPIKey: cfg.InternalAPIKey,
 	}
 }
 
@@ -60,12 +63,7 @@ func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		token, err := jwt.Parse(tokenString, m.keyfunc, jwt.WithValidMethods(jwks.Algorithms))
 
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, Response{
@@ -96,6 +94,7 @@ func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 		c.Set("user_id", claims["user_id"])
 		c.Set("email", claims["email"])
 		c.Set("role", claims["role"])
+		authz.SetFromClaims(c, claims)
 		c.Next()
 	}
 }
@@ -110,55 +109,55 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		token, err := jwt.Parse(tokenString, m.keyfunc, jwt.WithValidMethods(jwks.Algorithms))
 
 		if err == nil && token.Valid {
 			if claims, ok := token.Claims.(jwt.MapClaims); ok {
 				c.Set("user_id", claims["user_id"])
 				c.Set("email", claims["email"])
 				c.Set("role", claims["role"])
+				authz.SetFromClaims(c, claims)
 			}
 		}
 		c.Next()
 	}
 }
 
-// RequireRole checks if user has specific role
-func (m *AuthMiddleware) RequireRole(allowedRoles ...string) gin.HandlerFunc {
+// InternalAuth validates internal API key for service-to-service communication
+func (m *AuthMiddleware) InternalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, Response{
+		apiKey := c.GetHeader("X-Internal-API-Key")
+		if apiKey == "" {
+			c.JSON(http.StatusUnauthorized, Response{
 				Success: false,
 				Error: &ErrorInfo{
-					Code:    "NO_ROLE",
-					Message: "User role not found",
+					Code:    "MISSING_API_KEY",
+					Message: "Internal API key required",
 				},
 			})
 			c.Abort()
 			return
 		}
 
-		roleStr := role.(string)
-		for _, allowedRole := range allowedRoles {
-			if roleStr == allowedRole {
-				c.Next()
-				return
-			}
+		if apiKey != m.internalAPIKey {
+			c.JSON(http.StatusForbidden, Response{
+				Success: false,
+				Error: &ErrorInfo{
+					Code:    "INVALID_API_KEY",
+					Message: "Invalid internal API key",
+				},
+			})
+			c.Abort()
+			return
 		}
 
-		c.JSON(http.StatusForbidden, Response{
-			Success: false,
-			Error: &ErrorInfo{
-				Code:    "FORBIDDEN",
-				Message: "Insufficient permissions",
-			},
-		})
-		c.Abort()
+		// Mark request as internal
+		c.Set("is_internal", true)
+		c.Next()
 	}
 }
+
+// keyfunc resolves the auth-service public key named by the token's kid
+func (m *AuthMiddleware) keyfunc(token *jwt.Token) (interface{}, error) {
+	return m.keys.KeyFromHeader(token.Header)
+}
@@ -170,3 +170,9 @@ type SubmissionWithExercise struct {
 	Submission *Submission `json:"submission"`
 	Exercise   *Exercise   `json:"exercise"`
 }
+
+// UserDataErasure reports the rows removed or anonymized for a user, keyed by table
+type UserDataErasure struct {
+	Deleted    map[string]int64 `json:"deleted"`
+	Anonymized map[string]int64 `json:"anonymized,omitempty"`
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bisosad1501/ielts-platform/exercise-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// userDataTables lists every table holding a user's rows
+var userDataTables = []string{
+	"user_exercise_attempts",
+	"user_answers",
+}
+
+// ExportUserData returns the user's rows as JSON arrays keyed by table
+func (r *ExerciseRepository) ExportUserData(userID uuid.UUID) (map[string]json.RawMessage, error) {
+	data := make(map[string]json.RawMessage, len(userDataTables))
+	for _, table := range userDataTables {
+		query := fmt.Sprintf(`SELECT COALESCE(json_agg(t), '[]'::json) FROM (SELECT * FROM %s WHERE user_id = $1) t`, table)
+
+		var rows []byte
+		if err := r.db.QueryRow(query, userID).Scan(&rows); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		data[table] = rows
+	}
+
+	return data, nil
+}
+
+// EraseUserData deletes the user's answers, which may hold free text. Attempts
+// are kept for exercise statistics but detached from the user: each gets a
+// random owner and loses its device info. Safe to repeat.
+func (r *ExerciseRepository) EraseUserData(userID uuid.UUID) (*models.UserDataErasure, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	erasure := &models.UserDataErasure{
+		Deleted:    make(map[string]int64),
+		Anonymized: make(map[string]int64),
+	}
+
+	result, err := tx.Exec(`DELETE FROM user_answers WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase user_answers: %w", err)
+	}
+	erasure.Deleted["user_answers"], _ = result.RowsAffected()
+
+	result, err = tx.Exec(`
+		UPDATE user_exercise_attempts
+		SET user_id = uuid_generate_v4(), device_type = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to anonymize user_exercise_attempts: %w", err)
+	}
+	erasure.Anonymized["user_exercise_attempts"], _ = result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit erasure: %w", err)
+	}
+
+	return erasure, nil
+}
@@ -1,12 +1,13 @@
 package routes
 
 import (
+	"github.com/bisosad1501/DATN/shared/pkg/authz"
 	"github.com/bisosad1501/ielts-platform/exercise-service/internal/handlers"
 	"github.com/bisosad1501/ielts-platform/exercise-service/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, handler *handlers.ExerciseHandler, authMiddleware *middleware.AuthMiddleware) {
+func SetupRoutes(router *gin.Engine, handler *handlers.ExerciseHandler, internalHandler *handlers.InternalHandler, authMiddleware *middleware.AuthMiddleware) {
 	// Health check
 	router.GET("/health", handler.HealthCheck)
 
@@ -44,10 +45,10 @@ func SetupRoutes(router *gin.Engine, handler *handlers.ExerciseHandler, authMidd
 			exerciseTags.GET("", handler.GetExerciseTags) // Get exercise tags
 		}
 
-		// Admin routes (instructor/admin only)
+		// Admin routes (exercise:manage permission)
 		admin := api.Group("/admin")
 		admin.Use(authMiddleware.AuthRequired())
-		admin.Use(authMiddleware.RequireRole("instructor", "admin"))
+		admin.Use(authz.RequirePermission("exercise:manage"))
 		{
 			// Exercise management
 			admin.POST("/exercises", handler.CreateExercise)                           // Create exercise
@@ -74,5 +75,14 @@ func SetupRoutes(router *gin.Engine, handler *handlers.ExerciseHandler, authMidd
 			admin.PUT("/question-bank/:id", handler.UpdateBankQuestion)    // Update bank question
 			admin.DELETE("/question-bank/:id", handler.DeleteBankQuestion) // Delete bank question
 		}
+
+		// Internal routes (service-to-service communication only)
+		internal := api.Group("/exercises/internal")
+		internal.Use(authMiddleware.InternalAuth())
+		{
+			// Account deletion and data export (Auth Service)
+			internal.DELETE("/users/:id/data", internalHandler.EraseUserData)
+			internal.GET("/users/:id/export", internalHandler.ExportUserData)
+		}
 	}
 }
@@ -1,10 +1,12 @@
 package service
 
 import (
-	"log"
+	"context"
 	"time"
 
 	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
 	"github.com/bisosad1501/ielts-platform/exercise-service/internal/models"
 	"github.com/bisosad1501/ielts-platform/exercise-service/internal/repository"
 	"github.com/google/uuid"
@@ -14,13 +16,15 @@ type ExerciseService struct {
 	repo               *repository.ExerciseRepository
 	userServiceClient  *client.UserServiceClient
 	notificationClient *client.NotificationServiceClient
+	lifecycle          *lifecycle.Lifecycle // tracks completion work so shutdown waits for it
 }
 
-func NewExerciseService(repo *repository.ExerciseRepository, userServiceClient *client.UserServiceClient, notificationClient *client.NotificationServiceClient) *ExerciseService {
+func NewExerciseService(repo *repository.ExerciseRepository, userServiceClient *client.UserServiceClient, notificationClient *client.NotificationServiceClient, lc *lifecycle.Lifecycle) *ExerciseService {
 	return &ExerciseService{
 		repo:               repo,
 		userServiceClient:  userServiceClient,
 		notificationClient: notificationClient,
+		lifecycle:          lc,
 	}
 }
 
@@ -47,8 +51,9 @@ func (s *ExerciseService) StartExercise(userID, exerciseID uuid.UUID, deviceType
 	return s.repo.CreateSubmission(userID, exerciseID, deviceType)
 }
 
-// SubmitAnswers saves answers and grades the submission
-func (s *ExerciseService) SubmitAnswers(submissionID uuid.UUID, answers []models.SubmitAnswerItem) error {
+// SubmitAnswers saves answers and grades the submission.
+// ctx carries the request's trace, which the follow-up service calls continue.
+func (s *ExerciseService) SubmitAnswers(ctx context.Context, submissionID uuid.UUID, answers []models.SubmitAnswerItem) error {
 	// Save and grade answers
 	err := s.repo.SaveSubmissionAnswers(submissionID, answers)
 	if err != nil {
@@ -62,14 +67,10 @@ func (s *ExerciseService) SubmitAnswers(submissionID uuid.UUID, answers []models
 	}
 
 	// Service-to-service integration: Update user stats and send notification
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("[Exercise-Service] PANIC in handleExerciseCompletion: %v", r)
-			}
-		}()
-		s.handleExerciseCompletion(submissionID)
-	}()
+	// (outlives the request, shutdown waits for it)
+	s.lifecycle.Go(ctx, "handleExerciseCompletion", func(ctx context.Context) {
+		s.handleExerciseCompletion(ctx, submissionID)
+	})
 
 	return nil
 }
@@ -227,13 +228,19 @@ func (s *ExerciseService) GetExerciseAnalytics(exerciseID uuid.UUID) (*models.Ex
 }
 
 // handleExerciseCompletion handles service-to-service integration when exercise is completed
-func (s *ExerciseService) handleExerciseCompletion(submissionID uuid.UUID) {
-	log.Printf("[Exercise-Service] Handling exercise completion for submission %s", submissionID)
+func (s *ExerciseService) handleExerciseCompletion(ctx context.Context, submissionID uuid.UUID) {
+	ctx, span := tracing.StartSpan(ctx, "exercise.handleCompletion")
+	defer span.End()
+
+	userServiceClient := s.userServiceClient.WithContext(ctx)
+	notificationClient := s.notificationClient.WithContext(ctx)
+
+	tracing.Logf(ctx, "[Exercise-Service] Handling exercise completion for submission %s", submissionID)
 
 	// Get submission result (includes both submission and exercise)
 	result, err := s.repo.GetSubmissionResult(submissionID)
 	if err != nil {
-		log.Printf("[Exercise-Service] ERROR: Failed to get submission result: %v", err)
+		tracing.Logf(ctx, "[Exercise-Service] ERROR: Failed to get submission result: %v", err)
 		return
 	}
 
@@ -260,10 +267,10 @@ func (s *ExerciseService) handleExerciseCompletion(submissionID uuid.UUID) {
 
 	// 1. Update skill statistics in User Service (only if score > 0)
 	if score > 0 {
-		log.Printf("[Exercise-Service] Updating skill statistics in User Service...")
+		tracing.Logf(ctx, "[Exercise-Service] Updating skill statistics in User Service...")
 		var lastErr error
 		for attempt := 1; attempt <= maxRetries; attempt++ {
-			err = s.userServiceClient.UpdateSkillStatistics(client.UpdateSkillStatsRequest{
+			err = userServiceClient.UpdateSkillStatistics(client.UpdateSkillStatsRequest{
 				UserID:         submission.UserID.String(),
 				SkillType:      skillType,
 				Score:          score,
@@ -272,29 +279,29 @@ func (s *ExerciseService) handleExerciseCompletion(submissionID uuid.UUID) {
 				TotalPractices: 1,
 			})
 			if err == nil {
-				log.Printf("[Exercise-Service] SUCCESS: Updated skill statistics (attempt %d)", attempt)
+				tracing.Logf(ctx, "[Exercise-Service] SUCCESS: Updated skill statistics (attempt %d)", attempt)
 				break
 			}
 			lastErr = err
 			if attempt < maxRetries {
-				log.Printf("[Exercise-Service] Attempt %d failed, retrying in %v...", attempt, retryDelay)
+				tracing.Logf(ctx, "[Exercise-Service] Attempt %d failed, retrying in %v...", attempt, retryDelay)
 				time.Sleep(retryDelay)
 				retryDelay *= 2 // Exponential backoff
 			}
 		}
 		if lastErr != nil {
-			log.Printf("[Exercise-Service] ERROR: Failed to update skill stats after %d attempts: %v", maxRetries, lastErr)
+			tracing.Logf(ctx, "[Exercise-Service] ERROR: Failed to update skill stats after %d attempts: %v", maxRetries, lastErr)
 		}
 	} else {
-		log.Printf("[Exercise-Service] Skipping skill statistics update (score = 0)")
+		tracing.Logf(ctx, "[Exercise-Service] Skipping skill statistics update (score = 0)")
 	}
 
 	// 2. Update overall progress in User Service
-	log.Printf("[Exercise-Service] Updating user progress in User Service...")
+	tracing.Logf(ctx, "[Exercise-Service] Updating user progress in User Service...")
 	retryDelay = time.Second // Reset delay
 	var lastErr error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err = s.userServiceClient.UpdateProgress(client.UpdateProgressRequest{
+		err = userServiceClient.UpdateProgress(client.UpdateProgressRequest{
 			UserID:            submission.UserID.String(),
 			ExercisesComplete: 1,
 			StudyMinutes:      timeMinutes,
@@ -304,42 +311,42 @@ func (s *ExerciseService) handleExerciseCompletion(submissionID uuid.UUID) {
 			Score:             score,
 		})
 		if err == nil {
-			log.Printf("[Exercise-Service] SUCCESS: Updated user progress (attempt %d)", attempt)
+			tracing.Logf(ctx, "[Exercise-Service] SUCCESS: Updated user progress (attempt %d)", attempt)
 			break
 		}
 		lastErr = err
 		if attempt < maxRetries {
-			log.Printf("[Exercise-Service] Attempt %d failed, retrying in %v...", attempt, retryDelay)
+			tracing.Logf(ctx, "[Exercise-Service] Attempt %d failed, retrying in %v...", attempt, retryDelay)
 			time.Sleep(retryDelay)
 			retryDelay *= 2 // Exponential backoff
 		}
 	}
 	if lastErr != nil {
-		log.Printf("[Exercise-Service] ERROR: Failed to update user progress after %d attempts: %v", maxRetries, lastErr)
+		tracing.Logf(ctx, "[Exercise-Service] ERROR: Failed to update user progress after %d attempts: %v", maxRetries, lastErr)
 	}
 
 	// 3. Send exercise result notification
-	log.Printf("[Exercise-Service] Sending exercise result notification...")
+	tracing.Logf(ctx, "[Exercise-Service] Sending exercise result notification...")
 	retryDelay = time.Second // Reset delay
 	lastErr = nil
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err = s.notificationClient.SendExerciseResultNotification(
+		err = notificationClient.SendExerciseResultNotification(
 			submission.UserID.String(),
 			exercise.Title,
 			score,
 		)
 		if err == nil {
-			log.Printf("[Exercise-Service] SUCCESS: Sent exercise result notification (attempt %d)", attempt)
+			tracing.Logf(ctx, "[Exercise-Service] SUCCESS: Sent exercise result notification (attempt %d)", attempt)
 			break
 		}
 		lastErr = err
 		if attempt < maxRetries {
-			log.Printf("[Exercise-Service] Attempt %d failed, retrying in %v...", attempt, retryDelay)
+			tracing.Logf(ctx, "[Exercise-Service] Attempt %d failed, retrying in %v...", attempt, retryDelay)
 			time.Sleep(retryDelay)
 			retryDelay *= 2 // Exponential backoff
 		}
 	}
 	if lastErr != nil {
-		log.Printf("[Exercise-Service] ERROR: Failed to send notification after %d attempts: %v", maxRetries, lastErr)
+		tracing.Logf(ctx, "[Exercise-Service] ERROR: Failed to send notification after %d attempts: %v", maxRetries, lastErr)
 	}
 }
@@ -1,11 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
 
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
+	"github.com/bisosad1501/DATN/shared/pkg/metrics"
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
 	"github.com/bisosad1501/ielts-platform/notification-service/internal/config"
 	"github.com/bisosad1501/ielts-platform/notification-service/internal/database"
 	"github.com/bisosad1501/ielts-platform/notification-service/internal/handlers"
@@ -23,6 +24,13 @@ func main() {
 		log.Fatalf("❌ Failed to load config: %v", err)
 	}
 
+	// Initialize tracing (exporter selected with OTEL_TRACES_EXPORTER)
+	shutdownTracing, err := tracing.Init("notification-service")
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	log.Println("🚀 Starting Notification Service...")
 	log.Printf("📝 Server Port: %s", cfg.ServerPort)
 	log.Printf("📝 Database: %s", cfg.Database.DBName)
@@ -33,36 +41,35 @@ func main() {
 		log.Fatalf("❌ Failed to connect to database: %v", err)
 	}
 	defer db.Close()
+	metrics.RegisterDBStats(cfg.Database.DBName, db.DB)
+
+	// Graceful shutdown: drains requests, SSE streams get a final "closed" event
+	lc := lifecycle.New("notification-service")
 
 	// Initialize layers
 	notificationRepo := repository.NewNotificationRepository(db.DB)
 	broadcaster := service.NewNotificationBroadcaster()
+	lc.OnShutdown(broadcaster.Shutdown)
 	notificationService := service.NewNotificationService(notificationRepo, broadcaster)
 	notificationHandler := handlers.NewNotificationHandler(notificationService, broadcaster)
 	internalHandler := handlers.NewInternalHandler(notificationService)
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWTSecret, cfg.InternalAPIKey)
+	authMiddleware := middleware.NewAuthMiddleware(cfg.JWKSURL, cfg.InternalAPIKey)
 
 	// Setup Gin
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+	r.Use(tracing.Middleware("notification-service"), metrics.Middleware("notification-service"), tracing.Logger(), gin.Recovery())
+	r.GET("/metrics", metrics.Handler())
+	r.GET("/ready", lc.ReadyHandler())
 
 	// CORS is handled by API Gateway - no need to set headers here
 
 	// Setup routes
 	routes.SetupRoutes(r, notificationHandler, internalHandler, authMiddleware)
 
-	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		if err := r.Run(":" + cfg.ServerPort); err != nil {
-			log.Fatalf("❌ Failed to start server: %v", err)
-		}
-	}()
-
 	log.Printf("✅ Notification Service running on port %s", cfg.ServerPort)
-
-	<-quit
-	log.Println("🛑 Shutting down Notification Service...")
+	if err := lc.Run(":"+cfg.ServerPort, r); err != nil {
+		log.Fatalf("❌ Failed to start server: %v", err)
+	}
+	log.Println("🛑 Notification Service stopped")
 }
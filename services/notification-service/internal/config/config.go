@@ -8,7 +8,7 @@ import (
 
 type Config struct {
 	ServerPort     string
-	JWTSecret      string
+	JWKSURL        string // auth-service key set that verifies access tokens
 	InternalAPIKey string
 	Database       DatabaseConfig
 }
@@ -24,7 +24,7 @@ type DatabaseConfig struct {
 func LoadConfig() (*Config, error) {
 	config := &Config{
 		ServerPort:     getEnv("SERVER_PORT", "8085"),
-		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key"),
+		JWKSURL:        getEnv("JWKS_URL", "http://auth-service:8081/.well-known/jwks.json"),
 		InternalAPIKey: getEnv("INTERNAL_API_KEY", "internal_secret_key_ielts_2025_change_in_production"),
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -35,8 +35,8 @@ func LoadConfig() (*Config, error) {
 		},
 	}
 
-	if config.JWTSecret == "" {
-		return nil, fmt.Errorf("JWT_SECRET is required")
+	if config.JWKSURL == "" {
+		return nil, fmt.Errorf("JWKS_URL is required")
 	}
 
 	return config, nil
@@ -843,8 +843,8 @@ func (h *NotificationHandler) StreamNotifications(c *gin.Context) {
 		select {
 		case data, ok := <-ch:
 			if !ok {
-				// Channel closed
-				c.SSEvent("closed", gin.H{"message": "Connection closed"})
+				// Channel closed by the server shutting down, the client should reconnect
+				c.SSEvent("closed", gin.H{"message": "Connection closed", "reason": "server_shutdown"})
 				c.Writer.Flush()
 				return
 			}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bisosad1501/ielts-platform/notification-service/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EraseUserDataInternal deletes all notification data of a user (internal API).
+// Called by Auth Service when an account deletion runs; safe to repeat.
+// DELETE /api/v1/notifications/internal/users/:id/data
+func (h *InternalHandler) EraseUserDataInternal(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_user_id",
+			Message: "Invalid user ID format",
+		})
+		return
+	}
+
+	erasure, err := h.notificationService.EraseUserData(userID)
+	if err != nil {
+		log.Printf("[Internal] Failed to erase data for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "erase_failed",
+			Message: "Failed to erase user data",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User data erased",
+		"data":    erasure,
+	})
+}
+
+// ExportUserDataInternal returns all notification data of a user (internal API).
+// Called by Auth Service when building a personal-data export.
+// GET /api/v1/notifications/internal/users/:id/export
+func (h *InternalHandler) ExportUserDataInternal(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid_user_id",
+			Message: "Invalid user ID format",
+		})
+		return
+	}
+
+	data, err := h.notificationService.ExportUserData(userID)
+	if err != nil {
+		log.Printf("[Internal] Failed to export data for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "export_failed",
+			Message: "Failed to export user data",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    data,
+	})
+}
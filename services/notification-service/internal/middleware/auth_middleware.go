@@ -1,10 +1,11 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/bisosad1501/DATN/shared/pkg/authz"
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
 	"github.com/bisosad1501/ielts-platform/notification-service/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -12,13 +13,14 @@ import (
 )
 
 type AuthMiddleware struct {
-	jwtSecret      string
+	keys           *jwks.Cache
 	internalAPIKey string
 }
 
-func NewAuthMiddleware(jwtSecret, internalAPIKey string) *AuthMiddleware {
+// NewAuthMiddleware verifies tokens against the key set served at jwksURL
+func NewAuthMiddleware(jwksURL, internalAPIKey string) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret:      jwtSecret,
+		keys:           jwks.NewCache(jwksURL),
 		internalAPIKey: internalAPIKey,
 	}
 }
@@ -28,6 +30,8 @@ type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
 	Email  string    `json:"email"`
 	Role   string    `json:"role"`
+	// Permissions granted through the user's roles
+	Permissions []string `json:"permissions"`
 	jwt.RegisteredClaims
 }
 
@@ -47,6 +51,7 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 				if role := c.GetHeader("X-User-Role"); role != "" {
 					c.Set("role", role)
 				}
+				authz.SetFromHeader(c)
 				c.Next()
 				return
 			}
@@ -79,13 +84,7 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 		tokenString := parts[1]
 
 		// Parse and validate token
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			// Verify signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, m.keyfunc, jwt.WithValidMethods(jwks.Algorithms))
 
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
@@ -113,45 +112,7 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
-
-		c.Next()
-	}
-}
-
-// RequireRole checks if user has required role
-func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userRole, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
-				Error:   "unauthorized",
-				Message: "User role not found in context",
-				Code:    "AUTH_005",
-			})
-			c.Abort()
-			return
-		}
-
-		role := userRole.(string)
-
-		// Check if user's role is in allowed roles
-		allowed := false
-		for _, allowedRole := range roles {
-			if role == allowedRole {
-				allowed = true
-				break
-			}
-		}
-
-		if !allowed {
-			c.JSON(http.StatusForbidden, models.ErrorResponse{
-				Error:   "forbidden",
-				Message: fmt.Sprintf("Access denied. Required role: %v. Your role: %s", roles, role),
-				Code:    "AUTH_006",
-			})
-			c.Abort()
-			return
-		}
+		authz.Set(c, claims.Permissions)
 
 		c.Next()
 	}
@@ -186,3 +147,8 @@ func (m *AuthMiddleware) InternalAuth() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// keyfunc resolves the auth-service public key named by the token's kid
+func (m *AuthMiddleware) keyfunc(token *jwt.Token) (interface{}, error) {
+	return m.keys.KeyFromHeader(token.Header)
+}
@@ -187,3 +187,9 @@ type ScheduledNotificationResponse struct {
 	CreatedAt     string    `json:"created_at"`             // ISO8601
 	UpdatedAt     string    `json:"updated_at"`             // ISO8601
 }
+
+// UserDataErasure reports the rows removed for a user, keyed by table
+type UserDataErasure struct {
+	Deleted    map[string]int64 `json:"deleted"`
+	Anonymized map[string]int64 `json:"anonymized,omitempty"`
+}
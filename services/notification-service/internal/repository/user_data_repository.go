@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bisosad1501/ielts-platform/notification-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// userDataTables lists every table holding a user's rows. Erasure runs in this
+// order so rows pointing at notifications go before the notifications.
+var userDataTables = []string{
+	"notification_logs",
+	"push_notifications",
+	"email_notifications",
+	"scheduled_notifications",
+	"device_tokens",
+	"notification_preferences",
+	"notifications",
+}
+
+// ExportUserData returns the user's rows as JSON arrays keyed by table
+func (r *NotificationRepository) ExportUserData(userID uuid.UUID) (map[string]json.RawMessage, error) {
+	data := make(map[string]json.RawMessage, len(userDataTables))
+	for _, table := range userDataTables {
+		query := fmt.Sprintf(`SELECT COALESCE(json_agg(t), '[]'::json) FROM (SELECT * FROM %s WHERE user_id = $1) t`, table)
+
+		var rows []byte
+		if err := r.db.QueryRow(query, userID).Scan(&rows); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		data[table] = rows
+	}
+
+	return data, nil
+}
+
+// EraseUserData deletes every notification, device token and preference of the
+// user. Safe to repeat.
+func (r *NotificationRepository) EraseUserData(userID uuid.UUID) (*models.UserDataErasure, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	erasure := &models.UserDataErasure{Deleted: make(map[string]int64, len(userDataTables))}
+	for _, table := range userDataTables {
+		result, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1`, table), userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to erase %s: %w", table, err)
+		}
+		erasure.Deleted[table], _ = result.RowsAffected()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit erasure: %w", err)
+	}
+
+	return erasure, nil
+}
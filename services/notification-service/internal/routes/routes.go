@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"github.com/bisosad1501/DATN/shared/pkg/authz"
 	"github.com/bisosad1501/ielts-platform/notification-service/internal/handlers"
 	"github.com/bisosad1501/ielts-platform/notification-service/internal/middleware"
 	"github.com/gin-gonic/gin"
@@ -53,7 +54,7 @@ func SetupRoutes(r *gin.Engine, handler *handlers.NotificationHandler, internalH
 	// Admin routes
 	admin := v1.Group("/admin/notifications")
 	admin.Use(authMiddleware.Authenticate())
-	admin.Use(authMiddleware.RequireRole("admin", "instructor"))
+	admin.Use(authz.RequirePermission("notification:send"))
 	{
 		admin.POST("", handler.CreateNotification)         // Create notification for a user
 		admin.POST("/bulk", handler.SendBulkNotifications) // Send bulk notifications
@@ -66,5 +67,7 @@ func SetupRoutes(r *gin.Engine, handler *handlers.NotificationHandler, internalH
 		internal.POST("/send", internalHandler.SendNotificationInternal)     // Send notification from another service
 		internal.POST("/bulk", internalHandler.SendBulkNotificationInternal) // Send bulk notifications from another service
 		internal.PUT("/preferences/:user_id", internalHandler.UpdatePreferencesInternal) // Update preferences for a user (internal)
+		internal.DELETE("/users/:id/data", internalHandler.EraseUserDataInternal)       // Erase a user's data (account deletion)
+		internal.GET("/users/:id/export", internalHandler.ExportUserDataInternal)       // Export a user's data
 	}
 }
@@ -7,12 +7,26 @@ import (
 
 	"github.com/bisosad1501/ielts-platform/notification-service/internal/models"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	sseSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "notification_sse_subscribers",
+		Help: "Open SSE notification streams.",
+	})
+	sseSubscribedUsers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "notification_sse_subscribed_users",
+		Help: "Users with at least one open SSE notification stream.",
+	})
 )
 
 // NotificationBroadcaster manages SSE connections and broadcasts notifications
 type NotificationBroadcaster struct {
 	clients map[uuid.UUID]map[chan []byte]bool // userID -> channels
 	mu      sync.RWMutex
+	closed  bool // set by Shutdown, new subscribers get a closed channel
 }
 
 // NewNotificationBroadcaster creates a new broadcaster
@@ -27,12 +41,19 @@ func (nb *NotificationBroadcaster) Subscribe(userID uuid.UUID) chan []byte {
 	nb.mu.Lock()
 	defer nb.mu.Unlock()
 
+	ch := make(chan []byte, 10) // Buffer to prevent blocking
+	if nb.closed {
+		close(ch)
+		return ch
+	}
+
 	if nb.clients[userID] == nil {
 		nb.clients[userID] = make(map[chan []byte]bool)
 	}
 
-	ch := make(chan []byte, 10) // Buffer to prevent blocking
 	nb.clients[userID][ch] = true
+	sseSubscribers.Inc()
+	sseSubscribedUsers.Set(float64(len(nb.clients)))
 
 	log.Printf("[Notification-Broadcaster] ✅ Subscribed user %s (total clients: %d)", userID, len(nb.clients[userID]))
 	return ch
@@ -43,16 +64,42 @@ func (nb *NotificationBroadcaster) Unsubscribe(userID uuid.UUID, ch chan []byte)
 	nb.mu.Lock()
 	defer nb.mu.Unlock()
 
-	if nb.clients[userID] != nil {
-		delete(nb.clients[userID], ch)
-		close(ch)
+	// Already closed by Shutdown
+	if !nb.clients[userID][ch] {
+		return
+	}
 
-		if len(nb.clients[userID]) == 0 {
-			delete(nb.clients, userID)
-		}
+	delete(nb.clients[userID], ch)
+	close(ch)
+	sseSubscribers.Dec()
+
+	if len(nb.clients[userID]) == 0 {
+		delete(nb.clients, userID)
+	}
+	sseSubscribedUsers.Set(float64(len(nb.clients)))
+
+	log.Printf("[Notification-Broadcaster] ❌ Unsubscribed user %s (remaining clients: %d)", userID, len(nb.clients[userID]))
+}
 
-		log.Printf("[Notification-Broadcaster] ❌ Unsubscribed user %s (remaining clients: %d)", userID, len(nb.clients[userID]))
+// Shutdown closes every stream so handlers send a final "closed" event and return
+// instead of holding the server's drain open until the deadline
+func (nb *NotificationBroadcaster) Shutdown() {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	count := 0
+	for userID, clients := range nb.clients {
+		for ch := range clients {
+			close(ch)
+			count++
+		}
+		delete(nb.clients, userID)
 	}
+	nb.closed = true
+	sseSubscribers.Set(0)
+	sseSubscribedUsers.Set(0)
+
+	log.Printf("[Notification-Broadcaster] 🛑 Closed %d stream(s) for shutdown", count)
 }
 
 // Broadcast sends a notification to all clients of a specific user
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/bisosad1501/ielts-platform/notification-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// ExportUserData returns everything the service stores about the user, keyed by table
+func (s *NotificationService) ExportUserData(userID uuid.UUID) (map[string]json.RawMessage, error) {
+	return s.repo.ExportUserData(userID)
+}
+
+// EraseUserData removes the user's notifications, device tokens and preferences
+// (called by the auth service's deletion saga)
+func (s *NotificationService) EraseUserData(userID uuid.UUID) (*models.UserDataErasure, error) {
+	erasure, err := s.repo.EraseUserData(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[NotificationService] Erased data for user %s: %v", userID, erasure.Deleted)
+	return erasure, nil
+}
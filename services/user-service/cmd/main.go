@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -11,6 +12,10 @@ import (
 	"github.com/bisosad1501/DATN/services/user-service/internal/repository"
 	"github.com/bisosad1501/DATN/services/user-service/internal/routes"
 	"github.com/bisosad1501/DATN/services/user-service/internal/service"
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
+	"github.com/bisosad1501/DATN/shared/pkg/metrics"
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
@@ -22,18 +27,29 @@ func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Initialize tracing (exporter selected with OTEL_TRACES_EXPORTER)
+	shutdownTracing, err := tracing.Init("user-service")
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize database
 	db, err := database.NewDatabase(cfg)
 	if err != nil {
 		log.Fatalf("❌ Failed to initialize database: %v", err)
 	}
 	defer db.Close()
+	metrics.RegisterDBStats(cfg.DBName, db.DB)
 
 	// Initialize repository
 	userRepo := repository.NewUserRepository(db)
 
+	// Graceful shutdown: drains requests and waits for sync/notification work
+	lc := lifecycle.New("user-service")
+
 	// Initialize service
-	userService := service.NewUserService(userRepo, cfg)
+	userService := service.NewUserService(userRepo, cfg, lc)
 
 	// Initialize middleware
 	authMiddleware := middleware.NewAuthMiddleware(cfg)
@@ -42,8 +58,14 @@ func main() {
 	userHandler := handlers.NewUserHandler(userService)
 	internalHandler := handlers.NewInternalHandler(userService)
 
+	// Setup Gin router
+	router := gin.New()
+	router.Use(tracing.Middleware("user-service"), metrics.Middleware("user-service"), tracing.Logger(), gin.Recovery())
+	router.GET("/metrics", metrics.Handler())
+	router.GET("/ready", lc.ReadyHandler())
+
 	// Setup routes
-	router := routes.SetupRoutes(userHandler, internalHandler, authMiddleware)
+	routes.SetupRoutes(router, userHandler, internalHandler, authMiddleware)
 
 	// Start server
 	port := ":" + cfg.ServerPort
@@ -51,7 +73,7 @@ func main() {
 	log.Printf("🔗 Health check: http://localhost%s/health", port)
 	log.Printf("📚 API documentation: http://localhost%s/api/v1/user", port)
 
-	if err := router.Run(port); err != nil {
+	if err := lc.Run(port, router); err != nil {
 		log.Fatalf("❌ Failed to start server: %v", err)
 	}
 }
@@ -61,7 +61,7 @@ func (h *InternalHandler) CreateProfileInternal(c *gin.Context) {
 	}
 
 	// Create profile with full name and target band score
-	err = h.userService.CreateProfileWithData(userID, req.FullName, req.TargetBandScore)
+	err = h.userService.CreateProfileWithData(userID, req.Email, req.FullName, req.TargetBandScore)
 	if err != nil {
 		log.Printf("❌ Failed to create profile for user %s: %v", req.UserID, err)
 		c.JSON(http.StatusInternalServerError, models.Response{
@@ -86,6 +86,65 @@ func (h *InternalHandler) CreateProfileInternal(c *gin.Context) {
 	})
 }
 
+// UpdateEmailInternal applies a confirmed email change (called by Auth Service)
+func (h *InternalHandler) UpdateEmailInternal(c *gin.Context) {
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+		Email  string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Error: &models.ErrorInfo{
+				Code:    "INVALID_REQUEST",
+				Message: "Invalid request payload",
+				Details: err.Error(),
+			},
+		})
+		return
+	}
+
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Error: &models.ErrorInfo{
+				Code:    "INVALID_USER_ID",
+				Message: "Invalid user ID format",
+			},
+		})
+		return
+	}
+
+	if err := h.userService.UpdateEmail(userID, req.Email); err != nil {
+		if err.Error() == "profile not found" {
+			c.JSON(http.StatusNotFound, models.Response{
+				Success: false,
+				Error: &models.ErrorInfo{
+					Code:    "PROFILE_NOT_FOUND",
+					Message: "User profile not found",
+				},
+			})
+			return
+		}
+		log.Printf("❌ Failed to update email for user %s: %v", req.UserID, err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Error: &models.ErrorInfo{
+				Code:    "EMAIL_UPDATE_FAILED",
+				Message: "Failed to update email",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "Email updated successfully",
+	})
+}
+
 // UpdateProgressInternal updates user learning progress (called by Course/Exercise services)
 func (h *InternalHandler) UpdateProgressInternal(c *gin.Context) {
 	var req struct {
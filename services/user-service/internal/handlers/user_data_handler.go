@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bisosad1501/DATN/services/user-service/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EraseUserDataInternal deletes all data kept for a user (called by Auth Service
+// when an account deletion runs). Safe to repeat.
+func (h *InternalHandler) EraseUserDataInternal(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	erasure, err := h.userService.EraseUserData(userID)
+	if err != nil {
+		log.Printf("❌ Failed to erase data for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Error: &models.ErrorInfo{
+				Code:    "ERASE_FAILED",
+				Message: "Failed to erase user data",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Message: "User data erased",
+		Data:    erasure,
+	})
+}
+
+// ExportUserDataInternal returns all data kept for a user (called by Auth Service
+// when building a personal-data export)
+func (h *InternalHandler) ExportUserDataInternal(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.userService.ExportUserData(userID)
+	if err != nil {
+		log.Printf("❌ Failed to export data for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Error: &models.ErrorInfo{
+				Code:    "EXPORT_FAILED",
+				Message: "Failed to export user data",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// GetPreferencesInternal returns a user's preferences (called by Auth Service
+// to pick the language of the emails it sends)
+func (h *InternalHandler) GetPreferencesInternal(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := h.userService.GetPreferences(userID)
+	if err != nil {
+		log.Printf("❌ Failed to get preferences for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Error: &models.ErrorInfo{
+				Code:    "PREFERENCES_FETCH_FAILED",
+				Message: "Failed to get preferences",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Data:    prefs,
+	})
+}
+
+// GetProfileInternal returns a user's profile (used by Auth Service to keep
+// the user's name out of new passwords)
+func (h *InternalHandler) GetProfileInternal(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	profile, err := h.userService.GetOrCreateProfile(userID)
+	if err != nil {
+		log.Printf("❌ Failed to get profile for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			Success: false,
+			Error: &models.ErrorInfo{
+				Code:    "PROFILE_FETCH_FAILED",
+				Message: "Failed to get profile",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		Success: true,
+		Data:    profile,
+	})
+}
+
+func parseUserIDParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			Success: false,
+			Error: &models.ErrorInfo{
+				Code:    "INVALID_USER_ID",
+				Message: "Invalid user ID format",
+			},
+		})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
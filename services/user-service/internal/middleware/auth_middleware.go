@@ -1,24 +1,25 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/bisosad1501/DATN/services/user-service/internal/config"
 	"github.com/bisosad1501/DATN/services/user-service/internal/models"
+	"github.com/bisosad1501/DATN/shared/pkg/authz"
+	"github.com/bisosad1501/DATN/shared/pkg/jwks"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthMiddleware struct {
-	jwtSecret      string
+	keys           *jwks.Cache
 	internalAPIKey string
 }
 
 func NewAuthMiddleware(cfg *config.Config) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret:      cfg.JWTSecret,
+		keys:           jwks.NewCache(cfg.JWKSURL),
 		internalAPIKey: cfg.InternalAPIKey,
 	}
 }
@@ -54,12 +55,7 @@ func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 		}
 
 		// Parse and validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		token, err := jwt.Parse(tokenString, m.keyfunc, jwt.WithValidMethods(jwks.Algorithms))
 
 		if err != nil || !token.Valid {
 			c.JSON(http.StatusUnauthorized, models.Response{
@@ -92,46 +88,12 @@ func (m *AuthMiddleware) AuthRequired() gin.HandlerFunc {
 		c.Set("user_id", claims["user_id"])
 		c.Set("email", claims["email"])
 		c.Set("role", claims["role"])
+		authz.SetFromClaims(c, claims)
 
 		c.Next()
 	}
 }
 
-// RequireRole checks if user has specific role
-func (m *AuthMiddleware) RequireRole(allowedRoles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, models.Response{
-				Success: false,
-				Error: &models.ErrorInfo{
-					Code:    "FORBIDDEN",
-					Message: "Role information not found",
-				},
-			})
-			c.Abort()
-			return
-		}
-
-		userRole := role.(string)
-		for _, allowedRole := range allowedRoles {
-			if userRole == allowedRole {
-				c.Next()
-				return
-			}
-		}
-
-		c.JSON(http.StatusForbidden, models.Response{
-			Success: false,
-			Error: &models.ErrorInfo{
-				Code:    "INSUFFICIENT_PERMISSIONS",
-				Message: fmt.Sprintf("This action requires one of these roles: %v", allowedRoles),
-			},
-		})
-		c.Abort()
-	}
-}
-
 // OptionalAuth validates token if present, but allows requests without token
 // Used for public endpoints that may need user context for visibility checks
 func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
@@ -152,12 +114,7 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 		}
 
 		// Try to parse and validate token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(m.jwtSecret), nil
-		})
+		token, err := jwt.Parse(tokenString, m.keyfunc, jwt.WithValidMethods(jwks.Algorithms))
 
 		// If token is valid, set user context
 		if err == nil && token.Valid {
@@ -167,6 +124,7 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 				if role, ok := claims["role"].(string); ok {
 					c.Set("role", role)
 				}
+				authz.SetFromClaims(c, claims)
 			}
 		}
 
@@ -207,3 +165,8 @@ func (m *AuthMiddleware) InternalAuth() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// keyfunc resolves the auth-service public key named by the token's kid
+func (m *AuthMiddleware) keyfunc(token *jwt.Token) (interface{}, error) {
+	return m.keys.KeyFromHeader(token.Header)
+}
@@ -159,3 +159,11 @@ type StatisticsResponse struct {
 	WeakSkills         []string                    `json:"weak_skills"`
 	StrongSkills       []string                    `json:"strong_skills"`
 }
+
+// ============= Account Data DTOs =============
+
+// UserDataErasure reports the rows removed for a user, keyed by table
+type UserDataErasure struct {
+	Deleted    map[string]int64 `json:"deleted"`
+	Anonymized map[string]int64 `json:"anonymized,omitempty"`
+}
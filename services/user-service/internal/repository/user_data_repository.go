@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bisosad1501/DATN/services/user-service/internal/models"
+	"github.com/google/uuid"
+)
+
+// userDataTables lists every table holding a user's rows. Erasure runs in this
+// order so children are gone before user_profiles, which they reference.
+var userDataTables = []struct {
+	table string
+	where string
+}{
+	{"user_follows", "follower_id = $1 OR following_id = $1"},
+	{"study_reminders", "user_id = $1"},
+	{"user_preferences", "user_id = $1"},
+	{"user_achievements", "user_id = $1"},
+	{"study_goals", "user_id = $1"},
+	{"study_sessions", "user_id = $1"},
+	{"skill_statistics", "user_id = $1"},
+	{"learning_progress", "user_id = $1"},
+	{"user_profiles", "user_id = $1"},
+}
+
+// ExportUserData returns the user's rows as JSON arrays keyed by table
+func (r *UserRepository) ExportUserData(userID uuid.UUID) (map[string]json.RawMessage, error) {
+	data := make(map[string]json.RawMessage, len(userDataTables))
+	for _, t := range userDataTables {
+		query := fmt.Sprintf(`SELECT COALESCE(json_agg(t), '[]'::json) FROM (SELECT * FROM %s WHERE %s) t`, t.table, t.where)
+
+		var rows []byte
+		if err := r.db.DB.QueryRow(query, userID).Scan(&rows); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", t.table, err)
+		}
+		data[t.table] = rows
+	}
+
+	return data, nil
+}
+
+// EraseUserData deletes the profile and everything hanging off it. Running it
+// again for an erased user deletes nothing and succeeds.
+func (r *UserRepository) EraseUserData(userID uuid.UUID) (*models.UserDataErasure, error) {
+	tx, err := r.db.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	erasure := &models.UserDataErasure{Deleted: make(map[string]int64, len(userDataTables))}
+	for _, t := range userDataTables {
+		result, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s`, t.table, t.where), userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to erase %s: %w", t.table, err)
+		}
+		erasure.Deleted[t.table], _ = result.RowsAffected()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit erasure: %w", err)
+	}
+
+	return erasure, nil
+}
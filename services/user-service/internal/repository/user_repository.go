@@ -21,11 +21,11 @@ func NewUserRepository(db *database.Database) *UserRepository {
 
 // CreateProfile creates a new user profile
 func (r *UserRepository) CreateProfile(userID uuid.UUID) error {
-	return r.CreateProfileWithData(userID, "", 0)
+	return r.CreateProfileWithData(userID, "", "", 0)
 }
 
-// CreateProfileWithData creates a new user profile with full name and target band score
-func (r *UserRepository) CreateProfileWithData(userID uuid.UUID, fullName string, targetBandScore float64) error {
+// CreateProfileWithData creates a new user profile with email, full name and target band score
+func (r *UserRepository) CreateProfileWithData(userID uuid.UUID, email, fullName string, targetBandScore float64) error {
 	// Build query dynamically based on provided data
 	query := `
 		INSERT INTO user_profiles (user_id, timezone, language_preference`
@@ -33,6 +33,13 @@ func (r *UserRepository) CreateProfileWithData(userID uuid.UUID, fullName string
 	args := []interface{}{userID, "Asia/Ho_Chi_Minh", "vi"}
 	argCount := 3
 
+	if email != "" {
+		argCount++
+		query += `, email`
+		values += `, $` + fmt.Sprintf("%d", argCount)
+		args = append(args, email)
+	}
+
 	if fullName != "" {
 		argCount++
 		query += `, full_name`
@@ -49,6 +56,9 @@ func (r *UserRepository) CreateProfileWithData(userID uuid.UUID, fullName string
 
 	// Build UPDATE clause for ON CONFLICT
 	updateClause := ""
+	if email != "" {
+		updateClause += ` email = EXCLUDED.email,`
+	}
 	if fullName != "" {
 		updateClause += ` full_name = EXCLUDED.full_name,`
 	}
@@ -250,6 +260,26 @@ func (r *UserRepository) UpdateAvatar(userID uuid.UUID, avatarURL string) error
 	return nil
 }
 
+// UpdateEmail updates the email copied from Auth Service; false means no profile exists
+func (r *UserRepository) UpdateEmail(userID uuid.UUID, email string) (bool, error) {
+	query := `
+		UPDATE user_profiles
+		SET email = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $2
+	`
+	result, err := r.db.DB.Exec(query, email, userID)
+	if err != nil {
+		log.Printf("❌ Error updating email for user %s: %v", userID, err)
+		return false, fmt.Errorf("failed to update email: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
 // GetLearningProgress retrieves learning progress for a user with REAL-TIME study hours
 func (r *UserRepository) GetLearningProgress(userID uuid.UUID) (*models.LearningProgress, error) {
 	// 📊 Query learning_progress (without deprecated total_study_hours field)
@@ -6,9 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(handler *handlers.UserHandler, internalHandler *handlers.InternalHandler, authMiddleware *middleware.AuthMiddleware) *gin.Engine {
-	router := gin.Default()
-
+func SetupRoutes(router *gin.Engine, handler *handlers.UserHandler, internalHandler *handlers.InternalHandler, authMiddleware *middleware.AuthMiddleware) {
 	// Health check
 	router.GET("/health", handler.HealthCheck)
 
@@ -90,6 +88,7 @@ func SetupRoutes(handler *handlers.UserHandler, internalHandler *handlers.Intern
 		{
 			// Profile management
 			internal.POST("/profile/create", internalHandler.CreateProfileInternal)
+			internal.PUT("/profile/email/update", internalHandler.UpdateEmailInternal)
 
 			// Progress updates
 			internal.PUT("/progress/update", internalHandler.UpdateProgressInternal)
@@ -101,8 +100,16 @@ func SetupRoutes(handler *handlers.UserHandler, internalHandler *handlers.Intern
 		internal.POST("/session/start", internalHandler.StartSessionInternal)
 		internal.PUT("/session/:session_id/end", internalHandler.EndSessionInternal)
 		internal.POST("/session/record", internalHandler.RecordCompletedSessionInternal)
+
+			// Account deletion and data export (Auth Service)
+			internal.DELETE("/users/:id/data", internalHandler.EraseUserDataInternal)
+			internal.GET("/users/:id/export", internalHandler.ExportUserDataInternal)
+
+			// Email language (Auth Service)
+			internal.GET("/users/:id/preferences", internalHandler.GetPreferencesInternal)
+
+			// Name for the password policy (Auth Service)
+			internal.GET("/users/:id/profile", internalHandler.GetProfileInternal)
 		}
 	}
-
-	return router
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -10,15 +11,17 @@ import (
 	"github.com/bisosad1501/DATN/services/user-service/internal/models"
 	"github.com/bisosad1501/DATN/services/user-service/internal/repository"
 	"github.com/bisosad1501/DATN/shared/pkg/client"
+	"github.com/bisosad1501/DATN/shared/pkg/lifecycle"
 	"github.com/google/uuid"
 )
 
 type UserService struct {
 	repo              *repository.UserRepository
 	notificationClient *client.NotificationServiceClient
+	lifecycle          *lifecycle.Lifecycle // tracks sync/notification work so shutdown waits for it
 }
 
-func NewUserService(repo *repository.UserRepository, cfg *config.Config) *UserService {
+func NewUserService(repo *repository.UserRepository, cfg *config.Config, lc *lifecycle.Lifecycle) *UserService {
 	var notificationClient *client.NotificationServiceClient
 	if cfg != nil && cfg.NotificationServiceURL != "" {
 		notificationClient = client.NewNotificationServiceClient(
@@ -33,6 +36,7 @@ func NewUserService(repo *repository.UserRepository, cfg *config.Config) *UserSe
 	return &UserService{
 		repo:               repo,
 		notificationClient: notificationClient,
+		lifecycle:          lc,
 	}
 }
 
@@ -569,22 +573,16 @@ func (s *UserService) UpdatePreferences(userID uuid.UUID, req *models.UpdatePref
 		
 		// Sync with Notification Service (source of truth)
 		if s.notificationClient != nil {
-			go func() {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("[User-Service] PANIC in notification sync: %v", r)
-					}
-				}()
-				
-				pushEnabled := *req.PushNotifications
-				err := s.syncPushNotificationPreference(userID, pushEnabled)
+			pushEnabled := *req.PushNotifications
+			s.lifecycle.Go(context.Background(), "notification sync", func(ctx context.Context) {
+				err := s.syncPushNotificationPreference(ctx, userID, pushEnabled)
 				if err != nil {
 					log.Printf("[User-Service] ⚠️  Failed to sync push_notifications with Notification Service: %v", err)
 					// Non-critical error, continue with User Service update
 				} else {
 					log.Printf("[User-Service] ✅ Synced push_notifications=%v with Notification Service", pushEnabled)
 				}
-			}()
+			})
 		}
 	}
 	if req.StudyReminders != nil {
@@ -636,7 +634,7 @@ func (s *UserService) UpdatePreferences(userID uuid.UUID, req *models.UpdatePref
 // syncPushNotificationPreference syncs push_notifications with Notification Service
 // Notification Service is the source of truth for notification preferences
 // Uses retry mechanism with exponential backoff for reliability
-func (s *UserService) syncPushNotificationPreference(userID uuid.UUID, pushEnabled bool) error {
+func (s *UserService) syncPushNotificationPreference(ctx context.Context, userID uuid.UUID, pushEnabled bool) error {
 	if s.notificationClient == nil || s.notificationClient.ServiceClient == nil {
 		return fmt.Errorf("notification client not initialized")
 	}
@@ -656,14 +654,16 @@ func (s *UserService) syncPushNotificationPreference(userID uuid.UUID, pushEnabl
 	
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		// Use internal API endpoint for service-to-service communication
-		resp, err := s.notificationClient.ServiceClient.Put(endpoint, payload)
+		resp, err := s.notificationClient.ServiceClient.WithContext(ctx).Put(endpoint, payload)
 		if err != nil {
 			lastErr = fmt.Errorf("failed to call Notification Service: %w", err)
 			if attempt < maxRetries {
 				// Exponential backoff: 1s, 2s, 4s
 				backoff := time.Duration(1<<uint(attempt-1)) * time.Second
 				log.Printf("[User-Service] ⚠️  Sync attempt %d/%d failed, retrying in %v: %v", attempt, maxRetries, backoff, lastErr)
-				time.Sleep(backoff)
+				if !sleepContext(ctx, backoff) {
+					return fmt.Errorf("sync interrupted by shutdown: %w", lastErr)
+				}
 				continue
 			}
 			return lastErr
@@ -692,13 +692,25 @@ func (s *UserService) syncPushNotificationPreference(userID uuid.UUID, pushEnabl
 		if attempt < maxRetries {
 			backoff := time.Duration(1<<uint(attempt-1)) * time.Second
 			log.Printf("[User-Service] ⚠️  Server error on attempt %d/%d, retrying in %v: %v", attempt, maxRetries, backoff, lastErr)
-			time.Sleep(backoff)
+			if !sleepContext(ctx, backoff) {
+				return fmt.Errorf("sync interrupted by shutdown: %w", lastErr)
+			}
 		}
 	}
 
 	return lastErr
 }
 
+// sleepContext waits for d and reports false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 // ============= Study Reminders =============
 
 // CreateReminder creates a new study reminder with validation
@@ -835,13 +847,7 @@ func (s *UserService) FollowUser(followerID, followingID uuid.UUID) error {
 
 	// Send notification to the user being followed (async, non-blocking)
 	if s.notificationClient != nil {
-		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("[User-Service] PANIC in follow notification: %v", r)
-				}
-			}()
-
+		s.lifecycle.Go(context.Background(), "follow notification", func(ctx context.Context) {
 			// Get follower's profile for notification
 			followerProfile, err := s.repo.GetProfileByUserID(followerID)
 			if err != nil {
@@ -857,7 +863,7 @@ func (s *UserService) FollowUser(followerID, followingID uuid.UUID) error {
 
 			// Send notification with translation keys
 			actionType := "navigate_to_user_profile"
-			notificationErr := s.notificationClient.SendNotification(client.SendNotificationRequest{
+			notificationErr := s.notificationClient.WithContext(ctx).SendNotification(client.SendNotificationRequest{
 				UserID:     followingID.String(),
 				Title:      "notifications.new_follower_title", // Translation key
 				Message:    "notifications.new_follower_message", // Translation key
@@ -876,7 +882,7 @@ func (s *UserService) FollowUser(followerID, followingID uuid.UUID) error {
 			} else {
 				log.Printf("[User-Service] ✅ Sent follow notification to user %s", followingID.String())
 			}
-		}()
+		})
 	}
 
 	return nil
@@ -977,9 +983,21 @@ func (s *UserService) CreateProfile(profile *models.UserProfile) error {
 	return s.repo.CreateProfile(profile.UserID)
 }
 
-// CreateProfileWithData creates a new user profile with full name and target band score
-func (s *UserService) CreateProfileWithData(userID uuid.UUID, fullName string, targetBandScore float64) error {
-	return s.repo.CreateProfileWithData(userID, fullName, targetBandScore)
+// CreateProfileWithData creates a new user profile with email, full name and target band score
+func (s *UserService) CreateProfileWithData(userID uuid.UUID, email, fullName string, targetBandScore float64) error {
+	return s.repo.CreateProfileWithData(userID, email, fullName, targetBandScore)
+}
+
+// UpdateEmail applies an email change confirmed in Auth Service
+func (s *UserService) UpdateEmail(userID uuid.UUID, email string) error {
+	found, err := s.repo.UpdateEmail(userID, email)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("profile not found")
+	}
+	return nil
 }
 
 // UpdateProgress updates user learning progress using atomic operations
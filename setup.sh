@@ -75,8 +75,9 @@ if [ ! -f .env ]; then
     else
         echo -e "${YELLOW}⚠️  .env.example not found. Creating default .env...${NC}"
         cat > .env << 'ENVEOF'
-# JWT Configuration
-JWT_SECRET=your-super-secret-jwt-key-change-this-in-production-2025
+# JWT Configuration (empty JWT_KEYS_DIR = ephemeral development key)
+JWT_KEYS_DIR=
+JWT_ACTIVE_KEY_ID=
 JWT_EXPIRATION=86400
 
 # Database Configuration
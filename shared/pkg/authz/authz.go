@@ -0,0 +1,97 @@
+// Package authz enforces fine-grained permissions named "<resource>:<action>"
+// (e.g. course:publish). Permissions are granted to roles in auth_db
+// (role_permissions), resolved by auth-service at login and embedded in the
+// access token's "permissions" claim. The gateway forwards them downstream as
+// the X-User-Permissions header.
+//
+// Each service's auth middleware stores the permissions with SetFromClaims or
+// SetFromHeader; routes are then guarded with RequirePermission.
+package authz
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ClaimName is the JWT claim holding the permission list
+	ClaimName = "permissions"
+	// HeaderName carries the comma-separated permissions set by the gateway
+	HeaderName = "X-User-Permissions"
+
+	contextKey = "permissions"
+)
+
+// Set stores the caller's permissions in the gin context
+func Set(c *gin.Context, permissions []string) {
+	c.Set(contextKey, permissions)
+}
+
+// SetFromClaims stores the permissions claim of a decoded token
+// (jwt.MapClaims or any other map of claims)
+func SetFromClaims(c *gin.Context, claims map[string]interface{}) {
+	raw, _ := claims[ClaimName].([]interface{})
+	permissions := make([]string, 0, len(raw))
+	for _, p := range raw {
+		if s, ok := p.(string); ok && s != "" {
+			permissions = append(permissions, s)
+		}
+	}
+	Set(c, permissions)
+}
+
+// SetFromHeader stores the permissions forwarded by the gateway
+func SetFromHeader(c *gin.Context) {
+	Set(c, ParseHeader(c.GetHeader(HeaderName)))
+}
+
+// ParseHeader splits a comma-separated permission list
+func ParseHeader(value string) []string {
+	permissions := []string{}
+	for _, p := range strings.Split(value, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+// Permissions returns the permissions stored for the request
+func Permissions(c *gin.Context) []string {
+	permissions, _ := c.Get(contextKey)
+	list, _ := permissions.([]string)
+	return list
+}
+
+// Has reports whether the caller was granted permission
+func Has(c *gin.Context, permission string) bool {
+	for _, p := range Permissions(c) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission aborts with 403 unless the caller has at least one of
+// the given permissions. It must run after the service's auth middleware.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, permission := range permissions {
+			if Has(c, permission) {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error": gin.H{
+				"code":    "FORBIDDEN",
+				"message": "This action requires the " + strings.Join(permissions, " or ") + " permission",
+			},
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	clientRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "service_client_requests_total",
+		Help: "Service-to-service requests sent, by target, method and outcome (2xx, 3xx, 4xx, 5xx, error).",
+	}, []string{"target", "method", "outcome"})
+
+	clientRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "service_client_request_duration_seconds",
+		Help:    "Service-to-service request latency, by target and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"target", "method"})
+
+	clientRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "service_client_retries_total",
+		Help: "Retries performed by PostWithRetry/PutWithRetry, by target and method.",
+	}, []string{"target", "method"})
+
+	clientFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "service_client_failures_total",
+		Help: "Calls that failed after all retries (or with a non-retryable error), by target and method.",
+	}, []string{"target", "method"})
+)
+
+// outcome buckets a response for the request counter
+func outcome(statusCode int, err error) string {
+	switch {
+	case err != nil:
+		return "error"
+	case statusCode >= 500:
+		return "5xx"
+	case statusCode >= 400:
+		return "4xx"
+	case statusCode >= 300:
+		return "3xx"
+	default:
+		return "2xx"
+	}
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -16,6 +17,11 @@ func NewNotificationServiceClient(baseURL, apiKey string) *NotificationServiceCl
 	}
 }
 
+// WithContext returns a copy of the client that forwards the trace context of ctx
+func (c *NotificationServiceClient) WithContext(ctx context.Context) *NotificationServiceClient {
+	return &NotificationServiceClient{ServiceClient: c.ServiceClient.WithContext(ctx)}
+}
+
 // SendNotificationRequest represents notification send request
 type SendNotificationRequest struct {
 	UserID     string                 `json:"user_id"`
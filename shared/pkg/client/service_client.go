@@ -2,36 +2,58 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
+
+	"github.com/bisosad1501/DATN/shared/pkg/tracing"
 )
 
 // ServiceClient is a reusable HTTP client for service-to-service communication
 type ServiceClient struct {
 	baseURL    string
+	target     string // host of baseURL, used as metrics label
 	apiKey     string
 	httpClient *http.Client
+	ctx        context.Context // trace context forwarded with every request
 }
 
 // NewServiceClient creates a new service client
 func NewServiceClient(baseURL, apiKey string) *ServiceClient {
+	target := baseURL
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		target = u.Host
+	}
+
 	return &ServiceClient{
 		baseURL: baseURL,
+		target:  target,
 		apiKey:  apiKey,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
-			Transport: &http.Transport{
+			Transport: tracing.Transport(&http.Transport{
 				MaxIdleConns:        100,
 				MaxIdleConnsPerHost: 10,
 				IdleConnTimeout:     90 * time.Second,
-			},
+			}),
 		},
+		ctx: context.Background(),
 	}
 }
 
+// WithContext returns a copy of the client whose requests continue the trace and
+// request ID of ctx and stop when ctx is cancelled. Background work started from a
+// request should pass a detached context (tracing.Detach or lifecycle.Go).
+func (c *ServiceClient) WithContext(ctx context.Context) *ServiceClient {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
 // Post sends a POST request
 func (c *ServiceClient) Post(endpoint string, payload interface{}) (*http.Response, error) {
 	return c.doRequest("POST", endpoint, payload)
@@ -63,7 +85,7 @@ func (c *ServiceClient) doRequest(method, endpoint string, payload interface{})
 		body = bytes.NewBuffer(jsonData)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+endpoint, body)
+	req, err := http.NewRequestWithContext(c.ctx, method, c.baseURL+endpoint, body)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
@@ -72,10 +94,14 @@ func (c *ServiceClient) doRequest(method, endpoint string, payload interface{})
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Internal-API-Key", c.apiKey)
 
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
+	clientRequestDuration.WithLabelValues(c.target, method).Observe(time.Since(start).Seconds())
 	if err != nil {
+		clientRequestsTotal.WithLabelValues(c.target, method, outcome(0, err)).Inc()
 		return nil, fmt.Errorf("do request: %w", err)
 	}
+	clientRequestsTotal.WithLabelValues(c.target, method, outcome(resp.StatusCode, nil)).Inc()
 
 	return resp, nil
 }
@@ -94,6 +120,7 @@ func (c *ServiceClient) PostWithRetry(endpoint string, payload interface{}, maxR
 			if resp.StatusCode < 500 {
 				// Client error, don't retry
 				body, _ := io.ReadAll(resp.Body)
+				clientFailuresTotal.WithLabelValues(c.target, "POST").Inc()
 				return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
 			}
 			lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
@@ -103,10 +130,14 @@ func (c *ServiceClient) PostWithRetry(endpoint string, payload interface{}, maxR
 
 		if i < maxRetries-1 {
 			// Exponential backoff
-			time.Sleep(time.Duration(i+1) * 100 * time.Millisecond)
+			if err := c.backoff(i); err != nil {
+				lastErr = err
+				break
+			}
 		}
 	}
 
+	clientFailuresTotal.WithLabelValues(c.target, "POST").Inc()
 	return fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
@@ -123,6 +154,7 @@ func (c *ServiceClient) PutWithRetry(endpoint string, payload interface{}, maxRe
 			}
 			if resp.StatusCode < 500 {
 				body, _ := io.ReadAll(resp.Body)
+				clientFailuresTotal.WithLabelValues(c.target, "PUT").Inc()
 				return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
 			}
 			lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
@@ -131,13 +163,27 @@ func (c *ServiceClient) PutWithRetry(endpoint string, payload interface{}, maxRe
 		}
 
 		if i < maxRetries-1 {
-			time.Sleep(time.Duration(i+1) * 100 * time.Millisecond)
+			if err := c.backoff(i); err != nil {
+				lastErr = err
+				break
+			}
 		}
 	}
 
+	clientFailuresTotal.WithLabelValues(c.target, "PUT").Inc()
 	return fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
+// backoff waits before retry attempt+1, returning early if the client's context is cancelled
+func (c *ServiceClient) backoff(attempt int) error {
+	select {
+	case <-time.After(time.Duration(attempt+1) * 100 * time.Millisecond):
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
 // DecodeResponse decodes JSON response into target struct
 func DecodeResponse(resp *http.Response, target interface{}) error {
 	defer resp.Body.Close()
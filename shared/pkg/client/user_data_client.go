@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// UserDataClient erases and exports the personal data one service keeps about a
+// user. Every service exposes the same pair of internal endpoints under its own
+// internal prefix (e.g. /api/v1/user/internal):
+//
+//	DELETE <prefix>/users/:id/data   - erase or anonymize the user's rows
+//	GET    <prefix>/users/:id/export - the user's rows as JSON, keyed by table
+type UserDataClient struct {
+	*ServiceClient
+	service string
+	prefix  string
+}
+
+// NewUserDataClient creates a client for the service named service whose
+// internal routes live under prefix
+func NewUserDataClient(service, baseURL, prefix, apiKey string) *UserDataClient {
+	return &UserDataClient{
+		ServiceClient: NewServiceClient(baseURL, apiKey),
+		service:       service,
+		prefix:        prefix,
+	}
+}
+
+// WithContext returns a copy of the client that forwards the trace context of ctx
+func (c *UserDataClient) WithContext(ctx context.Context) *UserDataClient {
+	return &UserDataClient{ServiceClient: c.ServiceClient.WithContext(ctx), service: c.service, prefix: c.prefix}
+}
+
+// Service returns the name of the service the client talks to
+func (c *UserDataClient) Service() string {
+	return c.service
+}
+
+// UserDataErasure reports what a service removed; counts are keyed by table
+type UserDataErasure struct {
+	Deleted    map[string]int64 `json:"deleted"`
+	Anonymized map[string]int64 `json:"anonymized,omitempty"`
+}
+
+// EraseUserData deletes or anonymizes the user's data. Erasure is idempotent,
+// so a saga step may safely call it again after a timeout.
+func (c *UserDataClient) EraseUserData(userID string) (*UserDataErasure, error) {
+	resp, err := c.Delete(fmt.Sprintf("%s/users/%s/data", c.prefix, userID))
+	if err != nil {
+		return nil, fmt.Errorf("erase %s data: %w", c.service, err)
+	}
+
+	var result struct {
+		Success bool            `json:"success"`
+		Data    UserDataErasure `json:"data"`
+	}
+	if err := DecodeResponse(resp, &result); err != nil {
+		return nil, fmt.Errorf("erase %s data: %w", c.service, err)
+	}
+
+	return &result.Data, nil
+}
+
+// ExportUserData returns the user's data as a JSON object keyed by table
+func (c *UserDataClient) ExportUserData(userID string) (json.RawMessage, error) {
+	resp, err := c.Get(fmt.Sprintf("%s/users/%s/export", c.prefix, userID))
+	if err != nil {
+		return nil, fmt.Errorf("export %s data: %w", c.service, err)
+	}
+
+	var result struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := DecodeResponse(resp, &result); err != nil {
+		return nil, fmt.Errorf("export %s data: %w", c.service, err)
+	}
+
+	return result.Data, nil
+}
@@ -1,8 +1,11 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 	"time"
 )
 
@@ -18,6 +21,11 @@ func NewUserServiceClient(baseURL, apiKey string) *UserServiceClient {
 	}
 }
 
+// WithContext returns a copy of the client that forwards the trace context of ctx
+func (c *UserServiceClient) WithContext(ctx context.Context) *UserServiceClient {
+	return &UserServiceClient{ServiceClient: c.ServiceClient.WithContext(ctx)}
+}
+
 // CreateProfileRequest represents profile creation request
 type CreateProfileRequest struct {
 	UserID          string  `json:"user_id"`
@@ -71,6 +79,86 @@ func (c *UserServiceClient) CreateProfile(req CreateProfileRequest) error {
 	return nil
 }
 
+// UpdateEmail copies an email change confirmed in Auth Service to the user's profile
+func (c *UserServiceClient) UpdateEmail(userID, email string) error {
+	endpoint := "/api/v1/user/internal/profile/email/update"
+
+	payload := map[string]interface{}{
+		"user_id": userID,
+		"email":   email,
+	}
+
+	err := c.PutWithRetry(endpoint, payload, 3)
+	if err != nil {
+		return fmt.Errorf("update email: %w", err)
+	}
+
+	return nil
+}
+
+// GetLocale returns the interface language the user picked (vi or en)
+func (c *UserServiceClient) GetLocale(userID string) (string, error) {
+	endpoint := fmt.Sprintf("/api/v1/user/internal/users/%s/preferences", userID)
+
+	resp, err := c.Get(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("get preferences: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("get preferences failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Locale string `json:"locale"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+
+	return result.Data.Locale, nil
+}
+
+// GetFullName returns the name on the user's profile, or "" when none is set
+func (c *UserServiceClient) GetFullName(userID string) (string, error) {
+	endpoint := fmt.Sprintf("/api/v1/user/internal/users/%s/profile", userID)
+
+	resp, err := c.Get(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("get profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("get profile failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+		Data    struct {
+			FirstName string `json:"first_name"`
+			LastName  string `json:"last_name"`
+			FullName  string `json:"full_name"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("decode response: %w", err)
+	}
+
+	if result.Data.FullName != "" {
+		return result.Data.FullName, nil
+	}
+	return strings.TrimSpace(result.Data.FirstName + " " + result.Data.LastName), nil
+}
+
 // UpdateProgress updates user learning progress
 func (c *UserServiceClient) UpdateProgress(req UpdateProgressRequest) error {
 	endpoint := "/api/v1/user/internal/progress/update"
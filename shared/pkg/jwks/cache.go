@@ -0,0 +1,168 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// cacheTTL is how long a fetched key set is used before it is refetched
+	cacheTTL = 10 * time.Minute
+	// minRefreshInterval throttles refetches, e.g. for tokens with an unknown kid
+	minRefreshInterval = 30 * time.Second
+	fetchTimeout       = 5 * time.Second
+)
+
+// Cache verifies tokens against a remote key set. Keys are fetched lazily,
+// refreshed every cacheTTL and refetched early when a token names a kid the
+// cache has not seen, which is how a newly rotated key is picked up. If
+// auth-service is unreachable the last fetched keys stay in use.
+type Cache struct {
+	url    string
+	client *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+
+	refreshMu   sync.Mutex
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// NewCache creates a cache for the key set served at url
+func NewCache(url string) *Cache {
+	return &Cache{
+		url:    url,
+		client: &http.Client{Timeout: fetchTimeout},
+		keys:   map[string]crypto.PublicKey{},
+	}
+}
+
+// KeyFromHeader returns the public key for a token header (its "kid" and
+// "alg"). It fits jwt.Keyfunc: func(t *jwt.Token) { return c.KeyFromHeader(t.Header) }
+func (c *Cache) KeyFromHeader(header map[string]interface{}) (crypto.PublicKey, error) {
+	kid, _ := header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no kid header")
+	}
+
+	key, err := c.Key(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	// The key type decides the algorithm; never let the token choose
+	alg, err := Algorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	if header["alg"] != alg {
+		return nil, fmt.Errorf("unexpected signing method: %v", header["alg"])
+	}
+	return key, nil
+}
+
+// Key returns the public key with the given kid
+func (c *Cache) Key(kid string) (crypto.PublicKey, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	fresh := time.Since(c.fetchedAt) < cacheTTL
+	c.mu.RUnlock()
+	if ok && fresh {
+		return key, nil
+	}
+
+	err := c.refresh(context.Background())
+
+	c.mu.RLock()
+	key, ok = c.keys[kid]
+	c.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	return nil, ErrUnknownKey
+}
+
+// Refresh fetches the key set now, e.g. to warm the cache at startup
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	return c.fetch(ctx)
+}
+
+// refresh fetches the key set unless another attempt happened recently
+func (c *Cache) refresh(ctx context.Context) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if time.Since(c.lastAttempt) < minRefreshInterval {
+		return c.lastErr
+	}
+	return c.fetch(ctx)
+}
+
+// fetch must be called with refreshMu held
+func (c *Cache) fetch(ctx context.Context) error {
+	c.lastAttempt = time.Now()
+	c.lastErr = c.load(ctx)
+	if c.lastErr != nil {
+		log.Printf("[JWKS] Failed to fetch %s: %v", c.url, c.lastErr)
+	}
+	return c.lastErr
+}
+
+func (c *Cache) load(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var set Set
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("invalid key set: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.PublicKey()
+		if err != nil {
+			log.Printf("[JWKS] Skipping key: %v", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("key set has no usable keys")
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
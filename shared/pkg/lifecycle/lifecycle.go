@@ -0,0 +1,189 @@
+// Package lifecycle runs a service's HTTP server with graceful shutdown and
+// tracks the background work it starts, so SIGTERM drains instead of cutting off.
+//
+// Shutdown sequence:
+//  1. readiness (/ready) flips to 503 so load balancers stop sending traffic
+//  2. after SHUTDOWN_READINESS_DELAY the listener closes and in-flight requests
+//     and OnShutdown hooks (e.g. closing SSE streams) get SHUTDOWN_TIMEOUT to finish
+//  3. background work started with Go has the rest of that window; when it
+//     expires their context is cancelled so they can persist what is pending
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// workerGrace is how long cancelled background work may take to persist its state
+const workerGrace = 2 * time.Second
+
+// Lifecycle owns the server, readiness state and background goroutines of one service
+type Lifecycle struct {
+	service        string
+	timeout        time.Duration
+	readinessDelay time.Duration
+
+	ready  atomic.Bool
+	ctx    context.Context // cancelled when the drain window expires
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	hooks []func()
+}
+
+// New reads SHUTDOWN_TIMEOUT (default 15s) and SHUTDOWN_READINESS_DELAY (default 2s)
+func New(service string) *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{
+		service:        service,
+		timeout:        durationFromEnv("SHUTDOWN_TIMEOUT", 15*time.Second),
+		readinessDelay: durationFromEnv("SHUTDOWN_READINESS_DELAY", 2*time.Second),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// Context is cancelled when the drain window expires. Long-running workers
+// (tickers, loops) should stop when it is done.
+func (l *Lifecycle) Context() context.Context {
+	return l.ctx
+}
+
+// Go runs fn as tracked background work. Its context keeps the values of parent
+// (trace, request ID) but not its cancellation, so work started by a request
+// survives the response; it is cancelled only when the shutdown drain window expires.
+// Panics are recovered and logged.
+func (l *Lifecycle) Go(parent context.Context, name string, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+	stop := context.AfterFunc(l.ctx, cancel)
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer cancel()
+		defer stop()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[%s] PANIC in %s: %v", l.service, name, r)
+			}
+		}()
+		fn(ctx)
+	}()
+}
+
+// OnShutdown registers fn to run when the server starts draining,
+// e.g. to close long-lived streams that would otherwise hold the drain open
+func (l *Lifecycle) OnShutdown(fn func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, fn)
+}
+
+// Ready reports whether the service accepts new traffic
+func (l *Lifecycle) Ready() bool {
+	return l.ready.Load()
+}
+
+// ReadyHandler answers readiness probes: 200 while serving, 503 once shutdown began
+func (l *Lifecycle) ReadyHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.Ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down", "service": l.service})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "service": l.service})
+	}
+}
+
+// Run serves handler on addr until SIGINT/SIGTERM, then shuts down gracefully.
+// It returns an error only if the server could not start.
+func (l *Lifecycle) Run(addr string, handler http.Handler) error {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	srv.RegisterOnShutdown(func() {
+		l.mu.Lock()
+		hooks := append([]func(){}, l.hooks...)
+		l.mu.Unlock()
+		for _, hook := range hooks {
+			hook()
+		}
+	})
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	serveErr := make(chan error, 1)
+	l.ready.Store(true)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		l.ready.Store(false)
+		l.cancel()
+		return err
+	case sig := <-quit:
+		log.Printf("🛑 [%s] Received %s, shutting down", l.service, sig)
+	}
+
+	l.shutdown(srv)
+	return nil
+}
+
+func (l *Lifecycle) shutdown(srv *http.Server) {
+	l.ready.Store(false)
+	if l.readinessDelay > 0 {
+		log.Printf("[%s] Not ready, waiting %s for load balancers to notice", l.service, l.readinessDelay)
+		time.Sleep(l.readinessDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	log.Printf("[%s] Draining in-flight requests (up to %s)", l.service, l.timeout)
+	if err := srv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("⚠️  [%s] Drain window expired, closing remaining connections: %v", l.service, err)
+		srv.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("⚠️  [%s] Background work still running, cancelling", l.service)
+		l.cancel()
+		select {
+		case <-done:
+		case <-time.After(workerGrace):
+			log.Printf("⚠️  [%s] Background work did not stop within %s", l.service, workerGrace)
+		}
+	}
+
+	l.cancel()
+	log.Printf("✅ [%s] Shutdown complete", l.service)
+}
+
+func durationFromEnv(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
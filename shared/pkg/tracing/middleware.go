@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware continues the trace from the incoming traceparent header (or starts a new one),
+// opens a server span per request and makes the request ID available to handlers and logs.
+// The request ID comes from X-Request-ID, or defaults to the trace ID.
+func Middleware(serviceName string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("service.name", serviceName),
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+
+		requestID := c.GetHeader(HeaderRequestID)
+		if requestID == "" {
+			requestID = span.SpanContext().TraceID().String()
+		}
+		span.SetAttributes(attribute.String("request.id", requestID))
+
+		ctx = ContextWithRequestID(ctx, requestID)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(HeaderRequestID, requestID)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
+
+// Logger writes one access log line per request including the trace and request IDs.
+// Use it instead of gin.Logger, after Middleware.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		ctx := c.Request.Context()
+		log.Printf("[GIN] %3d | %13v | %15s | %-7s %s | trace_id=%s request_id=%s",
+			c.Writer.Status(), time.Since(start), c.ClientIP(),
+			c.Request.Method, c.Request.URL.Path, TraceID(ctx), RequestID(ctx))
+	}
+}
+
+// Logf is log.Printf prefixed with the trace and request IDs of ctx
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	log.Printf("[trace_id=%s request_id=%s] "+format,
+		append([]interface{}{TraceID(ctx), RequestID(ctx)}, args...)...)
+}
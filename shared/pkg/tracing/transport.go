@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Transport wraps base so every outgoing request gets a client span and carries
+// the traceparent and X-Request-ID headers of its context
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return &requestIDTransport{base: otelhttp.NewTransport(base)}
+}
+
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if requestID := RequestID(req.Context()); requestID != "" && req.Header.Get(HeaderRequestID) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(HeaderRequestID, requestID)
+	}
+	return t.base.RoundTrip(req)
+}